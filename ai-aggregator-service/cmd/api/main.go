@@ -1,9 +1,22 @@
 package main
 
 import (
+	"ai-aggregator-service/internal/accounts"
+	"ai-aggregator-service/internal/aliases"
+	"ai-aggregator-service/internal/apikeys"
+	"ai-aggregator-service/internal/auth"
+	"ai-aggregator-service/internal/catalog"
 	"ai-aggregator-service/internal/config"
+	"ai-aggregator-service/internal/credentials"
+	"ai-aggregator-service/internal/database"
 	"ai-aggregator-service/internal/handlers"
 	"ai-aggregator-service/internal/logger"
+	"ai-aggregator-service/internal/pricing"
+	"ai-aggregator-service/internal/providers"
+	"ai-aggregator-service/internal/registry"
+	"ai-aggregator-service/internal/routing"
+	"ai-aggregator-service/internal/sessions"
+	"ai-aggregator-service/internal/usage"
 	"context"
 	"fmt"
 	"log/slog"
@@ -33,12 +46,38 @@ func main() {
 
 	// Initialize logger
 	logger.Init(cfg.Logging)
-	slog.Info("Starting API Gateway", "config", cfg)
+	// The configuration holds secrets, such as provider keys, so only where it points is logged
+	slog.Info("Starting API Gateway",
+		"address", fmt.Sprintf("%s:%d", cfg.Server.Host, cfg.Server.Port),
+		"database", fmt.Sprintf("%s:%d/%s", cfg.Database.Host, cfg.Database.Port, cfg.Database.DBName),
+		"log_level", cfg.Logging.Level,
+	)
+
+	// Refuse to start with a JWT secret anyone could sign tokens with
+	verifier, err := auth.NewVerifier(cfg.Auth)
+	if err != nil {
+		slog.Error("Failed to configure authentication", "error", err)
+		os.Exit(1)
+	}
+	issuer, err := auth.NewIssuer(cfg.Auth)
+	if err != nil {
+		slog.Error("Failed to configure authentication", "error", err)
+		os.Exit(1)
+	}
+
+	// Connect to database
+	db, err := database.Connect(context.Background(), cfg.Database)
+	if err != nil {
+		slog.Error("Failed to connect to database", "error", err)
+		os.Exit(1)
+	}
+	defer db.Close()
 
 	// Create Echo instance
 	e := echo.New()
 	e.HideBanner = true
 	e.HidePort = true
+	e.HTTPErrorHandler = handlers.ErrorHandler
 
 	// Middleware
 	e.Use(middleware.Logger())
@@ -47,7 +86,55 @@ func main() {
 	e.Use(middleware.RequestID())
 	e.Use(middleware.Gzip())
 
-	handlers.SetupRoutes(e)
+	router := providers.NewRouter()
+	router.SetFallbacks(cfg.Routing.FallbackChains())
+	router.SetRetryPolicy(providers.RetryPolicy{
+		MaxRetries:     cfg.Routing.MaxRetries,
+		InitialBackoff: cfg.Routing.InitialBackoff,
+		MaxBackoff:     cfg.Routing.MaxBackoff,
+	})
+	modelAliases := aliases.NewStore(db)
+	router.SetAliases(modelAliases)
+
+	// Choose among the providers serving a model by price, latency or success rate
+	routingPolicy, err := providers.ParseRoutingPolicy(cfg.Routing.Policy)
+	if err != nil {
+		slog.Error("Invalid routing policy", "error", err)
+		os.Exit(1)
+	}
+	router.SetRouting(routing.NewStats(db, cfg.Routing.StatsWindow), providers.Routing{Policy: routingPolicy})
+
+	// Organizations' own provider keys, encrypted under the configured master key
+	var cipher *credentials.Cipher
+	if cfg.BYOK.MasterKey != "" {
+		cipher, err = credentials.NewCipher(cfg.BYOK.MasterKey)
+		if err != nil {
+			slog.Error("Invalid BYOK master key", "error", err)
+			os.Exit(1)
+		}
+	}
+	providerCredentials := credentials.NewStore(db, cipher)
+
+	// Build providers from the providers table and keep them in sync with it
+	providerRegistry := registry.NewRegistry(db, router, cfg.Providers)
+	providerRegistry.SetCredentials(providerCredentials)
+	if err := providerRegistry.Sync(context.Background()); err != nil {
+		slog.Warn("Failed to load providers table, providers will use their configured settings", "error", err)
+	}
+	watchCtx, stopWatch := context.WithCancel(context.Background())
+	defer stopWatch()
+	go providerRegistry.Watch(watchCtx, cfg.Providers.RefreshInterval)
+
+	// Reconcile the model catalog with provider model lists when scheduled
+	modelCatalog := catalog.NewCatalog(db)
+	if cfg.Catalog.SyncInterval > 0 {
+		go modelCatalog.Watch(watchCtx, router, cfg.Catalog.SyncInterval)
+	}
+
+	pricer := pricing.NewPricer(db)
+	userAccounts := accounts.NewStore(db, cfg.Auth.MaxLoginAttempts, cfg.Auth.LockoutDuration)
+	userSessions := sessions.NewManager(sessions.NewDBStore(db), cfg.Auth.RefreshExpiration)
+	handlers.SetupRoutes(e, handlers.NewHandler(router, usage.NewRecorder(db, pricer, cfg.BYOK.PlatformFee), modelCatalog, pricer, modelAliases, providerCredentials, apikeys.NewStore(db), userAccounts, issuer, verifier, userSessions), verifier)
 
 	address := fmt.Sprintf("%s:%d", cfg.Server.Host, cfg.Server.Port)
 	// Start server
@@ -0,0 +1,246 @@
+package accounts
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"ai-aggregator-service/internal/auth"
+	"ai-aggregator-service/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/driver/pgdriver"
+)
+
+var (
+	// ErrEmailTaken is returned when registering an email that already has an account
+	ErrEmailTaken = errors.New("email is already registered")
+
+	// ErrInvalidCredentials is returned when the email or the password is wrong; which
+	// of the two is not revealed
+	ErrInvalidCredentials = errors.New("invalid email or password")
+
+	// ErrAccountLocked is returned while an account is locked after failed sign-ins
+	ErrAccountLocked = errors.New("account is temporarily locked")
+
+	// ErrAccountDisabled is returned when a deactivated user signs in
+	ErrAccountDisabled = errors.New("account is disabled")
+
+	// ErrUserNotFound is returned when a user does not exist
+	ErrUserNotFound = errors.New("user not found")
+)
+
+// NewUser describes an account to register
+type NewUser struct {
+	Email    string
+	Password string
+	Name     string
+	Username string
+}
+
+// Store registers users and verifies their passwords, kept in the users table as
+// argon2id hashes. Repeated failed sign-ins lock an account for a while.
+type Store struct {
+	db          *bun.DB
+	maxAttempts int
+	lockout     time.Duration
+}
+
+// NewStore creates a new account store. maxAttempts failed sign-ins in a row lock an
+// account for lockout; zero disables locking.
+func NewStore(db *bun.DB, maxAttempts int, lockout time.Duration) *Store {
+	return &Store{
+		db:          db,
+		maxAttempts: maxAttempts,
+		lockout:     lockout,
+	}
+}
+
+// Register creates a user together with a default organization of their own
+func (s *Store) Register(ctx context.Context, newUser NewUser) (*models.User, error) {
+	email := normalizeEmail(newUser.Email)
+	hash, err := auth.HashPassword(newUser.Password)
+	if err != nil {
+		return nil, err
+	}
+	slug, err := organizationSlug(email)
+	if err != nil {
+		return nil, err
+	}
+
+	user := &models.User{
+		Email:        email,
+		FullName:     newUser.Name,
+		PasswordHash: hash,
+	}
+	if newUser.Username != "" {
+		user.Metadata = models.JSONB{"username": newUser.Username}
+	}
+
+	err = s.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		exists, err := tx.NewSelect().
+			Model((*models.User)(nil)).
+			Where("LOWER(email) = ?", email).
+			Exists(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to check email: %w", err)
+		}
+		if exists {
+			return ErrEmailTaken
+		}
+
+		organization := &models.Organization{
+			Name:         fmt.Sprintf("%s's organization", displayName(newUser.Name, email)),
+			Slug:         slug,
+			BillingEmail: email,
+		}
+		if _, err := tx.NewInsert().Model(organization).Returning("id").Exec(ctx); err != nil {
+			return fmt.Errorf("failed to create organization: %w", err)
+		}
+
+		user.OrganizationID = organization.ID
+		if _, err := tx.NewInsert().Model(user).Returning("id, role, is_active").Exec(ctx); err != nil {
+			// Lost a race with another registration of the same email
+			var pgErr pgdriver.Error
+			if errors.As(err, &pgErr) && pgErr.IntegrityViolation() {
+				return ErrEmailTaken
+			}
+			return fmt.Errorf("failed to create user: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// Login verifies the user's password and records the sign-in. Failed attempts count
+// towards locking the account. Locked and disabled accounts are rejected without checking
+// the password, so that the response never tells whether it was right.
+func (s *Store) Login(ctx context.Context, email, password string) (*models.User, error) {
+	user := new(models.User)
+	err := s.db.NewSelect().
+		Model(user).
+		Where("LOWER(email) = ?", normalizeEmail(email)).
+		Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		auth.CheckPassword(password, "")
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load user: %w", err)
+	}
+
+	now := time.Now()
+	if !user.IsActive {
+		return nil, ErrAccountDisabled
+	}
+	if user.LockedUntil != nil && now.Before(*user.LockedUntil) {
+		return nil, fmt.Errorf("%w until %s", ErrAccountLocked, user.LockedUntil.UTC().Format(time.RFC3339))
+	}
+
+	if !auth.CheckPassword(password, user.PasswordHash) {
+		if err := s.recordFailure(ctx, user.ID, now); err != nil {
+			return nil, err
+		}
+		return nil, ErrInvalidCredentials
+	}
+
+	user.LastLoginAt = &now
+	user.FailedLoginAttempts = 0
+	user.LockedUntil = nil
+	_, err = s.db.NewUpdate().
+		Model(user).
+		Column("last_login_at", "failed_login_attempts", "locked_until", "updated_at").
+		WherePK().
+		Exec(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record sign-in: %w", err)
+	}
+	return user, nil
+}
+
+// Get returns a user
+func (s *Store) Get(ctx context.Context, id uuid.UUID) (*models.User, error) {
+	user := new(models.User)
+	err := s.db.NewSelect().
+		Model(user).
+		Where("id = ?", id).
+		Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %s", ErrUserNotFound, id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load user: %w", err)
+	}
+	return user, nil
+}
+
+// recordFailure counts a failed sign-in, locking the account and starting the count
+// over once it reaches maxAttempts
+func (s *Store) recordFailure(ctx context.Context, id uuid.UUID, now time.Time) error {
+	if s.maxAttempts <= 0 {
+		return nil
+	}
+
+	_, err := s.db.NewUpdate().
+		Model((*models.User)(nil)).
+		Set("failed_login_attempts = CASE WHEN failed_login_attempts + 1 >= ? THEN 0 ELSE failed_login_attempts + 1 END", s.maxAttempts).
+		Set("locked_until = CASE WHEN failed_login_attempts + 1 >= ? THEN ? ELSE locked_until END", s.maxAttempts, now.Add(s.lockout)).
+		Set("updated_at = ?", now).
+		Where("id = ?", id).
+		Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to record failed sign-in: %w", err)
+	}
+	return nil
+}
+
+// normalizeEmail returns the form emails are stored and compared in
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// displayName returns the user's name, or the local part of their email without one
+func displayName(name, email string) string {
+	if name = strings.TrimSpace(name); name != "" {
+		return name
+	}
+	local, _, _ := strings.Cut(email, "@")
+	return local
+}
+
+// organizationSlug returns a unique slug for a user's default organization, made of the
+// local part of their email and a random suffix
+func organizationSlug(email string) (string, error) {
+	local, _, _ := strings.Cut(email, "@")
+	var b strings.Builder
+	for _, r := range local {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case b.Len() > 0 && !strings.HasSuffix(b.String(), "-"):
+			b.WriteByte('-')
+		}
+		if b.Len() >= 40 {
+			break
+		}
+	}
+	base := strings.Trim(b.String(), "-")
+	if base == "" {
+		base = "org"
+	}
+
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", fmt.Errorf("failed to generate organization slug: %w", err)
+	}
+	return base + "-" + hex.EncodeToString(suffix), nil
+}
@@ -0,0 +1,271 @@
+package aliases
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"ai-aggregator-service/internal/models"
+	"ai-aggregator-service/internal/providers"
+
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+// refreshInterval controls how long loaded aliases are trusted before the table is read again
+const refreshInterval = 30 * time.Second
+
+var (
+	// ErrAliasNotFound is returned when an alias to delete does not exist
+	ErrAliasNotFound = errors.New("alias not found")
+
+	// ErrInvalidAlias is returned when an alias cannot be saved as given
+	ErrInvalidAlias = errors.New("invalid alias")
+)
+
+// target is the model an alias stands for
+type target struct {
+	model    string
+	provider string
+}
+
+// aliasKey identifies an alias; global aliases have no organization
+type aliasKey struct {
+	organizationID uuid.UUID
+	alias          string
+}
+
+// Store resolves model aliases from the model_aliases table. Active aliases are kept in
+// memory and reloaded periodically, so that resolving does not add a query per request.
+type Store struct {
+	db *bun.DB
+
+	mu       sync.RWMutex
+	aliases  map[aliasKey]target
+	loadedAt time.Time
+
+	refreshMu sync.Mutex
+}
+
+// NewStore creates a new alias store
+func NewStore(db *bun.DB) *Store {
+	return &Store{
+		db:      db,
+		aliases: make(map[aliasKey]target),
+	}
+}
+
+// ResolveAlias returns the model an alias stands for, preferring the alias of the
+// organization attached to ctx with providers.WithOrganization over the global one. It implements providers.AliasResolver.
+func (s *Store) ResolveAlias(ctx context.Context, model string) (string, string, bool) {
+	s.refresh(ctx)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if organizationID := providers.OrganizationFrom(ctx); organizationID != uuid.Nil {
+		if t, ok := s.aliases[aliasKey{organizationID: organizationID, alias: model}]; ok {
+			return t.model, t.provider, true
+		}
+	}
+	t, ok := s.aliases[aliasKey{alias: model}]
+	return t.model, t.provider, ok
+}
+
+// refresh reloads the aliases when they are stale. When the table cannot be read the
+// previously loaded aliases keep being served until the next attempt.
+func (s *Store) refresh(ctx context.Context) {
+	s.mu.RLock()
+	fresh := time.Since(s.loadedAt) < refreshInterval
+	s.mu.RUnlock()
+	if fresh {
+		return
+	}
+
+	s.refreshMu.Lock()
+	defer s.refreshMu.Unlock()
+
+	s.mu.RLock()
+	fresh = time.Since(s.loadedAt) < refreshInterval
+	s.mu.RUnlock()
+	if fresh {
+		return
+	}
+
+	aliases, err := s.load(ctx)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.loadedAt = time.Now()
+	if err != nil {
+		slog.Warn("Failed to load model aliases", "error", err)
+		return
+	}
+	s.aliases = aliases
+}
+
+// load reads the active aliases
+func (s *Store) load(ctx context.Context) (map[aliasKey]target, error) {
+	var rows []models.ModelAlias
+	err := s.db.NewSelect().
+		Model(&rows).
+		Column("organization_id", "alias", "model", "provider").
+		Where("is_active").
+		Scan(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load model aliases: %w", err)
+	}
+
+	aliases := make(map[aliasKey]target, len(rows))
+	for _, row := range rows {
+		key := aliasKey{alias: row.Alias}
+		if row.OrganizationID != nil {
+			key.organizationID = *row.OrganizationID
+		}
+		t := target{model: row.Model}
+		if row.Provider != nil {
+			t.provider = *row.Provider
+		}
+		aliases[key] = t
+	}
+	return aliases, nil
+}
+
+// invalidate makes the next resolve reload the aliases
+func (s *Store) invalidate() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.loadedAt = time.Time{}
+}
+
+// List returns the aliases of the organization, or the global aliases for uuid.Nil
+func (s *Store) List(ctx context.Context, organizationID uuid.UUID) ([]models.ModelAlias, error) {
+	var rows []models.ModelAlias
+	query := s.db.NewSelect().
+		Model(&rows).
+		OrderExpr("alias")
+	query = whereOrganization(query, organizationID)
+
+	if err := query.Scan(ctx); err != nil {
+		return nil, fmt.Errorf("failed to list model aliases: %w", err)
+	}
+	return rows, nil
+}
+
+// Set creates or replaces an alias of the organization, or a global alias for uuid.Nil.
+// Aliases are resolved once, so an alias cannot stand for another alias, nor be named
+// after the model of another alias.
+func (s *Store) Set(ctx context.Context, alias *models.ModelAlias) error {
+	alias.Alias = strings.TrimSpace(alias.Alias)
+	alias.Model = strings.TrimSpace(alias.Model)
+	if alias.Alias == "" || alias.Model == "" {
+		return fmt.Errorf("%w: alias and model are required", ErrInvalidAlias)
+	}
+	if alias.Alias == alias.Model {
+		return fmt.Errorf("%w: an alias cannot stand for itself", ErrInvalidAlias)
+	}
+	organizationID := uuid.Nil
+	if alias.OrganizationID != nil {
+		organizationID = *alias.OrganizationID
+	}
+
+	err := s.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		// The target must not be an alias the requests resolving this one would see
+		chained, err := visibleWith(tx.NewSelect().Model((*models.ModelAlias)(nil)), organizationID).
+			Where("alias = ?", alias.Model).
+			Exists(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to check model aliases: %w", err)
+		}
+		if chained {
+			return fmt.Errorf("%w: %s is itself an alias", ErrInvalidAlias, alias.Model)
+		}
+
+		// Nor may the alias be the target of one those requests would see
+		targeted, err := visibleWith(tx.NewSelect().Model((*models.ModelAlias)(nil)), organizationID).
+			Where("model = ?", alias.Alias).
+			Exists(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to check model aliases: %w", err)
+		}
+		if targeted {
+			return fmt.Errorf("%w: %s is the model of another alias", ErrInvalidAlias, alias.Alias)
+		}
+
+		existing := new(models.ModelAlias)
+		err = whereOrganization(tx.NewSelect().Model(existing), organizationID).
+			Where("alias = ?", alias.Alias).
+			For("UPDATE").
+			Scan(ctx)
+		switch {
+		case err == nil:
+			alias.ID = existing.ID
+			alias.CreatedAt = existing.CreatedAt
+			_, err = tx.NewUpdate().
+				Model(alias).
+				Column("model", "provider", "is_active", "updated_at").
+				WherePK().
+				Exec(ctx)
+		case errors.Is(err, sql.ErrNoRows):
+			_, err = tx.NewInsert().Model(alias).Exec(ctx)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to save model alias: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	s.invalidate()
+	return nil
+}
+
+// Delete removes an alias of the organization, or a global alias for uuid.Nil
+func (s *Store) Delete(ctx context.Context, organizationID uuid.UUID, alias string) error {
+	query := s.db.NewDelete().
+		Model((*models.ModelAlias)(nil)).
+		Where("alias = ?", alias)
+	if organizationID == uuid.Nil {
+		query.Where("organization_id IS NULL")
+	} else {
+		query.Where("organization_id = ?", organizationID)
+	}
+
+	result, err := query.Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to delete model alias: %w", err)
+	}
+	if deleted, err := result.RowsAffected(); err == nil && deleted == 0 {
+		return fmt.Errorf("%w: %s", ErrAliasNotFound, alias)
+	}
+
+	s.invalidate()
+	return nil
+}
+
+// whereOrganization restricts a query to the aliases of the organization, or to the
+// global aliases for uuid.Nil
+func whereOrganization(query *bun.SelectQuery, organizationID uuid.UUID) *bun.SelectQuery {
+	if organizationID == uuid.Nil {
+		return query.Where("organization_id IS NULL")
+	}
+	return query.Where("organization_id = ?", organizationID)
+}
+
+// visibleWith restricts a query to the aliases resolved alongside an alias of the
+// organization: the global aliases and the organization's own. Global aliases are
+// resolved alongside those of every organization.
+func visibleWith(query *bun.SelectQuery, organizationID uuid.UUID) *bun.SelectQuery {
+	if organizationID == uuid.Nil {
+		return query
+	}
+	return query.Where("organization_id IS NULL OR organization_id = ?", organizationID)
+}
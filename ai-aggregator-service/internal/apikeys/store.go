@@ -0,0 +1,325 @@
+package apikeys
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"ai-aggregator-service/internal/auth"
+	"ai-aggregator-service/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+const (
+	// Prefix starts every gateway API key, so that leaked keys are easy to recognise
+	Prefix = "bai-live-"
+
+	// lookupLength is the number of characters after Prefix stored in key_prefix to
+	// find a key by, and secretLength the number of characters that follow them
+	lookupLength = 12
+	secretLength = 32
+
+	// hashScheme tags key hashes: sha256 over a random salt followed by the key
+	hashScheme = "sha256"
+
+	// lastUsedInterval limits how often last_used_at is written for a busy key
+	lastUsedInterval = time.Minute
+)
+
+// alphabet holds the characters keys are made of
+const alphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+var (
+	// ErrKeyNotFound is returned when a key does not exist or belongs to someone else
+	ErrKeyNotFound = errors.New("API key not found")
+
+	// ErrInvalidKey is returned when a presented key is malformed, unknown or wrong
+	ErrInvalidKey = errors.New("invalid API key")
+
+	// ErrKeyInactive is returned when a presented key was revoked or deactivated
+	ErrKeyInactive = errors.New("API key is inactive")
+
+	// ErrKeyExpired is returned when a presented key is past its expiry
+	ErrKeyExpired = errors.New("API key has expired")
+)
+
+// NewKey describes a key to create. Keys belong to the user who created them and, when
+// the user acted for an organization, to that organization too.
+type NewKey struct {
+	Name           string
+	UserID         uuid.UUID
+	OrganizationID uuid.UUID
+	Permissions    []string
+	ExpiresAt      *time.Time
+}
+
+// Update holds the changes to make to a key; nil fields are left as they are
+type Update struct {
+	Name        *string
+	IsActive    *bool
+	Permissions []string
+}
+
+// Store issues and verifies gateway API keys, kept in the api_keys table. Only a salted
+// hash of each key is stored, together with its public prefix to find it by.
+type Store struct {
+	db *bun.DB
+
+	mu   sync.Mutex
+	used map[uuid.UUID]time.Time // key ID -> when last_used_at was last written
+}
+
+// NewStore creates a new API key store
+func NewStore(db *bun.DB) *Store {
+	return &Store{
+		db:   db,
+		used: make(map[uuid.UUID]time.Time),
+	}
+}
+
+// Create generates a key and stores its hash. The key itself is returned only here.
+func (s *Store) Create(ctx context.Context, key NewKey) (*models.APIKey, string, error) {
+	lookup, err := randomString(lookupLength)
+	if err != nil {
+		return nil, "", err
+	}
+	secret, err := randomString(secretLength)
+	if err != nil {
+		return nil, "", err
+	}
+	plaintext := Prefix + lookup + secret
+
+	hash, err := hashKey(plaintext)
+	if err != nil {
+		return nil, "", err
+	}
+
+	row := &models.APIKey{
+		KeyHash:     hash,
+		KeyPrefix:   Prefix + lookup,
+		Name:        key.Name,
+		Permissions: key.Permissions,
+		IsActive:    true,
+		ExpiresAt:   key.ExpiresAt,
+	}
+	if row.Permissions == nil {
+		row.Permissions = []string{}
+	}
+	if key.UserID != uuid.Nil {
+		row.UserID = models.UUIDPtr(key.UserID)
+	}
+	if key.OrganizationID != uuid.Nil {
+		row.OrganizationID = models.UUIDPtr(key.OrganizationID)
+	}
+
+	if _, err := s.db.NewInsert().Model(row).Returning("id").Exec(ctx); err != nil {
+		return nil, "", fmt.Errorf("failed to insert API key: %w", err)
+	}
+	return row, plaintext, nil
+}
+
+// List returns the keys the user created, newest first
+func (s *Store) List(ctx context.Context, userID uuid.UUID, limit, offset int) ([]models.APIKey, int, error) {
+	var rows []models.APIKey
+	total, err := s.db.NewSelect().
+		Model(&rows).
+		Where("user_id = ?", userID).
+		OrderExpr("created_at DESC").
+		Limit(limit).
+		Offset(offset).
+		ScanAndCount(ctx)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list API keys: %w", err)
+	}
+	return rows, total, nil
+}
+
+// Get returns one of the user's keys
+func (s *Store) Get(ctx context.Context, userID, id uuid.UUID) (*models.APIKey, error) {
+	row := new(models.APIKey)
+	err := s.db.NewSelect().
+		Model(row).
+		Where("id = ?", id).
+		Where("user_id = ?", userID).
+		Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %s", ErrKeyNotFound, id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load API key: %w", err)
+	}
+	return row, nil
+}
+
+// Update changes the name, activity or permissions of one of the user's keys
+func (s *Store) Update(ctx context.Context, userID, id uuid.UUID, update Update) (*models.APIKey, error) {
+	row, err := s.Get(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	columns := []string{"updated_at"}
+	if update.Name != nil {
+		row.Name = *update.Name
+		columns = append(columns, "name")
+	}
+	if update.IsActive != nil {
+		row.IsActive = *update.IsActive
+		columns = append(columns, "is_active")
+	}
+	if update.Permissions != nil {
+		row.Permissions = update.Permissions
+		columns = append(columns, "permissions")
+	}
+
+	if _, err := s.db.NewUpdate().Model(row).Column(columns...).WherePK().Exec(ctx); err != nil {
+		return nil, fmt.Errorf("failed to update API key: %w", err)
+	}
+	return row, nil
+}
+
+// Revoke deactivates one of the user's keys; revoked keys are kept for the request log
+func (s *Store) Revoke(ctx context.Context, userID, id uuid.UUID) error {
+	inactive := false
+	_, err := s.Update(ctx, userID, id, Update{IsActive: &inactive})
+	return err
+}
+
+// Authenticate verifies a presented key and returns the identity it acts as: its owning
+// user and organization, with its permissions as scopes. Keys of deactivated users are
+// rejected, and a key acts as an admin only when granted the admin scope by an admin.
+// The key's last_used_at is updated in the background.
+func (s *Store) Authenticate(ctx context.Context, key string) (*auth.Claims, error) {
+	if !strings.HasPrefix(key, Prefix) || len(key) != len(Prefix)+lookupLength+secretLength {
+		return nil, ErrInvalidKey
+	}
+
+	row := new(models.APIKey)
+	err := s.db.NewSelect().
+		Model(row).
+		Relation("User").
+		Where("api_key.key_prefix = ?", key[:len(Prefix)+lookupLength]).
+		Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrInvalidKey
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load API key: %w", err)
+	}
+
+	if !verifyKey(key, row.KeyHash) {
+		return nil, ErrInvalidKey
+	}
+	if !row.IsActive || (row.User != nil && !row.User.IsActive) {
+		return nil, ErrKeyInactive
+	}
+	if row.ExpiresAt != nil && time.Now().After(*row.ExpiresAt) {
+		return nil, ErrKeyExpired
+	}
+
+	s.touch(row.ID)
+
+	claims := &auth.Claims{
+		Role:     auth.RoleUser,
+		Scopes:   row.Permissions,
+		APIKeyID: row.ID,
+	}
+	if row.UserID != nil {
+		claims.UserID = *row.UserID
+	}
+	if row.User != nil && row.User.Role == auth.RoleAdmin && slices.Contains(row.Permissions, auth.ScopeAdmin) {
+		claims.Role = auth.RoleAdmin
+	}
+	if row.OrganizationID != nil {
+		claims.OrganizationID = *row.OrganizationID
+	}
+	if row.ExpiresAt != nil {
+		claims.ExpiresAt = *row.ExpiresAt
+	}
+	return claims, nil
+}
+
+// touch records that the key was used, at most once per lastUsedInterval, without
+// holding up the request
+func (s *Store) touch(id uuid.UUID) {
+	now := time.Now()
+
+	s.mu.Lock()
+	if now.Sub(s.used[id]) < lastUsedInterval {
+		s.mu.Unlock()
+		return
+	}
+	s.used[id] = now
+	s.mu.Unlock()
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		row := &models.APIKey{ID: id, LastUsedAt: &now}
+		if _, err := s.db.NewUpdate().Model(row).Column("last_used_at").WherePK().Exec(ctx); err != nil {
+			slog.Warn("Failed to record API key use", "api_key_id", id, "error", err)
+		}
+	}()
+}
+
+// hashKey returns the salted hash stored for a key, as scheme$salt$hash
+func hashKey(key string) (string, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("failed to generate salt: %w", err)
+	}
+	return hashScheme + "$" + hex.EncodeToString(salt) + "$" + hex.EncodeToString(digest(salt, key)), nil
+}
+
+// verifyKey reports, in constant time, whether key matches a hash from hashKey
+func verifyKey(key, stored string) bool {
+	parts := strings.Split(stored, "$")
+	if len(parts) != 3 || parts[0] != hashScheme {
+		return false
+	}
+	salt, err := hex.DecodeString(parts[1])
+	if err != nil {
+		return false
+	}
+	want, err := hex.DecodeString(parts[2])
+	if err != nil {
+		return false
+	}
+	return subtle.ConstantTimeCompare(digest(salt, key), want) == 1
+}
+
+// digest hashes the salt followed by the key
+func digest(salt []byte, key string) []byte {
+	h := sha256.New()
+	h.Write(salt)
+	h.Write([]byte(key))
+	return h.Sum(nil)
+}
+
+// randomString returns n characters drawn uniformly from alphabet
+func randomString(n int) (string, error) {
+	max := big.NewInt(int64(len(alphabet)))
+	out := make([]byte, n)
+	for i := range out {
+		index, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", fmt.Errorf("failed to generate key: %w", err)
+		}
+		out[i] = alphabet[index.Int64()]
+	}
+	return string(out), nil
+}
@@ -0,0 +1,112 @@
+package apikeys
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestHashKey(t *testing.T) {
+	key := Prefix + strings.Repeat("a", lookupLength+secretLength)
+
+	hash, err := hashKey(key)
+	if err != nil {
+		t.Fatalf("hashKey() error = %v", err)
+	}
+	if !strings.HasPrefix(hash, hashScheme+"$") {
+		t.Errorf("hashKey() = %s, want a %s hash", hash, hashScheme)
+	}
+	if strings.Contains(hash, key) {
+		t.Errorf("hashKey() = %s, contains the key", hash)
+	}
+
+	// Every hash gets its own salt
+	other, err := hashKey(key)
+	if err != nil {
+		t.Fatalf("hashKey() error = %v", err)
+	}
+	if other == hash {
+		t.Errorf("hashKey() returned %s twice, want different salts", hash)
+	}
+	if !verifyKey(key, hash) || !verifyKey(key, other) {
+		t.Errorf("verifyKey() = false for both hashes of the key, want true")
+	}
+}
+
+func TestVerifyKey(t *testing.T) {
+	key := Prefix + strings.Repeat("a", lookupLength+secretLength)
+	hash, err := hashKey(key)
+	if err != nil {
+		t.Fatalf("hashKey() error = %v", err)
+	}
+	parts := strings.Split(hash, "$")
+	otherSalt := "00" + parts[1][2:]
+	if otherSalt == parts[1] {
+		otherSalt = "ff" + parts[1][2:]
+	}
+
+	tests := []struct {
+		name   string
+		key    string
+		stored string
+		want   bool
+	}{
+		{"matching key", key, hash, true},
+		{"other key", Prefix + strings.Repeat("b", lookupLength+secretLength), hash, false},
+		{"key with a suffix", key + "x", hash, false},
+		{"empty key", "", hash, false},
+		{"empty hash", key, "", false},
+		{"unknown scheme", key, "md5$" + parts[1] + "$" + parts[2], false},
+		{"missing digest", key, hashScheme + "$" + parts[1], false},
+		{"malformed salt", key, hashScheme + "$zz$" + parts[2], false},
+		{"malformed digest", key, hashScheme + "$" + parts[1] + "$zz", false},
+		{"other salt", key, hashScheme + "$" + otherSalt + "$" + parts[2], false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := verifyKey(tt.key, tt.stored); got != tt.want {
+				t.Errorf("verifyKey() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRandomString(t *testing.T) {
+	seen := make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		s, err := randomString(secretLength)
+		if err != nil {
+			t.Fatalf("randomString() error = %v", err)
+		}
+		if len(s) != secretLength {
+			t.Fatalf("randomString() = %q, want %d characters", s, secretLength)
+		}
+		if strings.Trim(s, alphabet) != "" {
+			t.Fatalf("randomString() = %q, want only characters from the alphabet", s)
+		}
+		if seen[s] {
+			t.Fatalf("randomString() returned %q twice", s)
+		}
+		seen[s] = true
+	}
+}
+
+func TestAuthenticateRejectsMalformedKeys(t *testing.T) {
+	// Malformed keys are rejected before the database is queried
+	s := NewStore(nil)
+
+	keys := []string{
+		"",
+		Prefix,
+		"sk-" + strings.Repeat("a", lookupLength+secretLength),
+		Prefix + strings.Repeat("a", lookupLength+secretLength-1),
+		Prefix + strings.Repeat("a", lookupLength+secretLength+1),
+	}
+	for _, key := range keys {
+		if _, err := s.Authenticate(context.Background(), key); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("Authenticate(%q) error = %v, want ErrInvalidKey", key, err)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package auth
+
+import (
+	"fmt"
+	"time"
+
+	"ai-aggregator-service/internal/config"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/google/uuid"
+)
+
+// Token is a signed token and when it expires
+type Token struct {
+	Value     string
+	ExpiresAt time.Time
+}
+
+// Issuer signs the HS256 access tokens of users who sign in with a password, carrying
+// the claims Verifier reads
+type Issuer struct {
+	secret    []byte
+	issuer    string
+	audience  string
+	accessTTL time.Duration
+}
+
+// NewIssuer creates an issuer from the authentication configuration, which must carry a
+// strong JWT secret
+func NewIssuer(cfg config.AuthConfig) (*Issuer, error) {
+	if err := checkSecret(cfg.JWTSecret); err != nil {
+		return nil, err
+	}
+
+	return &Issuer{
+		secret:    []byte(cfg.JWTSecret),
+		issuer:    cfg.JWTIssuer,
+		audience:  cfg.JWTAudience,
+		accessTTL: cfg.JWTExpiration,
+	}, nil
+}
+
+// AccessToken signs an access token for the claims' user, organization and role, with a
+// unique jti by which it can be revoked
+func (i *Issuer) AccessToken(claims Claims) (Token, error) {
+	mapClaims := i.registered(claims.UserID, i.accessTTL)
+	if claims.OrganizationID != uuid.Nil {
+		mapClaims["org_id"] = claims.OrganizationID.String()
+	}
+	if claims.Role != "" {
+		mapClaims["role"] = claims.Role
+	}
+	if len(claims.Scopes) > 0 {
+		mapClaims["scopes"] = claims.Scopes
+	}
+	return i.sign(mapClaims)
+}
+
+// registered returns the registered claims of a token for the user that expires after ttl
+func (i *Issuer) registered(userID uuid.UUID, ttl time.Duration) jwt.MapClaims {
+	now := time.Now()
+	mapClaims := jwt.MapClaims{
+		"sub": userID.String(),
+		"iat": now.Unix(),
+		"exp": now.Add(ttl).Unix(),
+		"jti": uuid.NewString(),
+	}
+	if i.issuer != "" {
+		mapClaims["iss"] = i.issuer
+	}
+	if i.audience != "" {
+		mapClaims["aud"] = i.audience
+	}
+	return mapClaims
+}
+
+// sign signs the claims with the configured secret
+func (i *Issuer) sign(mapClaims jwt.MapClaims) (Token, error) {
+	value, err := jwt.NewWithClaims(jwt.SigningMethodHS256, mapClaims).SignedString(i.secret)
+	if err != nil {
+		return Token{}, fmt.Errorf("failed to sign token: %w", err)
+	}
+	return Token{Value: value, ExpiresAt: time.Unix(mapClaims["exp"].(int64), 0)}, nil
+}
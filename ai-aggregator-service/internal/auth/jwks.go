@@ -0,0 +1,171 @@
+package auth
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// jwksRefreshInterval controls how long fetched keys are trusted before the set is
+	// fetched again
+	jwksRefreshInterval = time.Hour
+
+	// jwksMinRefreshInterval limits refetches for tokens signed with unknown keys
+	jwksMinRefreshInterval = time.Minute
+)
+
+// keySet holds the RSA public keys of a JSON Web Key Set, fetched from its URL and
+// refreshed periodically or when a token names a key it does not know
+type keySet struct {
+	url    string
+	client *http.Client
+
+	mu        sync.RWMutex
+	keys      map[string]*rsa.PublicKey // key ID -> key
+	fetchedAt time.Time
+
+	refreshMu sync.Mutex
+}
+
+// newKeySet creates a key set fetched from url
+func newKeySet(url string) *keySet {
+	return &keySet{
+		url:    url,
+		client: &http.Client{Timeout: 10 * time.Second},
+		keys:   make(map[string]*rsa.PublicKey),
+	}
+}
+
+// jsonWebKey is a key of a JSON Web Key Set
+type jsonWebKey struct {
+	KeyType string `json:"kty"`
+	KeyID   string `json:"kid"`
+	Use     string `json:"use"`
+	N       string `json:"n"`
+	E       string `json:"e"`
+}
+
+// key returns the key with the given ID. Tokens without a key ID are accepted when the
+// set has a single key.
+func (s *keySet) key(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	if s == nil {
+		return nil, fmt.Errorf("no JWKS configured")
+	}
+
+	if key, ok := s.lookup(kid); ok && !s.stale(jwksRefreshInterval) {
+		return key, nil
+	}
+	s.refresh(ctx)
+
+	if key, ok := s.lookup(kid); ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// lookup returns the key with the given ID from the fetched keys
+func (s *keySet) lookup(kid string) (*rsa.PublicKey, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if kid == "" && len(s.keys) == 1 {
+		for _, key := range s.keys {
+			return key, true
+		}
+	}
+	key, ok := s.keys[kid]
+	return key, ok
+}
+
+// stale reports whether the keys were fetched longer than interval ago
+func (s *keySet) stale(interval time.Duration) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return time.Since(s.fetchedAt) >= interval
+}
+
+// refresh fetches the keys unless they were fetched within the minimum interval. When
+// the set cannot be fetched the previously fetched keys keep being used.
+func (s *keySet) refresh(ctx context.Context) {
+	s.refreshMu.Lock()
+	defer s.refreshMu.Unlock()
+
+	if !s.stale(jwksMinRefreshInterval) {
+		return
+	}
+
+	keys, err := s.fetch(ctx)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.fetchedAt = time.Now()
+	if err != nil {
+		slog.Warn("Failed to fetch JWKS", "url", s.url, "error", err)
+		return
+	}
+	s.keys = keys
+}
+
+// fetch reads the RSA signing keys of the set
+func (s *keySet) fetch(ctx context.Context) (map[string]*rsa.PublicKey, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch keys: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch keys: status %d", resp.StatusCode)
+	}
+
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, fmt.Errorf("failed to decode keys: %w", err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.KeyType != "RSA" || (jwk.Use != "" && jwk.Use != "sig") {
+			continue
+		}
+		key, err := jwk.rsaKey()
+		if err != nil {
+			slog.Warn("Skipping invalid JWKS key", "kid", jwk.KeyID, "error", err)
+			continue
+		}
+		keys[jwk.KeyID] = key
+	}
+	return keys, nil
+}
+
+// rsaKey decodes the key's base64url-encoded modulus and exponent
+func (k jsonWebKey) rsaKey() (*rsa.PublicKey, error) {
+	n, err := base64.RawURLEncoding.DecodeString(k.N)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode modulus: %w", err)
+	}
+	e, err := base64.RawURLEncoding.DecodeString(k.E)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode exponent: %w", err)
+	}
+
+	exponent := new(big.Int).SetBytes(e)
+	if !exponent.IsInt64() || exponent.Int64() < 3 || exponent.Int64() > 1<<31-1 {
+		return nil, fmt.Errorf("unsupported exponent")
+	}
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}, nil
+}
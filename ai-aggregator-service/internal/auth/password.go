@@ -0,0 +1,81 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// argon2id parameters for new password hashes, following the OWASP recommendation.
+// Hashes record their parameters, so that they can be raised without invalidating
+// existing passwords.
+const (
+	argonMemory  = 64 * 1024 // KiB
+	argonTime    = 1
+	argonThreads = 4
+	argonKeyLen  = 32
+	argonSaltLen = 16
+)
+
+// dummyHash is verified against when there is no account, so that unknown emails take
+// as long to reject as wrong passwords
+var dummyHash, _ = HashPassword("dummy password for unknown accounts")
+
+// HashPassword returns an argon2id hash of the password in the PHC string format,
+// $argon2id$v=19$m=65536,t=1,p=4$<salt>$<hash>
+func HashPassword(password string) (string, error) {
+	salt := make([]byte, argonSaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("failed to generate salt: %w", err)
+	}
+	hash := argon2.IDKey([]byte(password), salt, argonTime, argonMemory, argonThreads, argonKeyLen)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, argonMemory, argonTime, argonThreads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(hash),
+	), nil
+}
+
+// CheckPassword reports, in constant time, whether password matches a hash from
+// HashPassword. An empty hash, as held by accounts without a password, never matches
+// but takes as long to check.
+func CheckPassword(password, encoded string) bool {
+	if encoded == "" {
+		checkPassword(password, dummyHash)
+		return false
+	}
+	return checkPassword(password, encoded)
+}
+
+// checkPassword verifies password against an encoded argon2id hash
+func checkPassword(password, encoded string) bool {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return false
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return false
+	}
+	var memory, time uint32
+	var threads uint8
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &time, &threads); err != nil {
+		return false
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return false
+	}
+	want, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(want) == 0 {
+		return false
+	}
+
+	hash := argon2.IDKey([]byte(password), salt, time, memory, threads, uint32(len(want)))
+	return subtle.ConstantTimeCompare(hash, want) == 1
+}
@@ -0,0 +1,77 @@
+package auth
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/argon2"
+)
+
+func TestHashPassword(t *testing.T) {
+	hash, err := HashPassword("correct horse battery staple")
+	if err != nil {
+		t.Fatalf("HashPassword() error = %v", err)
+	}
+
+	prefix := fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$", argon2.Version, argonMemory, argonTime, argonThreads)
+	if !strings.HasPrefix(hash, prefix) {
+		t.Errorf("HashPassword() = %s, want a PHC string starting with %s", hash, prefix)
+	}
+	if parts := strings.Split(hash, "$"); len(parts) != 6 {
+		t.Errorf("HashPassword() = %s, want 6 $-separated fields", hash)
+	}
+
+	// Every hash gets its own salt
+	other, err := HashPassword("correct horse battery staple")
+	if err != nil {
+		t.Fatalf("HashPassword() error = %v", err)
+	}
+	if other == hash {
+		t.Errorf("HashPassword() returned %s twice, want different salts", hash)
+	}
+}
+
+func TestCheckPassword(t *testing.T) {
+	const password = "correct horse battery staple"
+	hash, err := HashPassword(password)
+	if err != nil {
+		t.Fatalf("HashPassword() error = %v", err)
+	}
+	parts := strings.Split(hash, "$")
+
+	// A hash with other parameters, as stored before they were raised
+	salt := []byte("0123456789abcdef")
+	older := fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, 8*1024, 2, 1,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(argon2.IDKey([]byte(password), salt, 2, 8*1024, 1, 32)),
+	)
+
+	tests := []struct {
+		name     string
+		password string
+		encoded  string
+		want     bool
+	}{
+		{"matching password", password, hash, true},
+		{"hash with other parameters", password, older, true},
+		{"wrong password", "Correct horse battery staple", hash, false},
+		{"empty password", "", hash, false},
+		{"account without a password", password, "", false},
+		{"argon2i hash", password, strings.Replace(hash, "$argon2id$", "$argon2i$", 1), false},
+		{"other version", password, strings.Replace(hash, "$v=19$", "$v=16$", 1), false},
+		{"malformed parameters", password, strings.Join([]string{"", parts[1], parts[2], "m=x", parts[4], parts[5]}, "$"), false},
+		{"malformed salt", password, strings.Join([]string{"", parts[1], parts[2], parts[3], "!", parts[5]}, "$"), false},
+		{"empty digest", password, strings.Join([]string{"", parts[1], parts[2], parts[3], parts[4], ""}, "$"), false},
+		{"missing field", password, strings.Join(parts[:5], "$"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CheckPassword(tt.password, tt.encoded); got != tt.want {
+				t.Errorf("CheckPassword() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
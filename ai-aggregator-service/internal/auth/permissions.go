@@ -0,0 +1,153 @@
+package auth
+
+import (
+	"fmt"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// Scopes API keys can be granted, naming the endpoints they may call
+const (
+	ScopeChat        = "chat" // chat completions and the Messages API
+	ScopeCompletions = "completions"
+	ScopeEmbeddings  = "embeddings"
+	ScopeBillingRead = "billing:read"
+	ScopeAdmin       = "admin"
+)
+
+// Constraints API keys can be granted, as prefix:value entries
+const (
+	constraintModel     = "model:"      // glob of the models the key may request
+	constraintProvider  = "provider:"   // glob of the providers that may serve the key
+	constraintMaxTokens = "max_tokens:" // most tokens a request may ask to generate
+)
+
+// endpointScopes are the scopes a key without any is granted. Admin access must always
+// be granted explicitly.
+var endpointScopes = []string{ScopeChat, ScopeCompletions, ScopeEmbeddings, ScopeBillingRead}
+
+// Permissions restrict what an API key may do. They are stored as a list of scopes and
+// constraints, e.g. ["chat", "model:gpt-4o*", "provider:openai", "max_tokens:1024"].
+type Permissions struct {
+	// Scopes lists the endpoints the key may call; a key granted none may call every
+	// endpoint but the admin ones
+	Scopes []string
+
+	// Models and Providers hold glob patterns the requested model and the provider
+	// serving it must match, when set
+	Models    []string
+	Providers []string
+
+	// MaxTokens caps max_tokens of each request, when set
+	MaxTokens int
+}
+
+// ParsePermissions reads the permissions of an API key, rejecting entries it does not know
+func ParsePermissions(values []string) (Permissions, error) {
+	return parsePermissions(values, true)
+}
+
+// PermissionsOf returns the permissions granted to the caller. Callers that did not
+// authenticate with an API key are not restricted.
+func PermissionsOf(claims *Claims) (Permissions, bool) {
+	if claims == nil || claims.APIKeyID == uuid.Nil {
+		return Permissions{}, false
+	}
+	p, _ := parsePermissions(claims.Scopes, false)
+	return p, true
+}
+
+// parsePermissions reads permission entries. Unless strict, unknown entries, which only
+// keys created before permissions were checked can hold, are skipped and grant nothing.
+func parsePermissions(values []string, strict bool) (Permissions, error) {
+	var p Permissions
+	for _, value := range values {
+		var err error
+		switch {
+		case strings.HasPrefix(value, constraintModel):
+			pattern := strings.TrimPrefix(value, constraintModel)
+			if err = checkPattern(pattern); err == nil {
+				p.Models = append(p.Models, pattern)
+			}
+
+		case strings.HasPrefix(value, constraintProvider):
+			pattern := strings.TrimPrefix(value, constraintProvider)
+			if err = checkPattern(pattern); err == nil {
+				p.Providers = append(p.Providers, pattern)
+			}
+
+		case strings.HasPrefix(value, constraintMaxTokens):
+			maxTokens, convErr := strconv.Atoi(strings.TrimPrefix(value, constraintMaxTokens))
+			if convErr != nil || maxTokens < 1 {
+				err = fmt.Errorf("max_tokens must be a positive integer")
+			} else {
+				p.MaxTokens = maxTokens
+			}
+
+		case slices.Contains(endpointScopes, value) || value == ScopeAdmin:
+			p.Scopes = append(p.Scopes, value)
+
+		default:
+			err = fmt.Errorf("unknown permission")
+		}
+
+		if err != nil {
+			if strict {
+				return p, fmt.Errorf("invalid permission %q: %w", value, err)
+			}
+			if p.Scopes == nil {
+				p.Scopes = []string{}
+			}
+		}
+	}
+	return p, nil
+}
+
+// Allows reports whether the key may call endpoints of the scope
+func (p Permissions) Allows(scope string) bool {
+	if p.Scopes == nil {
+		return scope != ScopeAdmin
+	}
+	return slices.Contains(p.Scopes, scope)
+}
+
+// AllowsModel reports whether the key may request the model
+func (p Permissions) AllowsModel(model string) bool {
+	return matchesAny(p.Models, model)
+}
+
+// AllowsProvider reports whether the provider may serve the key's requests
+func (p Permissions) AllowsProvider(provider string) bool {
+	return matchesAny(p.Providers, provider)
+}
+
+// RestrictsRoutes reports whether the key limits the models or providers it may use
+func (p Permissions) RestrictsRoutes() bool {
+	return len(p.Models) > 0 || len(p.Providers) > 0
+}
+
+// matchesAny reports whether value matches one of the patterns, or there are none
+func matchesAny(patterns []string, value string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, value); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// checkPattern validates a glob pattern
+func checkPattern(pattern string) error {
+	if pattern == "" {
+		return fmt.Errorf("empty pattern")
+	}
+	_, err := path.Match(pattern, "")
+	return err
+}
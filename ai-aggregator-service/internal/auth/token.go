@@ -0,0 +1,201 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"ai-aggregator-service/internal/config"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrInvalidToken is returned for tokens that are malformed, badly signed or carry
+	// claims this service does not accept
+	ErrInvalidToken = errors.New("invalid token")
+
+	// ErrTokenExpired is returned for tokens past their expiry
+	ErrTokenExpired = errors.New("token expired")
+
+	// ErrWeakSecret is returned when the HS256 secret is missing or could be guessed
+	ErrWeakSecret = errors.New("JWT secret is missing or too weak")
+)
+
+// minSecretLength is the shortest HS256 secret accepted, in bytes: as long as the hash
+const minSecretLength = 32
+
+// publishedSecrets are secrets that appear in examples and earlier defaults, which anyone
+// could sign tokens with
+var publishedSecrets = []string{"your-secret-key-change-this-in-production"}
+
+// Roles carried in the role claim
+const (
+	RoleUser  = "user"
+	RoleOwner = "owner" // owner of the organization the user acts for
+	RoleAdmin = "admin"
+)
+
+// Claims is the identity a verified token or API key carries
+type Claims struct {
+	UserID         uuid.UUID
+	OrganizationID uuid.UUID // uuid.Nil for users acting outside an organization
+	Role           string
+	Scopes         []string
+	ExpiresAt      time.Time
+
+	// TokenID and IssuedAt are the jti and iat of the token, by which it can be revoked
+	TokenID  string
+	IssuedAt time.Time
+
+	// APIKeyID is set when the caller authenticated with a gateway API key rather than a token
+	APIKeyID uuid.UUID
+}
+
+// HasScope reports whether the token grants scope
+func (c *Claims) HasScope(scope string) bool {
+	return slices.Contains(c.Scopes, scope)
+}
+
+// Verifier validates bearer tokens: HS256 tokens signed with the configured secret and,
+// when a JWKS URL is configured, RS256 tokens signed with one of its keys
+type Verifier struct {
+	secret   []byte
+	jwks     *keySet
+	issuer   string
+	audience string
+}
+
+// NewVerifier creates a verifier from the authentication configuration, which must carry
+// a strong JWT secret
+func NewVerifier(cfg config.AuthConfig) (*Verifier, error) {
+	if err := checkSecret(cfg.JWTSecret); err != nil {
+		return nil, err
+	}
+
+	v := &Verifier{
+		secret:   []byte(cfg.JWTSecret),
+		issuer:   cfg.JWTIssuer,
+		audience: cfg.JWTAudience,
+	}
+	if cfg.JWKSURL != "" {
+		v.jwks = newKeySet(cfg.JWKSURL)
+	}
+	return v, nil
+}
+
+// checkSecret returns ErrWeakSecret unless secret is long and not a published one
+func checkSecret(secret string) error {
+	switch {
+	case secret == "":
+		return fmt.Errorf("%w: no secret is configured", ErrWeakSecret)
+	case slices.Contains(publishedSecrets, secret):
+		return fmt.Errorf("%w: the secret is the published example value", ErrWeakSecret)
+	case len(secret) < minSecretLength:
+		return fmt.Errorf("%w: the secret must be at least %d bytes", ErrWeakSecret, minSecretLength)
+	}
+	return nil
+}
+
+// Verify checks the access token's signature, expiry and, when configured, its issuer
+// and audience, and returns its claims
+func (v *Verifier) Verify(ctx context.Context, tokenString string) (*Claims, error) {
+	mapClaims, err := v.parse(ctx, tokenString)
+	if err != nil {
+		return nil, err
+	}
+	return toClaims(mapClaims)
+}
+
+// parse checks the token's signature, expiry and, when configured, its issuer and
+// audience, and returns its raw claims
+func (v *Verifier) parse(ctx context.Context, tokenString string) (jwt.MapClaims, error) {
+	parser := &jwt.Parser{ValidMethods: v.methods()}
+	mapClaims := jwt.MapClaims{}
+	_, err := parser.ParseWithClaims(tokenString, mapClaims, func(token *jwt.Token) (interface{}, error) {
+		switch token.Method.Alg() {
+		case jwt.SigningMethodHS256.Alg():
+			return v.secret, nil
+		case jwt.SigningMethodRS256.Alg():
+			kid, _ := token.Header["kid"].(string)
+			return v.jwks.key(ctx, kid)
+		}
+		return nil, fmt.Errorf("unexpected signing method %s", token.Method.Alg())
+	})
+	if err != nil {
+		var validationErr *jwt.ValidationError
+		// Only well-signed tokens are reported as expired
+		if errors.As(err, &validationErr) && validationErr.Errors == jwt.ValidationErrorExpired {
+			return nil, ErrTokenExpired
+		}
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	// Tokens must expire; the parser only checks exp when it is present
+	if _, ok := mapClaims["exp"]; !ok {
+		return nil, fmt.Errorf("%w: missing exp claim", ErrInvalidToken)
+	}
+	if v.issuer != "" && !mapClaims.VerifyIssuer(v.issuer, true) {
+		return nil, fmt.Errorf("%w: unexpected issuer", ErrInvalidToken)
+	}
+	if v.audience != "" && !mapClaims.VerifyAudience(v.audience, true) {
+		return nil, fmt.Errorf("%w: unexpected audience", ErrInvalidToken)
+	}
+	return mapClaims, nil
+}
+
+// methods returns the signing algorithms tokens may use
+func (v *Verifier) methods() []string {
+	methods := []string{jwt.SigningMethodHS256.Alg()}
+	if v.jwks != nil {
+		methods = append(methods, jwt.SigningMethodRS256.Alg())
+	}
+	return methods
+}
+
+// toClaims reads the typed claims: the user ID from sub, the organization from org_id,
+// role, scopes from a space-separated scope claim or a scopes array, and jti
+func toClaims(mapClaims jwt.MapClaims) (*Claims, error) {
+	subject, _ := mapClaims["sub"].(string)
+	userID, err := uuid.Parse(subject)
+	if err != nil {
+		return nil, fmt.Errorf("%w: sub must be a user ID", ErrInvalidToken)
+	}
+
+	claims := &Claims{UserID: userID, Role: RoleUser}
+	if value, ok := mapClaims["org_id"].(string); ok && value != "" {
+		if claims.OrganizationID, err = uuid.Parse(value); err != nil {
+			return nil, fmt.Errorf("%w: org_id must be an organization ID", ErrInvalidToken)
+		}
+	}
+	if role, ok := mapClaims["role"].(string); ok && role != "" {
+		claims.Role = role
+	}
+	if scope, ok := mapClaims["scope"].(string); ok {
+		claims.Scopes = strings.Fields(scope)
+	}
+	if scopes, ok := mapClaims["scopes"].([]interface{}); ok {
+		for _, scope := range scopes {
+			if scope, ok := scope.(string); ok && scope != "" {
+				claims.Scopes = append(claims.Scopes, scope)
+			}
+		}
+	}
+	if exp, ok := mapClaims["exp"].(float64); ok {
+		claims.ExpiresAt = time.Unix(int64(exp), 0)
+	}
+	// Signing out of all sessions revokes the tokens issued before, which only iat tells
+	iat, ok := mapClaims["iat"].(float64)
+	if !ok {
+		return nil, fmt.Errorf("%w: iat is required", ErrInvalidToken)
+	}
+	claims.IssuedAt = time.Unix(int64(iat), 0)
+	if jti, ok := mapClaims["jti"].(string); ok {
+		claims.TokenID = jti
+	}
+	return claims, nil
+}
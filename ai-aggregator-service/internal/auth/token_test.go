@@ -0,0 +1,247 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"ai-aggregator-service/internal/config"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/google/uuid"
+)
+
+func TestNewVerifierRejectsWeakSecrets(t *testing.T) {
+	tests := []struct {
+		name    string
+		secret  string
+		wantErr bool
+	}{
+		{"empty", "", true},
+		{"published example", "your-secret-key-change-this-in-production", true},
+		{"short", strings.Repeat("x", minSecretLength-1), true},
+		{"long enough", strings.Repeat("x", minSecretLength), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.AuthConfig{JWTSecret: tt.secret}
+
+			_, err := NewVerifier(cfg)
+			if got := errors.Is(err, ErrWeakSecret); got != tt.wantErr {
+				t.Errorf("NewVerifier() error = %v, want weak secret error: %v", err, tt.wantErr)
+			}
+			_, err = NewIssuer(cfg)
+			if got := errors.Is(err, ErrWeakSecret); got != tt.wantErr {
+				t.Errorf("NewIssuer() error = %v, want weak secret error: %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// testAuthConfig verifies tokens signed with a test secret for a fixed issuer and audience
+var testAuthConfig = config.AuthConfig{
+	JWTSecret:     strings.Repeat("s", minSecretLength),
+	JWTExpiration: time.Hour,
+	JWTIssuer:     "https://auth.example.com",
+	JWTAudience:   "ai-gateway",
+}
+
+// signHS256 signs the claims with the test secret
+func signHS256(t *testing.T, mapClaims jwt.MapClaims) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, mapClaims).SignedString([]byte(testAuthConfig.JWTSecret))
+	if err != nil {
+		t.Fatalf("SignedString() error = %v", err)
+	}
+	return token
+}
+
+func TestVerify(t *testing.T) {
+	verifier, err := NewVerifier(testAuthConfig)
+	if err != nil {
+		t.Fatalf("NewVerifier() error = %v", err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+
+	userID, orgID := uuid.New(), uuid.New()
+	now := time.Now()
+	valid := func() jwt.MapClaims {
+		return jwt.MapClaims{
+			"sub":    userID.String(),
+			"org_id": orgID.String(),
+			"role":   RoleAdmin,
+			"scope":  "chat embeddings",
+			"iat":    now.Unix(),
+			"exp":    now.Add(time.Hour).Unix(),
+			"jti":    "token-1",
+			"iss":    testAuthConfig.JWTIssuer,
+			"aud":    testAuthConfig.JWTAudience,
+		}
+	}
+	// with returns the valid claims changed by edit
+	with := func(edit func(jwt.MapClaims)) jwt.MapClaims {
+		mapClaims := valid()
+		edit(mapClaims)
+		return mapClaims
+	}
+
+	tests := []struct {
+		name    string
+		token   func() string
+		wantErr error
+	}{
+		{
+			name:  "valid",
+			token: func() string { return signHS256(t, valid()) },
+		},
+		{
+			name: "expired",
+			token: func() string {
+				return signHS256(t, with(func(c jwt.MapClaims) { c["exp"] = now.Add(-time.Minute).Unix() }))
+			},
+			wantErr: ErrTokenExpired,
+		},
+		{
+			name: "expired with another secret",
+			token: func() string {
+				token, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, with(func(c jwt.MapClaims) {
+					c["exp"] = now.Add(-time.Minute).Unix()
+				})).SignedString([]byte(strings.Repeat("x", minSecretLength)))
+				return token
+			},
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name:    "missing exp",
+			token:   func() string { return signHS256(t, with(func(c jwt.MapClaims) { delete(c, "exp") })) },
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name:    "missing iat",
+			token:   func() string { return signHS256(t, with(func(c jwt.MapClaims) { delete(c, "iat") })) },
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name: "wrong issuer",
+			token: func() string {
+				return signHS256(t, with(func(c jwt.MapClaims) { c["iss"] = "https://evil.example.com" }))
+			},
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name:    "missing issuer",
+			token:   func() string { return signHS256(t, with(func(c jwt.MapClaims) { delete(c, "iss") })) },
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name:    "wrong audience",
+			token:   func() string { return signHS256(t, with(func(c jwt.MapClaims) { c["aud"] = "another-service" })) },
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name: "RS256 without a JWKS",
+			token: func() string {
+				token, err := jwt.NewWithClaims(jwt.SigningMethodRS256, valid()).SignedString(rsaKey)
+				if err != nil {
+					t.Fatalf("SignedString() error = %v", err)
+				}
+				return token
+			},
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name: "alg none",
+			token: func() string {
+				token, err := jwt.NewWithClaims(jwt.SigningMethodNone, valid()).SignedString(jwt.UnsafeAllowNoneSignatureType)
+				if err != nil {
+					t.Fatalf("SignedString() error = %v", err)
+				}
+				return token
+			},
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name:    "sub that is not a user ID",
+			token:   func() string { return signHS256(t, with(func(c jwt.MapClaims) { c["sub"] = "admin" })) },
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name:    "missing sub",
+			token:   func() string { return signHS256(t, with(func(c jwt.MapClaims) { delete(c, "sub") })) },
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name:    "org_id that is not an organization ID",
+			token:   func() string { return signHS256(t, with(func(c jwt.MapClaims) { c["org_id"] = "acme" })) },
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name:    "malformed",
+			token:   func() string { return "not.a.token" },
+			wantErr: ErrInvalidToken,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := verifier.Verify(context.Background(), tt.token())
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("Verify() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Verify() error = %v", err)
+			}
+			if claims.UserID != userID || claims.OrganizationID != orgID || claims.Role != RoleAdmin {
+				t.Errorf("Verify() = user %s, organization %s, role %s, want %s, %s, %s",
+					claims.UserID, claims.OrganizationID, claims.Role, userID, orgID, RoleAdmin)
+			}
+			if !slices.Equal(claims.Scopes, []string{"chat", "embeddings"}) {
+				t.Errorf("Verify() scopes = %v, want [chat embeddings]", claims.Scopes)
+			}
+			if claims.TokenID != "token-1" || claims.IssuedAt.Unix() != now.Unix() {
+				t.Errorf("Verify() jti = %s, iat = %v, want token-1, %v", claims.TokenID, claims.IssuedAt, now)
+			}
+		})
+	}
+}
+
+func TestVerifyIssuedTokens(t *testing.T) {
+	issuer, err := NewIssuer(testAuthConfig)
+	if err != nil {
+		t.Fatalf("NewIssuer() error = %v", err)
+	}
+	verifier, err := NewVerifier(testAuthConfig)
+	if err != nil {
+		t.Fatalf("NewVerifier() error = %v", err)
+	}
+
+	want := Claims{UserID: uuid.New(), OrganizationID: uuid.New(), Role: RoleOwner, Scopes: []string{"chat"}}
+	token, err := issuer.AccessToken(want)
+	if err != nil {
+		t.Fatalf("AccessToken() error = %v", err)
+	}
+
+	claims, err := verifier.Verify(context.Background(), token.Value)
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if claims.UserID != want.UserID || claims.OrganizationID != want.OrganizationID || claims.Role != want.Role ||
+		!slices.Equal(claims.Scopes, want.Scopes) || claims.TokenID == "" {
+		t.Errorf("Verify() = %+v, want the claims the token was issued for", claims)
+	}
+	if !claims.ExpiresAt.Equal(token.ExpiresAt.Truncate(time.Second)) {
+		t.Errorf("Verify() expires at %v, want %v", claims.ExpiresAt, token.ExpiresAt)
+	}
+}
@@ -0,0 +1,148 @@
+package catalog
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"ai-aggregator-service/internal/models"
+	"ai-aggregator-service/internal/providers"
+
+	"github.com/uptrace/bun"
+)
+
+// Capabilities stored in models.capabilities
+const (
+	CapabilityText      = "text"
+	CapabilityVision    = "vision"
+	CapabilityAudio     = "audio"
+	CapabilityDocuments = "documents"
+)
+
+// partCapabilities maps content part types to the capability a model needs to accept them
+var partCapabilities = map[string]string{
+	providers.ContentPartImageURL:   CapabilityVision,
+	providers.ContentPartInputAudio: CapabilityAudio,
+	providers.ContentPartFile:       CapabilityDocuments,
+}
+
+// Catalog provides the models recorded in the models table
+type Catalog struct {
+	db *bun.DB
+}
+
+// NewCatalog creates a new model catalog
+func NewCatalog(db *bun.DB) *Catalog {
+	return &Catalog{db: db}
+}
+
+// Capabilities returns the capabilities of the active catalog entries named model. found is
+// false when the model is not in the catalog, e.g. for models served by self-hosted providers.
+func (c *Catalog) Capabilities(ctx context.Context, model string) (capabilities []string, found bool, err error) {
+	var rows []models.Model
+	err = c.db.NewSelect().
+		Model(&rows).
+		Column("capabilities").
+		Where("name = ?", model).
+		Where("is_active").
+		Scan(ctx)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to load model capabilities: %w", err)
+	}
+	if len(rows) == 0 {
+		return nil, false, nil
+	}
+
+	// The same model may be served by several providers
+	seen := make(map[string]bool)
+	for _, row := range rows {
+		for _, capability := range row.Capabilities {
+			if !seen[capability] {
+				seen[capability] = true
+				capabilities = append(capabilities, capability)
+			}
+		}
+	}
+	return capabilities, true, nil
+}
+
+// Filter narrows the models returned by List; empty fields match every model
+type Filter struct {
+	ModelType  string
+	Capability string
+	Provider   string
+}
+
+// List returns the active models of active providers, with their provider, ordered by
+// provider and model name
+func (c *Catalog) List(ctx context.Context, filter Filter) ([]models.Model, error) {
+	var rows []models.Model
+	query := c.db.NewSelect().
+		Model(&rows).
+		Relation("Provider").
+		Where("model.is_active").
+		Where("provider.is_active").
+		OrderExpr("provider.name, model.name")
+	if filter.ModelType != "" {
+		query.Where("model.model_type = ?", filter.ModelType)
+	}
+	if filter.Capability != "" {
+		capability, err := json.Marshal([]string{filter.Capability})
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode capability filter: %w", err)
+		}
+		query.Where("model.capabilities @> ?::jsonb", string(capability))
+	}
+	if filter.Provider != "" {
+		query.Where("provider.name = ?", filter.Provider)
+	}
+
+	if err := query.Scan(ctx); err != nil {
+		return nil, fmt.Errorf("failed to list models: %w", err)
+	}
+	return rows, nil
+}
+
+// Get returns the active model named name, served by provider when it is not empty.
+// It fails with providers.ErrModelNotFound when the catalog has no such model.
+func (c *Catalog) Get(ctx context.Context, name, provider string) (*models.Model, error) {
+	row := new(models.Model)
+	query := c.db.NewSelect().
+		Model(row).
+		Relation("Provider").
+		Where("model.name = ?", name).
+		Where("model.is_active").
+		Where("provider.is_active").
+		OrderExpr("provider.name").
+		Limit(1)
+	if provider != "" {
+		query.Where("provider.name = ?", provider)
+	}
+
+	err := query.Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %s", providers.ErrModelNotFound, name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load model: %w", err)
+	}
+	return row, nil
+}
+
+// RequiredCapabilities returns the capabilities a model needs to accept the content of req
+func RequiredCapabilities(req *providers.Request) []string {
+	var required []string
+	seen := make(map[string]bool)
+	for _, msg := range req.Messages {
+		for _, part := range msg.Content {
+			capability, ok := partCapabilities[part.Type]
+			if ok && !seen[capability] {
+				seen[capability] = true
+				required = append(required, capability)
+			}
+		}
+	}
+	return required
+}
@@ -0,0 +1,198 @@
+package catalog
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"ai-aggregator-service/internal/models"
+	"ai-aggregator-service/internal/providers"
+
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+// syncDeactivatedKey marks, in models.config, rows the sync deactivated because their
+// provider stopped listing them. Only those rows are reactivated when the model returns,
+// so models disabled by hand stay disabled.
+const syncDeactivatedKey = "sync_deactivated"
+
+// SyncResult reports the changes a sync made to one provider's models
+type SyncResult struct {
+	Provider    string   `json:"provider"`
+	Added       []string `json:"added,omitempty"`
+	Deactivated []string `json:"deactivated,omitempty"`
+	Reactivated []string `json:"reactivated,omitempty"`
+	Error       string   `json:"error,omitempty"`
+}
+
+// Sync reconciles the models table with the models each registered provider lists. New
+// models are added, models no longer listed are deactivated and deactivated models that
+// are listed again are reactivated. Providers without a providers row are skipped, and
+// providers whose listing fails are left untouched and reported with the error.
+func (c *Catalog) Sync(ctx context.Context, router *providers.Router) ([]SyncResult, error) {
+	var rows []models.Provider
+	err := c.db.NewSelect().
+		Model(&rows).
+		Column("id", "name").
+		Scan(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load providers: %w", err)
+	}
+	providerIDs := make(map[string]uuid.UUID, len(rows))
+	for _, row := range rows {
+		providerIDs[row.Name] = row.ID
+	}
+
+	results := []SyncResult{}
+	for _, p := range router.Providers() {
+		providerID, ok := providerIDs[p.Name()]
+		if !ok {
+			continue
+		}
+
+		result := SyncResult{Provider: p.Name()}
+		listed, err := p.GetModels(ctx)
+		switch {
+		case err != nil:
+			result.Error = err.Error()
+		case len(listed) == 0:
+			// An empty listing is more likely an upstream fault than a retired catalog
+			result.Error = "provider listed no models"
+		default:
+			if err := c.syncProvider(ctx, providerID, listed, &result); err != nil {
+				result.Error = err.Error()
+			}
+		}
+		if result.Error != "" {
+			slog.Warn("Failed to sync provider models", "provider", p.Name(), "error", result.Error)
+		}
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// syncProvider reconciles the models of a single provider in one transaction
+func (c *Catalog) syncProvider(ctx context.Context, providerID uuid.UUID, listed []providers.ModelInfo, result *SyncResult) error {
+	return c.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		var existing []models.Model
+		err := tx.NewSelect().
+			Model(&existing).
+			Column("id", "name", "is_active", "config").
+			Where("provider_id = ?", providerID).
+			Scan(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to load models: %w", err)
+		}
+
+		known := make(map[string]bool, len(existing))
+		for _, row := range existing {
+			known[row.Name] = true
+		}
+		available := make(map[string]bool, len(listed))
+		for _, info := range listed {
+			available[info.ID] = true
+			if known[info.ID] {
+				continue
+			}
+			known[info.ID] = true
+
+			row := newModel(providerID, info)
+			_, err := tx.NewInsert().
+				Model(row).
+				On("CONFLICT (provider_id, name) DO NOTHING").
+				Exec(ctx)
+			if err != nil {
+				return fmt.Errorf("failed to add model %s: %w", info.ID, err)
+			}
+			result.Added = append(result.Added, info.ID)
+		}
+
+		for _, row := range existing {
+			switch {
+			case row.IsActive && !available[row.Name]:
+				_, err = tx.NewUpdate().
+					Model((*models.Model)(nil)).
+					Set("is_active = FALSE").
+					Set("config = COALESCE(config, '{}'::jsonb) || jsonb_build_object(?, TRUE)", syncDeactivatedKey).
+					Set("updated_at = ?", time.Now()).
+					Where("id = ?", row.ID).
+					Exec(ctx)
+				if err != nil {
+					return fmt.Errorf("failed to deactivate model %s: %w", row.Name, err)
+				}
+				result.Deactivated = append(result.Deactivated, row.Name)
+			case !row.IsActive && available[row.Name] && row.Config[syncDeactivatedKey] == true:
+				_, err = tx.NewUpdate().
+					Model((*models.Model)(nil)).
+					Set("is_active = TRUE").
+					Set("config = config - ?", syncDeactivatedKey).
+					Set("updated_at = ?", time.Now()).
+					Where("id = ?", row.ID).
+					Exec(ctx)
+				if err != nil {
+					return fmt.Errorf("failed to reactivate model %s: %w", row.Name, err)
+				}
+				result.Reactivated = append(result.Reactivated, row.Name)
+			}
+		}
+
+		return nil
+	})
+}
+
+// newModel builds the catalog entry for a model a provider listed. Display names, costs
+// and capabilities beyond text are not listed by providers and are left for admins to fill in.
+func newModel(providerID uuid.UUID, info providers.ModelInfo) *models.Model {
+	row := &models.Model{
+		ProviderID:    providerID,
+		Name:          info.ID,
+		DisplayName:   info.ID,
+		ModelType:     modelType(info.ID),
+		IsActive:      true,
+		ContextWindow: info.ContextSize,
+		MaxTokens:     info.MaxTokens,
+		Capabilities:  []string{},
+	}
+	if row.ModelType == "chat" {
+		row.Capabilities = []string{CapabilityText}
+	}
+	return row
+}
+
+// modelType guesses the type of a listed model from its name
+func modelType(name string) string {
+	name = strings.ToLower(name)
+	switch {
+	case strings.Contains(name, "embed"):
+		return "embedding"
+	case strings.Contains(name, "dall-e"), strings.Contains(name, "imagen"):
+		return "image"
+	case strings.Contains(name, "whisper"), strings.Contains(name, "tts"):
+		return "audio"
+	case strings.Contains(name, "moderation"):
+		return "moderation"
+	default:
+		return "chat"
+	}
+}
+
+// Watch syncs the catalog every interval until ctx is done
+func (c *Catalog) Watch(ctx context.Context, router *providers.Router, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := c.Sync(ctx, router); err != nil {
+				slog.Warn("Failed to sync model catalog", "error", err)
+			}
+		}
+	}
+}
@@ -1,8 +1,10 @@
 package config
 
 import (
+	"encoding/json"
 	"log/slog"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/caarlos0/env/v11"
@@ -18,6 +20,9 @@ type Config struct {
 	Auth      AuthConfig      `envPrefix:"AUTH_"`
 	Metrics   MetricsConfig   `envPrefix:"METRICS_"`
 	Providers ProvidersConfig `envPrefix:"PROVIDERS_"`
+	Routing   RoutingConfig   `envPrefix:"ROUTING_"`
+	Catalog   CatalogConfig   `envPrefix:"CATALOG_"`
+	BYOK      BYOKConfig      `envPrefix:"BYOK_"`
 }
 
 // ServerConfig holds server configuration
@@ -60,8 +65,25 @@ type LoggingConfig struct {
 
 // AuthConfig holds authentication configuration
 type AuthConfig struct {
-	JWTSecret     string        `env:"JWT_SECRET" envDefault:"your-secret-key-change-this-in-production"`
+	// JWTSecret signs and verifies HS256 tokens; startup fails unless it is at least
+	// 32 bytes long
+	JWTSecret     string        `env:"JWT_SECRET"`
 	JWTExpiration time.Duration `env:"JWT_EXPIRATION" envDefault:"24h"`
+
+	// RefreshExpiration is how long refresh tokens issued at sign-in stay valid
+	RefreshExpiration time.Duration `env:"REFRESH_EXPIRATION" envDefault:"720h"`
+
+	// MaxLoginAttempts failed sign-ins in a row lock an account for LockoutDuration
+	MaxLoginAttempts int           `env:"MAX_LOGIN_ATTEMPTS" envDefault:"5"`
+	LockoutDuration  time.Duration `env:"LOCKOUT_DURATION" envDefault:"15m"`
+
+	// JWTIssuer and JWTAudience, when set, must match the iss and aud claims of tokens
+	JWTIssuer   string `env:"JWT_ISSUER"`
+	JWTAudience string `env:"JWT_AUDIENCE"`
+
+	// JWKSURL enables RS256 tokens signed with the keys published at the URL, e.g. by an
+	// external identity provider
+	JWKSURL string `env:"JWKS_URL"`
 }
 
 // MetricsConfig holds metrics configuration
@@ -75,6 +97,7 @@ type MetricsConfig struct {
 type ProviderConfig struct {
 	Name    string            `env:"NAME"`
 	APIKey  string            `env:"API_KEY"`
+	APIKeys APIKeys           `env:"API_KEYS"`
 	BaseURL string            `env:"BASE_URL"`
 	Models  []string          `env:"MODELS"`
 	Headers map[string]string `env:"HEADERS"`
@@ -86,27 +109,141 @@ type ProvidersConfig struct {
 	Anthropic ProviderConfig `envPrefix:"ANTHROPIC_"`
 	GoogleAI  ProviderConfig `envPrefix:"GOOGLE_AI_"`
 	Cohere    ProviderConfig `envPrefix:"COHERE_"`
+	Mistral   ProviderConfig `envPrefix:"MISTRAL_"`
+
+	// Azure holds the Azure OpenAI resource endpoint in BASE_URL; deployments
+	// are mapped to model IDs in the azure row of the providers table
+	Azure ProviderConfig `envPrefix:"AZURE_OPENAI_"`
+
+	// Custom lists additional provider instances as a JSON array, e.g.
+	// [{"name":"local-llama","type":"ollama","base_url":"http://localhost:11434"}]
+	Custom CustomProviders `env:"CUSTOM"`
+
+	// RefreshInterval controls how often the providers table is checked for changed rows
+	RefreshInterval time.Duration `env:"REFRESH_INTERVAL" envDefault:"30s"`
+}
+
+// CustomProviderConfig holds configuration for an additional provider instance,
+// such as a self-hosted inference server
+type CustomProviderConfig struct {
+	Name           string            `json:"name"`
+	Type           string            `json:"type"` // openai_compatible or ollama
+	BaseURL        string            `json:"base_url"`
+	APIKey         string            `json:"api_key"`
+	APIKeys        APIKeys           `json:"api_keys"`
+	APIKeyRequired bool              `json:"api_key_required"`
+	Headers        map[string]string `json:"headers"`
+	Models         []string          `json:"models"`
+	Timeout        int               `json:"timeout"`
+}
+
+// CustomProviders holds the custom provider instances
+type CustomProviders []CustomProviderConfig
+
+// UnmarshalText parses custom providers from a JSON array
+func (c *CustomProviders) UnmarshalText(text []byte) error {
+	return json.Unmarshal(text, (*[]CustomProviderConfig)(c))
+}
+
+// APIKeyConfig holds one key of a provider's key pool
+type APIKeyConfig struct {
+	Key               string `json:"key"`
+	Weight            int    `json:"weight"`
+	RequestsPerMinute int    `json:"requests_per_minute"`
+	TokensPerMinute   int    `json:"tokens_per_minute"`
+}
+
+// APIKeys holds a provider's key pool, spreading requests over several upstream keys
+type APIKeys []APIKeyConfig
+
+// UnmarshalText parses a key pool from a JSON array, e.g.
+// [{"key":"sk-a","weight":3,"requests_per_minute":500},{"key":"sk-b"}]
+func (k *APIKeys) UnmarshalText(text []byte) error {
+	return json.Unmarshal(text, (*[]APIKeyConfig)(k))
+}
+
+// RoutingConfig holds provider routing configuration
+type RoutingConfig struct {
+	// Fallbacks maps a model to a comma-separated fallback chain,
+	// e.g. "gpt-4o=claude-3-5-sonnet-20241022,gemini-1.5-pro;gpt-4o-mini=claude-3-5-haiku-20241022"
+	Fallbacks      map[string]string `env:"FALLBACKS" envSeparator:";" envKeyValSeparator:"="`
+	MaxRetries     int               `env:"MAX_RETRIES" envDefault:"3"`
+	InitialBackoff time.Duration     `env:"INITIAL_BACKOFF" envDefault:"500ms"`
+	MaxBackoff     time.Duration     `env:"MAX_BACKOFF" envDefault:"10s"`
+
+	// Policy chooses among the providers serving a model for requests without a
+	// routing_policy hint: cheapest, fastest, reliable, or empty for the first registered
+	Policy string `env:"POLICY"`
+
+	// StatsWindow is how far back latency and success rates are measured over
+	StatsWindow time.Duration `env:"STATS_WINDOW" envDefault:"1h"`
+}
+
+// CatalogConfig holds model catalog configuration
+type CatalogConfig struct {
+	// SyncInterval is how often the models table is reconciled with provider model lists;
+	// zero leaves syncing to the admin endpoint
+	SyncInterval time.Duration `env:"SYNC_INTERVAL" envDefault:"0"`
+}
+
+// BYOKConfig holds configuration for organizations' own provider credentials
+type BYOKConfig struct {
+	// MasterKey is the base64-encoded 32-byte key credentials are encrypted under;
+	// without it organizations cannot bring their own keys
+	MasterKey string `env:"MASTER_KEY"`
+
+	// PlatformFee is charged per request served with an organization's own key, in USD
+	PlatformFee float64 `env:"PLATFORM_FEE" envDefault:"0"`
+}
+
+// FallbackChains returns the configured fallback chains keyed by model
+func (c RoutingConfig) FallbackChains() map[string][]string {
+	chains := make(map[string][]string, len(c.Fallbacks))
+	for model, chain := range c.Fallbacks {
+		for _, fallback := range strings.Split(chain, ",") {
+			if fallback = strings.TrimSpace(fallback); fallback != "" {
+				chains[strings.TrimSpace(model)] = append(chains[strings.TrimSpace(model)], fallback)
+			}
+		}
+	}
+	return chains
 }
 
 // Provider-specific configs with defaults
 type openAIConfig struct {
-	Name   string `env:"NAME" envDefault:"openai"`
-	APIKey string `env:"API_KEY"`
+	Name    string  `env:"NAME" envDefault:"openai"`
+	APIKey  string  `env:"API_KEY"`
+	APIKeys APIKeys `env:"API_KEYS"`
 }
 
 type anthropicConfig struct {
-	Name   string `env:"NAME" envDefault:"anthropic"`
-	APIKey string `env:"API_KEY"`
+	Name    string  `env:"NAME" envDefault:"anthropic"`
+	APIKey  string  `env:"API_KEY"`
+	APIKeys APIKeys `env:"API_KEYS"`
 }
 
 type googleAIConfig struct {
-	Name   string `env:"NAME" envDefault:"google_ai"`
-	APIKey string `env:"API_KEY"`
+	Name    string  `env:"NAME" envDefault:"google"`
+	APIKey  string  `env:"API_KEY"`
+	APIKeys APIKeys `env:"API_KEYS"`
 }
 
 type cohereConfig struct {
-	Name   string `env:"NAME" envDefault:"cohere"`
-	APIKey string `env:"API_KEY"`
+	Name    string  `env:"NAME" envDefault:"cohere"`
+	APIKey  string  `env:"API_KEY"`
+	APIKeys APIKeys `env:"API_KEYS"`
+}
+
+type mistralConfig struct {
+	Name    string  `env:"NAME" envDefault:"mistral"`
+	APIKey  string  `env:"API_KEY"`
+	APIKeys APIKeys `env:"API_KEYS"`
+}
+
+type azureConfig struct {
+	Name    string  `env:"NAME" envDefault:"azure"`
+	APIKey  string  `env:"API_KEY"`
+	APIKeys APIKeys `env:"API_KEYS"`
 }
 
 // LoadConfig loads configuration from environment variables
@@ -124,38 +261,48 @@ func LoadConfig() (*Config, error) {
 	anthropic := anthropicConfig{}
 	googleAI := googleAIConfig{}
 	cohere := cohereConfig{}
+	mistral := mistralConfig{}
+	azure := azureConfig{}
 
-	if err := env.Parse(&openAI); err != nil {
+	if err := env.ParseWithOptions(&openAI, env.Options{Prefix: "OPENAI_"}); err != nil {
 		return nil, err
 	}
-	if err := env.Parse(&anthropic); err != nil {
+	if err := env.ParseWithOptions(&anthropic, env.Options{Prefix: "ANTHROPIC_"}); err != nil {
 		return nil, err
 	}
-	if err := env.Parse(&googleAI); err != nil {
+	if err := env.ParseWithOptions(&googleAI, env.Options{Prefix: "GOOGLE_AI_"}); err != nil {
 		return nil, err
 	}
-	if err := env.Parse(&cohere); err != nil {
+	if err := env.ParseWithOptions(&cohere, env.Options{Prefix: "COHERE_"}); err != nil {
 		return nil, err
 	}
-
-	// Set provider configs
-	cfg.Providers.OpenAI = ProviderConfig{
-		Name:   openAI.Name,
-		APIKey: openAI.APIKey,
-	}
-	cfg.Providers.Anthropic = ProviderConfig{
-		Name:   anthropic.Name,
-		APIKey: anthropic.APIKey,
-	}
-	cfg.Providers.GoogleAI = ProviderConfig{
-		Name:   googleAI.Name,
-		APIKey: googleAI.APIKey,
+	if err := env.ParseWithOptions(&mistral, env.Options{Prefix: "MISTRAL_"}); err != nil {
+		return nil, err
 	}
-	cfg.Providers.Cohere = ProviderConfig{
-		Name:   cohere.Name,
-		APIKey: cohere.APIKey,
+	if err := env.ParseWithOptions(&azure, env.Options{Prefix: "AZURE_OPENAI_"}); err != nil {
+		return nil, err
 	}
 
+	// Set provider configs, keeping base URLs and headers loaded with the main config
+	cfg.Providers.OpenAI.Name = openAI.Name
+	cfg.Providers.OpenAI.APIKey = openAI.APIKey
+	cfg.Providers.OpenAI.APIKeys = openAI.APIKeys
+	cfg.Providers.Anthropic.Name = anthropic.Name
+	cfg.Providers.Anthropic.APIKey = anthropic.APIKey
+	cfg.Providers.Anthropic.APIKeys = anthropic.APIKeys
+	cfg.Providers.GoogleAI.Name = googleAI.Name
+	cfg.Providers.GoogleAI.APIKey = googleAI.APIKey
+	cfg.Providers.GoogleAI.APIKeys = googleAI.APIKeys
+	cfg.Providers.Cohere.Name = cohere.Name
+	cfg.Providers.Cohere.APIKey = cohere.APIKey
+	cfg.Providers.Cohere.APIKeys = cohere.APIKeys
+	cfg.Providers.Mistral.Name = mistral.Name
+	cfg.Providers.Mistral.APIKey = mistral.APIKey
+	cfg.Providers.Mistral.APIKeys = mistral.APIKeys
+	cfg.Providers.Azure.Name = azure.Name
+	cfg.Providers.Azure.APIKey = azure.APIKey
+	cfg.Providers.Azure.APIKeys = azure.APIKeys
+
 	return cfg, nil
 }
 
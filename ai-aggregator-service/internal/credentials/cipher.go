@@ -0,0 +1,123 @@
+package credentials
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+// ErrMasterKeyMismatch is returned when a credential was sealed with a different master key
+var ErrMasterKeyMismatch = errors.New("credential was encrypted with a different master key")
+
+// dataKeySize is the size of the AES-256 keys used for master and data keys
+const dataKeySize = 32
+
+// Cipher envelope-encrypts secrets: each secret is sealed with a fresh data key, and the
+// data key with the master key, so that the master key never encrypts secrets directly
+type Cipher struct {
+	master cipher.AEAD
+	id     string
+}
+
+// NewCipher creates a cipher from a base64-encoded 32-byte master key
+func NewCipher(masterKey string) (*Cipher, error) {
+	key, err := base64.StdEncoding.DecodeString(masterKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode master key: %w", err)
+	}
+	if len(key) != dataKeySize {
+		return nil, fmt.Errorf("master key must be %d bytes, got %d", dataKeySize, len(key))
+	}
+
+	master, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	// The ID tells which master key sealed a credential without revealing the key
+	sum := sha256.Sum256(key)
+	return &Cipher{master: master, id: hex.EncodeToString(sum[:8])}, nil
+}
+
+// MasterKeyID identifies the master key
+func (c *Cipher) MasterKeyID() string {
+	return c.id
+}
+
+// Seal encrypts the secret with a new data key and returns it with the encrypted data key
+func (c *Cipher) Seal(secret []byte) (sealed, sealedDataKey []byte, err error) {
+	dataKey := make([]byte, dataKeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, nil, fmt.Errorf("failed to generate data key: %w", err)
+	}
+
+	data, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	if sealed, err = seal(data, secret); err != nil {
+		return nil, nil, err
+	}
+	if sealedDataKey, err = seal(c.master, dataKey); err != nil {
+		return nil, nil, err
+	}
+	return sealed, sealedDataKey, nil
+}
+
+// Open decrypts a secret sealed by Seal with the master key identified by masterKeyID
+func (c *Cipher) Open(sealed, sealedDataKey []byte, masterKeyID string) ([]byte, error) {
+	if masterKeyID != c.id {
+		return nil, fmt.Errorf("%w: %s", ErrMasterKeyMismatch, masterKeyID)
+	}
+
+	dataKey, err := open(c.master, sealedDataKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt data key: %w", err)
+	}
+	data, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := open(data, sealed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt credential: %w", err)
+	}
+	return secret, nil
+}
+
+// newAEAD returns AES-GCM with the given key
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	return aead, nil
+}
+
+// seal encrypts plaintext under a random nonce, which is prepended to the ciphertext
+func seal(aead cipher.AEAD, plaintext []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// open decrypts a ciphertext produced by seal
+func open(aead cipher.AEAD, ciphertext []byte) ([]byte, error) {
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	nonce, ciphertext := ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, nil)
+}
@@ -0,0 +1,141 @@
+package credentials
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// newTestCipher returns a cipher whose master key repeats b
+func newTestCipher(t *testing.T, b byte) *Cipher {
+	t.Helper()
+	c, err := NewCipher(base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{b}, dataKeySize)))
+	if err != nil {
+		t.Fatalf("NewCipher() error = %v", err)
+	}
+	return c
+}
+
+func TestNewCipher(t *testing.T) {
+	tests := []struct {
+		name      string
+		masterKey string
+		wantErr   bool
+	}{
+		{"32-byte key", base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, 32)), false},
+		{"not base64", "not base64!", true},
+		{"short key", base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, 16)), true},
+		{"empty key", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewCipher(tt.masterKey); (err != nil) != tt.wantErr {
+				t.Errorf("NewCipher() error = %v, want error: %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCipherRoundTrip(t *testing.T) {
+	c := newTestCipher(t, 1)
+	secret := []byte("sk-proj-abcdefghijklmnopqrstuvwxyz")
+
+	sealed, sealedDataKey, err := c.Seal(secret)
+	if err != nil {
+		t.Fatalf("Seal() error = %v", err)
+	}
+	if bytes.Contains(sealed, secret) {
+		t.Error("Seal() output contains the secret")
+	}
+
+	opened, err := c.Open(sealed, sealedDataKey, c.MasterKeyID())
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	if !bytes.Equal(opened, secret) {
+		t.Errorf("Open() = %q, want %q", opened, secret)
+	}
+
+	// Every secret gets its own data key and nonce
+	again, againDataKey, err := c.Seal(secret)
+	if err != nil {
+		t.Fatalf("Seal() error = %v", err)
+	}
+	if bytes.Equal(again, sealed) || bytes.Equal(againDataKey, sealedDataKey) {
+		t.Error("Seal() returned the same output twice, want a fresh data key and nonce")
+	}
+}
+
+func TestCipherMasterKeyID(t *testing.T) {
+	first, again, other := newTestCipher(t, 1), newTestCipher(t, 1), newTestCipher(t, 2)
+
+	if first.MasterKeyID() != again.MasterKeyID() {
+		t.Errorf("MasterKeyID() = %s and %s for the same key, want them equal", first.MasterKeyID(), again.MasterKeyID())
+	}
+	if first.MasterKeyID() == other.MasterKeyID() {
+		t.Errorf("MasterKeyID() = %s for different keys, want them to differ", first.MasterKeyID())
+	}
+	if strings.Contains(base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, dataKeySize)), first.MasterKeyID()) {
+		t.Error("MasterKeyID() reveals the master key")
+	}
+}
+
+func TestCipherOpenRejectsOtherMasterKeys(t *testing.T) {
+	c, other := newTestCipher(t, 1), newTestCipher(t, 2)
+	sealed, sealedDataKey, err := c.Seal([]byte("secret"))
+	if err != nil {
+		t.Fatalf("Seal() error = %v", err)
+	}
+
+	if _, err := other.Open(sealed, sealedDataKey, c.MasterKeyID()); !errors.Is(err, ErrMasterKeyMismatch) {
+		t.Errorf("Open() with another master key: error = %v, want ErrMasterKeyMismatch", err)
+	}
+
+	// A wrong ID is caught even before decrypting fails
+	if _, err := c.Open(sealed, sealedDataKey, other.MasterKeyID()); !errors.Is(err, ErrMasterKeyMismatch) {
+		t.Errorf("Open() with another master key ID: error = %v, want ErrMasterKeyMismatch", err)
+	}
+}
+
+func TestCipherOpenRejectsTampering(t *testing.T) {
+	c := newTestCipher(t, 1)
+	sealed, sealedDataKey, err := c.Seal([]byte("secret"))
+	if err != nil {
+		t.Fatalf("Seal() error = %v", err)
+	}
+	otherSealed, otherDataKey, err := c.Seal([]byte("other"))
+	if err != nil {
+		t.Fatalf("Seal() error = %v", err)
+	}
+
+	flip := func(b []byte, i int) []byte {
+		b = bytes.Clone(b)
+		b[i] ^= 1
+		return b
+	}
+
+	tests := []struct {
+		name          string
+		sealed        []byte
+		sealedDataKey []byte
+	}{
+		{"flipped ciphertext bit", flip(sealed, len(sealed)-1), sealedDataKey},
+		{"flipped nonce bit", flip(sealed, 0), sealedDataKey},
+		{"flipped data key bit", sealed, flip(sealedDataKey, len(sealedDataKey)-1)},
+		{"truncated ciphertext", sealed[:4], sealedDataKey},
+		{"empty data key", sealed, nil},
+		{"data key of another secret", sealed, otherDataKey},
+		{"ciphertext of another secret", otherSealed, sealedDataKey},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if secret, err := c.Open(tt.sealed, tt.sealedDataKey, c.MasterKeyID()); err == nil {
+				t.Errorf("Open() = %q, want an error", secret)
+			}
+		})
+	}
+}
@@ -0,0 +1,186 @@
+package credentials
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+
+	"ai-aggregator-service/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+var (
+	// ErrNotConfigured is returned when credentials are saved without a master key configured
+	ErrNotConfigured = errors.New("no master key configured for provider credentials")
+
+	// ErrCredentialNotFound is returned when a credential to delete does not exist
+	ErrCredentialNotFound = errors.New("credential not found")
+
+	// ErrInvalidCredential is returned when a credential cannot be saved as given
+	ErrInvalidCredential = errors.New("invalid credential")
+
+	// ErrUnsupportedOption is returned when a credential overrides a provider option
+	// organizations may not set
+	ErrUnsupportedOption = errors.New("unsupported provider option")
+)
+
+// overridableOptions are the provider options a credential may override. Options such as
+// key_selection shape how we use our own keys and stay under our control.
+var overridableOptions = []string{"endpoint", "api_version", "deployments"}
+
+// Credential is an organization's decrypted API key for a provider, with the provider
+// options that go with it
+type Credential struct {
+	OrganizationID uuid.UUID
+	Provider       string
+	APIKey         string
+	Options        map[string]interface{}
+}
+
+// Store keeps organizations' provider credentials in the provider_credentials table,
+// encrypted with the cipher
+type Store struct {
+	db     *bun.DB
+	cipher *Cipher
+}
+
+// NewStore creates a new credential store. Without a cipher no credentials can be
+// saved and saved ones are not used.
+func NewStore(db *bun.DB, cipher *Cipher) *Store {
+	return &Store{db: db, cipher: cipher}
+}
+
+// Active returns the decrypted active credentials of all organizations. Credentials
+// that cannot be decrypted, e.g. after the master key changed, are skipped.
+func (s *Store) Active(ctx context.Context) ([]Credential, error) {
+	if s.cipher == nil {
+		return nil, nil
+	}
+
+	var rows []models.ProviderCredential
+	err := s.db.NewSelect().
+		Model(&rows).
+		Where("is_active").
+		Scan(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load provider credentials: %w", err)
+	}
+
+	credentials := make([]Credential, 0, len(rows))
+	for _, row := range rows {
+		key, err := s.cipher.Open(row.EncryptedKey, row.EncryptedDataKey, row.MasterKeyID)
+		if err != nil {
+			slog.Error("Failed to decrypt provider credential",
+				"organization_id", row.OrganizationID,
+				"provider", row.Provider,
+				"error", err,
+			)
+			continue
+		}
+		credentials = append(credentials, Credential{
+			OrganizationID: row.OrganizationID,
+			Provider:       row.Provider,
+			APIKey:         string(key),
+			Options:        row.Options,
+		})
+	}
+	return credentials, nil
+}
+
+// List returns the organization's credentials without their keys
+func (s *Store) List(ctx context.Context, organizationID uuid.UUID) ([]models.ProviderCredential, error) {
+	var rows []models.ProviderCredential
+	err := s.db.NewSelect().
+		Model(&rows).
+		Column("id", "created_at", "updated_at", "organization_id", "provider", "key_hint", "options", "is_active").
+		Where("organization_id = ?", organizationID).
+		OrderExpr("provider").
+		Scan(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list provider credentials: %w", err)
+	}
+	return rows, nil
+}
+
+// Set encrypts and saves the organization's API key for the provider, replacing any
+// key it had. options override the provider's options for the organization's instance,
+// and may only hold the overridableOptions.
+func (s *Store) Set(ctx context.Context, organizationID uuid.UUID, provider, apiKey string, options map[string]interface{}, active bool) (*models.ProviderCredential, error) {
+	if s.cipher == nil {
+		return nil, ErrNotConfigured
+	}
+	provider = strings.TrimSpace(provider)
+	apiKey = strings.TrimSpace(apiKey)
+	if organizationID == uuid.Nil || provider == "" || apiKey == "" {
+		return nil, fmt.Errorf("%w: organization, provider and api_key are required", ErrInvalidCredential)
+	}
+	for name := range options {
+		if !slices.Contains(overridableOptions, name) {
+			return nil, fmt.Errorf("%w: %s", ErrUnsupportedOption, name)
+		}
+	}
+
+	sealed, sealedDataKey, err := s.cipher.Seal([]byte(apiKey))
+	if err != nil {
+		return nil, err
+	}
+
+	row := &models.ProviderCredential{
+		OrganizationID:   organizationID,
+		Provider:         provider,
+		EncryptedKey:     sealed,
+		EncryptedDataKey: sealedDataKey,
+		MasterKeyID:      s.cipher.MasterKeyID(),
+		KeyHint:          keyHint(apiKey),
+		Options:          models.JSONB(options),
+		IsActive:         active,
+	}
+	if row.Options == nil {
+		row.Options = models.JSONB{}
+	}
+	_, err = s.db.NewInsert().
+		Model(row).
+		On("CONFLICT (organization_id, provider) DO UPDATE").
+		Set("encrypted_key = EXCLUDED.encrypted_key").
+		Set("encrypted_data_key = EXCLUDED.encrypted_data_key").
+		Set("master_key_id = EXCLUDED.master_key_id").
+		Set("key_hint = EXCLUDED.key_hint").
+		Set("options = EXCLUDED.options").
+		Set("is_active = EXCLUDED.is_active").
+		Set("updated_at = EXCLUDED.updated_at").
+		Returning("id, created_at").
+		Exec(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save provider credential: %w", err)
+	}
+	return row, nil
+}
+
+// Delete removes the organization's API key for the provider
+func (s *Store) Delete(ctx context.Context, organizationID uuid.UUID, provider string) error {
+	result, err := s.db.NewDelete().
+		Model((*models.ProviderCredential)(nil)).
+		Where("organization_id = ?", organizationID).
+		Where("provider = ?", provider).
+		Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to delete provider credential: %w", err)
+	}
+	if deleted, err := result.RowsAffected(); err == nil && deleted == 0 {
+		return fmt.Errorf("%w: %s", ErrCredentialNotFound, provider)
+	}
+	return nil
+}
+
+// keyHint returns the end of a key, enough for its owner to recognise it
+func keyHint(key string) string {
+	if len(key) <= 8 {
+		return "..."
+	}
+	return "..." + key[len(key)-4:]
+}
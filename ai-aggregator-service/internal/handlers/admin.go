@@ -0,0 +1,249 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"ai-aggregator-service/internal/aliases"
+	"ai-aggregator-service/internal/models"
+	"ai-aggregator-service/internal/pricing"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// SyncModels handles POST /admin/models/sync, reconciling the model catalog with the
+// models each provider currently lists
+func (h *handler) SyncModels(c echo.Context) error {
+	if h.catalog == nil {
+		return errors.New("model catalog is not configured")
+	}
+
+	results, err := h.catalog.Sync(c.Request().Context(), h.router)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"object": "list",
+		"data":   results,
+	})
+}
+
+// ModelPriceRequest represents a new price for a catalog model. EffectiveFrom defaults to
+// now; an earlier time corrects the price of the requests made since then, once re-priced.
+type ModelPriceRequest struct {
+	Provider        string          `json:"provider"`
+	InputCostPer1K  *float64        `json:"input_cost_per_1k_tokens"`
+	OutputCostPer1K *float64        `json:"output_cost_per_1k_tokens"`
+	Pricing         pricing.Details `json:"pricing"`
+	EffectiveFrom   *time.Time      `json:"effective_from,omitempty"`
+}
+
+// RepriceRequest represents the window of requests to re-price
+type RepriceRequest struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+}
+
+// SetModelPrice handles PUT /admin/models/{model_id}/pricing, recording a new price in the
+// model's price history. When several providers serve the model, provider selects one.
+func (h *handler) SetModelPrice(c echo.Context) error {
+	if h.catalog == nil || h.pricer == nil {
+		return errors.New("pricing is not configured")
+	}
+
+	var req ModelPriceRequest
+	if err := c.Bind(&req); err != nil {
+		return invalidRequest("", "Invalid request format")
+	}
+	if req.InputCostPer1K == nil {
+		return invalidRequest("input_cost_per_1k_tokens", "input_cost_per_1k_tokens is required")
+	}
+	if req.OutputCostPer1K == nil {
+		return invalidRequest("output_cost_per_1k_tokens", "output_cost_per_1k_tokens is required")
+	}
+
+	price := pricing.Price{
+		InputCostPer1K:  *req.InputCostPer1K,
+		OutputCostPer1K: *req.OutputCostPer1K,
+		EffectiveFrom:   time.Now(),
+		Details:         req.Pricing,
+	}
+	if req.EffectiveFrom != nil {
+		if req.EffectiveFrom.After(price.EffectiveFrom) {
+			return invalidRequest("effective_from", "effective_from must not be in the future")
+		}
+		price.EffectiveFrom = *req.EffectiveFrom
+	}
+	if err := price.Validate(); err != nil {
+		return invalidRequest("pricing", "%s", err)
+	}
+
+	model, err := h.catalog.Get(c.Request().Context(), c.Param("model_id"), req.Provider)
+	if err != nil {
+		return err
+	}
+	if err := h.pricer.SetPrice(c.Request().Context(), model.ID, price); err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, price)
+}
+
+// RepriceRequests handles POST /admin/pricing/reprice, recomputing the cost of the
+// requests made in [from, to) at the prices in effect when they were made
+func (h *handler) RepriceRequests(c echo.Context) error {
+	if h.pricer == nil {
+		return errors.New("pricing is not configured")
+	}
+
+	var req RepriceRequest
+	if err := c.Bind(&req); err != nil {
+		return invalidRequest("", "Invalid request format")
+	}
+	if req.From.IsZero() || req.To.IsZero() {
+		return invalidRequest("", "from and to are required")
+	}
+	if !req.From.Before(req.To) {
+		return invalidRequest("to", "to must be after from")
+	}
+
+	result, err := h.pricer.Reprice(c.Request().Context(), req.From, req.To)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, result)
+}
+
+// ModelAlias represents an alias of a concrete model, optionally pinned to a provider.
+// Aliases without an organization apply to every organization without its own alias.
+type ModelAlias struct {
+	Alias          string     `json:"alias"`
+	Model          string     `json:"model"`
+	Provider       string     `json:"provider,omitempty"`
+	OrganizationID *uuid.UUID `json:"organization_id,omitempty"`
+	IsActive       *bool      `json:"is_active,omitempty"`
+}
+
+// ListModelAliases handles GET /admin/aliases, listing the global aliases or, with the
+// organization_id query parameter, an organization's own
+func (h *handler) ListModelAliases(c echo.Context) error {
+	if h.aliases == nil {
+		return errors.New("model aliases are not configured")
+	}
+
+	organizationID, err := organizationParam(c)
+	if err != nil {
+		return err
+	}
+	rows, err := h.aliases.List(c.Request().Context(), organizationID)
+	if err != nil {
+		return err
+	}
+
+	data := make([]ModelAlias, 0, len(rows))
+	for i := range rows {
+		data = append(data, toModelAlias(&rows[i]))
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"object": "list",
+		"data":   data,
+	})
+}
+
+// SetModelAlias handles PUT /admin/aliases/{alias}, creating or repointing a global alias
+// or, with the organization_id query parameter, an organization's own
+func (h *handler) SetModelAlias(c echo.Context) error {
+	if h.aliases == nil {
+		return errors.New("model aliases are not configured")
+	}
+
+	var req ModelAlias
+	if err := c.Bind(&req); err != nil {
+		return invalidRequest("", "Invalid request format")
+	}
+	if req.Model == "" {
+		return invalidRequest("model", "model is required")
+	}
+	if req.Provider != "" {
+		if _, err := h.router.Provider(req.Provider); err != nil {
+			return invalidRequest("provider", "provider %s is not registered", req.Provider)
+		}
+	}
+
+	organizationID, err := organizationParam(c)
+	if err != nil {
+		return err
+	}
+
+	alias := &models.ModelAlias{
+		Alias:    c.Param("alias"),
+		Model:    req.Model,
+		IsActive: req.IsActive == nil || *req.IsActive,
+	}
+	if organizationID != uuid.Nil {
+		alias.OrganizationID = &organizationID
+	}
+	if req.Provider != "" {
+		alias.Provider = models.StringPtr(req.Provider)
+	}
+	if err := h.aliases.Set(c.Request().Context(), alias); err != nil {
+		if errors.Is(err, aliases.ErrInvalidAlias) {
+			return invalidRequest("model", "%s", err)
+		}
+		return err
+	}
+
+	return c.JSON(http.StatusOK, toModelAlias(alias))
+}
+
+// DeleteModelAlias handles DELETE /admin/aliases/{alias}; the organization_id query
+// parameter selects an organization's own alias
+func (h *handler) DeleteModelAlias(c echo.Context) error {
+	if h.aliases == nil {
+		return errors.New("model aliases are not configured")
+	}
+
+	organizationID, err := organizationParam(c)
+	if err != nil {
+		return err
+	}
+	if err := h.aliases.Delete(c.Request().Context(), organizationID, c.Param("alias")); err != nil {
+		if errors.Is(err, aliases.ErrAliasNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, err.Error())
+		}
+		return err
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// organizationParam parses the optional organization_id query parameter
+func organizationParam(c echo.Context) (uuid.UUID, error) {
+	value := c.QueryParam("organization_id")
+	if value == "" {
+		return uuid.Nil, nil
+	}
+	organizationID, err := uuid.Parse(value)
+	if err != nil {
+		return uuid.Nil, invalidRequest("organization_id", "organization_id must be a UUID")
+	}
+	return organizationID, nil
+}
+
+// toModelAlias converts an alias row into its API representation
+func toModelAlias(row *models.ModelAlias) ModelAlias {
+	alias := ModelAlias{
+		Alias:          row.Alias,
+		Model:          row.Model,
+		OrganizationID: row.OrganizationID,
+		IsActive:       &row.IsActive,
+	}
+	if row.Provider != nil {
+		alias.Provider = *row.Provider
+	}
+	return alias
+}
@@ -0,0 +1,668 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"ai-aggregator-service/internal/providers"
+
+	"github.com/labstack/echo/v4"
+)
+
+// MessagesRequest represents the request structure for Anthropic Messages API requests
+type MessagesRequest struct {
+	Model         string                 `json:"model" validate:"required"`
+	MaxTokens     int                    `json:"max_tokens" validate:"required"`
+	System        ContentBlocks          `json:"system,omitempty"`
+	Messages      []MessageParam         `json:"messages" validate:"required"`
+	Temperature   float64                `json:"temperature,omitempty"`
+	TopP          float64                `json:"top_p,omitempty"`
+	StopSequences []string               `json:"stop_sequences,omitempty"`
+	Stream        bool                   `json:"stream,omitempty"`
+	Tools         []MessagesTool         `json:"tools,omitempty"`
+	ToolChoice    *MessagesToolChoice    `json:"tool_choice,omitempty"`
+	Metadata      map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// MessageParam represents a message in an Anthropic Messages API request
+type MessageParam struct {
+	Role    string        `json:"role" validate:"required,oneof=user assistant"`
+	Content ContentBlocks `json:"content"`
+}
+
+// ContentBlocks is the content of a message, given as a string or an array of content blocks
+type ContentBlocks []ContentBlock
+
+// UnmarshalJSON implements json.Unmarshaler
+func (b *ContentBlocks) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		*b = ContentBlocks{{Type: "text", Text: text}}
+		return nil
+	}
+
+	return json.Unmarshal(data, (*[]ContentBlock)(b))
+}
+
+// text returns the concatenated text of all text blocks
+func (b ContentBlocks) text() string {
+	var text strings.Builder
+	for _, block := range b {
+		if block.Type == "text" {
+			text.WriteString(block.Text)
+		}
+	}
+	return text.String()
+}
+
+// ContentBlock represents a content block in the Anthropic format
+type ContentBlock struct {
+	Type      string          `json:"type"`
+	Text      string          `json:"text,omitempty"`
+	Source    *BlockSource    `json:"source,omitempty"`
+	ID        string          `json:"id,omitempty"`
+	Name      string          `json:"name,omitempty"`
+	Input     json.RawMessage `json:"input,omitempty"`
+	ToolUseID string          `json:"tool_use_id,omitempty"`
+	Content   ContentBlocks   `json:"content,omitempty"`
+	IsError   bool            `json:"is_error,omitempty"`
+}
+
+// BlockSource represents the source of an image or document block
+type BlockSource struct {
+	Type      string `json:"type"`
+	MediaType string `json:"media_type,omitempty"`
+	Data      string `json:"data,omitempty"`
+	URL       string `json:"url,omitempty"`
+}
+
+// MessagesTool represents a tool definition in the Anthropic format
+type MessagesTool struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	InputSchema json.RawMessage `json:"input_schema"`
+}
+
+// MessagesToolChoice represents a tool choice in the Anthropic format
+type MessagesToolChoice struct {
+	Type string `json:"type"`
+	Name string `json:"name,omitempty"`
+}
+
+// MessagesResponse represents the response structure for Anthropic Messages API requests
+type MessagesResponse struct {
+	ID           string          `json:"id"`
+	Type         string          `json:"type"`
+	Role         string          `json:"role"`
+	Model        string          `json:"model"`
+	Content      []ResponseBlock `json:"content"`
+	StopReason   *string         `json:"stop_reason"`
+	StopSequence *string         `json:"stop_sequence"`
+	Usage        MessagesUsage   `json:"usage"`
+}
+
+// ResponseBlock represents a text or tool_use block in a Messages API response. Unlike
+// request blocks, empty text and input are always encoded, as Anthropic clients expect them.
+type ResponseBlock struct {
+	Type  string          `json:"type"`
+	Text  *string         `json:"text,omitempty"`
+	ID    string          `json:"id,omitempty"`
+	Name  string          `json:"name,omitempty"`
+	Input json.RawMessage `json:"input,omitempty"`
+}
+
+// MessagesUsage represents token usage in the Anthropic format
+type MessagesUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+// Messages handles POST /v1/messages, the Anthropic Messages API, for any backend model
+func (h *handler) Messages(c echo.Context) error {
+	var req MessagesRequest
+	if err := c.Bind(&req); err != nil {
+		return invalidRequest("", "Invalid request format")
+	}
+
+	if err := req.validate(); err != nil {
+		return err
+	}
+
+	providerReq := req.toProviderRequest()
+	if err := h.checkCapabilities(requestContext(c), providerReq); err != nil {
+		return err
+	}
+
+	// TODO: Handle rate limiting
+	// TODO: Handle billing
+
+	log := h.startRequestLog(c, req.Model, req.Stream, countImages(providerReq))
+	ctx := log.observe(requestContext(c))
+
+	if req.Stream {
+		return h.streamMessages(ctx, c, log, providerReq)
+	}
+
+	resp, err := h.router.SendRequest(ctx, providerReq, c.Param("provider"))
+	if err != nil {
+		log.complete(ctx, nil, errorStatus(err), err)
+		return err
+	}
+
+	log.complete(ctx, &resp.Usage, http.StatusOK, nil)
+	return c.JSON(http.StatusOK, toMessagesResponse(resp))
+}
+
+// validate checks the fields the bind step cannot enforce
+func (r *MessagesRequest) validate() error {
+	if r.Model == "" {
+		return invalidRequest("model", "model: Field required")
+	}
+	if r.MaxTokens <= 0 {
+		return invalidRequest("max_tokens", "max_tokens: Field required and must be greater than 0")
+	}
+	if len(r.Messages) == 0 {
+		return invalidRequest("messages", "messages: at least one message is required")
+	}
+	for i, block := range r.System {
+		if block.Type != "text" {
+			return invalidRequest(fmt.Sprintf("system.%d.type", i), "system.%d: only text blocks are allowed", i)
+		}
+	}
+
+	names := make(map[string]bool, len(r.Tools))
+	for i, tool := range r.Tools {
+		if tool.Name == "" {
+			return invalidRequest(fmt.Sprintf("tools.%d.name", i), "tools.%d.name: Field required", i)
+		}
+		names[tool.Name] = true
+	}
+	if r.ToolChoice != nil {
+		switch r.ToolChoice.Type {
+		case "auto", "any", "none":
+		case "tool":
+			if !names[r.ToolChoice.Name] {
+				return invalidRequest("tool_choice.name", "tool_choice.name: unknown tool %s", r.ToolChoice.Name)
+			}
+		default:
+			return invalidRequest("tool_choice.type", "tool_choice.type: must be one of auto, any, tool, none")
+		}
+	}
+
+	for i, msg := range r.Messages {
+		if msg.Role != "user" && msg.Role != "assistant" {
+			return invalidRequest(fmt.Sprintf("messages.%d.role", i), "messages.%d.role: must be one of user, assistant", i)
+		}
+		for j, block := range msg.Content {
+			if err := validateContentBlock(msg.Role, block); err != nil {
+				return invalidRequest(fmt.Sprintf("messages.%d.content.%d", i, j), "messages.%d.content.%d: %s", i, j, err)
+			}
+		}
+	}
+	return nil
+}
+
+// validateContentBlock checks that a block is of a known type, carries the fields of that
+// type, and is allowed in a message with the given role
+func validateContentBlock(role string, block ContentBlock) error {
+	switch block.Type {
+	case "text":
+		return nil
+	case "image", "document":
+		if block.Source == nil {
+			return fmt.Errorf("source is required")
+		}
+		switch block.Source.Type {
+		case "base64":
+			if block.Source.MediaType == "" || block.Source.Data == "" {
+				return fmt.Errorf("source.media_type and source.data are required")
+			}
+		case "url":
+			if block.Type == "document" {
+				return fmt.Errorf("url document sources are not supported")
+			}
+			if block.Source.URL == "" {
+				return fmt.Errorf("source.url is required")
+			}
+		case "text":
+			if block.Type == "image" {
+				return fmt.Errorf("source.type must be one of base64, url")
+			}
+		default:
+			return fmt.Errorf("source.type must be one of base64, url, text")
+		}
+	case "tool_use":
+		if role != "assistant" {
+			return fmt.Errorf("tool_use blocks are only allowed in assistant messages")
+		}
+		if block.ID == "" || block.Name == "" {
+			return fmt.Errorf("tool_use blocks must have an id and a name")
+		}
+		return nil
+	case "tool_result":
+		if role != "user" {
+			return fmt.Errorf("tool_result blocks are only allowed in user messages")
+		}
+		if block.ToolUseID == "" {
+			return fmt.Errorf("tool_use_id is required")
+		}
+		for _, inner := range block.Content {
+			if inner.Type != "text" {
+				return fmt.Errorf("tool_result content must consist of text blocks")
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("type must be one of text, image, document, tool_use, tool_result")
+	}
+
+	if role != "user" {
+		return fmt.Errorf("%s blocks are only allowed in user messages", block.Type)
+	}
+	return nil
+}
+
+// toProviderRequest converts the Messages API request to the unified provider request.
+// Tool results become tool messages ahead of the rest of their user turn.
+func (r *MessagesRequest) toProviderRequest() *providers.Request {
+	messages := make([]providers.Message, 0, len(r.Messages)+1)
+	if system := r.System.text(); system != "" {
+		messages = append(messages, providers.Message{Role: "system", Content: providers.TextContent(system)})
+	}
+
+	for _, msg := range r.Messages {
+		out := providers.Message{Role: msg.Role}
+		for _, block := range msg.Content {
+			switch block.Type {
+			case "tool_result":
+				messages = append(messages, providers.Message{
+					Role:       "tool",
+					Content:    providers.TextContent(block.Content.text()),
+					ToolCallID: block.ToolUseID,
+				})
+			case "tool_use":
+				arguments := "{}"
+				if len(block.Input) > 0 {
+					arguments = string(block.Input)
+				}
+				out.ToolCalls = append(out.ToolCalls, providers.ToolCall{
+					ID:   block.ID,
+					Type: "function",
+					Function: providers.FunctionCall{
+						Name:      block.Name,
+						Arguments: arguments,
+					},
+				})
+			default:
+				out.Content = append(out.Content, toContentPart(block))
+			}
+		}
+		if len(out.Content) > 0 || len(out.ToolCalls) > 0 {
+			messages = append(messages, out)
+		}
+	}
+
+	req := &providers.Request{
+		Model:       r.Model,
+		Messages:    messages,
+		MaxTokens:   r.MaxTokens,
+		Temperature: r.Temperature,
+		TopP:        r.TopP,
+		Stream:      r.Stream,
+		Stop:        r.StopSequences,
+		Metadata:    r.Metadata,
+	}
+
+	for _, tool := range r.Tools {
+		req.Tools = append(req.Tools, providers.Tool{
+			Type: "function",
+			Function: providers.FunctionDefinition{
+				Name:        tool.Name,
+				Description: tool.Description,
+				Parameters:  tool.InputSchema,
+			},
+		})
+	}
+
+	if r.ToolChoice != nil {
+		switch r.ToolChoice.Type {
+		case "any":
+			req.ToolChoice = &providers.ToolChoice{Mode: "required"}
+		case "tool":
+			req.ToolChoice = &providers.ToolChoice{Function: r.ToolChoice.Name}
+		default:
+			req.ToolChoice = &providers.ToolChoice{Mode: r.ToolChoice.Type}
+		}
+	}
+
+	return req
+}
+
+// toContentPart converts a text, image or document block to a unified content part
+func toContentPart(block ContentBlock) providers.ContentPart {
+	switch {
+	case block.Type == "image" && block.Source.Type == "url":
+		return providers.ContentPart{
+			Type:     providers.ContentPartImageURL,
+			ImageURL: &providers.ImageURL{URL: block.Source.URL},
+		}
+	case block.Type == "image":
+		return providers.ContentPart{
+			Type:     providers.ContentPartImageURL,
+			ImageURL: &providers.ImageURL{URL: dataURL(block.Source)},
+		}
+	case block.Type == "document" && block.Source.Type == "text":
+		return providers.ContentPart{Type: providers.ContentPartText, Text: block.Source.Data}
+	case block.Type == "document":
+		return providers.ContentPart{
+			Type: providers.ContentPartFile,
+			File: &providers.File{FileData: dataURL(block.Source)},
+		}
+	default:
+		return providers.ContentPart{Type: providers.ContentPartText, Text: block.Text}
+	}
+}
+
+// dataURL encodes a base64 source as a data URL
+func dataURL(source *BlockSource) string {
+	return "data:" + source.MediaType + ";base64," + source.Data
+}
+
+// toMessagesResponse converts a unified provider response to the Anthropic format
+func toMessagesResponse(resp *providers.Response) *MessagesResponse {
+	out := &MessagesResponse{
+		ID:      resp.ID,
+		Type:    "message",
+		Role:    "assistant",
+		Model:   resp.Model,
+		Content: []ResponseBlock{},
+		Usage: MessagesUsage{
+			InputTokens:  resp.Usage.PromptTokens,
+			OutputTokens: resp.Usage.CompletionTokens,
+		},
+	}
+	if len(resp.Choices) == 0 {
+		return out
+	}
+
+	choice := resp.Choices[0]
+	if text := choice.Message.Content.Text(); text != "" {
+		out.Content = append(out.Content, ResponseBlock{Type: "text", Text: &text})
+	}
+	for _, call := range choice.Message.ToolCalls {
+		out.Content = append(out.Content, ResponseBlock{
+			Type:  "tool_use",
+			ID:    call.ID,
+			Name:  call.Function.Name,
+			Input: toolInput(call.Function.Arguments),
+		})
+	}
+
+	stopReason := messagesStopReason(choice.FinishReason)
+	out.StopReason = &stopReason
+	return out
+}
+
+// toolInput returns the arguments of a tool call as a JSON object. Arguments that are
+// not valid JSON, which some models produce, are replaced by an empty object.
+func toolInput(arguments string) json.RawMessage {
+	if !json.Valid([]byte(arguments)) || strings.TrimSpace(arguments) == "" {
+		return json.RawMessage("{}")
+	}
+	return json.RawMessage(arguments)
+}
+
+// messagesStopReason maps a unified finish reason to an Anthropic stop reason
+func messagesStopReason(reason string) string {
+	switch reason {
+	case "length":
+		return "max_tokens"
+	case "tool_calls":
+		return "tool_use"
+	case "content_filter":
+		return "refusal"
+	default:
+		return "end_turn"
+	}
+}
+
+// streamMessages relays a provider stream to the client as Anthropic Messages API events
+func (h *handler) streamMessages(ctx context.Context, c echo.Context, log *requestLog, req *providers.Request) error {
+	stream, err := h.router.SendStreamRequest(ctx, req, c.Param("provider"))
+	if err != nil {
+		log.complete(ctx, nil, errorStatus(err), err)
+		return err
+	}
+	defer stream.Close()
+
+	res := c.Response()
+	w := &messagesStreamWriter{res: res, model: req.Model, blocks: make(map[int]int), current: -1}
+	return relaySSE(ctx, res, log, req.Model, sseStream[*providers.StreamResponse]{
+		recv:  stream.Recv,
+		usage: func(chunk *providers.StreamResponse) *providers.Usage { return chunk.Usage },
+		write: w.write,
+		fail: func(err error) error {
+			status, apiErr := errorResponse(err)
+			return writeEvent(res, "error", messagesError(status, apiErr))
+		},
+		finish: w.finish,
+	})
+}
+
+// messagesStreamWriter converts unified stream chunks into Anthropic stream events. Text
+// and each tool call become consecutive content blocks.
+type messagesStreamWriter struct {
+	res   *echo.Response
+	model string
+
+	started     bool
+	current     int         // index of the open content block, or -1
+	next        int         // index of the next content block
+	currentType string      // type of the open content block
+	blocks      map[int]int // tool call index -> content block index
+	stopReason  string
+	usage       *providers.Usage
+}
+
+// write emits the events for a chunk, opening and closing content blocks as needed
+func (w *messagesStreamWriter) write(chunk *providers.StreamResponse) error {
+	if !w.started {
+		w.started = true
+		if chunk.Model != "" {
+			w.model = chunk.Model
+		}
+		err := writeEvent(w.res, "message_start", map[string]interface{}{
+			"type": "message_start",
+			"message": MessagesResponse{
+				ID:      chunk.ID,
+				Type:    "message",
+				Role:    "assistant",
+				Model:   w.model,
+				Content: []ResponseBlock{},
+			},
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if chunk.Usage != nil {
+		w.usage = chunk.Usage
+	}
+
+	for _, choice := range chunk.Choices {
+		if choice.Index != 0 {
+			continue
+		}
+
+		if choice.Delta.Content != "" {
+			if w.currentType != "text" {
+				text := ""
+				if err := w.open("text", ResponseBlock{Type: "text", Text: &text}); err != nil {
+					return err
+				}
+			}
+			if err := w.delta(map[string]interface{}{"type": "text_delta", "text": choice.Delta.Content}); err != nil {
+				return err
+			}
+		}
+
+		for _, call := range choice.Delta.ToolCalls {
+			index, ok := w.blocks[call.Index]
+			if !ok {
+				block := ResponseBlock{Type: "tool_use", ID: call.ID, Name: call.Function.Name, Input: json.RawMessage("{}")}
+				if err := w.open("tool_use", block); err != nil {
+					return err
+				}
+				w.blocks[call.Index] = w.current
+				index = w.current
+			}
+			if call.Function.Arguments == "" {
+				continue
+			}
+			if index != w.current {
+				// Anthropic streams blocks strictly one after another, so late fragments
+				// of an earlier call cannot be relayed
+				slog.Warn("Dropping interleaved tool call fragment", "model", w.model, "tool_call", call.Index)
+				continue
+			}
+			if err := w.delta(map[string]interface{}{"type": "input_json_delta", "partial_json": call.Function.Arguments}); err != nil {
+				return err
+			}
+		}
+
+		if choice.FinishReason != nil {
+			w.stopReason = messagesStopReason(*choice.FinishReason)
+		}
+	}
+	return nil
+}
+
+// open closes the current content block and starts a new one
+func (w *messagesStreamWriter) open(blockType string, block ResponseBlock) error {
+	if err := w.close(); err != nil {
+		return err
+	}
+
+	w.current = w.next
+	w.currentType = blockType
+	w.next++
+	return writeEvent(w.res, "content_block_start", map[string]interface{}{
+		"type":          "content_block_start",
+		"index":         w.current,
+		"content_block": block,
+	})
+}
+
+// delta emits a delta for the current content block
+func (w *messagesStreamWriter) delta(delta map[string]interface{}) error {
+	return writeEvent(w.res, "content_block_delta", map[string]interface{}{
+		"type":  "content_block_delta",
+		"index": w.current,
+		"delta": delta,
+	})
+}
+
+// close ends the current content block, if any
+func (w *messagesStreamWriter) close() error {
+	if w.current < 0 {
+		return nil
+	}
+
+	index := w.current
+	w.current = -1
+	w.currentType = ""
+	return writeEvent(w.res, "content_block_stop", map[string]interface{}{
+		"type":  "content_block_stop",
+		"index": index,
+	})
+}
+
+// finish closes the open content block and ends the message with its stop reason and usage
+func (w *messagesStreamWriter) finish() error {
+	if !w.started {
+		if err := w.write(&providers.StreamResponse{}); err != nil {
+			return err
+		}
+	}
+	if err := w.close(); err != nil {
+		return err
+	}
+
+	stopReason := w.stopReason
+	if stopReason == "" {
+		stopReason = "end_turn"
+	}
+	usage := MessagesUsage{}
+	if w.usage != nil {
+		usage.InputTokens = w.usage.PromptTokens
+		usage.OutputTokens = w.usage.CompletionTokens
+	}
+
+	err := writeEvent(w.res, "message_delta", map[string]interface{}{
+		"type":  "message_delta",
+		"delta": map[string]interface{}{"stop_reason": stopReason, "stop_sequence": nil},
+		"usage": usage,
+	})
+	if err != nil {
+		return err
+	}
+	return writeEvent(w.res, "message_stop", map[string]interface{}{"type": "message_stop"})
+}
+
+// writeEvent writes a JSON payload as a named Server-Sent Event and flushes it
+func writeEvent(res *echo.Response, event string, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(res, "event: %s\ndata: %s\n\n", event, data); err != nil {
+		return err
+	}
+	res.Flush()
+	return nil
+}
+
+// messagesErrors renders errors returned by the rest of the chain in the Anthropic
+// {"type":"error","error":{"type","message"}} format instead of the OpenAI one
+func messagesErrors(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		err := next(c)
+		if err == nil || c.Response().Committed {
+			return err
+		}
+
+		status, apiErr := errorResponse(err)
+		if status >= http.StatusInternalServerError {
+			slog.Error("Request failed", "method", c.Request().Method, "path", c.Path(), "status", status, "error", err)
+		}
+		setRetryAfter(c, err)
+
+		return c.JSON(status, messagesError(status, apiErr))
+	}
+}
+
+// messagesError converts an OpenAI-compatible error to the Anthropic error format
+func messagesError(status int, apiErr providers.APIError) map[string]interface{} {
+	errType := apiErr.Type
+	switch {
+	case status == http.StatusNotFound:
+		errType = "not_found_error"
+	case status == http.StatusServiceUnavailable:
+		errType = "overloaded_error"
+	case errType == errorTypeServer:
+		errType = "api_error"
+	}
+
+	return map[string]interface{}{
+		"type": "error",
+		"error": map[string]interface{}{
+			"type":    errType,
+			"message": apiErr.Message,
+		},
+	}
+}
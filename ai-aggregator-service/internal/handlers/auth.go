@@ -1,7 +1,17 @@
 package handlers
 
 import (
+	"context"
+	"errors"
 	"net/http"
+	"net/mail"
+	"strings"
+	"time"
+
+	"ai-aggregator-service/internal/accounts"
+	"ai-aggregator-service/internal/auth"
+	"ai-aggregator-service/internal/models"
+	"ai-aggregator-service/internal/sessions"
 
 	"github.com/labstack/echo/v4"
 )
@@ -34,25 +44,26 @@ type RegisterRequest struct {
 	Email           string `json:"email" validate:"required,email"`
 	Password        string `json:"password" validate:"required,min=8"`
 	Name            string `json:"name" validate:"required"`
-	Username        string `json:"username" validate:"required,min=3,max=30"`
+	Username        string `json:"username" validate:"omitempty,min=3,max=30"`
 	ConfirmPassword string `json:"confirm_password" validate:"required,eqfield=Password"`
 }
 
-// RegisterResponse represents the registration response structure
-type RegisterResponse struct {
-	User UserInfo `json:"user"`
-}
+// RegisterResponse represents the registration response structure; new users are signed
+// in straight away
+type RegisterResponse LoginResponse
 
 // RefreshTokenRequest represents the refresh token request structure
 type RefreshTokenRequest struct {
 	RefreshToken string `json:"refresh_token" validate:"required"`
 }
 
-// RefreshTokenResponse represents the refresh token response structure
+// RefreshTokenResponse represents the refresh token response structure. Refresh tokens
+// are single-use: the one returned replaces the one exchanged.
 type RefreshTokenResponse struct {
-	AccessToken string `json:"access_token"`
-	TokenType   string `json:"token_type"`
-	ExpiresIn   int    `json:"expires_in"`
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
 }
 
 // LogoutRequest represents the logout request structure
@@ -70,7 +81,8 @@ type LogoutRequest struct {
 // @Success 200 {object} LoginResponse "Successfully authenticated"
 // @Failure 400 {object} map[string]interface{} "Bad request - Invalid request format"
 // @Failure 401 {object} map[string]interface{} "Unauthorized - Invalid credentials"
-// @Failure 422 {object} map[string]interface{} "Unprocessable entity - Validation errors"
+// @Failure 403 {object} map[string]interface{} "Forbidden - Account disabled"
+// @Failure 423 {object} map[string]interface{} "Locked - Too many failed sign-ins, try again later"
 // @Failure 500 {object} map[string]interface{} "Internal server error"
 // @Router /auth/login [post]
 func (h *handler) Login(c echo.Context) error {
@@ -83,39 +95,37 @@ func (h *handler) Login(c echo.Context) error {
 			},
 		})
 	}
+	if req.Email == "" || req.Password == "" {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"error": map[string]interface{}{
+				"code":    "INVALID_REQUEST",
+				"message": "email and password are required",
+			},
+		})
+	}
 
-	// TODO: Validate request
-	// TODO: Check user credentials
-	// TODO: Generate tokens
-	// TODO: Update last login
-
-	// Mock response for now
-	response := LoginResponse{
-		AccessToken:  "mock_access_token_" + generateID(),
-		RefreshToken: "mock_refresh_token_" + generateID(),
-		TokenType:    "Bearer",
-		ExpiresIn:    3600,
-		User: UserInfo{
-			ID:       "user_" + generateID(),
-			Email:    req.Email,
-			Name:     "Test User",
-			Username: "testuser",
-		},
+	user, err := h.accounts.Login(c.Request().Context(), req.Email, req.Password)
+	if err != nil {
+		return loginError(c, err)
 	}
 
+	response, err := h.signIn(c.Request().Context(), user)
+	if err != nil {
+		return err
+	}
 	return c.JSON(http.StatusOK, response)
 }
 
 // Register godoc
 // @Summary User registration
-// @Description Register a new user account with email, password, and personal details
+// @Description Register a new user account with email, password, and personal details. A default organization is created for the user, who is signed in straight away
 // @Tags Authentication
 // @Accept json
 // @Produce json
 // @Param register body RegisterRequest true "Registration details"
 // @Success 201 {object} RegisterResponse "User successfully registered"
 // @Failure 400 {object} map[string]interface{} "Bad request - Invalid request format"
-// @Failure 409 {object} map[string]interface{} "Conflict - Email or username already exists"
+// @Failure 409 {object} map[string]interface{} "Conflict - Email already exists"
 // @Failure 422 {object} map[string]interface{} "Unprocessable entity - Validation errors"
 // @Failure 500 {object} map[string]interface{} "Internal server error"
 // @Router /auth/register [post]
@@ -129,31 +139,45 @@ func (h *handler) Register(c echo.Context) error {
 			},
 		})
 	}
+	if message := validateRegistration(req); message != "" {
+		return c.JSON(http.StatusUnprocessableEntity, map[string]interface{}{
+			"error": map[string]interface{}{
+				"code":    "VALIDATION_ERROR",
+				"message": message,
+			},
+		})
+	}
+
+	user, err := h.accounts.Register(c.Request().Context(), accounts.NewUser{
+		Email:    req.Email,
+		Password: req.Password,
+		Name:     req.Name,
+		Username: req.Username,
+	})
+	if errors.Is(err, accounts.ErrEmailTaken) {
+		return c.JSON(http.StatusConflict, map[string]interface{}{
+			"error": map[string]interface{}{
+				"code":    "EMAIL_TAKEN",
+				"message": err.Error(),
+			},
+		})
+	}
+	if err != nil {
+		return err
+	}
 
-	// TODO: Validate request
-	// TODO: Check if email already exists
-	// TODO: Check if username already exists
-	// TODO: Hash password
-	// TODO: Create user
-	// TODO: Generate tokens
 	// TODO: Send welcome email
 
-	// Mock response for now
-	response := RegisterResponse{
-		User: UserInfo{
-			ID:       "user_" + generateID(),
-			Email:    req.Email,
-			Name:     req.Name,
-			Username: req.Username,
-		},
+	response, err := h.signIn(c.Request().Context(), user)
+	if err != nil {
+		return err
 	}
-
-	return c.JSON(http.StatusCreated, response)
+	return c.JSON(http.StatusCreated, RegisterResponse(response))
 }
 
 // RefreshToken godoc
 // @Summary Refresh access token
-// @Description Generate a new access token using a valid refresh token
+// @Description Exchange a refresh token for a new access token and the next refresh token of the session. Each refresh token is single-use; presenting one again signs the session out
 // @Tags Authentication
 // @Accept json
 // @Produce json
@@ -175,24 +199,43 @@ func (h *handler) RefreshToken(c echo.Context) error {
 		})
 	}
 
-	// TODO: Validate refresh token
-	// TODO: Check if token is blacklisted
-	// TODO: Generate new access token
-	// TODO: Update token expiry
+	userID, refresh, err := h.sessions.Refresh(c.Request().Context(), req.RefreshToken)
+	if errors.Is(err, sessions.ErrInvalidRefreshToken) || errors.Is(err, sessions.ErrRefreshTokenReused) {
+		return invalidRefreshToken(c, err)
+	}
+	if err != nil {
+		return err
+	}
 
-	// Mock response for now
-	response := RefreshTokenResponse{
-		AccessToken: "new_mock_access_token_" + generateID(),
-		TokenType:   "Bearer",
-		ExpiresIn:   3600,
+	user, err := h.accounts.Get(c.Request().Context(), userID)
+	if err == nil && !user.IsActive {
+		err = accounts.ErrAccountDisabled
+	}
+	if err != nil {
+		if _, endErr := h.sessions.End(c.Request().Context(), refresh.Value); endErr != nil {
+			return endErr
+		}
+		if errors.Is(err, accounts.ErrUserNotFound) {
+			return invalidRefreshToken(c, sessions.ErrInvalidRefreshToken)
+		}
+		return loginError(c, err)
 	}
 
-	return c.JSON(http.StatusOK, response)
+	access, err := h.tokens.AccessToken(userClaims(user))
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, RefreshTokenResponse{
+		AccessToken:  access.Value,
+		RefreshToken: refresh.Value,
+		TokenType:    "Bearer",
+		ExpiresIn:    expiresIn(access),
+	})
 }
 
 // Logout godoc
 // @Summary User logout
-// @Description Logout user by invalidating refresh token and clearing session
+// @Description Logout user by revoking the session of the refresh token and, when presented as a bearer token, its access token
 // @Tags Authentication
 // @Accept json
 // @Produce json
@@ -214,16 +257,58 @@ func (h *handler) Logout(c echo.Context) error {
 		})
 	}
 
-	// TODO: Validate refresh token
-	// TODO: Blacklist refresh token
-	// TODO: Blacklist access token
-	// TODO: Clear user session
+	userID, err := h.sessions.End(c.Request().Context(), req.RefreshToken)
+	if errors.Is(err, sessions.ErrInvalidRefreshToken) {
+		return invalidRefreshToken(c, err)
+	}
+	if err != nil {
+		return err
+	}
+
+	// The route is public, so the access token of the session is revoked only when it
+	// is presented and was issued to the same user
+	if token, ok := strings.CutPrefix(c.Request().Header.Get("Authorization"), "Bearer "); ok {
+		claims, err := h.verifier.Verify(c.Request().Context(), token)
+		if err == nil && claims.UserID == userID {
+			if err := h.sessions.RevokeAccess(c.Request().Context(), claims); err != nil {
+				return err
+			}
+		}
+	}
 
 	return c.JSON(http.StatusOK, map[string]interface{}{
 		"message": "Successfully logged out",
 	})
 }
 
+// LogoutAll godoc
+// @Summary Log out of all sessions
+// @Description Revoke every refresh token of the authenticated user and every access token issued to them so far, signing them out on all devices
+// @Tags Authentication
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} map[string]interface{} "Successfully logged out of all sessions"
+// @Failure 401 {object} map[string]interface{} "Unauthorized - Missing or invalid access token"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /auth/logout-all [post]
+func (h *handler) LogoutAll(c echo.Context) error {
+	claims, err := currentClaims(c)
+	if err != nil {
+		return err
+	}
+
+	if err := h.sessions.EndAll(c.Request().Context(), claims.UserID); err != nil {
+		return err
+	}
+	if err := h.sessions.RevokeAccess(c.Request().Context(), claims); err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"message": "Successfully logged out of all sessions",
+	})
+}
+
 // Me godoc
 // @Summary Get current user
 // @Description Retrieve information about the currently authenticated user
@@ -319,3 +404,102 @@ func (h *handler) ResetPassword(c echo.Context) error {
 		"message": "Password has been reset successfully",
 	})
 }
+
+// signIn starts a session for a user who signed in and issues its access and refresh
+// tokens
+func (h *handler) signIn(ctx context.Context, user *models.User) (LoginResponse, error) {
+	access, err := h.tokens.AccessToken(userClaims(user))
+	if err != nil {
+		return LoginResponse{}, err
+	}
+	refresh, err := h.sessions.Start(ctx, user.ID)
+	if err != nil {
+		return LoginResponse{}, err
+	}
+	return LoginResponse{
+		AccessToken:  access.Value,
+		RefreshToken: refresh.Value,
+		TokenType:    "Bearer",
+		ExpiresIn:    expiresIn(access),
+		User:         toUserInfo(user),
+	}, nil
+}
+
+// userClaims returns the claims of a user's access tokens: the user acts for their
+// organization, and only users with the owner or admin role carry it
+func userClaims(user *models.User) auth.Claims {
+	claims := auth.Claims{
+		UserID:         user.ID,
+		OrganizationID: user.OrganizationID,
+		Role:           auth.RoleUser,
+	}
+	if user.Role == auth.RoleOwner || user.Role == auth.RoleAdmin {
+		claims.Role = user.Role
+	}
+	return claims
+}
+
+// toUserInfo converts a users row into its API representation
+func toUserInfo(user *models.User) UserInfo {
+	username, _ := user.Metadata["username"].(string)
+	return UserInfo{
+		ID:       user.ID.String(),
+		Email:    user.Email,
+		Name:     user.FullName,
+		Username: username,
+	}
+}
+
+// expiresIn returns the seconds until the token expires
+func expiresIn(token auth.Token) int {
+	return int(time.Until(token.ExpiresAt).Seconds())
+}
+
+// validateRegistration returns what is wrong with a registration request, if anything
+func validateRegistration(req RegisterRequest) string {
+	if address, err := mail.ParseAddress(req.Email); err != nil || address.Address != strings.TrimSpace(req.Email) {
+		return "email must be a valid email address"
+	}
+	if len(req.Password) < 8 || len(req.Password) > 128 {
+		return "password must be between 8 and 128 characters"
+	}
+	if req.ConfirmPassword != req.Password {
+		return "confirm_password must match password"
+	}
+	if strings.TrimSpace(req.Name) == "" {
+		return "name is required"
+	}
+	if req.Username != "" && (len(req.Username) < 3 || len(req.Username) > 30) {
+		return "username must be between 3 and 30 characters"
+	}
+	return ""
+}
+
+// loginError renders a failed sign-in
+func loginError(c echo.Context, err error) error {
+	status, code := http.StatusUnauthorized, "INVALID_CREDENTIALS"
+	switch {
+	case errors.Is(err, accounts.ErrAccountLocked):
+		status, code = http.StatusLocked, "ACCOUNT_LOCKED"
+	case errors.Is(err, accounts.ErrAccountDisabled):
+		status, code = http.StatusForbidden, "ACCOUNT_DISABLED"
+	case !errors.Is(err, accounts.ErrInvalidCredentials):
+		return err
+	}
+	return c.JSON(status, map[string]interface{}{
+		"error": map[string]interface{}{
+			"code":    code,
+			"message": err.Error(),
+		},
+	})
+}
+
+// invalidRefreshToken renders the rejection of a refresh token
+func invalidRefreshToken(c echo.Context, err error) error {
+	return c.JSON(http.StatusUnauthorized, map[string]interface{}{
+		"error": map[string]interface{}{
+			"code":    "INVALID_TOKEN",
+			"message": err.Error(),
+		},
+	})
+}
@@ -120,13 +120,17 @@ type AddPaymentMethodRequest struct {
 // @Failure 500 {object} map[string]interface{} "Internal server error"
 // @Router /billing/account [get]
 func (h *handler) GetAccount(c echo.Context) error {
-	// TODO: Get user ID from context
+	claims, err := currentClaims(c)
+	if err != nil {
+		return err
+	}
+
 	// TODO: Fetch billing account from database
 
 	// Mock response for now
 	account := BillingAccount{
 		ID:     "acct_" + generateID(),
-		UserID: "user_" + generateID(),
+		UserID: claims.UserID.String(),
 		Email:  "user@example.com",
 		Status: "active",
 		Plan: Plan{
@@ -185,8 +189,11 @@ func (h *handler) GetUsage(c echo.Context) error {
 	startDate := c.QueryParam("start_date")
 	endDate := c.QueryParam("end_date")
 
+	if _, err := currentClaims(c); err != nil {
+		return err
+	}
+
 	// TODO: Validate date parameters
-	// TODO: Get user ID from context
 	// TODO: Fetch usage statistics from database
 	// TODO: Use startDate and endDate for filtering
 	_ = startDate
@@ -262,8 +269,11 @@ func (h *handler) GetInvoices(c echo.Context) error {
 	offset := c.QueryParam("offset")
 	status := c.QueryParam("status")
 
+	if _, err := currentClaims(c); err != nil {
+		return err
+	}
+
 	// TODO: Validate query parameters
-	// TODO: Get user ID from context
 	// TODO: Fetch invoices from database with pagination
 	// TODO: Use limit, offset, and status for filtering
 	_ = limit
@@ -362,8 +372,11 @@ func (h *handler) GetInvoice(c echo.Context) error {
 		})
 	}
 
+	if _, err := currentClaims(c); err != nil {
+		return err
+	}
+
 	// TODO: Validate invoice ID format
-	// TODO: Get user ID from context
 	// TODO: Check if invoice belongs to user
 	// TODO: Fetch invoice from database
 
@@ -427,8 +440,11 @@ func (h *handler) AddPaymentMethod(c echo.Context) error {
 		})
 	}
 
+	if _, err := currentClaims(c); err != nil {
+		return err
+	}
+
 	// TODO: Validate request
-	// TODO: Get user ID from context
 	// TODO: Add payment method to billing provider (Stripe, etc.)
 	// TODO: Store payment method in database
 
@@ -459,7 +475,10 @@ func (h *handler) AddPaymentMethod(c echo.Context) error {
 // @Failure 500 {object} map[string]interface{} "Internal server error"
 // @Router /billing/payment-methods [get]
 func (h *handler) GetPaymentMethods(c echo.Context) error {
-	// TODO: Get user ID from context
+	if _, err := currentClaims(c); err != nil {
+		return err
+	}
+
 	// TODO: Fetch payment methods from database
 
 	// Mock response for now
@@ -518,8 +537,11 @@ func (h *handler) DeletePaymentMethod(c echo.Context) error {
 		})
 	}
 
+	if _, err := currentClaims(c); err != nil {
+		return err
+	}
+
 	// TODO: Validate payment method ID format
-	// TODO: Get user ID from context
 	// TODO: Check if payment method belongs to user
 	// TODO: Remove payment method from billing provider
 	// TODO: Delete payment method from database
@@ -559,8 +581,11 @@ func (h *handler) UpdatePlan(c echo.Context) error {
 		})
 	}
 
+	if _, err := currentClaims(c); err != nil {
+		return err
+	}
+
 	// TODO: Validate plan ID
-	// TODO: Get user ID from context
 	// TODO: Update subscription with billing provider
 	// TODO: Update user plan in database
 
@@ -689,8 +714,11 @@ func (h *handler) DownloadInvoice(c echo.Context) error {
 		})
 	}
 
+	if _, err := currentClaims(c); err != nil {
+		return err
+	}
+
 	// TODO: Validate invoice ID format
-	// TODO: Get user ID from context
 	// TODO: Check if invoice belongs to user
 	// TODO: Generate or retrieve PDF from billing provider
 
@@ -730,8 +758,11 @@ func (h *handler) CreateSubscription(c echo.Context) error {
 		})
 	}
 
+	if _, err := currentClaims(c); err != nil {
+		return err
+	}
+
 	// TODO: Validate request
-	// TODO: Get user ID from context
 	// TODO: Create subscription with billing provider
 	// TODO: Store subscription in database
 
@@ -770,8 +801,11 @@ func (h *handler) UpdateSubscription(c echo.Context) error {
 		})
 	}
 
+	if _, err := currentClaims(c); err != nil {
+		return err
+	}
+
 	// TODO: Validate request
-	// TODO: Get user ID from context
 	// TODO: Update subscription with billing provider
 	// TODO: Update subscription in database
 
@@ -810,7 +844,10 @@ func (h *handler) CancelSubscription(c echo.Context) error {
 		}{}
 	}
 
-	// TODO: Get user ID from context
+	if _, err := currentClaims(c); err != nil {
+		return err
+	}
+
 	// TODO: Cancel subscription with billing provider
 	// TODO: Update subscription status in database
 
@@ -833,7 +870,10 @@ func (h *handler) CancelSubscription(c echo.Context) error {
 // @Failure 500 {object} map[string]interface{} "Internal server error"
 // @Router /billing/subscription [get]
 func (h *handler) GetSubscription(c echo.Context) error {
-	// TODO: Get user ID from context
+	if _, err := currentClaims(c); err != nil {
+		return err
+	}
+
 	// TODO: Fetch subscription from database
 
 	// Mock response
@@ -894,8 +934,11 @@ func (h *handler) UpdatePaymentMethod(c echo.Context) error {
 		})
 	}
 
+	if _, err := currentClaims(c); err != nil {
+		return err
+	}
+
 	// TODO: Validate request
-	// TODO: Get user ID from context
 	// TODO: Check if payment method belongs to user
 	// TODO: Update payment method with billing provider
 
@@ -930,8 +973,11 @@ func (h *handler) SetDefaultPaymentMethod(c echo.Context) error {
 		})
 	}
 
+	if _, err := currentClaims(c); err != nil {
+		return err
+	}
+
 	// TODO: Validate payment method ID format
-	// TODO: Get user ID from context
 	// TODO: Check if payment method belongs to user
 	// TODO: Update default payment method in database
 
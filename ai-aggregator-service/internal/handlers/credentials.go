@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"ai-aggregator-service/internal/credentials"
+	"ai-aggregator-service/internal/middleware"
+	"ai-aggregator-service/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// ProviderCredential represents an organization's own API key for a provider. The key
+// is write-only: responses only carry its last characters. Options override the
+// provider's settings for the organization, e.g. the endpoint and deployments of its own
+// Azure OpenAI resource.
+type ProviderCredential struct {
+	Provider  string                 `json:"provider"`
+	APIKey    string                 `json:"api_key,omitempty"`
+	KeyHint   string                 `json:"key_hint,omitempty"`
+	Options   map[string]interface{} `json:"options,omitempty"`
+	IsActive  *bool                  `json:"is_active,omitempty"`
+	CreatedAt time.Time              `json:"created_at,omitempty"`
+	UpdatedAt time.Time              `json:"updated_at,omitempty"`
+}
+
+// ListProviderCredentials handles GET /organization/credentials and
+// GET /admin/organizations/{organization_id}/credentials
+func (h *handler) ListProviderCredentials(c echo.Context) error {
+	if h.credentials == nil {
+		return errors.New("provider credentials are not configured")
+	}
+
+	organizationID, err := credentialOrganization(c)
+	if err != nil {
+		return err
+	}
+	rows, err := h.credentials.List(c.Request().Context(), organizationID)
+	if err != nil {
+		return err
+	}
+
+	data := make([]ProviderCredential, 0, len(rows))
+	for i := range rows {
+		data = append(data, toProviderCredential(&rows[i]))
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"object": "list",
+		"data":   data,
+	})
+}
+
+// SetProviderCredential handles PUT /organization/credentials/{provider} and its admin
+// counterpart, saving the organization's own API key for the provider. Only the
+// organization's owner may call the former. Requests the
+// provider serves for the organization use the key once the provider registry next syncs.
+func (h *handler) SetProviderCredential(c echo.Context) error {
+	if h.credentials == nil {
+		return errors.New("provider credentials are not configured")
+	}
+
+	var req ProviderCredential
+	if err := c.Bind(&req); err != nil {
+		return invalidRequest("", "Invalid request format")
+	}
+	if req.APIKey == "" {
+		return invalidRequest("api_key", "api_key is required")
+	}
+
+	organizationID, err := credentialOrganization(c)
+	if err != nil {
+		return err
+	}
+	row, err := h.credentials.Set(c.Request().Context(), organizationID, c.Param("provider"), req.APIKey, req.Options, req.IsActive == nil || *req.IsActive)
+	if err != nil {
+		switch {
+		case errors.Is(err, credentials.ErrInvalidCredential):
+			return invalidRequest("api_key", "%s", err)
+		case errors.Is(err, credentials.ErrUnsupportedOption):
+			return invalidRequest("options", "%s", err)
+		case errors.Is(err, credentials.ErrNotConfigured):
+			return echo.NewHTTPError(http.StatusServiceUnavailable, err.Error())
+		}
+		return err
+	}
+
+	return c.JSON(http.StatusOK, toProviderCredential(row))
+}
+
+// DeleteProviderCredential handles DELETE /organization/credentials/{provider} and its
+// admin counterpart
+func (h *handler) DeleteProviderCredential(c echo.Context) error {
+	if h.credentials == nil {
+		return errors.New("provider credentials are not configured")
+	}
+
+	organizationID, err := credentialOrganization(c)
+	if err != nil {
+		return err
+	}
+	if err := h.credentials.Delete(c.Request().Context(), organizationID, c.Param("provider")); err != nil {
+		if errors.Is(err, credentials.ErrCredentialNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, err.Error())
+		}
+		return err
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// credentialOrganization returns the organization whose credentials are managed: the
+// organization_id path parameter of admin routes, or the caller's own organization
+func credentialOrganization(c echo.Context) (uuid.UUID, error) {
+	if value := c.Param("organization_id"); value != "" {
+		organizationID, err := uuid.Parse(value)
+		if err != nil {
+			return uuid.Nil, invalidRequest("organization_id", "organization_id must be a UUID")
+		}
+		return organizationID, nil
+	}
+
+	organizationID, ok := c.Get(middleware.ContextKeyOrganizationID).(uuid.UUID)
+	if !ok || organizationID == uuid.Nil {
+		return uuid.Nil, echo.NewHTTPError(http.StatusForbidden, "provider credentials belong to an organization")
+	}
+	return organizationID, nil
+}
+
+// toProviderCredential converts a credential row into its API representation
+func toProviderCredential(row *models.ProviderCredential) ProviderCredential {
+	return ProviderCredential{
+		Provider:  row.Provider,
+		KeyHint:   row.KeyHint,
+		Options:   row.Options,
+		IsActive:  &row.IsActive,
+		CreatedAt: row.CreatedAt,
+		UpdatedAt: row.UpdatedAt,
+	}
+}
@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"ai-aggregator-service/internal/providers"
+
+	"github.com/labstack/echo/v4"
+)
+
+// OpenAI-compatible error types
+const (
+	errorTypeInvalidRequest = "invalid_request_error"
+	errorTypeAuthentication = "authentication_error"
+	errorTypePermission     = "permission_error"
+	errorTypeRateLimit      = "rate_limit_error"
+	errorTypeServer         = "server_error"
+)
+
+// ErrorHandler renders every error returned by a handler as an OpenAI-compatible
+// {"error":{"message","type","code","param"}} body with the matching HTTP status
+func ErrorHandler(err error, c echo.Context) {
+	if c.Response().Committed {
+		return
+	}
+
+	status, apiErr := errorResponse(err)
+	if status >= http.StatusInternalServerError {
+		slog.Error("Request failed", "method", c.Request().Method, "path", c.Path(), "status", status, "error", err)
+	}
+
+	setRetryAfter(c, err)
+
+	if c.Request().Method == http.MethodHead {
+		err = c.NoContent(status)
+	} else {
+		err = c.JSON(status, map[string]interface{}{"error": apiErr})
+	}
+	if err != nil {
+		slog.Error("Failed to write error response", "error", err)
+	}
+}
+
+// setRetryAfter passes on how long a rate-limited provider asked callers to wait
+func setRetryAfter(c echo.Context, err error) {
+	var providerErr *providers.Error
+	if errors.As(err, &providerErr) && providerErr.RetryAfter > 0 {
+		seconds := int(math.Ceil(providerErr.RetryAfter.Seconds()))
+		c.Response().Header().Set("Retry-After", strconv.Itoa(seconds))
+	}
+}
+
+// invalidRequest returns a client error about the given request parameter
+func invalidRequest(param, format string, args ...interface{}) error {
+	return &providers.Error{
+		Kind:    providers.ErrorKindInvalidRequest,
+		Message: fmt.Sprintf(format, args...),
+		Param:   param,
+	}
+}
+
+// errorStatus returns the HTTP status used to report err
+func errorStatus(err error) int {
+	status, _ := errorResponse(err)
+	return status
+}
+
+// errorResponse maps err to an HTTP status and OpenAI-compatible error object
+func errorResponse(err error) (int, providers.APIError) {
+	var providerErr *providers.Error
+	var httpErr *echo.HTTPError
+
+	switch {
+	case errors.Is(err, providers.ErrModelNotFound):
+		return http.StatusNotFound, providers.APIError{
+			Message: err.Error(),
+			Type:    errorTypeInvalidRequest,
+			Code:    "model_not_found",
+		}
+
+	case errors.Is(err, providers.ErrProviderNotFound):
+		return http.StatusNotFound, providers.APIError{
+			Message: err.Error(),
+			Type:    errorTypeInvalidRequest,
+			Code:    "provider_not_found",
+		}
+
+	case errors.As(err, &providerErr):
+		status, errType := kindStatus(providerErr)
+		apiErr := providers.APIError{
+			Message: providerErr.Message,
+			Type:    errType,
+			Code:    providerErr.Code,
+		}
+		if apiErr.Code == "" {
+			apiErr.Code = string(providerErr.Kind)
+		}
+		if providerErr.Param != "" {
+			apiErr.Param = &providerErr.Param
+		}
+		if upstreamAuth(providerErr) {
+			// The provider's message may quote the rejected key
+			apiErr.Message = fmt.Sprintf("%s rejected the configured provider credentials", providerErr.Provider)
+		}
+		return status, apiErr
+
+	case errors.As(err, &httpErr):
+		message := http.StatusText(httpErr.Code)
+		if m, ok := httpErr.Message.(string); ok && m != "" {
+			message = m
+		}
+		return httpErr.Code, providers.APIError{
+			Message: message,
+			Type:    statusErrorType(httpErr.Code),
+			Code:    strings.ToLower(strings.ReplaceAll(http.StatusText(httpErr.Code), " ", "_")),
+		}
+	}
+
+	return http.StatusInternalServerError, providers.APIError{
+		Message: "The server had an error while processing your request",
+		Type:    errorTypeServer,
+		Code:    "internal_error",
+	}
+}
+
+// kindStatus returns the HTTP status and error type reported for a classified error
+func kindStatus(err *providers.Error) (int, string) {
+	if upstreamAuth(err) {
+		return http.StatusBadGateway, errorTypeServer
+	}
+
+	switch err.Kind {
+	case providers.ErrorKindInvalidRequest,
+		providers.ErrorKindContextLengthExceeded,
+		providers.ErrorKindContentFiltered:
+		return http.StatusBadRequest, errorTypeInvalidRequest
+	case providers.ErrorKindAuth:
+		return http.StatusUnauthorized, errorTypeAuthentication
+	case providers.ErrorKindPermission:
+		return http.StatusForbidden, errorTypePermission
+	case providers.ErrorKindRateLimited:
+		return http.StatusTooManyRequests, errorTypeRateLimit
+	case providers.ErrorKindTimeout:
+		return http.StatusGatewayTimeout, errorTypeServer
+	default:
+		return http.StatusServiceUnavailable, errorTypeServer
+	}
+}
+
+// upstreamAuth reports whether a provider rejected the credentials the gateway called it
+// with. That is a gateway misconfiguration, not a failure of the caller to authenticate,
+// so it must not be reported as a 401.
+func upstreamAuth(err *providers.Error) bool {
+	return err.Kind == providers.ErrorKindAuth && err.Provider != ""
+}
+
+// statusErrorType returns the error type reported for a plain HTTP status
+func statusErrorType(status int) string {
+	switch {
+	case status == http.StatusUnauthorized:
+		return errorTypeAuthentication
+	case status == http.StatusForbidden:
+		return errorTypePermission
+	case status == http.StatusTooManyRequests:
+		return errorTypeRateLimit
+	case status >= http.StatusInternalServerError:
+		return errorTypeServer
+	default:
+		return errorTypeInvalidRequest
+	}
+}
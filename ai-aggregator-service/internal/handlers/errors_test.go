@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"ai-aggregator-service/internal/providers"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestErrorResponse(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantType   string
+		wantCode   string
+	}{
+		{
+			name:       "provider rejected our key",
+			err:        &providers.Error{Kind: providers.ErrorKindAuth, Provider: "openai", StatusCode: 401, Message: "Incorrect API key provided: sk-abc***xyz"},
+			wantStatus: http.StatusBadGateway,
+			wantType:   errorTypeServer,
+			wantCode:   string(providers.ErrorKindAuth),
+		},
+		{
+			name:       "caller failed to authenticate",
+			err:        &providers.Error{Kind: providers.ErrorKindAuth, Message: "invalid API key"},
+			wantStatus: http.StatusUnauthorized,
+			wantType:   errorTypeAuthentication,
+			wantCode:   string(providers.ErrorKindAuth),
+		},
+		{
+			name:       "permission denied",
+			err:        &providers.Error{Kind: providers.ErrorKindPermission, Message: "not permitted", Param: "model"},
+			wantStatus: http.StatusForbidden,
+			wantType:   errorTypePermission,
+			wantCode:   string(providers.ErrorKindPermission),
+		},
+		{
+			name:       "rate limited",
+			err:        &providers.Error{Kind: providers.ErrorKindRateLimited, Provider: "openai", StatusCode: 429, Message: "slow down"},
+			wantStatus: http.StatusTooManyRequests,
+			wantType:   errorTypeRateLimit,
+			wantCode:   string(providers.ErrorKindRateLimited),
+		},
+		{
+			name:       "context length exceeded",
+			err:        &providers.Error{Kind: providers.ErrorKindContextLengthExceeded, Provider: "openai", StatusCode: 400, Message: "too long"},
+			wantStatus: http.StatusBadRequest,
+			wantType:   errorTypeInvalidRequest,
+			wantCode:   string(providers.ErrorKindContextLengthExceeded),
+		},
+		{
+			name:       "timeout",
+			err:        &providers.Error{Kind: providers.ErrorKindTimeout, Provider: "openai", Message: "timed out"},
+			wantStatus: http.StatusGatewayTimeout,
+			wantType:   errorTypeServer,
+			wantCode:   string(providers.ErrorKindTimeout),
+		},
+		{
+			name:       "upstream unavailable",
+			err:        &providers.Error{Kind: providers.ErrorKindUpstreamUnavailable, Provider: "openai", StatusCode: 500, Message: "boom"},
+			wantStatus: http.StatusServiceUnavailable,
+			wantType:   errorTypeServer,
+			wantCode:   string(providers.ErrorKindUpstreamUnavailable),
+		},
+		{
+			name:       "explicit code",
+			err:        &providers.Error{Kind: providers.ErrorKindInvalidRequest, Code: "insufficient_quota", Message: "no credit"},
+			wantStatus: http.StatusBadRequest,
+			wantType:   errorTypeInvalidRequest,
+			wantCode:   "insufficient_quota",
+		},
+		{
+			name:       "unknown model",
+			err:        fmt.Errorf("%w: gpt-9", providers.ErrModelNotFound),
+			wantStatus: http.StatusNotFound,
+			wantType:   errorTypeInvalidRequest,
+			wantCode:   "model_not_found",
+		},
+		{
+			name:       "echo error",
+			err:        echo.NewHTTPError(http.StatusForbidden, "provider credentials belong to an organization"),
+			wantStatus: http.StatusForbidden,
+			wantType:   errorTypePermission,
+			wantCode:   "forbidden",
+		},
+		{
+			name:       "unexpected error",
+			err:        fmt.Errorf("database is down"),
+			wantStatus: http.StatusInternalServerError,
+			wantType:   errorTypeServer,
+			wantCode:   "internal_error",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, apiErr := errorResponse(tt.err)
+			if status != tt.wantStatus || apiErr.Type != tt.wantType || apiErr.Code != tt.wantCode {
+				t.Errorf("errorResponse() = %d %s/%s, want %d %s/%s", status, apiErr.Type, apiErr.Code, tt.wantStatus, tt.wantType, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestErrorResponseHidesUpstreamAuthMessage(t *testing.T) {
+	err := &providers.Error{Kind: providers.ErrorKindAuth, Provider: "anthropic", StatusCode: 401, Message: "invalid x-api-key sk-ant-secret"}
+
+	_, apiErr := errorResponse(err)
+	if strings.Contains(apiErr.Message, "sk-ant-secret") {
+		t.Errorf("message = %q, quotes the provider's message", apiErr.Message)
+	}
+	if want := "anthropic rejected the configured provider credentials"; apiErr.Message != want {
+		t.Errorf("message = %q, want %q", apiErr.Message, want)
+	}
+}
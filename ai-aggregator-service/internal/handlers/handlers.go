@@ -1,8 +1,88 @@
 package handlers
 
+import (
+	"context"
+	"net/http"
+
+	"ai-aggregator-service/internal/accounts"
+	"ai-aggregator-service/internal/aliases"
+	"ai-aggregator-service/internal/apikeys"
+	"ai-aggregator-service/internal/auth"
+	"ai-aggregator-service/internal/catalog"
+	"ai-aggregator-service/internal/credentials"
+	"ai-aggregator-service/internal/middleware"
+	"ai-aggregator-service/internal/pricing"
+	"ai-aggregator-service/internal/providers"
+	"ai-aggregator-service/internal/sessions"
+	"ai-aggregator-service/internal/usage"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
 type handler struct {
+	router      *providers.Router
+	recorder    *usage.Recorder
+	catalog     *catalog.Catalog
+	pricer      *pricing.Pricer
+	aliases     *aliases.Store
+	credentials *credentials.Store
+	apiKeys     *apikeys.Store
+	accounts    *accounts.Store
+	tokens      *auth.Issuer
+	verifier    *auth.Verifier
+	sessions    *sessions.Manager
+}
+
+func NewHandler(router *providers.Router, recorder *usage.Recorder, catalog *catalog.Catalog, pricer *pricing.Pricer, aliases *aliases.Store, credentials *credentials.Store, apiKeys *apikeys.Store, accounts *accounts.Store, tokens *auth.Issuer, verifier *auth.Verifier, sessions *sessions.Manager) *handler {
+	return &handler{
+		router:      router,
+		recorder:    recorder,
+		catalog:     catalog,
+		pricer:      pricer,
+		aliases:     aliases,
+		credentials: credentials,
+		apiKeys:     apiKeys,
+		accounts:    accounts,
+		tokens:      tokens,
+		verifier:    verifier,
+		sessions:    sessions,
+	}
+}
+
+// currentClaims returns the verified identity of the caller, set by the authentication
+// middleware
+func currentClaims(c echo.Context) (*auth.Claims, error) {
+	claims, ok := middleware.ClaimsFrom(c)
+	if !ok {
+		return nil, echo.NewHTTPError(http.StatusUnauthorized, "authentication required")
+	}
+	return claims, nil
 }
 
-func NewHandler() *handler {
-	return &handler{}
+// HeaderProvider is the response header naming the provider that served a request
+const HeaderProvider = "X-Provider"
+
+// requestContext returns the context requests are routed with. It carries the caller's
+// organization, set by authentication, so that the organization's model aliases and
+// provider credentials apply, restricts routing to the models and providers an API key
+// may use, and reports the provider that served the request in the X-Provider response
+// header.
+func requestContext(c echo.Context) context.Context {
+	ctx := c.Request().Context()
+	if organizationID, ok := c.Get(middleware.ContextKeyOrganizationID).(uuid.UUID); ok {
+		ctx = providers.WithOrganization(ctx, organizationID)
+	}
+	if claims, ok := middleware.ClaimsFrom(c); ok {
+		if permissions, ok := auth.PermissionsOf(claims); ok && permissions.RestrictsRoutes() {
+			ctx = providers.WithRouteFilter(ctx, func(provider, model string) bool {
+				return permissions.AllowsProvider(provider) && permissions.AllowsModel(model)
+			})
+		}
+	}
+	return providers.WithAttemptObserver(ctx, func(attempt providers.Attempt) {
+		if attempt.Err == nil && !c.Response().Committed {
+			c.Response().Header().Set(HeaderProvider, attempt.Provider)
+		}
+	})
 }
@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+
+	"ai-aggregator-service/internal/middleware"
+	"ai-aggregator-service/internal/models"
+	"ai-aggregator-service/internal/providers"
+	"ai-aggregator-service/internal/usage"
+
+	"github.com/labstack/echo/v4"
+)
+
+// requestLog tracks the api_requests row of an in-flight request
+type requestLog struct {
+	recorder *usage.Recorder
+	request  *models.APIRequest
+}
+
+// startRequestLog records the incoming request and the number of images it sends, which
+// some models charge per image. Logging is best effort, so a nil log is returned (and
+// safely ignored by its methods) when recording fails.
+func (h *handler) startRequestLog(c echo.Context, model string, stream bool, images int) *requestLog {
+	if h.recorder == nil {
+		return nil
+	}
+
+	req := c.Request()
+	info := usage.RequestInfo{
+		RequestID:      c.Response().Header().Get(echo.HeaderXRequestID),
+		OrganizationID: providers.OrganizationFrom(requestContext(c)),
+		Method:         req.Method,
+		Endpoint:       req.URL.Path,
+		Model:          model,
+		Stream:         stream,
+		Images:         images,
+		IPAddress:      c.RealIP(),
+		UserAgent:      req.UserAgent(),
+	}
+	if claims, ok := middleware.ClaimsFrom(c); ok {
+		info.APIKeyID = claims.APIKeyID
+		info.UserID = claims.UserID
+	}
+
+	request, err := h.recorder.Start(context.WithoutCancel(req.Context()), info)
+	if err != nil {
+		slog.Warn("Failed to record API request", "error", err)
+		return nil
+	}
+
+	return &requestLog{recorder: h.recorder, request: request}
+}
+
+// countImages returns the number of image parts in the request messages
+func countImages(req *providers.Request) int {
+	images := 0
+	for _, msg := range req.Messages {
+		for _, part := range msg.Content {
+			if part.Type == providers.ContentPartImageURL {
+				images++
+			}
+		}
+	}
+	return images
+}
+
+// observe returns a context that records every provider attempt against the request
+func (l *requestLog) observe(ctx context.Context) context.Context {
+	if l == nil {
+		return ctx
+	}
+
+	return providers.WithAttemptObserver(ctx, func(attempt providers.Attempt) {
+		if err := l.recorder.RecordAttempt(context.WithoutCancel(ctx), l.request, attempt); err != nil {
+			slog.Warn("Failed to record provider attempt", "provider", attempt.Provider, "error", err)
+		}
+	})
+}
+
+// complete records the final outcome of the request
+func (l *requestLog) complete(ctx context.Context, u *providers.Usage, statusCode int, err error) {
+	if l == nil {
+		return
+	}
+
+	if recErr := l.recorder.Complete(context.WithoutCancel(ctx), l.request, u, statusCode, err); recErr != nil {
+		slog.Warn("Failed to complete API request record", "error", recErr)
+	}
+}
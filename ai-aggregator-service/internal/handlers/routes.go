@@ -3,16 +3,14 @@ package handlers
 import (
 	"net/http"
 
+	"ai-aggregator-service/internal/auth"
 	"ai-aggregator-service/internal/middleware"
 
 	"github.com/labstack/echo/v4"
 )
 
 // SetupRoutes configures all API routes for the AI Aggregator Service
-func SetupRoutes(e *echo.Echo) {
-	// Initialize handlers
-	handler := NewHandler()
-
+func SetupRoutes(e *echo.Echo, handler *handler, verifier *auth.Verifier) {
 	// Health check endpoint (public)
 	e.GET("/health", func(c echo.Context) error {
 		return c.JSON(http.StatusOK, map[string]interface{}{
@@ -29,32 +27,46 @@ func SetupRoutes(e *echo.Echo) {
 	public := v1.Group("")
 	{
 		// Auth routes
-		auth := public.Group("/auth")
+		session := public.Group("/auth")
 		{
-			auth.POST("/login", handler.Login)
-			auth.POST("/register", handler.Register)
-			auth.POST("/refresh", handler.RefreshToken)
-			auth.POST("/logout", handler.Logout)
-			auth.POST("/forgot-password", handler.ForgotPassword)
-			auth.POST("/reset-password", handler.ResetPassword)
+			session.POST("/login", handler.Login)
+			session.POST("/register", handler.Register)
+			session.POST("/refresh", handler.RefreshToken)
+			session.POST("/logout", handler.Logout)
+			session.POST("/forgot-password", handler.ForgotPassword)
+			session.POST("/reset-password", handler.ResetPassword)
 		}
 
-		// OpenAI-compatible API routes (public access with API key)
-		openai := public.Group("/openai")
+		// OpenAI-compatible API routes (access with a gateway API key)
+		openai := public.Group("/openai", middleware.APIKeyMiddleware(handler.apiKeys))
 		{
 			openai.GET("/models", handler.ListModels)
-			openai.POST("/chat/completions", handler.ChatCompletions)
-			openai.POST("/completions", handler.Completions)
-			openai.POST("/embeddings", handler.Embeddings)
+			openai.GET("/models/:model_id", handler.GetModel)
+			openai.POST("/chat/completions", handler.ChatCompletions, middleware.RequireScope(auth.ScopeChat))
+			openai.POST("/completions", handler.Completions, middleware.RequireScope(auth.ScopeCompletions))
+			openai.POST("/embeddings", handler.Embeddings, middleware.RequireScope(auth.ScopeEmbeddings))
+		}
+
+		// Anthropic-compatible API routes, for clients using base_url .../api/v1/anthropic
+		anthropic := public.Group("/anthropic", messagesErrors, middleware.APIKeyMiddleware(handler.apiKeys))
+		{
+			anthropic.POST("/v1/messages", handler.Messages, middleware.RequireScope(auth.ScopeChat))
 		}
 	}
 
-	// Protected routes (require authentication)
+	// Protected routes (require authentication, with a token or a gateway API key granted
+	// the route's scope)
 	protected := v1.Group("")
-	protected.Use(middleware.AuthMiddleware())
+	protected.Use(middleware.AuthMiddleware(verifier, handler.sessions, handler.apiKeys))
 	{
+		// Auth routes for signed-in users
+		authenticated := protected.Group("/auth", middleware.DenyAPIKeys)
+		{
+			authenticated.POST("/logout-all", handler.LogoutAll)
+		}
+
 		// User management routes
-		users := protected.Group("/users")
+		users := protected.Group("/users", middleware.DenyAPIKeys)
 		{
 			users.GET("/profile", handler.GetProfile)
 			users.PUT("/profile", handler.UpdateProfile)
@@ -64,12 +76,24 @@ func SetupRoutes(e *echo.Echo) {
 			{
 				apiKeys.GET("", handler.ListAPIKeys)
 				apiKeys.POST("", handler.CreateAPIKey)
-				apiKeys.PUT("/:id", handler.UpdateAPIKey)
+				apiKeys.GET("/:key_id", handler.GetAPIKey)
+				apiKeys.PUT("/:key_id", handler.UpdateAPIKey)
+				apiKeys.DELETE("/:key_id", handler.RevokeAPIKey)
 			}
 		}
 
+		// Organization routes
+		organization := protected.Group("/organization", middleware.DenyAPIKeys)
+		{
+			// Bring-your-own-key provider credentials, which only the organization's owner
+			// may change since they serve every member's requests
+			organization.GET("/credentials", handler.ListProviderCredentials)
+			organization.PUT("/credentials/:provider", handler.SetProviderCredential, middleware.RequireRole(auth.RoleOwner, auth.RoleAdmin))
+			organization.DELETE("/credentials/:provider", handler.DeleteProviderCredential, middleware.RequireRole(auth.RoleOwner, auth.RoleAdmin))
+		}
+
 		// Billing routes
-		billing := protected.Group("/billing")
+		billing := protected.Group("/billing", middleware.RequireScope(auth.ScopeBillingRead))
 		{
 			billing.GET("/usage", handler.GetUsage)
 
@@ -99,17 +123,21 @@ func SetupRoutes(e *echo.Echo) {
 		gateway := protected.Group("/gateway")
 		{
 			gateway.GET("/models", handler.ListModels)
+			gateway.GET("/models/:model_id", handler.GetModel)
 
 			// Provider-specific endpoints
 			providers := gateway.Group("/providers")
 			{
-				providers.POST("/:provider/chat/completions", handler.ChatCompletions)
+				providers.POST("/:provider/chat/completions", handler.ChatCompletions, middleware.RequireScope(auth.ScopeChat))
 
 				// Completion endpoints
-				providers.POST("/:provider/completions", handler.Completions)
+				providers.POST("/:provider/completions", handler.Completions, middleware.RequireScope(auth.ScopeCompletions))
 
 				// Embedding endpoints
-				providers.POST("/:provider/embeddings", handler.Embeddings)
+				providers.POST("/:provider/embeddings", handler.Embeddings, middleware.RequireScope(auth.ScopeEmbeddings))
+
+				// Anthropic Messages API endpoints
+				providers.POST("/:provider/messages", handler.Messages, messagesErrors, middleware.RequireScope(auth.ScopeChat))
 			}
 		}
 
@@ -117,15 +145,35 @@ func SetupRoutes(e *echo.Echo) {
 		unified := protected.Group("/unified")
 		{
 			unified.GET("/models", handler.ListModels)
-			unified.POST("/chat/completions", handler.ChatCompletions)
-			unified.POST("/completions", handler.Completions)
-			unified.POST("/embeddings", handler.Embeddings)
+			unified.GET("/models/:model_id", handler.GetModel)
+			unified.POST("/chat/completions", handler.ChatCompletions, middleware.RequireScope(auth.ScopeChat))
+			unified.POST("/completions", handler.Completions, middleware.RequireScope(auth.ScopeCompletions))
+			unified.POST("/embeddings", handler.Embeddings, middleware.RequireScope(auth.ScopeEmbeddings))
+			unified.POST("/messages", handler.Messages, messagesErrors, middleware.RequireScope(auth.ScopeChat))
 		}
 	}
 
-	// Admin routes (require admin role)
+	// Admin routes (require admin role and, for API keys, the admin scope)
 	admin := v1.Group("/admin")
-	admin.Use(middleware.AuthMiddleware())
+	admin.Use(middleware.AuthMiddleware(verifier, handler.sessions, handler.apiKeys), middleware.RequireRole(auth.RoleAdmin), middleware.RequireScope(auth.ScopeAdmin))
+	{
+		// Model catalog management
+		admin.POST("/models/sync", handler.SyncModels)
+		admin.PUT("/models/:model_id/pricing", handler.SetModelPrice)
+
+		// Pricing management
+		admin.POST("/pricing/reprice", handler.RepriceRequests)
+
+		// Model alias management
+		admin.GET("/aliases", handler.ListModelAliases)
+		admin.PUT("/aliases/:alias", handler.SetModelAlias)
+		admin.DELETE("/aliases/:alias", handler.DeleteModelAlias)
+
+		// Organization provider credential management
+		admin.GET("/organizations/:organization_id/credentials", handler.ListProviderCredentials)
+		admin.PUT("/organizations/:organization_id/credentials/:provider", handler.SetProviderCredential)
+		admin.DELETE("/organizations/:organization_id/credentials/:provider", handler.DeleteProviderCredential)
+	}
 }
 
 // SetupTestRoutes configures test routes for development/testing
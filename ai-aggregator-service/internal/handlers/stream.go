@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"ai-aggregator-service/internal/providers"
+
+	"github.com/labstack/echo/v4"
+)
+
+// sseStream renders the chunks of a provider stream as the Server-Sent Events of one API
+type sseStream[T any] struct {
+	recv   func() (T, error)        // next chunk, or io.EOF once the stream has finished
+	usage  func(T) *providers.Usage // usage reported by a chunk, or nil
+	write  func(T) error            // sends a chunk
+	fail   func(error) error        // reports a stream failure in-band
+	finish func() error             // ends a stream that completed
+}
+
+// relaySSE sends the Server-Sent Event headers and relays the stream to the client,
+// completing the request log with the last usage the stream reported
+func relaySSE[T any](ctx context.Context, res *echo.Response, log *requestLog, model string, s sseStream[T]) error {
+	res.Header().Set(echo.HeaderContentType, "text/event-stream")
+	res.Header().Set(echo.HeaderCacheControl, "no-cache")
+	res.Header().Set(echo.HeaderConnection, "keep-alive")
+	res.Header().Set("X-Accel-Buffering", "no")
+	res.WriteHeader(http.StatusOK)
+
+	var streamUsage *providers.Usage
+	for {
+		chunk, err := s.recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			log.complete(ctx, streamUsage, http.StatusOK, err)
+
+			// The client disconnected and cancelled the upstream request
+			if ctx.Err() != nil {
+				return nil
+			}
+
+			// Headers are already sent, so the error is reported in-band
+			slog.Error("Provider stream failed", "model", model, "error", err)
+			return s.fail(err)
+		}
+
+		if usage := s.usage(chunk); usage != nil {
+			streamUsage = usage
+		}
+
+		if err := s.write(chunk); err != nil {
+			log.complete(ctx, streamUsage, http.StatusOK, err)
+			return nil
+		}
+	}
+
+	log.complete(ctx, streamUsage, http.StatusOK, nil)
+
+	// A failed write means the client went away, so there is nobody left to tell
+	_ = s.finish()
+	return nil
+}
+
+// openAIStream renders chunks as OpenAI-compatible events, ending with data: [DONE]
+func openAIStream[T any](res *echo.Response, recv func() (T, error), usage func(T) *providers.Usage) sseStream[T] {
+	return sseStream[T]{
+		recv:  recv,
+		usage: usage,
+		write: func(chunk T) error {
+			return writeSSE(res, chunk)
+		},
+		fail: func(err error) error {
+			_, apiErr := errorResponse(err)
+			return writeSSE(res, map[string]interface{}{"error": apiErr})
+		},
+		finish: func() error {
+			if _, err := fmt.Fprint(res, "data: [DONE]\n\n"); err != nil {
+				return err
+			}
+			res.Flush()
+			return nil
+		},
+	}
+}
+
+// writeSSE writes a JSON payload as a single Server-Sent Event and flushes it
+func writeSSE(res *echo.Response, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(res, "data: %s\n\n", data); err != nil {
+		return err
+	}
+	res.Flush()
+	return nil
+}
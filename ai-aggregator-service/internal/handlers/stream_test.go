@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"ai-aggregator-service/internal/providers"
+
+	"github.com/labstack/echo/v4"
+)
+
+// chunkRecv returns the chunks one by one, then err
+func chunkRecv(err error, chunks ...*providers.StreamResponse) func() (*providers.StreamResponse, error) {
+	return func() (*providers.StreamResponse, error) {
+		if len(chunks) == 0 {
+			return nil, err
+		}
+		chunk := chunks[0]
+		chunks = chunks[1:]
+		return chunk, nil
+	}
+}
+
+func chunkUsage(chunk *providers.StreamResponse) *providers.Usage { return chunk.Usage }
+
+func TestRelaySSE(t *testing.T) {
+	overloaded := &providers.Error{Kind: providers.ErrorKindUpstreamUnavailable, Provider: "openai", StatusCode: 503, Message: "overloaded"}
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		err  error // ending the stream
+		want string
+	}{
+		{
+			name: "completed",
+			ctx:  context.Background(),
+			err:  io.EOF,
+			want: "data: {\"id\":\"1\",\"object\":\"\",\"created\":0,\"model\":\"\",\"choices\":null}\n\ndata: [DONE]\n\n",
+		},
+		{
+			name: "failed",
+			ctx:  context.Background(),
+			err:  overloaded,
+			want: "data: {\"id\":\"1\",\"object\":\"\",\"created\":0,\"model\":\"\",\"choices\":null}\n\n" +
+				"data: {\"error\":{\"message\":\"overloaded\",\"type\":\"server_error\",\"code\":\"upstream_unavailable\",\"param\":null}}\n\n",
+		},
+		{
+			name: "cancelled by the client",
+			ctx:  cancelled,
+			err:  context.Canceled,
+			want: "data: {\"id\":\"1\",\"object\":\"\",\"created\":0,\"model\":\"\",\"choices\":null}\n\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			c := echo.New().NewContext(httptest.NewRequest(http.MethodPost, "/", nil), rec)
+
+			stream := openAIStream(c.Response(), chunkRecv(tt.err, &providers.StreamResponse{ID: "1"}), chunkUsage)
+			if err := relaySSE(tt.ctx, c.Response(), nil, "gpt-4o", stream); err != nil {
+				t.Fatalf("relaySSE() error = %v", err)
+			}
+
+			if got := rec.Header().Get(echo.HeaderContentType); got != "text/event-stream" {
+				t.Errorf("Content-Type = %q, want text/event-stream", got)
+			}
+			if got := rec.Body.String(); got != tt.want {
+				t.Errorf("body = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,26 +1,49 @@
 package handlers
 
 import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
 	"net/http"
+	"slices"
 	"time"
 
+	"ai-aggregator-service/internal/catalog"
+	"ai-aggregator-service/internal/models"
+	"ai-aggregator-service/internal/pricing"
+	"ai-aggregator-service/internal/providers"
+
 	"github.com/labstack/echo/v4"
 )
 
 // ChatCompletionsRequest represents the request structure for chat completions
 type ChatCompletionsRequest struct {
-	Model       string        `json:"model" validate:"required"`
-	Messages    []ChatMessage `json:"messages" validate:"required"`
-	MaxTokens   int           `json:"max_tokens,omitempty"`
-	Temperature float64       `json:"temperature,omitempty"`
-	TopP        float64       `json:"top_p,omitempty"`
-	Stream      bool          `json:"stream,omitempty"`
+	Model       string                `json:"model" validate:"required"`
+	Messages    []ChatMessage         `json:"messages" validate:"required"`
+	MaxTokens   int                   `json:"max_tokens,omitempty"`
+	Temperature float64               `json:"temperature,omitempty"`
+	TopP        float64               `json:"top_p,omitempty"`
+	Stream      bool                  `json:"stream,omitempty"`
+	Stop        []string              `json:"stop,omitempty"`
+	Tools       []providers.Tool      `json:"tools,omitempty"`
+	ToolChoice  *providers.ToolChoice `json:"tool_choice,omitempty"`
+
+	// Metadata carries routing hints: routing_policy, min_context_window and
+	// required_capabilities
+	Metadata map[string]interface{} `json:"metadata,omitempty"`
 }
 
-// ChatMessage represents a message in the chat
+// ChatMessage represents a message in the chat. Content is a string or an array of
+// content parts, and may be empty on assistant messages that carry tool calls.
 type ChatMessage struct {
-	Role    string `json:"role" validate:"required,oneof=system user assistant"`
-	Content string `json:"content" validate:"required"`
+	Role       string               `json:"role" validate:"required,oneof=system user assistant tool"`
+	Content    providers.Content    `json:"content"`
+	ToolCalls  []providers.ToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string               `json:"tool_call_id,omitempty"`
 }
 
 // ChatCompletionsResponse represents the response structure for chat completions
@@ -42,35 +65,40 @@ type ChatChoice struct {
 
 // CompletionsRequest represents the request structure for text completions
 type CompletionsRequest struct {
-	Model       string  `json:"model" validate:"required"`
-	Prompt      string  `json:"prompt" validate:"required"`
-	MaxTokens   int     `json:"max_tokens,omitempty"`
-	Temperature float64 `json:"temperature,omitempty"`
-	TopP        float64 `json:"top_p,omitempty"`
-	Stream      bool    `json:"stream,omitempty"`
+	Model       string     `json:"model" validate:"required"`
+	Prompt      StringList `json:"prompt" validate:"required"`
+	Suffix      string     `json:"suffix,omitempty"`
+	MaxTokens   int        `json:"max_tokens,omitempty"`
+	Temperature float64    `json:"temperature,omitempty"`
+	TopP        float64    `json:"top_p,omitempty"`
+	N           int        `json:"n,omitempty"`
+	Stream      bool       `json:"stream,omitempty"`
+	Logprobs    *int       `json:"logprobs,omitempty"`
+	Echo        bool       `json:"echo,omitempty"`
+	Stop        StringList `json:"stop,omitempty"`
 }
 
-// CompletionsResponse represents the response structure for text completions
-type CompletionsResponse struct {
-	ID      string                 `json:"id"`
-	Object  string                 `json:"object"`
-	Created int64                  `json:"created"`
-	Model   string                 `json:"model"`
-	Choices []CompletionChoice     `json:"choices"`
-	Usage   map[string]interface{} `json:"usage"`
+// EmbeddingsRequest represents the request structure for embeddings
+type EmbeddingsRequest struct {
+	Model          string     `json:"model" validate:"required"`
+	Input          StringList `json:"input" validate:"required"`
+	Dimensions     int        `json:"dimensions,omitempty"`
+	EncodingFormat string     `json:"encoding_format,omitempty" validate:"omitempty,oneof=float base64"`
+	InputType      string     `json:"input_type,omitempty"` // e.g. search_query or search_document, for Cohere
 }
 
-// CompletionChoice represents a choice in the completion response
-type CompletionChoice struct {
-	Text         string `json:"text"`
-	Index        int    `json:"index"`
-	FinishReason string `json:"finish_reason"`
-}
+// StringList is a list of strings that may also be given as a single string
+type StringList []string
 
-// EmbeddingsRequest represents the request structure for embeddings
-type EmbeddingsRequest struct {
-	Model string   `json:"model" validate:"required"`
-	Input []string `json:"input" validate:"required"`
+// UnmarshalJSON implements json.Unmarshaler
+func (l *StringList) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		*l = StringList{text}
+		return nil
+	}
+
+	return json.Unmarshal(data, (*[]string)(l))
 }
 
 // EmbeddingsResponse represents the response structure for embeddings
@@ -81,217 +109,487 @@ type EmbeddingsResponse struct {
 	Usage  map[string]interface{} `json:"usage"`
 }
 
-// EmbeddingData represents embedding data
+// EmbeddingData represents embedding data. Embedding is an array of floats, or a base64
+// string of little-endian float32 values when base64 encoding was requested.
 type EmbeddingData struct {
-	Object    string    `json:"object"`
-	Embedding []float64 `json:"embedding"`
-	Index     int       `json:"index"`
+	Object    string      `json:"object"`
+	Embedding interface{} `json:"embedding"`
+	Index     int         `json:"index"`
 }
 
-// Model represents a model in the system
+// Model represents a model in the system. Besides the OpenAI fields it exposes the
+// catalog details clients need to pick a model; pricing is null for unpriced models.
 type Model struct {
-	ID      string `json:"id"`
-	Object  string `json:"object"`
-	Created int64  `json:"created"`
-	OwnedBy string `json:"owned_by"`
+	ID            string        `json:"id"`
+	Object        string        `json:"object"`
+	Created       int64         `json:"created"`
+	OwnedBy       string        `json:"owned_by"`
+	DisplayName   string        `json:"display_name"`
+	ModelType     string        `json:"model_type"`
+	ContextWindow int           `json:"context_window,omitempty"`
+	MaxTokens     int           `json:"max_tokens,omitempty"`
+	Capabilities  []string      `json:"capabilities"`
+	Pricing       *ModelPricing `json:"pricing"`
+}
+
+// ModelPricing holds the current price of a model, per 1K tokens unless noted otherwise
+type ModelPricing struct {
+	InputCostPer1K  float64 `json:"input_cost_per_1k_tokens"`
+	OutputCostPer1K float64 `json:"output_cost_per_1k_tokens"`
+	pricing.Details
+	Currency string `json:"currency"`
 }
 
 // ChatCompletions handles POST /v1/chat/completions
 func (h *handler) ChatCompletions(c echo.Context) error {
 	var req ChatCompletionsRequest
 	if err := c.Bind(&req); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]interface{}{
-			"error": map[string]interface{}{
-				"code":    "INVALID_REQUEST",
-				"message": "Invalid request format",
-			},
-		})
+		return invalidRequest("", "Invalid request format")
+	}
+
+	if err := req.validate(); err != nil {
+		return err
+	}
+
+	providerReq := req.toProviderRequest()
+	if err := h.checkCapabilities(requestContext(c), providerReq); err != nil {
+		return err
 	}
 
-	// TODO: Validate request
-	// TODO: Route to appropriate provider
 	// TODO: Handle rate limiting
 	// TODO: Handle billing
 
-	// Mock response for now
-	response := ChatCompletionsResponse{
-		ID:      "chatcmpl-" + generateID(),
-		Object:  "chat.completion",
-		Created: time.Now().Unix(),
-		Model:   req.Model,
-		Choices: []ChatChoice{
-			{
-				Index: 0,
-				Message: ChatMessage{
-					Role:    "assistant",
-					Content: "This is a mock response. Implementation pending.",
-				},
-				FinishReason: "stop",
-			},
-		},
-		Usage: map[string]interface{}{
-			"prompt_tokens":     10,
-			"completion_tokens": 5,
-			"total_tokens":      15,
-		},
+	log := h.startRequestLog(c, req.Model, req.Stream, countImages(providerReq))
+	ctx := log.observe(requestContext(c))
+
+	if req.Stream {
+		return h.streamChatCompletions(ctx, c, log, providerReq)
 	}
 
-	return c.JSON(http.StatusOK, response)
+	// The :provider path parameter is only set on gateway routes and forces a backend
+	resp, err := h.router.SendRequest(ctx, providerReq, c.Param("provider"))
+	if err != nil {
+		log.complete(ctx, nil, errorStatus(err), err)
+		return err
+	}
+
+	log.complete(ctx, &resp.Usage, http.StatusOK, nil)
+	return c.JSON(http.StatusOK, resp)
+}
+
+// streamChatCompletions relays a provider stream to the client as OpenAI-compatible Server-Sent Events
+func (h *handler) streamChatCompletions(ctx context.Context, c echo.Context, log *requestLog, req *providers.Request) error {
+	stream, err := h.router.SendStreamRequest(ctx, req, c.Param("provider"))
+	if err != nil {
+		log.complete(ctx, nil, errorStatus(err), err)
+		return err
+	}
+	defer stream.Close()
+
+	return relaySSE(ctx, c.Response(), log, req.Model, openAIStream(c.Response(), stream.Recv,
+		func(chunk *providers.StreamResponse) *providers.Usage { return chunk.Usage }))
+}
+
+// validate checks the fields the bind step cannot enforce
+func (r *ChatCompletionsRequest) validate() error {
+	if r.Model == "" {
+		return invalidRequest("model", "model is required")
+	}
+	if len(r.Messages) == 0 {
+		return invalidRequest("messages", "messages must contain at least one message")
+	}
+	for i, msg := range r.Messages {
+		switch msg.Role {
+		case "system", "user", "assistant", "tool":
+		default:
+			return invalidRequest(fmt.Sprintf("messages[%d].role", i), "messages[%d].role must be one of system, user, assistant, tool", i)
+		}
+		for j, part := range msg.Content {
+			if err := validateContentPart(msg.Role, part); err != nil {
+				return invalidRequest(fmt.Sprintf("messages[%d].content[%d]", i, j), "messages[%d].content[%d]: %s", i, j, err)
+			}
+		}
+		if msg.Role == "tool" && msg.ToolCallID == "" {
+			return invalidRequest(fmt.Sprintf("messages[%d].tool_call_id", i), "messages[%d].tool_call_id is required for tool messages", i)
+		}
+		if msg.Role != "assistant" && len(msg.ToolCalls) > 0 {
+			return invalidRequest(fmt.Sprintf("messages[%d].tool_calls", i), "messages[%d].tool_calls is only allowed on assistant messages", i)
+		}
+		for j, call := range msg.ToolCalls {
+			if call.ID == "" || call.Function.Name == "" {
+				return invalidRequest(fmt.Sprintf("messages[%d].tool_calls[%d]", i, j), "messages[%d].tool_calls[%d] must have an id and a function name", i, j)
+			}
+		}
+	}
+	return r.validateTools()
+}
+
+// validateContentPart checks that a content part is of a known type, carries the field of
+// that type, and that only user messages carry media
+func validateContentPart(role string, part providers.ContentPart) error {
+	switch part.Type {
+	case providers.ContentPartText:
+		return nil
+	case providers.ContentPartImageURL:
+		if part.ImageURL == nil || part.ImageURL.URL == "" {
+			return fmt.Errorf("image_url.url is required")
+		}
+	case providers.ContentPartInputAudio:
+		if part.InputAudio == nil || part.InputAudio.Data == "" || part.InputAudio.Format == "" {
+			return fmt.Errorf("input_audio.data and input_audio.format are required")
+		}
+	case providers.ContentPartFile:
+		if part.File == nil || (part.File.FileData == "" && part.File.FileID == "") {
+			return fmt.Errorf("file.file_data or file.file_id is required")
+		}
+	default:
+		return fmt.Errorf("type must be one of text, image_url, input_audio, file")
+	}
+
+	if role != "user" {
+		return fmt.Errorf("%s parts are only allowed in user messages", part.Type)
+	}
+	return nil
+}
+
+// checkCapabilities rejects media the requested model, or the model its alias stands for,
+// cannot accept according to the capabilities recorded in the model catalog. Models
+// missing from the catalog are not checked.
+func (h *handler) checkCapabilities(ctx context.Context, req *providers.Request) error {
+	required := catalog.RequiredCapabilities(req)
+	if len(required) == 0 || h.catalog == nil {
+		return nil
+	}
+
+	model := h.router.ResolveModel(ctx, req.Model)
+	capabilities, found, err := h.catalog.Capabilities(ctx, model)
+	if err != nil {
+		slog.Warn("Failed to check model capabilities", "model", model, "error", err)
+		return nil
+	}
+	if !found {
+		return nil
+	}
+
+	for _, capability := range required {
+		if !slices.Contains(capabilities, capability) {
+			return invalidRequest("messages", "model %s does not support %s input", req.Model, capability)
+		}
+	}
+	return nil
+}
+
+// validateTools checks the tool definitions and that the tool choice refers to one of them
+func (r *ChatCompletionsRequest) validateTools() error {
+	names := make(map[string]bool, len(r.Tools))
+	for i, tool := range r.Tools {
+		if tool.Type != "function" {
+			return invalidRequest(fmt.Sprintf("tools[%d].type", i), "tools[%d].type must be function", i)
+		}
+		if tool.Function.Name == "" {
+			return invalidRequest(fmt.Sprintf("tools[%d].function.name", i), "tools[%d].function.name is required", i)
+		}
+		names[tool.Function.Name] = true
+	}
+
+	if r.ToolChoice == nil {
+		return nil
+	}
+	if r.ToolChoice.Function != "" {
+		if !names[r.ToolChoice.Function] {
+			return invalidRequest("tool_choice", "tool_choice names unknown function %s", r.ToolChoice.Function)
+		}
+		return nil
+	}
+	switch r.ToolChoice.Mode {
+	case "none":
+	case "auto", "required":
+		if len(r.Tools) == 0 {
+			return invalidRequest("tool_choice", "tool_choice %s requires tools", r.ToolChoice.Mode)
+		}
+	default:
+		return invalidRequest("tool_choice", "tool_choice must be one of none, auto, required or a function")
+	}
+	return nil
 }
 
-// Completions handles POST /v1/completions
+// toProviderRequest converts the chat completions request to the unified provider request
+func (r *ChatCompletionsRequest) toProviderRequest() *providers.Request {
+	messages := make([]providers.Message, 0, len(r.Messages))
+	for _, msg := range r.Messages {
+		messages = append(messages, providers.Message{
+			Role:       msg.Role,
+			Content:    msg.Content,
+			ToolCalls:  msg.ToolCalls,
+			ToolCallID: msg.ToolCallID,
+		})
+	}
+
+	return &providers.Request{
+		Model:       r.Model,
+		Messages:    messages,
+		MaxTokens:   r.MaxTokens,
+		Temperature: r.Temperature,
+		TopP:        r.TopP,
+		Stream:      r.Stream,
+		Stop:        r.Stop,
+		Tools:       r.Tools,
+		ToolChoice:  r.ToolChoice,
+		Metadata:    r.Metadata,
+	}
+}
+
+// Completions handles POST /v1/completions. Models without a native completions API
+// are served by wrapping the prompt into a chat turn.
 func (h *handler) Completions(c echo.Context) error {
 	var req CompletionsRequest
 	if err := c.Bind(&req); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]interface{}{
-			"error": map[string]interface{}{
-				"code":    "INVALID_REQUEST",
-				"message": "Invalid request format",
-			},
-		})
+		return invalidRequest("", "Invalid request format")
+	}
+
+	if err := req.validate(); err != nil {
+		return err
 	}
 
-	// TODO: Validate request
-	// TODO: Route to appropriate provider
 	// TODO: Handle rate limiting
 	// TODO: Handle billing
 
-	// Mock response for now
-	response := CompletionsResponse{
-		ID:      "cmpl-" + generateID(),
-		Object:  "text_completion",
-		Created: time.Now().Unix(),
-		Model:   req.Model,
-		Choices: []CompletionChoice{
-			{
-				Text:         "This is a mock completion response. Implementation pending.",
-				Index:        0,
-				FinishReason: "stop",
-			},
-		},
-		Usage: map[string]interface{}{
-			"prompt_tokens":     5,
-			"completion_tokens": 10,
-			"total_tokens":      15,
-		},
+	log := h.startRequestLog(c, req.Model, req.Stream, 0)
+	ctx := log.observe(requestContext(c))
+
+	providerReq := req.toProviderRequest()
+	if req.Stream {
+		return h.streamCompletions(ctx, c, log, providerReq)
 	}
 
-	return c.JSON(http.StatusOK, response)
+	resp, err := h.router.CreateCompletion(ctx, providerReq, c.Param("provider"))
+	if err != nil {
+		log.complete(ctx, nil, errorStatus(err), err)
+		return err
+	}
+
+	log.complete(ctx, resp.Usage, http.StatusOK, nil)
+	return c.JSON(http.StatusOK, resp)
+}
+
+// streamCompletions relays a text completion stream to the client as Server-Sent Events
+func (h *handler) streamCompletions(ctx context.Context, c echo.Context, log *requestLog, req *providers.CompletionRequest) error {
+	stream, err := h.router.CreateCompletionStream(ctx, req, c.Param("provider"))
+	if err != nil {
+		log.complete(ctx, nil, errorStatus(err), err)
+		return err
+	}
+	defer stream.Close()
+
+	return relaySSE(ctx, c.Response(), log, req.Model, openAIStream(c.Response(), stream.Recv,
+		func(chunk *providers.CompletionResponse) *providers.Usage { return chunk.Usage }))
+}
+
+// maxCompletionChoices bounds the choices generated for a completions request across all
+// its prompts. Models without native completions make one upstream call per choice.
+const maxCompletionChoices = 128
+
+// validate checks the fields the bind step cannot enforce
+func (r *CompletionsRequest) validate() error {
+	if r.Model == "" {
+		return invalidRequest("model", "model is required")
+	}
+	if len(r.Prompt) == 0 {
+		return invalidRequest("prompt", "prompt must contain at least one string")
+	}
+	if r.N < 0 || r.N > maxCompletionChoices {
+		return invalidRequest("n", fmt.Sprintf("n must be between 1 and %d", maxCompletionChoices))
+	}
+	if len(r.Prompt)*max(r.N, 1) > maxCompletionChoices {
+		return invalidRequest("n", fmt.Sprintf("the number of prompts times n must not exceed %d", maxCompletionChoices))
+	}
+	if r.Logprobs != nil && (*r.Logprobs < 0 || *r.Logprobs > 5) {
+		return invalidRequest("logprobs", "logprobs must be between 0 and 5")
+	}
+	if r.MaxTokens < 0 {
+		return invalidRequest("max_tokens", "max_tokens must be a positive integer")
+	}
+	return nil
+}
+
+// toProviderRequest converts the completions request to the unified provider request
+func (r *CompletionsRequest) toProviderRequest() *providers.CompletionRequest {
+	return &providers.CompletionRequest{
+		Model:       r.Model,
+		Prompt:      r.Prompt,
+		Suffix:      r.Suffix,
+		MaxTokens:   r.MaxTokens,
+		Temperature: r.Temperature,
+		TopP:        r.TopP,
+		N:           r.N,
+		Stream:      r.Stream,
+		Logprobs:    r.Logprobs,
+		Echo:        r.Echo,
+		Stop:        r.Stop,
+	}
 }
 
 // Embeddings handles POST /v1/embeddings
 func (h *handler) Embeddings(c echo.Context) error {
 	var req EmbeddingsRequest
 	if err := c.Bind(&req); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]interface{}{
-			"error": map[string]interface{}{
-				"code":    "INVALID_REQUEST",
-				"message": "Invalid request format",
-			},
-		})
+		return invalidRequest("", "Invalid request format")
+	}
+
+	if err := req.validate(); err != nil {
+		return err
 	}
 
-	// TODO: Validate request
-	// TODO: Route to appropriate provider
 	// TODO: Handle rate limiting
 	// TODO: Handle billing
 
-	// Mock response for now
-	var embeddings []EmbeddingData
-	for i := range req.Input {
-		// Generate mock embedding vector
-		embedding := make([]float64, 1536)
-		for j := range embedding {
-			embedding[j] = 0.1 * float64(j%10)
-		}
+	log := h.startRequestLog(c, req.Model, false, 0)
+	ctx := log.observe(requestContext(c))
+
+	resp, err := h.router.CreateEmbeddings(ctx, &providers.EmbeddingRequest{
+		Model:      req.Model,
+		Input:      req.Input,
+		Dimensions: req.Dimensions,
+		InputType:  req.InputType,
+	}, c.Param("provider"))
+	if err != nil {
+		log.complete(ctx, nil, errorStatus(err), err)
+		return err
+	}
+
+	log.complete(ctx, &resp.Usage, http.StatusOK, nil)
 
-		embeddings = append(embeddings, EmbeddingData{
+	data := make([]EmbeddingData, 0, len(resp.Data))
+	for _, embedding := range resp.Data {
+		var vector interface{} = embedding.Embedding
+		if req.EncodingFormat == "base64" {
+			vector = encodeEmbedding(embedding.Embedding)
+		}
+		data = append(data, EmbeddingData{
 			Object:    "embedding",
-			Embedding: embedding,
-			Index:     i,
+			Embedding: vector,
+			Index:     embedding.Index,
 		})
 	}
 
 	response := EmbeddingsResponse{
 		Object: "list",
-		Data:   embeddings,
-		Model:  req.Model,
+		Data:   data,
+		Model:  resp.Model,
 		Usage: map[string]interface{}{
-			"prompt_tokens": 10,
-			"total_tokens":  10,
+			"prompt_tokens": resp.Usage.PromptTokens,
+			"total_tokens":  resp.Usage.TotalTokens,
 		},
 	}
 
 	return c.JSON(http.StatusOK, response)
 }
 
-// ListModels handles GET /v1/models
+// validate checks the fields the bind step cannot enforce
+func (r *EmbeddingsRequest) validate() error {
+	if r.Model == "" {
+		return invalidRequest("model", "model is required")
+	}
+	if len(r.Input) == 0 {
+		return invalidRequest("input", "input must contain at least one string")
+	}
+	for i, input := range r.Input {
+		if input == "" {
+			return invalidRequest(fmt.Sprintf("input[%d]", i), "input[%d] must not be empty", i)
+		}
+	}
+	if r.Dimensions < 0 {
+		return invalidRequest("dimensions", "dimensions must be a positive integer")
+	}
+	switch r.EncodingFormat {
+	case "", "float", "base64":
+	default:
+		return invalidRequest("encoding_format", "encoding_format must be one of float, base64")
+	}
+	return nil
+}
+
+// encodeEmbedding encodes a vector the way OpenAI does for encoding_format=base64:
+// base64 of the little-endian float32 values
+func encodeEmbedding(vector []float64) string {
+	buf := make([]byte, 4*len(vector))
+	for i, v := range vector {
+		binary.LittleEndian.PutUint32(buf[4*i:], math.Float32bits(float32(v)))
+	}
+	return base64.StdEncoding.EncodeToString(buf)
+}
+
+// ListModels handles GET /v1/models. The list can be narrowed with the model_type,
+// capability and provider query parameters.
 func (h *handler) ListModels(c echo.Context) error {
-	// Mock models list
-	models := []Model{
-		{
-			ID:      "gpt-4",
-			Object:  "model",
-			Created: time.Now().AddDate(-1, 0, 0).Unix(),
-			OwnedBy: "openai",
-		},
-		{
-			ID:      "gpt-3.5-turbo",
-			Object:  "model",
-			Created: time.Now().AddDate(-2, 0, 0).Unix(),
-			OwnedBy: "openai",
-		},
-		{
-			ID:      "claude-3-opus",
-			Object:  "model",
-			Created: time.Now().AddDate(-1, -6, 0).Unix(),
-			OwnedBy: "anthropic",
-		},
-		{
-			ID:      "claude-3-sonnet",
-			Object:  "model",
-			Created: time.Now().AddDate(-1, -3, 0).Unix(),
-			OwnedBy: "anthropic",
-		},
+	data := []Model{}
+	if h.catalog != nil {
+		rows, err := h.catalog.List(c.Request().Context(), catalog.Filter{
+			ModelType:  c.QueryParam("model_type"),
+			Capability: c.QueryParam("capability"),
+			Provider:   c.QueryParam("provider"),
+		})
+		if err != nil {
+			return err
+		}
+		for i := range rows {
+			data = append(data, toModel(&rows[i]))
+		}
 	}
 
 	return c.JSON(http.StatusOK, map[string]interface{}{
 		"object": "list",
-		"data":   models,
+		"data":   data,
 	})
 }
 
-// GetModel handles GET /v1/models/{model_id}
+// GetModel handles GET /v1/models/{model_id}. When several providers serve the model the
+// provider query parameter selects one.
 func (h *handler) GetModel(c echo.Context) error {
 	modelID := c.Param("model_id")
-
-	// Mock model lookup
-	models := map[string]Model{
-		"gpt-4": {
-			ID:      "gpt-4",
-			Object:  "model",
-			Created: time.Now().AddDate(-1, 0, 0).Unix(),
-			OwnedBy: "openai",
-		},
-		"gpt-3.5-turbo": {
-			ID:      "gpt-3.5-turbo",
-			Object:  "model",
-			Created: time.Now().AddDate(-2, 0, 0).Unix(),
-			OwnedBy: "openai",
-		},
+	if h.catalog == nil {
+		return fmt.Errorf("%w: %s", providers.ErrModelNotFound, modelID)
 	}
 
-	model, exists := models[modelID]
-	if !exists {
-		return c.JSON(http.StatusNotFound, map[string]interface{}{
-			"error": map[string]interface{}{
-				"code":    "MODEL_NOT_FOUND",
-				"message": "The model '" + modelID + "' does not exist",
-			},
-		})
+	row, err := h.catalog.Get(c.Request().Context(), modelID, c.QueryParam("provider"))
+	if err != nil {
+		return err
 	}
 
-	return c.JSON(http.StatusOK, model)
+	return c.JSON(http.StatusOK, toModel(row))
+}
+
+// toModel converts a catalog entry into its API representation
+func toModel(row *models.Model) Model {
+	model := Model{
+		ID:            row.Name,
+		Object:        "model",
+		Created:       row.CreatedAt.Unix(),
+		DisplayName:   row.DisplayName,
+		ModelType:     row.ModelType,
+		ContextWindow: row.ContextWindow,
+		MaxTokens:     row.MaxTokens,
+		Capabilities:  row.Capabilities,
+	}
+	if row.InputCostPer1K != nil && row.OutputCostPer1K != nil {
+		details, err := pricing.ParseDetails(row.Pricing)
+		if err != nil {
+			slog.Warn("Ignoring invalid model pricing", "model", row.Name, "error", err)
+		}
+		model.Pricing = &ModelPricing{
+			InputCostPer1K:  *row.InputCostPer1K,
+			OutputCostPer1K: *row.OutputCostPer1K,
+			Details:         details,
+			Currency:        pricing.Currency,
+		}
+	}
+	if row.Provider != nil {
+		model.OwnedBy = row.Provider.Name
+	}
+	if model.Capabilities == nil {
+		model.Capabilities = []string{}
+	}
+	return model
 }
 
 // generateID generates a random ID for responses
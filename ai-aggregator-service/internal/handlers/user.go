@@ -1,9 +1,17 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
+	"slices"
+	"strconv"
 	"time"
 
+	"ai-aggregator-service/internal/apikeys"
+	"ai-aggregator-service/internal/auth"
+	"ai-aggregator-service/internal/models"
+
+	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
 )
 
@@ -48,22 +56,22 @@ type Organization struct {
 
 // APIKey represents an API key
 type APIKey struct {
-	ID          string    `json:"id"`
-	Name        string    `json:"name"`
-	Key         string    `json:"key"`
-	Prefix      string    `json:"prefix"`
-	LastUsed    time.Time `json:"last_used,omitempty"`
-	CreatedAt   time.Time `json:"created_at"`
-	ExpiresAt   time.Time `json:"expires_at,omitempty"`
-	IsActive    bool      `json:"is_active"`
-	Permissions []string  `json:"permissions"`
+	ID          string     `json:"id"`
+	Name        string     `json:"name"`
+	Key         string     `json:"key,omitempty"`
+	Prefix      string     `json:"prefix"`
+	LastUsed    *time.Time `json:"last_used,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	IsActive    bool       `json:"is_active"`
+	Permissions []string   `json:"permissions"`
 }
 
 // CreateAPIKeyRequest represents the create API key request structure
 type CreateAPIKeyRequest struct {
 	Name        string   `json:"name" validate:"required,min=3,max=50"`
-	ExpiresIn   int      `json:"expires_in,omitempty"` // days
-	Permissions []string `json:"permissions,omitempty"`
+	ExpiresIn   int      `json:"expires_in,omitempty"`  // days
+	Permissions []string `json:"permissions,omitempty"` // scopes and constraints, e.g. ["chat", "model:gpt-4o*", "max_tokens:1024"]
 }
 
 // CreateAPIKeyResponse represents the create API key response structure
@@ -84,8 +92,11 @@ type CreateAPIKeyResponse struct {
 // @Failure 500 {object} map[string]interface{} "Internal server error"
 // @Router /users/profile [get]
 func (h *handler) GetProfile(c echo.Context) error {
-	// TODO: Get user ID from context (after authentication middleware)
-	userID := "user_" + generateID()
+	claims, err := currentClaims(c)
+	if err != nil {
+		return err
+	}
+	userID := claims.UserID.String()
 
 	// TODO: Fetch user profile from database
 	// Mock response for now
@@ -97,7 +108,7 @@ func (h *handler) GetProfile(c echo.Context) error {
 		Avatar:    "https://example.com/avatar.jpg",
 		Phone:     "+1234567890",
 		Company:   "Test Company",
-		Role:      "user",
+		Role:      claims.Role,
 		CreatedAt: time.Now().AddDate(-1, 0, 0),
 		UpdatedAt: time.Now(),
 	}
@@ -130,21 +141,25 @@ func (h *handler) UpdateProfile(c echo.Context) error {
 		})
 	}
 
+	claims, err := currentClaims(c)
+	if err != nil {
+		return err
+	}
+
 	// TODO: Validate request
-	// TODO: Get user ID from context
 	// TODO: Update user profile in database
 	// TODO: Check username uniqueness if provided
 
 	// Mock response for now
 	profile := UserProfile{
-		ID:        "user_" + generateID(),
+		ID:        claims.UserID.String(),
 		Email:     "user@example.com",
 		Name:      req.Name,
 		Username:  req.Username,
 		Avatar:    req.Avatar,
 		Phone:     req.Phone,
 		Company:   req.Company,
-		Role:      "user",
+		Role:      claims.Role,
 		CreatedAt: time.Now().AddDate(-1, 0, 0),
 		UpdatedAt: time.Now(),
 	}
@@ -165,7 +180,10 @@ func (h *handler) UpdateProfile(c echo.Context) error {
 // @Failure 500 {object} map[string]interface{} "Internal server error"
 // @Router /users/organizations [get]
 func (h *handler) GetOrganizations(c echo.Context) error {
-	// TODO: Get user ID from context
+	if _, err := currentClaims(c); err != nil {
+		return err
+	}
+
 	// TODO: Fetch user's organizations from database
 
 	// Mock response for now
@@ -205,8 +223,9 @@ func (h *handler) GetOrganizations(c echo.Context) error {
 // @Security BearerAuth
 // @Param api_key body CreateAPIKeyRequest true "API key creation request"
 // @Success 201 {object} CreateAPIKeyResponse "API key created successfully"
-// @Failure 400 {object} map[string]interface{} "Bad request - Invalid request format or validation errors"
+// @Failure 400 {object} map[string]interface{} "Bad request - Invalid request format, validation errors or unknown permissions"
 // @Failure 401 {object} map[string]interface{} "Unauthorized - Invalid or missing authentication token"
+// @Failure 403 {object} map[string]interface{} "Forbidden - Only admins may grant the admin scope"
 // @Failure 500 {object} map[string]interface{} "Internal server error"
 // @Router /users/api-keys [post]
 func (h *handler) CreateAPIKey(c echo.Context) error {
@@ -220,37 +239,51 @@ func (h *handler) CreateAPIKey(c echo.Context) error {
 		})
 	}
 
-	// TODO: Validate request
-	// TODO: Get user ID from context
-	// TODO: Generate API key
-	// TODO: Store API key in database
-	// TODO: Set permissions
+	if len(req.Name) < 3 || len(req.Name) > 50 {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"error": map[string]interface{}{
+				"code":    "INVALID_REQUEST",
+				"message": "name must be between 3 and 50 characters",
+			},
+		})
+	}
+	if req.ExpiresIn < 0 {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"error": map[string]interface{}{
+				"code":    "INVALID_REQUEST",
+				"message": "expires_in must not be negative",
+			},
+		})
+	}
 
-	// Generate mock API key
-	apiKey := "sk_" + generateID() + generateID()
-	prefix := apiKey[:8]
+	claims, err := currentClaims(c)
+	if err != nil {
+		return err
+	}
+	if err := checkKeyPermissions(claims, req.Permissions); err != nil {
+		return err
+	}
 
-	// Mock response for now
-	response := CreateAPIKeyResponse{
-		APIKey: APIKey{
-			ID:        "key_" + generateID(),
-			Name:      req.Name,
-			Key:       apiKey,
-			Prefix:    prefix,
-			CreatedAt: time.Now(),
-			ExpiresAt: time.Now().AddDate(0, 0, req.ExpiresIn),
-			IsActive:  true,
-			Permissions: []string{
-				"chat:read",
-				"chat:write",
-				"completions:read",
-				"completions:write",
-			},
-		},
-		Key: apiKey,
+	key := apikeys.NewKey{
+		Name:           req.Name,
+		UserID:         claims.UserID,
+		OrganizationID: claims.OrganizationID,
+		Permissions:    req.Permissions,
+	}
+	if req.ExpiresIn > 0 {
+		expiresAt := time.Now().AddDate(0, 0, req.ExpiresIn)
+		key.ExpiresAt = &expiresAt
+	}
+
+	row, plaintext, err := h.apiKeys.Create(c.Request().Context(), key)
+	if err != nil {
+		return err
 	}
 
-	return c.JSON(http.StatusCreated, response)
+	return c.JSON(http.StatusCreated, CreateAPIKeyResponse{
+		APIKey: toAPIKey(row),
+		Key:    plaintext,
+	})
 }
 
 // ListAPIKeys handles GET /users/api-keys
@@ -268,45 +301,48 @@ func (h *handler) CreateAPIKey(c echo.Context) error {
 // @Failure 500 {object} map[string]interface{} "Internal server error"
 // @Router /users/api-keys [get]
 func (h *handler) ListAPIKeys(c echo.Context) error {
-	// TODO: Get user ID from context
-	// TODO: Fetch user's API keys from database
-	// TODO: Support pagination
+	claims, err := currentClaims(c)
+	if err != nil {
+		return err
+	}
 
-	// Mock response for now
-	apiKeys := []APIKey{
-		{
-			ID:        "key_" + generateID(),
-			Name:      "Production Key",
-			Prefix:    "sk_abc123",
-			LastUsed:  time.Now().AddDate(0, 0, -1),
-			CreatedAt: time.Now().AddDate(-1, 0, 0),
-			ExpiresAt: time.Now().AddDate(0, 6, 0),
-			IsActive:  true,
-			Permissions: []string{
-				"chat:read",
-				"chat:write",
-				"completions:read",
-				"completions:write",
-			},
-		},
-		{
-			ID:        "key_" + generateID(),
-			Name:      "Development Key",
-			Prefix:    "sk_def456",
-			LastUsed:  time.Now().AddDate(0, 0, -7),
-			CreatedAt: time.Now().AddDate(-2, 0, 0),
-			ExpiresAt: time.Now().AddDate(0, 3, 0),
-			IsActive:  true,
-			Permissions: []string{
-				"chat:read",
-				"chat:write",
-			},
-		},
+	limit, offset := 50, 0
+	if value := c.QueryParam("limit"); value != "" {
+		if limit, err = strconv.Atoi(value); err != nil || limit < 1 || limit > 100 {
+			return c.JSON(http.StatusBadRequest, map[string]interface{}{
+				"error": map[string]interface{}{
+					"code":    "INVALID_REQUEST",
+					"message": "limit must be between 1 and 100",
+				},
+			})
+		}
+	}
+	if value := c.QueryParam("offset"); value != "" {
+		if offset, err = strconv.Atoi(value); err != nil || offset < 0 {
+			return c.JSON(http.StatusBadRequest, map[string]interface{}{
+				"error": map[string]interface{}{
+					"code":    "INVALID_REQUEST",
+					"message": "offset must not be negative",
+				},
+			})
+		}
+	}
+
+	rows, total, err := h.apiKeys.List(c.Request().Context(), claims.UserID, limit, offset)
+	if err != nil {
+		return err
+	}
+
+	apiKeys := make([]APIKey, 0, len(rows))
+	for i := range rows {
+		apiKeys = append(apiKeys, toAPIKey(&rows[i]))
 	}
 
 	return c.JSON(http.StatusOK, map[string]interface{}{
 		"api_keys": apiKeys,
-		"total":    len(apiKeys),
+		"total":    total,
+		"limit":    limit,
+		"offset":   offset,
 	})
 }
 
@@ -327,20 +363,24 @@ func (h *handler) ListAPIKeys(c echo.Context) error {
 // @Failure 500 {object} map[string]interface{} "Internal server error"
 // @Router /users/api-keys/{key_id} [delete]
 func (h *handler) RevokeAPIKey(c echo.Context) error {
-	keyID := c.Param("key_id")
-	if keyID == "" {
+	keyID, err := uuid.Parse(c.Param("key_id"))
+	if err != nil {
 		return c.JSON(http.StatusBadRequest, map[string]interface{}{
 			"error": map[string]interface{}{
 				"code":    "INVALID_REQUEST",
-				"message": "API key ID is required",
+				"message": "Invalid API key ID",
 			},
 		})
 	}
 
-	// TODO: Validate key ID format
-	// TODO: Get user ID from context
-	// TODO: Check if key belongs to user
-	// TODO: Revoke API key in database
+	claims, err := currentClaims(c)
+	if err != nil {
+		return err
+	}
+
+	if err := h.apiKeys.Revoke(c.Request().Context(), claims.UserID, keyID); err != nil {
+		return apiKeyError(err)
+	}
 
 	return c.JSON(http.StatusOK, map[string]interface{}{
 		"message": "API key revoked successfully",
@@ -364,38 +404,27 @@ func (h *handler) RevokeAPIKey(c echo.Context) error {
 // @Failure 500 {object} map[string]interface{} "Internal server error"
 // @Router /users/api-keys/{key_id} [get]
 func (h *handler) GetAPIKey(c echo.Context) error {
-	keyID := c.Param("key_id")
-	if keyID == "" {
+	keyID, err := uuid.Parse(c.Param("key_id"))
+	if err != nil {
 		return c.JSON(http.StatusBadRequest, map[string]interface{}{
 			"error": map[string]interface{}{
 				"code":    "INVALID_REQUEST",
-				"message": "API key ID is required",
+				"message": "Invalid API key ID",
 			},
 		})
 	}
 
-	// TODO: Get user ID from context
-	// TODO: Check if key belongs to user
-	// TODO: Fetch API key from database
+	claims, err := currentClaims(c)
+	if err != nil {
+		return err
+	}
 
-	// Mock response for now
-	apiKey := APIKey{
-		ID:        keyID,
-		Name:      "Production Key",
-		Prefix:    "sk_abc123",
-		LastUsed:  time.Now().AddDate(0, 0, -1),
-		CreatedAt: time.Now().AddDate(-1, 0, 0),
-		ExpiresAt: time.Now().AddDate(0, 6, 0),
-		IsActive:  true,
-		Permissions: []string{
-			"chat:read",
-			"chat:write",
-			"completions:read",
-			"completions:write",
-		},
+	row, err := h.apiKeys.Get(c.Request().Context(), claims.UserID, keyID)
+	if err != nil {
+		return apiKeyError(err)
 	}
 
-	return c.JSON(http.StatusOK, apiKey)
+	return c.JSON(http.StatusOK, toAPIKey(row))
 }
 
 // UpdateAPIKey handles PUT /users/api-keys/:key_id
@@ -421,18 +450,18 @@ func (h *handler) GetAPIKey(c echo.Context) error {
 // @Failure 500 {object} map[string]interface{} "Internal server error"
 // @Router /users/api-keys/{key_id} [put]
 func (h *handler) UpdateAPIKey(c echo.Context) error {
-	keyID := c.Param("key_id")
-	if keyID == "" {
+	keyID, err := uuid.Parse(c.Param("key_id"))
+	if err != nil {
 		return c.JSON(http.StatusBadRequest, map[string]interface{}{
 			"error": map[string]interface{}{
 				"code":    "INVALID_REQUEST",
-				"message": "API key ID is required",
+				"message": "Invalid API key ID",
 			},
 		})
 	}
 
 	var req struct {
-		Name        string   `json:"name,omitempty" validate:"omitempty,min=3,max=50"`
+		Name        *string  `json:"name,omitempty" validate:"omitempty,min=3,max=50"`
 		IsActive    *bool    `json:"is_active,omitempty"`
 		Permissions []string `json:"permissions,omitempty"`
 	}
@@ -445,23 +474,70 @@ func (h *handler) UpdateAPIKey(c echo.Context) error {
 			},
 		})
 	}
+	if req.Name != nil && (len(*req.Name) < 3 || len(*req.Name) > 50) {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"error": map[string]interface{}{
+				"code":    "INVALID_REQUEST",
+				"message": "name must be between 3 and 50 characters",
+			},
+		})
+	}
 
-	// TODO: Validate request
-	// TODO: Get user ID from context
-	// TODO: Check if key belongs to user
-	// TODO: Update API key in database
+	claims, err := currentClaims(c)
+	if err != nil {
+		return err
+	}
+	if err := checkKeyPermissions(claims, req.Permissions); err != nil {
+		return err
+	}
 
-	// Mock response for now
-	apiKey := APIKey{
-		ID:          keyID,
+	row, err := h.apiKeys.Update(c.Request().Context(), claims.UserID, keyID, apikeys.Update{
 		Name:        req.Name,
-		Prefix:      "sk_abc123",
-		LastUsed:    time.Now().AddDate(0, 0, -1),
-		CreatedAt:   time.Now().AddDate(-1, 0, 0),
-		ExpiresAt:   time.Now().AddDate(0, 6, 0),
-		IsActive:    *req.IsActive,
+		IsActive:    req.IsActive,
 		Permissions: req.Permissions,
+	})
+	if err != nil {
+		return apiKeyError(err)
+	}
+
+	return c.JSON(http.StatusOK, toAPIKey(row))
+}
+
+// toAPIKey converts an api_keys row into its API representation, without the key
+func toAPIKey(row *models.APIKey) APIKey {
+	permissions := row.Permissions
+	if permissions == nil {
+		permissions = []string{}
+	}
+	return APIKey{
+		ID:          row.ID.String(),
+		Name:        row.Name,
+		Prefix:      row.KeyPrefix,
+		LastUsed:    row.LastUsedAt,
+		CreatedAt:   row.CreatedAt,
+		ExpiresAt:   row.ExpiresAt,
+		IsActive:    row.IsActive,
+		Permissions: permissions,
 	}
+}
 
-	return c.JSON(http.StatusOK, apiKey)
+// checkKeyPermissions validates the permissions requested for a key. Only admins may
+// grant the admin scope.
+func checkKeyPermissions(claims *auth.Claims, values []string) error {
+	permissions, err := auth.ParsePermissions(values)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	if slices.Contains(permissions.Scopes, auth.ScopeAdmin) && claims.Role != auth.RoleAdmin {
+		return echo.NewHTTPError(http.StatusForbidden, "only admins may grant the admin scope")
+	}
+	return nil
+}
+
+// apiKeyError maps API key store errors to HTTP errors
+func apiKeyError(err error) error {
+	if errors.Is(err, apikeys.ErrKeyNotFound) {
+		return echo.NewHTTPError(http.StatusNotFound, err.Error())
+	}
+	return err
 }
@@ -1,15 +1,44 @@
 package middleware
 
 import (
+	"context"
+	"errors"
+	"log/slog"
 	"net/http"
+	"slices"
 	"strings"
 
+	"ai-aggregator-service/internal/apikeys"
+	"ai-aggregator-service/internal/auth"
+
+	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
 )
 
-// AuthMiddleware handles JWT token validation
-func AuthMiddleware() echo.MiddlewareFunc {
+// Context keys the authenticated identity is stored under
+const (
+	ContextKeyClaims         = "claims"
+	ContextKeyUserID         = "userID"
+	ContextKeyOrganizationID = "organizationID"
+)
+
+// HeaderAPIKey is the header gateway API keys may be passed in instead of Authorization
+const HeaderAPIKey = "X-API-Key"
+
+// TokenRevocations is the revocation list of access tokens
+type TokenRevocations interface {
+	// Revoked reports whether the token the claims were verified from was revoked
+	Revoked(ctx context.Context, claims *auth.Claims) (bool, error)
+}
+
+// AuthMiddleware handles JWT token validation. The verified claims are stored in the
+// context, together with the user ID and, for tokens issued for an organization, the
+// organization ID. When revocations is set, revoked tokens are rejected. When keys is
+// set, gateway API keys are accepted as well, so that routes guarded by RequireScope can
+// be called with a key granted the scope.
+func AuthMiddleware(verifier *auth.Verifier, revocations TokenRevocations, keys APIKeyAuthenticator) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		apiKeys := APIKeyMiddleware(keys)(next)
 		return func(c echo.Context) error {
 			// Skip auth for public endpoints
 			if isPublicEndpoint(c.Path()) {
@@ -18,6 +47,9 @@ func AuthMiddleware() echo.MiddlewareFunc {
 
 			// Get token from Authorization header
 			authHeader := c.Request().Header.Get("Authorization")
+			if keys != nil && (c.Request().Header.Get(HeaderAPIKey) != "" || strings.HasPrefix(authHeader, "Bearer "+apikeys.Prefix)) {
+				return apiKeys(c)
+			}
 			if authHeader == "" {
 				return c.JSON(http.StatusUnauthorized, map[string]string{
 					"error": "Authorization header required",
@@ -32,23 +64,111 @@ func AuthMiddleware() echo.MiddlewareFunc {
 				})
 			}
 
-			// TODO: Validate JWT token
-			// For now, we'll just check if token exists
-			if tokenString == "" {
+			claims, err := verifier.Verify(c.Request().Context(), tokenString)
+			if err != nil {
+				if errors.Is(err, auth.ErrTokenExpired) {
+					return c.JSON(http.StatusUnauthorized, map[string]string{
+						"error": "Token expired",
+					})
+				}
+				slog.Debug("Rejected bearer token", "path", c.Path(), "error", err)
 				return c.JSON(http.StatusUnauthorized, map[string]string{
 					"error": "Invalid token",
 				})
 			}
 
-			// TODO: Extract user claims from token and set in context
-			// userID := extractUserIDFromToken(tokenString)
-			// c.Set("userID", userID)
+			if revocations != nil {
+				revoked, err := revocations.Revoked(c.Request().Context(), claims)
+				if err != nil {
+					return err
+				}
+				if revoked {
+					return c.JSON(http.StatusUnauthorized, map[string]string{
+						"error": "Token revoked",
+					})
+				}
+			}
+
+			setIdentity(c, claims)
+			return next(c)
+		}
+	}
+}
+
+// APIKeyAuthenticator verifies gateway API keys
+type APIKeyAuthenticator interface {
+	// Authenticate returns the identity the key acts as
+	Authenticate(ctx context.Context, key string) (*auth.Claims, error)
+}
+
+// APIKeyMiddleware authenticates requests with a gateway API key, passed as a bearer
+// token or in the X-API-Key header, and stores the identity the key acts as in the
+// context like AuthMiddleware does. Errors are returned rather than rendered, so that
+// they take the error format of the API being served.
+func APIKeyMiddleware(keys APIKeyAuthenticator) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			key := c.Request().Header.Get(HeaderAPIKey)
+			if key == "" {
+				key = strings.TrimPrefix(c.Request().Header.Get("Authorization"), "Bearer ")
+			}
+			if key == "" {
+				return echo.NewHTTPError(http.StatusUnauthorized, "API key required")
+			}
+
+			claims, err := keys.Authenticate(c.Request().Context(), key)
+			if err != nil {
+				if errors.Is(err, apikeys.ErrInvalidKey) || errors.Is(err, apikeys.ErrKeyInactive) || errors.Is(err, apikeys.ErrKeyExpired) {
+					return echo.NewHTTPError(http.StatusUnauthorized, err.Error())
+				}
+				return err
+			}
+
+			setIdentity(c, claims)
+			return next(c)
+		}
+	}
+}
+
+// setIdentity stores the caller's verified claims, user ID and, when acting for an
+// organization, organization ID in the context
+func setIdentity(c echo.Context, claims *auth.Claims) {
+	c.Set(ContextKeyClaims, claims)
+	if claims.UserID != uuid.Nil {
+		c.Set(ContextKeyUserID, claims.UserID)
+	}
+	if claims.OrganizationID != uuid.Nil {
+		c.Set(ContextKeyOrganizationID, claims.OrganizationID)
+	}
+}
 
+// RequireRole rejects requests whose verified claims carry none of the roles. It must
+// run after AuthMiddleware.
+func RequireRole(roles ...string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			claims, ok := ClaimsFrom(c)
+			if !ok {
+				return c.JSON(http.StatusUnauthorized, map[string]string{
+					"error": "Authentication required",
+				})
+			}
+			if !slices.Contains(roles, claims.Role) {
+				return c.JSON(http.StatusForbidden, map[string]string{
+					"error": "Insufficient permissions",
+				})
+			}
 			return next(c)
 		}
 	}
 }
 
+// ClaimsFrom returns the claims AuthMiddleware verified for the request
+func ClaimsFrom(c echo.Context) (*auth.Claims, bool) {
+	claims, ok := c.Get(ContextKeyClaims).(*auth.Claims)
+	return claims, ok
+}
+
 // isPublicEndpoint checks if the endpoint doesn't require authentication
 func isPublicEndpoint(path string) bool {
 	publicEndpoints := []string{
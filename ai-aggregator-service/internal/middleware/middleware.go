@@ -3,11 +3,13 @@ package middleware
 import (
 	"fmt"
 
+	"ai-aggregator-service/internal/auth"
+
 	"github.com/labstack/echo/v4"
 )
 
 // SetupMiddleware configures all middleware for the Echo server
-func SetupMiddleware(e *echo.Echo) {
+func SetupMiddleware(e *echo.Echo, verifier *auth.Verifier) {
 	// Request ID middleware (first to ensure ID is available)
 	e.Use(RequestIDMiddleware())
 
@@ -22,7 +24,7 @@ func SetupMiddleware(e *echo.Echo) {
 	e.Use(rateLimiter.RateLimitMiddleware())
 
 	// Authentication middleware
-	e.Use(AuthMiddleware())
+	e.Use(AuthMiddleware(verifier, nil, nil))
 
 	// Recovery middleware (built-in Echo)
 	e.Use(RecoverMiddleware())
@@ -0,0 +1,111 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"ai-aggregator-service/internal/auth"
+	"ai-aggregator-service/internal/providers"
+
+	"github.com/labstack/echo/v4"
+)
+
+// requestLimits holds the fields of a model request that API key permissions restrict
+type requestLimits struct {
+	MaxTokens *int `json:"max_tokens"`
+}
+
+// RequireScope rejects requests made with an API key that was not granted the scope.
+// For the chat, completions and embeddings scopes the forced provider must match the
+// key's patterns, and max_tokens must stay within its limit. The key's model patterns
+// are enforced by the router, which matches them against the model an alias resolves
+// to. A key with the billing:read scope may only read. Callers that authenticated with
+// a token are not restricted. It must run after the authentication middleware.
+func RequireScope(scope string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			claims, ok := ClaimsFrom(c)
+			if !ok {
+				return echo.NewHTTPError(http.StatusUnauthorized, "authentication required")
+			}
+			permissions, ok := auth.PermissionsOf(claims)
+			if !ok {
+				return next(c)
+			}
+
+			if !permissions.Allows(scope) {
+				return permissionDenied("", "API key lacks the %s scope", scope)
+			}
+
+			switch scope {
+			case auth.ScopeBillingRead:
+				if method := c.Request().Method; method != http.MethodGet && method != http.MethodHead {
+					return permissionDenied("", "the %s scope only allows reading", scope)
+				}
+
+			case auth.ScopeChat, auth.ScopeCompletions, auth.ScopeEmbeddings:
+				if err := checkRequest(c, permissions, scope != auth.ScopeEmbeddings); err != nil {
+					return err
+				}
+			}
+
+			return next(c)
+		}
+	}
+}
+
+// DenyAPIKeys rejects requests made with an API key, for routes that manage the account
+// itself, such as creating further keys
+func DenyAPIKeys(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if claims, ok := ClaimsFrom(c); ok {
+			if _, ok := auth.PermissionsOf(claims); ok {
+				return permissionDenied("", "API keys cannot be used for this endpoint; sign in instead")
+			}
+		}
+		return next(c)
+	}
+}
+
+// checkRequest checks the forced provider and, for generation requests, the max_tokens
+// of a request against the key's permissions. The body is read ahead and
+// restored for the handler; bodies that are not JSON are left for the handler to reject.
+func checkRequest(c echo.Context, permissions auth.Permissions, generates bool) error {
+	if provider := c.Param("provider"); provider != "" && !permissions.AllowsProvider(provider) {
+		return permissionDenied("provider", "API key may not use provider %s", provider)
+	}
+
+	body, err := io.ReadAll(c.Request().Body)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "failed to read request body")
+	}
+	c.Request().Body = io.NopCloser(bytes.NewReader(body))
+
+	var limits requestLimits
+	if err := json.Unmarshal(body, &limits); err != nil {
+		return nil
+	}
+
+	if generates && permissions.MaxTokens > 0 {
+		if limits.MaxTokens == nil {
+			return permissionDenied("max_tokens", "API key requires max_tokens of at most %d", permissions.MaxTokens)
+		}
+		if *limits.MaxTokens > permissions.MaxTokens {
+			return permissionDenied("max_tokens", "API key limits max_tokens to %d", permissions.MaxTokens)
+		}
+	}
+	return nil
+}
+
+// permissionDenied returns a 403 error about the given request parameter, rendered in
+// the format of the API being served
+func permissionDenied(param, format string, args ...interface{}) error {
+	return &providers.Error{
+		Kind:    providers.ErrorKindPermission,
+		Message: fmt.Sprintf(format, args...),
+		Param:   param,
+	}
+}
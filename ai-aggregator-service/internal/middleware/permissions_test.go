@@ -0,0 +1,213 @@
+package middleware
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"ai-aggregator-service/internal/auth"
+	"ai-aggregator-service/internal/providers"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// apiKeyClaims returns the claims of a caller authenticated with an API key holding permissions
+func apiKeyClaims(permissions ...string) *auth.Claims {
+	return &auth.Claims{
+		UserID:   uuid.New(),
+		Role:     auth.RoleUser,
+		Scopes:   permissions,
+		APIKeyID: uuid.New(),
+	}
+}
+
+// permissionRequest describes a request run through a permission middleware
+type permissionRequest struct {
+	claims   *auth.Claims
+	method   string
+	body     string
+	provider string // the :provider path parameter, if any
+}
+
+// serve runs the request through middleware and returns the body the handler read,
+// whether the handler was reached, and the error the middleware returned
+func serve(t *testing.T, middleware echo.MiddlewareFunc, r permissionRequest) (string, bool, error) {
+	t.Helper()
+
+	method := r.method
+	if method == "" {
+		method = http.MethodPost
+	}
+	req := httptest.NewRequest(method, "/", strings.NewReader(r.body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	c := echo.New().NewContext(req, httptest.NewRecorder())
+	if r.claims != nil {
+		c.Set(ContextKeyClaims, r.claims)
+	}
+	if r.provider != "" {
+		c.SetParamNames("provider")
+		c.SetParamValues(r.provider)
+	}
+
+	var body string
+	reached := false
+	err := middleware(func(c echo.Context) error {
+		reached = true
+		data, err := io.ReadAll(c.Request().Body)
+		if err != nil {
+			t.Fatalf("failed to read request body: %v", err)
+		}
+		body = string(data)
+		return nil
+	})(c)
+	return body, reached, err
+}
+
+func TestRequireScope(t *testing.T) {
+	tests := []struct {
+		name      string
+		scope     string
+		request   permissionRequest
+		denied    bool
+		wantParam string // parameter the permission error is about, if denied
+	}{
+		{
+			name:    "token caller is not restricted",
+			scope:   auth.ScopeAdmin,
+			request: permissionRequest{claims: &auth.Claims{UserID: uuid.New(), Role: auth.RoleAdmin}},
+		},
+		{
+			name:    "key without scopes may chat",
+			scope:   auth.ScopeChat,
+			request: permissionRequest{claims: apiKeyClaims(), body: `{"model":"gpt-4o"}`},
+		},
+		{
+			name:    "key without scopes is no admin",
+			scope:   auth.ScopeAdmin,
+			request: permissionRequest{claims: apiKeyClaims()},
+			denied:  true,
+		},
+		{
+			name:    "key lacking the scope",
+			scope:   auth.ScopeChat,
+			request: permissionRequest{claims: apiKeyClaims(auth.ScopeEmbeddings), body: `{"model":"gpt-4o"}`},
+			denied:  true,
+		},
+		{
+			name:    "billing read with GET",
+			scope:   auth.ScopeBillingRead,
+			request: permissionRequest{claims: apiKeyClaims(auth.ScopeBillingRead), method: http.MethodGet},
+		},
+		{
+			name:    "billing read with POST",
+			scope:   auth.ScopeBillingRead,
+			request: permissionRequest{claims: apiKeyClaims(auth.ScopeBillingRead), method: http.MethodPost},
+			denied:  true,
+		},
+		{
+			name:    "model matching the pattern",
+			scope:   auth.ScopeChat,
+			request: permissionRequest{claims: apiKeyClaims("model:gpt-4o*"), body: `{"model":"gpt-4o-mini"}`},
+		},
+		{
+			// The router matches the pattern against the model the alias resolves to
+			name:    "alias outside the pattern is left to the router",
+			scope:   auth.ScopeChat,
+			request: permissionRequest{claims: apiKeyClaims("model:gpt-4o*"), body: `{"model":"bharat-smart"}`},
+		},
+		{
+			name:    "allowed provider",
+			scope:   auth.ScopeChat,
+			request: permissionRequest{claims: apiKeyClaims("provider:openai"), body: `{"model":"gpt-4o"}`, provider: "openai"},
+		},
+		{
+			name:      "forced provider outside the pattern",
+			scope:     auth.ScopeChat,
+			request:   permissionRequest{claims: apiKeyClaims("provider:openai"), body: `{"model":"gpt-4o"}`, provider: "anthropic"},
+			wantParam: "provider",
+			denied:    true,
+		},
+		{
+			name:      "missing max_tokens",
+			scope:     auth.ScopeChat,
+			request:   permissionRequest{claims: apiKeyClaims("max_tokens:100"), body: `{"model":"gpt-4o"}`},
+			wantParam: "max_tokens",
+			denied:    true,
+		},
+		{
+			name:      "max_tokens over the limit",
+			scope:     auth.ScopeCompletions,
+			request:   permissionRequest{claims: apiKeyClaims("max_tokens:100"), body: `{"model":"gpt-4o","max_tokens":200}`},
+			wantParam: "max_tokens",
+			denied:    true,
+		},
+		{
+			name:    "max_tokens within the limit",
+			scope:   auth.ScopeChat,
+			request: permissionRequest{claims: apiKeyClaims("max_tokens:100"), body: `{"model":"gpt-4o","max_tokens":100}`},
+		},
+		{
+			name:    "embeddings ignore the max_tokens limit",
+			scope:   auth.ScopeEmbeddings,
+			request: permissionRequest{claims: apiKeyClaims("max_tokens:100"), body: `{"model":"text-embedding-3-small","input":"hi"}`},
+		},
+		{
+			name:    "body that is not JSON is left to the handler",
+			scope:   auth.ScopeChat,
+			request: permissionRequest{claims: apiKeyClaims("model:gpt-4o*", "max_tokens:100"), body: `not json`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, reached, err := serve(t, RequireScope(tt.scope), tt.request)
+
+			if !tt.denied {
+				if err != nil || !reached {
+					t.Fatalf("RequireScope(%s) error = %v, reached handler = %v, want the request allowed", tt.scope, err, reached)
+				}
+				if body != tt.request.body {
+					t.Errorf("handler read body %q, want the request body %q restored", body, tt.request.body)
+				}
+				return
+			}
+
+			if reached {
+				t.Fatalf("RequireScope(%s) reached the handler, want the request denied", tt.scope)
+			}
+			var providerErr *providers.Error
+			if !errors.As(err, &providerErr) || providerErr.Kind != providers.ErrorKindPermission {
+				t.Fatalf("RequireScope(%s) error = %v, want a permission error", tt.scope, err)
+			}
+			if providerErr.Param != tt.wantParam {
+				t.Errorf("error param = %q, want %q", providerErr.Param, tt.wantParam)
+			}
+		})
+	}
+}
+
+func TestRequireScopeWithoutClaims(t *testing.T) {
+	_, reached, err := serve(t, RequireScope(auth.ScopeChat), permissionRequest{body: `{}`})
+
+	var httpErr *echo.HTTPError
+	if reached || !errors.As(err, &httpErr) || httpErr.Code != http.StatusUnauthorized {
+		t.Errorf("RequireScope() error = %v, reached handler = %v, want 401", err, reached)
+	}
+}
+
+func TestDenyAPIKeys(t *testing.T) {
+	_, reached, err := serve(t, DenyAPIKeys, permissionRequest{claims: apiKeyClaims(auth.ScopeAdmin)})
+	var providerErr *providers.Error
+	if reached || !errors.As(err, &providerErr) || providerErr.Kind != providers.ErrorKindPermission {
+		t.Errorf("DenyAPIKeys() with an API key: error = %v, reached handler = %v, want a permission error", err, reached)
+	}
+
+	_, reached, err = serve(t, DenyAPIKeys, permissionRequest{claims: &auth.Claims{UserID: uuid.New(), Role: auth.RoleUser}})
+	if err != nil || !reached {
+		t.Errorf("DenyAPIKeys() with a token: error = %v, reached handler = %v, want the request allowed", err, reached)
+	}
+}
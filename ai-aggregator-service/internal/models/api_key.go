@@ -16,10 +16,11 @@ type APIKey struct {
 	CreatedAt      time.Time  `bun:"created_at,notnull,default:current_timestamp"`
 	UpdatedAt      time.Time  `bun:"updated_at,notnull,default:current_timestamp"`
 	KeyHash        string     `bun:"key_hash,notnull,unique,type:varchar(255)"`
+	KeyPrefix      string     `bun:"key_prefix,unique,type:varchar(32)"`
 	UserID         *uuid.UUID `bun:"user_id,type:uuid"`
 	OrganizationID *uuid.UUID `bun:"organization_id,type:uuid"`
 	Name           string     `bun:"name,notnull,type:varchar(255)"`
-	Permissions    JSONB      `bun:"permissions,type:jsonb,default:'[]'"`
+	Permissions    []string   `bun:"permissions,type:jsonb,default:'[]'"`
 	IsActive       bool       `bun:"is_active,notnull,default:true"`
 	LastUsedAt     *time.Time `bun:"last_used_at"`
 	ExpiresAt      *time.Time `bun:"expires_at"`
@@ -15,22 +15,31 @@ type APIRequest struct {
 	ID             uuid.UUID  `bun:"id,pk,type:uuid,default:gen_random_uuid()"`
 	CreatedAt      time.Time  `bun:"created_at,notnull,default:current_timestamp"`
 	UpdatedAt      time.Time  `bun:"updated_at,notnull,default:current_timestamp"`
-	APIKeyID       uuid.UUID  `bun:"api_key_id,notnull,type:uuid"`
+	APIKeyID       *uuid.UUID `bun:"api_key_id,type:uuid"`
 	UserID         *uuid.UUID `bun:"user_id,type:uuid"`
 	OrganizationID *uuid.UUID `bun:"organization_id,type:uuid"`
-	ProviderID     uuid.UUID  `bun:"provider_id,notnull,type:uuid"`
-	ModelID        uuid.UUID  `bun:"model_id,notnull,type:uuid"`
-	RequestID      string     `bun:"request_id,notnull,type:varchar(255)"`
-	RequestBody    JSONB      `bun:"request_body,type:jsonb,notnull"`
-	RequestHeaders JSONB      `bun:"request_headers,type:jsonb,default:'{}'"`
-	RequestMethod  string     `bun:"request_method,notnull,type:varchar(10)"`
-	RequestURL     string     `bun:"request_url,notnull,type:text"`
-	RequestSize    int        `bun:"request_size,type:integer,default:0"`
+	ProviderID     *uuid.UUID `bun:"provider_id,type:uuid"`
+	ModelID        *uuid.UUID `bun:"model_id,type:uuid"`
+	RequestID      string     `bun:"request_id,notnull,unique,type:varchar(255)"`
 	Status         string     `bun:"status,notnull,type:varchar(50),default:'pending'"`
-	StartedAt      time.Time  `bun:"started_at,notnull,default:current_timestamp"`
-	CompletedAt    *time.Time `bun:"completed_at"`
+	Method         string     `bun:"method,notnull,type:varchar(10)"`
+	Endpoint       string     `bun:"endpoint,notnull,type:varchar(500)"`
+	Headers        JSONB      `bun:"headers,type:jsonb,default:'{}'"`
+	RequestBody    JSONB      `bun:"request_body,type:jsonb"`
+	ResponseBody   JSONB      `bun:"response_body,type:jsonb"`
 	ErrorMessage   *string    `bun:"error_message,type:text"`
-	ProcessingTime *int       `bun:"processing_time,type:integer"`
+	StatusCode     *int       `bun:"status_code,type:integer"`
+	InputTokens    int        `bun:"input_tokens,type:integer,default:0"`
+	OutputTokens   int        `bun:"output_tokens,type:integer,default:0"`
+	TotalTokens    int        `bun:"total_tokens,type:integer,default:0"`
+	CachedTokens   int        `bun:"cached_input_tokens,type:integer,default:0"`
+	InputImages    int        `bun:"input_images,type:integer,default:0"`
+	Cost           float64    `bun:"cost,type:numeric,default:0.0"`
+	BYOK           bool       `bun:"byok,notnull,default:false"`
+	LatencyMS      *int       `bun:"latency_ms,type:integer"`
+	IPAddress      *string    `bun:"ip_address,type:inet"`
+	UserAgent      string     `bun:"user_agent,type:text"`
+	CompletedAt    *time.Time `bun:"completed_at"`
 
 	// Relations
 	APIKey             *APIKey             `bun:"rel:belongs-to,join:api_key_id=id"`
@@ -38,7 +47,7 @@ type APIRequest struct {
 	Organization       *Organization       `bun:"rel:belongs-to,join:organization_id=id"`
 	Provider           *Provider           `bun:"rel:belongs-to,join:provider_id=id"`
 	Model              *Model              `bun:"rel:belongs-to,join:model_id=id"`
-	APIResponse        *APIResponse        `bun:"rel:has-one,join:id=api_request_id"`
+	APIResponses       []*APIResponse      `bun:"rel:has-many,join:id=request_id"`
 	BillingTransaction *BillingTransaction `bun:"rel:has-one,join:id=api_request_id"`
 }
 
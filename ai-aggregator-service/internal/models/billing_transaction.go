@@ -19,6 +19,7 @@ type BillingTransaction struct {
 	APIRequestID     *uuid.UUID `bun:"api_request_id,type:uuid"`
 	TransactionType  string     `bun:"transaction_type,notnull,type:varchar(50)"`
 	Amount           float64    `bun:"amount,notnull,type:numeric"`
+	BalanceAfter     float64    `bun:"balance_after,notnull,type:numeric"`
 	Currency         string     `bun:"currency,notnull,type:varchar(3),default:'USD'"`
 	Description      string     `bun:"description,type:text"`
 	Metadata         JSONB      `bun:"metadata,type:jsonb,default:'{}'"`
@@ -12,24 +12,25 @@ import (
 type Model struct {
 	bun.BaseModel `bun:"table:models"`
 
-	ID            uuid.UUID `bun:"id,pk,type:uuid,default:gen_random_uuid()"`
-	CreatedAt     time.Time `bun:"created_at,notnull,default:current_timestamp"`
-	UpdatedAt     time.Time `bun:"updated_at,notnull,default:current_timestamp"`
-	ProviderID    uuid.UUID `bun:"provider_id,notnull,type:uuid"`
-	Name          string    `bun:"name,notnull,type:varchar(255)"`
-	DisplayName   string    `bun:"display_name,type:varchar(255)"`
-	Description   string    `bun:"description,type:text"`
-	ModelType     string    `bun:"model_type,notnull,type:varchar(50)"`
-	IsActive      bool      `bun:"is_active,notnull,default:true"`
-	Config        JSONB     `bun:"config,type:jsonb,default:'{}'"`
-	Pricing       JSONB     `bun:"pricing,type:jsonb,default:'{}'"`
-	ContextWindow int       `bun:"context_window,type:integer"`
-	MaxTokens     int       `bun:"max_tokens,type:integer"`
-	Temperature   float64   `bun:"temperature,type:numeric"`
-	TopP          float64   `bun:"top_p,type:numeric"`
+	ID              uuid.UUID `bun:"id,pk,type:uuid,default:gen_random_uuid()"`
+	CreatedAt       time.Time `bun:"created_at,notnull,default:current_timestamp"`
+	UpdatedAt       time.Time `bun:"updated_at,notnull,default:current_timestamp"`
+	ProviderID      uuid.UUID `bun:"provider_id,notnull,type:uuid"`
+	Name            string    `bun:"name,notnull,type:varchar(255)"`
+	DisplayName     string    `bun:"display_name,notnull,type:varchar(255)"`
+	ModelType       string    `bun:"model_type,notnull,type:varchar(50)"`
+	IsActive        bool      `bun:"is_active,notnull,default:true"`
+	ContextWindow   int       `bun:"context_window,nullzero,type:integer"`
+	MaxTokens       int       `bun:"max_tokens,nullzero,type:integer"`
+	InputCostPer1K  *float64  `bun:"input_cost_per_1k_tokens,type:decimal(10,6)"`
+	OutputCostPer1K *float64  `bun:"output_cost_per_1k_tokens,type:decimal(10,6)"`
+	Pricing         JSONB     `bun:"pricing,type:jsonb,default:'{}'"`
+	Config          JSONB     `bun:"config,type:jsonb,default:'{}'"`
+	Capabilities    []string  `bun:"capabilities,type:jsonb,default:'[]'"`
 
 	// Relations
 	Provider     *Provider      `bun:"rel:belongs-to,join:provider_id=id"`
+	Prices       []*ModelPrice  `bun:"rel:has-many,join:id=model_id"`
 	APIRequests  []*APIRequest  `bun:"rel:has-many,join:id=model_id"`
 	APIResponses []*APIResponse `bun:"rel:has-many,join:id=model_id"`
 	RateLimits   []*RateLimit   `bun:"rel:has-many,join:id=model_id"`
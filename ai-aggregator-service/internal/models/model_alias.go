@@ -0,0 +1,46 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+// ModelAlias represents the model_aliases table
+type ModelAlias struct {
+	bun.BaseModel `bun:"table:model_aliases"`
+
+	ID             uuid.UUID  `bun:"id,pk,type:uuid,default:gen_random_uuid()"`
+	CreatedAt      time.Time  `bun:"created_at,notnull,default:current_timestamp"`
+	UpdatedAt      time.Time  `bun:"updated_at,notnull,default:current_timestamp"`
+	OrganizationID *uuid.UUID `bun:"organization_id,type:uuid"`
+	Alias          string     `bun:"alias,notnull,type:varchar(255)"`
+	Model          string     `bun:"model,notnull,type:varchar(255)"`
+	Provider       *string    `bun:"provider,type:varchar(100)"`
+	IsActive       bool       `bun:"is_active,notnull,default:true"`
+
+	// Relations
+	Organization *Organization `bun:"rel:belongs-to,join:organization_id=id"`
+}
+
+// Ensure ModelAlias implements bun.BeforeAppendModelHook
+var _ bun.BeforeAppendModelHook = (*ModelAlias)(nil)
+
+// BeforeAppendModel implements bun.BeforeAppendModelHook
+func (m *ModelAlias) BeforeAppendModel(ctx context.Context, query bun.Query) error {
+	switch query.(type) {
+	case *bun.InsertQuery:
+		m.CreatedAt = time.Now()
+		m.UpdatedAt = time.Now()
+	case *bun.UpdateQuery:
+		m.UpdatedAt = time.Now()
+	}
+	return nil
+}
+
+// TableName returns the table name for ModelAlias
+func (ModelAlias) TableName() string {
+	return "model_aliases"
+}
@@ -0,0 +1,41 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+// ModelPrice represents the model_prices table, the price history of a model
+type ModelPrice struct {
+	bun.BaseModel `bun:"table:model_prices"`
+
+	ID              uuid.UUID `bun:"id,pk,type:uuid,default:gen_random_uuid()"`
+	CreatedAt       time.Time `bun:"created_at,notnull,default:current_timestamp"`
+	ModelID         uuid.UUID `bun:"model_id,notnull,type:uuid"`
+	EffectiveFrom   time.Time `bun:"effective_from,notnull"`
+	InputCostPer1K  float64   `bun:"input_cost_per_1k_tokens,notnull,type:decimal(10,6)"`
+	OutputCostPer1K float64   `bun:"output_cost_per_1k_tokens,notnull,type:decimal(10,6)"`
+	Pricing         JSONB     `bun:"pricing,type:jsonb,default:'{}'"`
+
+	// Relations
+	Model *Model `bun:"rel:belongs-to,join:model_id=id"`
+}
+
+// Ensure ModelPrice implements bun.BeforeAppendModelHook
+var _ bun.BeforeAppendModelHook = (*ModelPrice)(nil)
+
+// BeforeAppendModel implements bun.BeforeAppendModelHook
+func (m *ModelPrice) BeforeAppendModel(ctx context.Context, query bun.Query) error {
+	if _, ok := query.(*bun.InsertQuery); ok {
+		m.CreatedAt = time.Now()
+	}
+	return nil
+}
+
+// TableName returns the table name for ModelPrice
+func (ModelPrice) TableName() string {
+	return "model_prices"
+}
@@ -13,6 +13,9 @@ var ModelList = []interface{}{
 	(*User)(nil),
 	(*APIKey)(nil),
 	(*Model)(nil),
+	(*ModelPrice)(nil),
+	(*ModelAlias)(nil),
+	(*ProviderCredential)(nil),
 	(*APIRequest)(nil),
 	(*APIResponse)(nil),
 	(*BillingAccount)(nil),
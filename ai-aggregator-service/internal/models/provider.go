@@ -17,6 +17,7 @@ type Provider struct {
 	UpdatedAt         time.Time `bun:"updated_at,notnull,default:current_timestamp"`
 	Name              string    `bun:"name,notnull,unique,type:varchar(100)"`
 	DisplayName       string    `bun:"display_name,notnull,type:varchar(255)"`
+	Type              string    `bun:"type,type:varchar(50)"`
 	BaseURL           string    `bun:"base_url,notnull,type:varchar(500)"`
 	APIKeyRequired    bool      `bun:"api_key_required,notnull,default:true"`
 	IsActive          bool      `bun:"is_active,notnull,default:true"`
@@ -0,0 +1,49 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+// ProviderCredential represents the provider_credentials table
+type ProviderCredential struct {
+	bun.BaseModel `bun:"table:provider_credentials"`
+
+	ID               uuid.UUID `bun:"id,pk,type:uuid,default:gen_random_uuid()"`
+	CreatedAt        time.Time `bun:"created_at,notnull,default:current_timestamp"`
+	UpdatedAt        time.Time `bun:"updated_at,notnull,default:current_timestamp"`
+	OrganizationID   uuid.UUID `bun:"organization_id,notnull,type:uuid"`
+	Provider         string    `bun:"provider,notnull,type:varchar(100)"`
+	EncryptedKey     []byte    `bun:"encrypted_key,notnull,type:bytea"`
+	EncryptedDataKey []byte    `bun:"encrypted_data_key,notnull,type:bytea"`
+	MasterKeyID      string    `bun:"master_key_id,notnull,type:varchar(16)"`
+	KeyHint          string    `bun:"key_hint,type:varchar(20)"`
+	Options          JSONB     `bun:"options,type:jsonb,default:'{}'"`
+	IsActive         bool      `bun:"is_active,notnull,default:true"`
+
+	// Relations
+	Organization *Organization `bun:"rel:belongs-to,join:organization_id=id"`
+}
+
+// Ensure ProviderCredential implements bun.BeforeAppendModelHook
+var _ bun.BeforeAppendModelHook = (*ProviderCredential)(nil)
+
+// BeforeAppendModel implements bun.BeforeAppendModelHook
+func (m *ProviderCredential) BeforeAppendModel(ctx context.Context, query bun.Query) error {
+	switch query.(type) {
+	case *bun.InsertQuery:
+		m.CreatedAt = time.Now()
+		m.UpdatedAt = time.Now()
+	case *bun.UpdateQuery:
+		m.UpdatedAt = time.Now()
+	}
+	return nil
+}
+
+// TableName returns the table name for ProviderCredential
+func (ProviderCredential) TableName() string {
+	return "provider_credentials"
+}
@@ -0,0 +1,58 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+// RefreshToken represents the refresh_tokens table
+type RefreshToken struct {
+	bun.BaseModel `bun:"table:refresh_tokens"`
+
+	ID        uuid.UUID  `bun:"id,pk,type:uuid,default:gen_random_uuid()"`
+	CreatedAt time.Time  `bun:"created_at,notnull,default:current_timestamp"`
+	UserID    uuid.UUID  `bun:"user_id,notnull,type:uuid"`
+	FamilyID  uuid.UUID  `bun:"family_id,notnull,type:uuid"`
+	TokenHash string     `bun:"token_hash,notnull,unique,type:varchar(64)"`
+	ExpiresAt time.Time  `bun:"expires_at,notnull"`
+	UsedAt    *time.Time `bun:"used_at"`
+	RevokedAt *time.Time `bun:"revoked_at"`
+
+	// Relations
+	User *User `bun:"rel:belongs-to,join:user_id=id"`
+}
+
+// Ensure RefreshToken implements bun.BeforeAppendModelHook
+var _ bun.BeforeAppendModelHook = (*RefreshToken)(nil)
+
+// BeforeAppendModel implements bun.BeforeAppendModelHook
+func (m *RefreshToken) BeforeAppendModel(ctx context.Context, query bun.Query) error {
+	switch query.(type) {
+	case *bun.InsertQuery:
+		m.CreatedAt = time.Now()
+	}
+	return nil
+}
+
+// TableName returns the table name for RefreshToken
+func (RefreshToken) TableName() string {
+	return "refresh_tokens"
+}
+
+// RevokedToken represents the revoked_tokens table
+type RevokedToken struct {
+	bun.BaseModel `bun:"table:revoked_tokens"`
+
+	JTI       string     `bun:"jti,pk,type:varchar(64)"`
+	RevokedAt time.Time  `bun:"revoked_at,notnull,default:current_timestamp"`
+	UserID    *uuid.UUID `bun:"user_id,type:uuid"`
+	ExpiresAt time.Time  `bun:"expires_at,notnull"`
+}
+
+// TableName returns the table name for RevokedToken
+func (RevokedToken) TableName() string {
+	return "revoked_tokens"
+}
@@ -18,11 +18,19 @@ type User struct {
 	OrganizationID uuid.UUID  `bun:"organization_id,type:uuid,notnull"`
 	Email          string     `bun:"email,notnull,unique,type:varchar(255)"`
 	FullName       string     `bun:"full_name,type:varchar(255)"`
+	PasswordHash   string     `bun:"password_hash,type:varchar(255)"`
 	Role           string     `bun:"role,notnull,default:'member',type:varchar(50)"`
 	IsActive       bool       `bun:"is_active,notnull,default:true"`
 	LastLoginAt    *time.Time `bun:"last_login_at"`
 	Metadata       JSONB      `bun:"metadata,type:jsonb,default:'{}'"`
 
+	// Failed sign-ins since the last successful one, and until when the account is locked
+	FailedLoginAttempts int        `bun:"failed_login_attempts,notnull,default:0"`
+	LockedUntil         *time.Time `bun:"locked_until"`
+
+	// SessionsRevokedAt invalidates every access token issued to the user before it
+	SessionsRevokedAt *time.Time `bun:"sessions_revoked_at"`
+
 	// Relations
 	Organization    *Organization     `bun:"rel:belongs-to,join:organization_id=id"`
 	APIKeys         []*APIKey         `bun:"rel:has-many,join:id=user_id"`
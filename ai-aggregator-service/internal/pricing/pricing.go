@@ -0,0 +1,238 @@
+package pricing
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"ai-aggregator-service/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+// Currency all prices are recorded in
+const Currency = "usd"
+
+// ErrPriceNotFound is returned when a model has no price in effect at the requested
+// time. Such requests are left unpriced rather than billed at a guessed price.
+var ErrPriceNotFound = errors.New("price not found")
+
+// Details holds the rates beyond flat per-token costs, stored in the pricing JSONB
+type Details struct {
+	// CachedInputCostPer1K is charged for prompt tokens served from the prompt cache;
+	// cached tokens are charged at the input rate when it is not set
+	CachedInputCostPer1K *float64 `json:"cached_input_cost_per_1k_tokens,omitempty"`
+
+	// ImageCost is charged per input image, on top of any tokens the image was counted as
+	ImageCost float64 `json:"image_cost,omitempty"`
+
+	// Tiers replace the token rates of requests with long prompts
+	Tiers []Tier `json:"tiers,omitempty"`
+}
+
+// Tier holds the token rates of requests whose prompt exceeds AboveInputTokens
+type Tier struct {
+	AboveInputTokens     int      `json:"above_input_tokens"`
+	InputCostPer1K       float64  `json:"input_cost_per_1k_tokens"`
+	OutputCostPer1K      float64  `json:"output_cost_per_1k_tokens"`
+	CachedInputCostPer1K *float64 `json:"cached_input_cost_per_1k_tokens,omitempty"`
+}
+
+// Price is the price of a model from EffectiveFrom until the next price takes effect
+type Price struct {
+	InputCostPer1K  float64   `json:"input_cost_per_1k_tokens"`
+	OutputCostPer1K float64   `json:"output_cost_per_1k_tokens"`
+	EffectiveFrom   time.Time `json:"effective_from"`
+	Details
+}
+
+// Quantity is what a request consumed
+type Quantity struct {
+	InputTokens       int
+	CachedInputTokens int
+	OutputTokens      int
+	Images            int
+}
+
+// Cost returns the cost of q at this price. The tier with the highest threshold the
+// prompt exceeds sets the token rates for the whole request.
+func (p *Price) Cost(q Quantity) float64 {
+	input, output, cached := p.InputCostPer1K, p.OutputCostPer1K, p.CachedInputCostPer1K
+	threshold := -1
+	for _, tier := range p.Tiers {
+		if q.InputTokens > tier.AboveInputTokens && tier.AboveInputTokens > threshold {
+			threshold = tier.AboveInputTokens
+			input, output, cached = tier.InputCostPer1K, tier.OutputCostPer1K, tier.CachedInputCostPer1K
+		}
+	}
+
+	cachedRate := input
+	if cached != nil {
+		cachedRate = *cached
+	}
+	uncached := q.InputTokens - q.CachedInputTokens
+	if uncached < 0 {
+		uncached = 0
+	}
+
+	return float64(uncached)/1000*input +
+		float64(q.CachedInputTokens)/1000*cachedRate +
+		float64(q.OutputTokens)/1000*output +
+		float64(q.Images)*p.ImageCost
+}
+
+// Validate checks that the price can be recorded
+func (p *Price) Validate() error {
+	if p.InputCostPer1K < 0 || p.OutputCostPer1K < 0 || p.ImageCost < 0 {
+		return errors.New("costs must not be negative")
+	}
+	if p.CachedInputCostPer1K != nil && *p.CachedInputCostPer1K < 0 {
+		return errors.New("costs must not be negative")
+	}
+	for _, tier := range p.Tiers {
+		if tier.AboveInputTokens < 0 {
+			return errors.New("tier thresholds must not be negative")
+		}
+		if tier.InputCostPer1K < 0 || tier.OutputCostPer1K < 0 || (tier.CachedInputCostPer1K != nil && *tier.CachedInputCostPer1K < 0) {
+			return errors.New("costs must not be negative")
+		}
+	}
+	return nil
+}
+
+// ParseDetails decodes the pricing JSONB of a model or price row
+func ParseDetails(data models.JSONB) (Details, error) {
+	var details Details
+	if len(data) == 0 {
+		return details, nil
+	}
+
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return details, fmt.Errorf("failed to encode pricing: %w", err)
+	}
+	if err := json.Unmarshal(encoded, &details); err != nil {
+		return details, fmt.Errorf("failed to decode pricing: %w", err)
+	}
+	return details, nil
+}
+
+// toJSONB encodes the details for the pricing JSONB
+func (d Details) toJSONB() (models.JSONB, error) {
+	encoded, err := json.Marshal(d)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode pricing: %w", err)
+	}
+
+	data := models.JSONB{}
+	if err := json.Unmarshal(encoded, &data); err != nil {
+		return nil, fmt.Errorf("failed to encode pricing: %w", err)
+	}
+	return data, nil
+}
+
+// Pricer resolves model prices from the model_prices history
+type Pricer struct {
+	db *bun.DB
+}
+
+// NewPricer creates a new pricer
+func NewPricer(db *bun.DB) *Pricer {
+	return &Pricer{db: db}
+}
+
+// PriceAt returns the price of the model in effect at the given time
+func (p *Pricer) PriceAt(ctx context.Context, modelID uuid.UUID, at time.Time) (*Price, error) {
+	row := new(models.ModelPrice)
+	err := p.db.NewSelect().
+		Model(row).
+		Where("model_id = ?", modelID).
+		Where("effective_from <= ?", at).
+		OrderExpr("effective_from DESC").
+		Limit(1).
+		Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: model %s has no price at %s", ErrPriceNotFound, modelID, at.Format(time.RFC3339))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load model price: %w", err)
+	}
+
+	return toPrice(row)
+}
+
+// SetPrice records a new price for the model, effective from price.EffectiveFrom, and
+// makes it the model's current price unless a later price is already recorded
+func (p *Pricer) SetPrice(ctx context.Context, modelID uuid.UUID, price Price) error {
+	if err := price.Validate(); err != nil {
+		return err
+	}
+	details, err := price.Details.toJSONB()
+	if err != nil {
+		return err
+	}
+
+	return p.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		row := &models.ModelPrice{
+			ModelID:         modelID,
+			EffectiveFrom:   price.EffectiveFrom,
+			InputCostPer1K:  price.InputCostPer1K,
+			OutputCostPer1K: price.OutputCostPer1K,
+			Pricing:         details,
+		}
+		_, err := tx.NewInsert().
+			Model(row).
+			On("CONFLICT (model_id, effective_from) DO UPDATE").
+			Set("input_cost_per_1k_tokens = EXCLUDED.input_cost_per_1k_tokens").
+			Set("output_cost_per_1k_tokens = EXCLUDED.output_cost_per_1k_tokens").
+			Set("pricing = EXCLUDED.pricing").
+			Exec(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to record model price: %w", err)
+		}
+
+		later, err := tx.NewSelect().
+			Model((*models.ModelPrice)(nil)).
+			Where("model_id = ?", modelID).
+			Where("effective_from > ?", price.EffectiveFrom).
+			Exists(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to load model prices: %w", err)
+		}
+		if later {
+			return nil
+		}
+
+		_, err = tx.NewUpdate().
+			Model((*models.Model)(nil)).
+			Set("input_cost_per_1k_tokens = ?", price.InputCostPer1K).
+			Set("output_cost_per_1k_tokens = ?", price.OutputCostPer1K).
+			Set("pricing = ?", details).
+			Set("updated_at = ?", time.Now()).
+			Where("id = ?", modelID).
+			Exec(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to update model price: %w", err)
+		}
+		return nil
+	})
+}
+
+// toPrice converts a price history row
+func toPrice(row *models.ModelPrice) (*Price, error) {
+	details, err := ParseDetails(row.Pricing)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Price{
+		InputCostPer1K:  row.InputCostPer1K,
+		OutputCostPer1K: row.OutputCostPer1K,
+		EffectiveFrom:   row.EffectiveFrom,
+		Details:         details,
+	}, nil
+}
@@ -0,0 +1,154 @@
+package pricing
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"ai-aggregator-service/internal/models"
+
+	"github.com/google/uuid"
+)
+
+func rate(r float64) *float64 {
+	return &r
+}
+
+func TestPriceCost(t *testing.T) {
+	flat := &Price{InputCostPer1K: 0.003, OutputCostPer1K: 0.015}
+	cached := &Price{InputCostPer1K: 0.003, OutputCostPer1K: 0.015, Details: Details{CachedInputCostPer1K: rate(0.0003)}}
+	tiered := &Price{
+		InputCostPer1K:  0.00125,
+		OutputCostPer1K: 0.01,
+		Details: Details{
+			CachedInputCostPer1K: rate(0.0003),
+			Tiers: []Tier{
+				// Listed out of order, and without a cached rate of its own
+				{AboveInputTokens: 500000, InputCostPer1K: 0.005, OutputCostPer1K: 0.03},
+				{AboveInputTokens: 200000, InputCostPer1K: 0.0025, OutputCostPer1K: 0.015, CachedInputCostPer1K: rate(0.000625)},
+			},
+		},
+	}
+	images := &Price{InputCostPer1K: 0.0025, OutputCostPer1K: 0.01, Details: Details{ImageCost: 0.001}}
+
+	tests := []struct {
+		name  string
+		price *Price
+		q     Quantity
+		want  float64
+	}{
+		{"nothing used", flat, Quantity{}, 0},
+		{"input and output", flat, Quantity{InputTokens: 1000, OutputTokens: 2000}, 0.003 + 0.03},
+		{"cached tokens at the input rate without a cached rate", flat, Quantity{InputTokens: 1000, CachedInputTokens: 400}, 0.003},
+		{"cached tokens at the cached rate", cached, Quantity{InputTokens: 1000, CachedInputTokens: 400}, 0.6*0.003 + 0.4*0.0003},
+		{"more cached than input tokens", cached, Quantity{InputTokens: 100, CachedInputTokens: 1000}, 0.0003},
+		{"below every tier", tiered, Quantity{InputTokens: 200000, OutputTokens: 1000}, 200*0.00125 + 0.01},
+		{"above the first tier", tiered, Quantity{InputTokens: 200001, OutputTokens: 1000}, 200.001*0.0025 + 0.015},
+		{"tier's cached rate", tiered, Quantity{InputTokens: 300000, CachedInputTokens: 100000}, 200*0.0025 + 100*0.000625},
+		{"highest tier exceeded", tiered, Quantity{InputTokens: 600000, OutputTokens: 1000}, 600*0.005 + 0.03},
+		{"tier without a cached rate charges the tier's input rate", tiered, Quantity{InputTokens: 600000, CachedInputTokens: 100000}, 600 * 0.005},
+		{"images on top of tokens", images, Quantity{InputTokens: 1000, Images: 3}, 0.0025 + 0.003},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.price.Cost(tt.q); math.Abs(got-tt.want) > 1e-12 {
+				t.Errorf("Cost(%+v) = %v, want %v", tt.q, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPriceValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		price   Price
+		wantErr bool
+	}{
+		{"free model", Price{}, false},
+		{"full price", Price{InputCostPer1K: 1, OutputCostPer1K: 2, Details: Details{CachedInputCostPer1K: rate(0.5), ImageCost: 0.1, Tiers: []Tier{{AboveInputTokens: 1000, InputCostPer1K: 2}}}}, false},
+		{"negative input rate", Price{InputCostPer1K: -1}, true},
+		{"negative output rate", Price{OutputCostPer1K: -1}, true},
+		{"negative cached rate", Price{Details: Details{CachedInputCostPer1K: rate(-1)}}, true},
+		{"negative image cost", Price{Details: Details{ImageCost: -1}}, true},
+		{"negative tier threshold", Price{Details: Details{Tiers: []Tier{{AboveInputTokens: -1}}}}, true},
+		{"negative tier rate", Price{Details: Details{Tiers: []Tier{{AboveInputTokens: 1000, OutputCostPer1K: -1}}}}, true},
+		{"negative tier cached rate", Price{Details: Details{Tiers: []Tier{{AboveInputTokens: 1000, CachedInputCostPer1K: rate(-1)}}}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.price.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, want error: %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseDetails(t *testing.T) {
+	details, err := ParseDetails(models.JSONB{
+		"cached_input_cost_per_1k_tokens": 0.0003,
+		"image_cost":                      0.001,
+		"tiers":                           []interface{}{map[string]interface{}{"above_input_tokens": 200000, "input_cost_per_1k_tokens": 0.006}},
+	})
+	if err != nil {
+		t.Fatalf("ParseDetails() error = %v", err)
+	}
+	if details.CachedInputCostPer1K == nil || *details.CachedInputCostPer1K != 0.0003 || details.ImageCost != 0.001 {
+		t.Errorf("ParseDetails() = %+v, want the cached rate and image cost", details)
+	}
+	if len(details.Tiers) != 1 || details.Tiers[0].AboveInputTokens != 200000 || details.Tiers[0].InputCostPer1K != 0.006 {
+		t.Errorf("tiers = %+v, want the 200000 token tier", details.Tiers)
+	}
+
+	if details, err := ParseDetails(nil); err != nil || details.CachedInputCostPer1K != nil || len(details.Tiers) != 0 {
+		t.Errorf("ParseDetails(nil) = %+v, %v, want empty details", details, err)
+	}
+	if _, err := ParseDetails(models.JSONB{"tiers": "cheap"}); err == nil {
+		t.Error("ParseDetails() with malformed tiers: error = nil, want an error")
+	}
+
+	// Details survive the round trip through the pricing column
+	data, err := details.toJSONB()
+	if err != nil {
+		t.Fatalf("toJSONB() error = %v", err)
+	}
+	if again, err := ParseDetails(data); err != nil || *again.CachedInputCostPer1K != 0.0003 || len(again.Tiers) != 1 {
+		t.Errorf("ParseDetails(toJSONB()) = %+v, %v, want the original details", again, err)
+	}
+}
+
+func TestPriceHistoryAt(t *testing.T) {
+	modelID := uuid.New()
+	january := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	march := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	history := priceHistory{modelID: {
+		{InputCostPer1K: 2, EffectiveFrom: march},
+		{InputCostPer1K: 1, EffectiveFrom: january},
+	}}
+
+	tests := []struct {
+		name    string
+		modelID uuid.UUID
+		at      time.Time
+		want    float64 // input rate of the price in effect, or -1 for none
+	}{
+		{"before the first price", modelID, january.Add(-time.Second), -1},
+		{"when the first price takes effect", modelID, january, 1},
+		{"between prices", modelID, march.Add(-time.Second), 1},
+		{"after the latest price", modelID, march.AddDate(1, 0, 0), 2},
+		{"unknown model", uuid.New(), march, -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			price := history.at(tt.modelID, tt.at)
+			switch {
+			case tt.want < 0 && price != nil:
+				t.Errorf("at(%s) = %+v, want no price", tt.at, price)
+			case tt.want >= 0 && (price == nil || price.InputCostPer1K != tt.want):
+				t.Errorf("at(%s) = %+v, want the price with input rate %v", tt.at, price, tt.want)
+			}
+		})
+	}
+}
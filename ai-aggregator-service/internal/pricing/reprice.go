@@ -0,0 +1,154 @@
+package pricing
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"ai-aggregator-service/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+// RepriceResult reports the outcome of re-pricing a window of requests
+type RepriceResult struct {
+	Repriced int `json:"repriced"`
+	Unpriced int `json:"unpriced"`
+}
+
+// repriceBatchSize is how many requests are loaded and updated at a time while re-pricing
+const repriceBatchSize = 1000
+
+// repricedRequest is the new cost of an api_requests row
+type repricedRequest struct {
+	bun.BaseModel `bun:"table:api_requests,alias:api_request"`
+
+	ID        uuid.UUID `bun:"id,pk,type:uuid"`
+	Cost      float64   `bun:"cost,type:numeric"`
+	UpdatedAt time.Time `bun:"updated_at,type:timestamptz"`
+}
+
+// Reprice recomputes the cost of the completed requests made in [from, to) at the
+// price in effect when each was made, e.g. after a price was corrected or added late.
+// Requests whose model has no price at that time are counted as unpriced and keep their cost.
+// Requests served with an organization's own provider key are never priced. The window is
+// re-priced in pages, each updated in a single statement, so a re-pricing that fails part
+// way keeps the pages it finished and can simply be run again.
+func (p *Pricer) Reprice(ctx context.Context, from, to time.Time) (*RepriceResult, error) {
+	result := &RepriceResult{}
+	history := make(priceHistory)
+
+	var last *models.APIRequest
+	for {
+		var requests []models.APIRequest
+		query := p.db.NewSelect().
+			Model(&requests).
+			Column("id", "created_at", "model_id", "input_tokens", "cached_input_tokens", "output_tokens", "input_images", "cost").
+			Where("status = ?", "completed").
+			Where("model_id IS NOT NULL").
+			Where("NOT byok").
+			Where("created_at >= ?", from).
+			Where("created_at < ?", to).
+			OrderExpr("created_at, id").
+			Limit(repriceBatchSize)
+		if last != nil {
+			query = query.Where("(created_at, id) > (?, ?)", last.CreatedAt, last.ID)
+		}
+		if err := query.Scan(ctx); err != nil {
+			return nil, fmt.Errorf("failed to load api requests: %w", err)
+		}
+		if len(requests) == 0 {
+			break
+		}
+
+		if err := p.loadHistory(ctx, history, requests); err != nil {
+			return nil, err
+		}
+
+		now := time.Now()
+		repriced := make([]repricedRequest, 0, len(requests))
+		for _, request := range requests {
+			price := history.at(*request.ModelID, request.CreatedAt)
+			if price == nil {
+				slog.Warn("Request could not be re-priced", "request_id", request.ID, "error", ErrPriceNotFound)
+				result.Unpriced++
+				continue
+			}
+
+			cost := price.Cost(Quantity{
+				InputTokens:       request.InputTokens,
+				CachedInputTokens: request.CachedTokens,
+				OutputTokens:      request.OutputTokens,
+				Images:            request.InputImages,
+			})
+			repriced = append(repriced, repricedRequest{ID: request.ID, Cost: cost, UpdatedAt: now})
+		}
+
+		if len(repriced) > 0 {
+			_, err := p.db.NewUpdate().
+				Model(&repriced).
+				Bulk().
+				Exec(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("failed to update api request costs: %w", err)
+			}
+			result.Repriced += len(repriced)
+		}
+
+		if len(requests) < repriceBatchSize {
+			break
+		}
+		last = &requests[len(requests)-1]
+	}
+
+	return result, nil
+}
+
+// priceHistory holds the prices of several models, latest first
+type priceHistory map[uuid.UUID][]*Price
+
+// loadHistory adds the price history of the models the requests were served by that
+// history does not hold yet
+func (p *Pricer) loadHistory(ctx context.Context, history priceHistory, requests []models.APIRequest) error {
+	var modelIDs []uuid.UUID
+	for _, request := range requests {
+		if _, ok := history[*request.ModelID]; !ok {
+			history[*request.ModelID] = nil
+			modelIDs = append(modelIDs, *request.ModelID)
+		}
+	}
+	if len(modelIDs) == 0 {
+		return nil
+	}
+
+	var rows []models.ModelPrice
+	err := p.db.NewSelect().
+		Model(&rows).
+		Where("model_id IN (?)", bun.In(modelIDs)).
+		OrderExpr("effective_from DESC").
+		Scan(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load model prices: %w", err)
+	}
+
+	for i := range rows {
+		price, err := toPrice(&rows[i])
+		if err != nil {
+			return err
+		}
+		history[rows[i].ModelID] = append(history[rows[i].ModelID], price)
+	}
+	return nil
+}
+
+// at returns the price of the model in effect at the given time, or nil if there is none
+func (h priceHistory) at(modelID uuid.UUID, at time.Time) *Price {
+	for _, price := range h[modelID] {
+		if !price.EffectiveFrom.After(at) {
+			return price
+		}
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+)
+
+// RouteFilter reports whether a request may be served by the named provider with the model
+type RouteFilter func(provider, model string) bool
+
+type routeFilterKey struct{}
+
+// WithRouteFilter returns a context whose requests are only routed to the provider/model
+// pairs filter allows, e.g. those an API key restricted to some models may use. Aliases
+// and fallbacks are resolved before filtering, so neither can lead outside the filter.
+func WithRouteFilter(ctx context.Context, filter RouteFilter) context.Context {
+	return context.WithValue(ctx, routeFilterKey{}, filter)
+}
+
+// permitted drops the candidates the route filter attached to ctx does not allow. It
+// fails when none is left for the requested model.
+func permitted(ctx context.Context, model string, chain []candidate) ([]candidate, error) {
+	filter, ok := ctx.Value(routeFilterKey{}).(RouteFilter)
+	if !ok {
+		return chain, nil
+	}
+
+	allowed := chain[:0:0]
+	for _, c := range chain {
+		if filter(c.provider.Name(), c.model) {
+			allowed = append(allowed, c)
+		}
+	}
+	if len(allowed) == 0 {
+		return nil, &Error{
+			Kind:    ErrorKindPermission,
+			Message: fmt.Sprintf("not permitted to use model %s with any provider serving it", model),
+			Param:   "model",
+		}
+	}
+	return allowed, nil
+}
@@ -7,9 +7,13 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 	"time"
 )
 
+// defaultAnthropicMaxTokens is used when a request omits max_tokens, which Anthropic requires
+const defaultAnthropicMaxTokens = 4096
+
 // AnthropicProvider implements the Provider interface for Anthropic
 type AnthropicProvider struct {
 	config Config
@@ -18,32 +22,38 @@ type AnthropicProvider struct {
 
 // NewAnthropicProvider creates a new Anthropic provider instance
 func NewAnthropicProvider(config Config) *AnthropicProvider {
+	if config.Name == "" {
+		config.Name = "anthropic"
+	}
 	if config.BaseURL == "" {
 		config.BaseURL = "https://api.anthropic.com/v1"
 	}
 	if config.Timeout == 0 {
 		config.Timeout = 30
 	}
-	if config.MaxRetries == 0 {
-		config.MaxRetries = 3
-	}
 
-	return &AnthropicProvider{
-		config: config,
-		client: &http.Client{
-			Timeout: time.Duration(config.Timeout) * time.Second,
-		},
-	}
+	p := &AnthropicProvider{config: config}
+	p.client = newHTTPClient(config, p.authorize)
+	return p
 }
 
 // Name returns the provider name
 func (p *AnthropicProvider) Name() string {
-	return "anthropic"
+	return p.config.Name
+}
+
+// MaxRetries returns the retry limit configured for Anthropic, and false when the router's
+// retry policy applies
+func (p *AnthropicProvider) MaxRetries() (int, bool) {
+	return p.config.RetryLimit()
 }
 
 // SendRequest sends a request to Anthropic
 func (p *AnthropicProvider) SendRequest(ctx context.Context, req *Request) (*Response, error) {
-	anthropicReq := p.convertToAnthropicRequest(req)
+	anthropicReq, err := p.convertToAnthropicRequest(req)
+	if err != nil {
+		return nil, err
+	}
 
 	jsonData, err := json.Marshal(anthropicReq)
 	if err != nil {
@@ -59,13 +69,12 @@ func (p *AnthropicProvider) SendRequest(ctx context.Context, req *Request) (*Res
 
 	resp, err := p.client.Do(httpReq)
 	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
+		return nil, newTransportError(p.Name(), err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body))
+		return nil, newHTTPError(p.Name(), resp)
 	}
 
 	var anthropicResp AnthropicResponse
@@ -78,7 +87,10 @@ func (p *AnthropicProvider) SendRequest(ctx context.Context, req *Request) (*Res
 
 // SendStreamRequest sends a streaming request to Anthropic
 func (p *AnthropicProvider) SendStreamRequest(ctx context.Context, req *Request) (io.ReadCloser, error) {
-	anthropicReq := p.convertToAnthropicRequest(req)
+	anthropicReq, err := p.convertToAnthropicRequest(req)
+	if err != nil {
+		return nil, err
+	}
 	anthropicReq.Stream = true
 
 	jsonData, err := json.Marshal(anthropicReq)
@@ -95,18 +107,29 @@ func (p *AnthropicProvider) SendStreamRequest(ctx context.Context, req *Request)
 
 	resp, err := p.client.Do(httpReq)
 	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
+		return nil, newTransportError(p.Name(), err)
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		resp.Body.Close()
-		return nil, fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body))
+		defer resp.Body.Close()
+		return nil, newHTTPError(p.Name(), resp)
 	}
 
 	return resp.Body, nil
 }
 
+// NewStreamDecoder wraps an Anthropic event stream in a decoder that emits unified chunks
+func (p *AnthropicProvider) NewStreamDecoder(body io.ReadCloser, req *Request) StreamDecoder {
+	return &anthropicStreamDecoder{
+		provider:   p.Name(),
+		body:       body,
+		reader:     newSSEReader(body),
+		model:      req.Model,
+		created:    time.Now().Unix(),
+		toolBlocks: make(map[int]int),
+	}
+}
+
 // GetModels returns the list of available Anthropic models
 func (p *AnthropicProvider) GetModels(ctx context.Context) ([]ModelInfo, error) {
 	// Anthropic doesn't have a models endpoint, so we return hardcoded models
@@ -159,31 +182,10 @@ func (p *AnthropicProvider) ValidateModel(ctx context.Context, modelID string) e
 	return err
 }
 
-// GetPricing returns the pricing information for an Anthropic model
-func (p *AnthropicProvider) GetPricing(ctx context.Context, modelID string) (*Pricing, error) {
-	// Anthropic pricing data (as of 2024)
-	pricingMap := map[string]Pricing{
-		"claude-3-5-sonnet-20241022": {InputCost: 0.003, OutputCost: 0.015, Currency: "usd"},
-		"claude-3-5-haiku-20241022":  {InputCost: 0.0008, OutputCost: 0.004, Currency: "usd"},
-		"claude-3-opus-20240229":     {InputCost: 0.015, OutputCost: 0.075, Currency: "usd"},
-	}
-
-	if pricing, exists := pricingMap[modelID]; exists {
-		return &pricing, nil
-	}
-
-	// Default pricing for unknown models
-	return &Pricing{
-		InputCost:  0.003,
-		OutputCost: 0.015,
-		Currency:   "usd",
-	}, nil
-}
-
 // setHeaders sets the required headers for Anthropic API
 func (p *AnthropicProvider) setHeaders(req *http.Request) {
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("x-api-key", p.config.APIKey)
+	p.authorize(req, p.config.APIKey)
 	req.Header.Set("anthropic-version", "2023-06-01")
 
 	for key, value := range p.config.Headers {
@@ -191,76 +193,392 @@ func (p *AnthropicProvider) setHeaders(req *http.Request) {
 	}
 }
 
+// authorize sets the API key on req
+func (p *AnthropicProvider) authorize(req *http.Request, key string) {
+	req.Header.Set("x-api-key", key)
+}
+
 // convertToAnthropicRequest converts our unified request to Anthropic format
-func (p *AnthropicProvider) convertToAnthropicRequest(req *Request) AnthropicRequest {
-	return AnthropicRequest{
+func (p *AnthropicProvider) convertToAnthropicRequest(req *Request) (AnthropicRequest, error) {
+	maxTokens := req.MaxTokens
+	if maxTokens == 0 {
+		maxTokens = defaultAnthropicMaxTokens
+	}
+
+	system, messages, err := p.convertMessages(req.Messages)
+	if err != nil {
+		return AnthropicRequest{}, err
+	}
+
+	anthropicReq := AnthropicRequest{
 		Model:         req.Model,
-		MaxTokens:     req.MaxTokens,
+		MaxTokens:     maxTokens,
+		System:        system,
 		Temperature:   req.Temperature,
 		TopP:          req.TopP,
 		Stream:        req.Stream,
 		StopSequences: req.Stop,
-		Messages:      p.convertMessages(req.Messages),
+		Messages:      messages,
 	}
+
+	for _, tool := range req.Tools {
+		schema := tool.Function.Parameters
+		if len(schema) == 0 {
+			// Anthropic requires an input schema even for functions without parameters
+			schema = json.RawMessage(`{"type":"object","properties":{}}`)
+		}
+		anthropicReq.Tools = append(anthropicReq.Tools, AnthropicTool{
+			Name:        tool.Function.Name,
+			Description: tool.Function.Description,
+			InputSchema: schema,
+		})
+	}
+
+	// Anthropic rejects a tool choice without tools
+	if req.ToolChoice != nil && len(req.Tools) > 0 {
+		anthropicReq.ToolChoice = convertToAnthropicToolChoice(req.ToolChoice)
+	}
+
+	return anthropicReq, nil
 }
 
-// convertMessages converts our message format to Anthropic format
-func (p *AnthropicProvider) convertMessages(messages []Message) []AnthropicMessage {
+// convertToAnthropicToolChoice maps an OpenAI tool choice to Anthropic's, which names
+// "required" as "any" and a specific function as a "tool" choice
+func convertToAnthropicToolChoice(choice *ToolChoice) *AnthropicToolChoice {
+	switch {
+	case choice.Function != "":
+		return &AnthropicToolChoice{Type: "tool", Name: choice.Function}
+	case choice.Mode == "required":
+		return &AnthropicToolChoice{Type: "any"}
+	default:
+		return &AnthropicToolChoice{Type: choice.Mode}
+	}
+}
+
+// convertMessages converts our message format to Anthropic format. Anthropic takes
+// system prompts as a top-level field, so system messages are joined and returned separately.
+// Tool calls become tool_use blocks and tool messages become tool_result blocks of a user
+// message; consecutive messages of the same role are merged, as Anthropic expects results
+// of parallel tool calls in a single message.
+func (p *AnthropicProvider) convertMessages(messages []Message) (string, []AnthropicMessage, error) {
+	var system []string
 	var anthropicMessages []AnthropicMessage
-	for _, msg := range messages {
+	for i, msg := range messages {
+		if msg.Role == "system" {
+			system = append(system, msg.Content.Text())
+			continue
+		}
+
+		role := msg.Role
+		var blocks []AnthropicContent
+		switch msg.Role {
+		case "tool":
+			role = "user"
+			blocks = append(blocks, AnthropicContent{
+				Type:      "tool_result",
+				ToolUseID: msg.ToolCallID,
+				Content:   msg.Content.Text(),
+			})
+		default:
+			for j, part := range msg.Content {
+				block, err := p.convertContentPart(part)
+				if err != nil {
+					err.Param = fmt.Sprintf("messages[%d].content[%d]", i, j)
+					return "", nil, err
+				}
+				if block != nil {
+					blocks = append(blocks, *block)
+				}
+			}
+			for _, call := range msg.ToolCalls {
+				input := json.RawMessage(call.Function.Arguments)
+				if len(input) == 0 {
+					input = json.RawMessage("{}")
+				}
+				if !json.Valid(input) {
+					return "", nil, newInvalidRequestError(p.Name(), fmt.Sprintf("messages[%d].tool_calls", i),
+						"arguments of tool call %s are not valid JSON", call.ID)
+				}
+				blocks = append(blocks, AnthropicContent{
+					Type:  "tool_use",
+					ID:    call.ID,
+					Name:  call.Function.Name,
+					Input: input,
+				})
+			}
+		}
+
+		if n := len(anthropicMessages); n > 0 && anthropicMessages[n-1].Role == role {
+			anthropicMessages[n-1].Content = append(anthropicMessages[n-1].Content, blocks...)
+			continue
+		}
 		anthropicMessages = append(anthropicMessages, AnthropicMessage{
-			Role:    msg.Role,
-			Content: msg.Content,
+			Role:    role,
+			Content: blocks,
 		})
 	}
-	return anthropicMessages
+	return strings.Join(system, "\n\n"), anthropicMessages, nil
+}
+
+// convertContentPart converts a content part to an Anthropic content block. Images are sent
+// as base64 or URL sources and PDF files as document blocks; audio is not supported.
+// Empty text parts, which Anthropic rejects, are dropped.
+func (p *AnthropicProvider) convertContentPart(part ContentPart) (*AnthropicContent, *Error) {
+	switch part.Type {
+	case ContentPartText:
+		if part.Text == "" {
+			return nil, nil
+		}
+		return &AnthropicContent{Type: "text", Text: part.Text}, nil
+
+	case ContentPartImageURL:
+		if part.ImageURL == nil {
+			break
+		}
+		if mediaType, data, ok := parseDataURL(part.ImageURL.URL); ok {
+			return &AnthropicContent{
+				Type:   "image",
+				Source: &AnthropicSource{Type: "base64", MediaType: mediaType, Data: data},
+			}, nil
+		}
+		return &AnthropicContent{
+			Type:   "image",
+			Source: &AnthropicSource{Type: "url", URL: part.ImageURL.URL},
+		}, nil
+
+	case ContentPartFile:
+		if part.File == nil {
+			break
+		}
+		mediaType, data, ok := parseDataURL(part.File.FileData)
+		if !ok || mediaType != "application/pdf" {
+			return nil, newInvalidRequestError(p.Name(), "", "only PDF files given as data URLs are supported")
+		}
+		return &AnthropicContent{
+			Type:   "document",
+			Source: &AnthropicSource{Type: "base64", MediaType: mediaType, Data: data},
+		}, nil
+	}
+
+	return nil, newInvalidRequestError(p.Name(), "", "content part type %s is not supported", part.Type)
 }
 
 // convertFromAnthropicResponse converts Anthropic response to our unified format
 func (p *AnthropicProvider) convertFromAnthropicResponse(resp *AnthropicResponse, model string) *Response {
-	var choices []Choice
+	var text strings.Builder
+	var toolCalls []ToolCall
 	for _, content := range resp.Content {
-		if content.Type == "text" {
-			choices = append(choices, Choice{
-				Index: 0,
-				Message: Message{
-					Role:    "assistant",
-					Content: content.Text,
+		switch content.Type {
+		case "text":
+			text.WriteString(content.Text)
+		case "tool_use":
+			toolCalls = append(toolCalls, ToolCall{
+				ID:   content.ID,
+				Type: "function",
+				Function: FunctionCall{
+					Name:      content.Name,
+					Arguments: string(content.Input),
 				},
-				FinishReason: resp.StopReason,
 			})
 		}
 	}
 
+	if resp.Model != "" {
+		model = resp.Model
+	}
+
 	return &Response{
 		ID:      resp.ID,
 		Object:  "chat.completion",
 		Created: time.Now().Unix(),
 		Model:   model,
-		Choices: choices,
-		Usage: Usage{
-			PromptTokens:     resp.Usage.InputTokens,
-			CompletionTokens: resp.Usage.OutputTokens,
-			TotalTokens:      resp.Usage.InputTokens + resp.Usage.OutputTokens,
+		Choices: []Choice{
+			{
+				Index: 0,
+				Message: Message{
+					Role:      "assistant",
+					Content:   TextContent(text.String()),
+					ToolCalls: toolCalls,
+				},
+				FinishReason: convertAnthropicStopReason(resp.StopReason),
+			},
+		},
+		Usage: resp.Usage.usage(),
+	}
+}
+
+// convertAnthropicStopReason maps Anthropic stop reasons to OpenAI finish reasons
+func convertAnthropicStopReason(reason string) string {
+	switch reason {
+	case "end_turn", "stop_sequence":
+		return "stop"
+	case "max_tokens":
+		return "length"
+	case "tool_use":
+		return "tool_calls"
+	default:
+		return reason
+	}
+}
+
+// anthropicStreamDecoder translates Anthropic message events into unified chunks
+type anthropicStreamDecoder struct {
+	provider string
+	body     io.ReadCloser
+	reader   *sseReader
+	id       string
+	model    string
+	created  int64
+	usage    AnthropicUsage
+	done     bool
+
+	// toolBlocks maps the content block index of each tool_use block to its tool call index
+	toolBlocks map[int]int
+}
+
+// Recv returns the next chunk translated from the Anthropic stream
+func (d *anthropicStreamDecoder) Recv() (*StreamResponse, error) {
+	for {
+		if d.done {
+			return nil, io.EOF
+		}
+
+		event, err := d.reader.next()
+		if err != nil {
+			return nil, err
+		}
+		if event.Data == "" {
+			continue
+		}
+
+		var streamEvent AnthropicStreamEvent
+		if err := json.Unmarshal([]byte(event.Data), &streamEvent); err != nil {
+			return nil, fmt.Errorf("failed to decode stream event: %w", err)
+		}
+
+		switch streamEvent.Type {
+		case "message_start":
+			if streamEvent.Message != nil {
+				d.id = streamEvent.Message.ID
+				if streamEvent.Message.Model != "" {
+					d.model = streamEvent.Message.Model
+				}
+				d.usage = streamEvent.Message.Usage
+			}
+			return d.chunk(StreamDelta{Role: "assistant"}, nil), nil
+
+		case "content_block_start":
+			block := streamEvent.ContentBlock
+			if block == nil || block.Type != "tool_use" {
+				continue
+			}
+			index := len(d.toolBlocks)
+			d.toolBlocks[streamEvent.Index] = index
+			return d.chunk(StreamDelta{ToolCalls: []ToolCallDelta{{
+				Index:    index,
+				ID:       block.ID,
+				Type:     "function",
+				Function: FunctionCall{Name: block.Name},
+			}}}, nil), nil
+
+		case "content_block_delta":
+			if streamEvent.Delta == nil {
+				continue
+			}
+			switch streamEvent.Delta.Type {
+			case "text_delta":
+				return d.chunk(StreamDelta{Content: streamEvent.Delta.Text}, nil), nil
+			case "input_json_delta":
+				index, ok := d.toolBlocks[streamEvent.Index]
+				if !ok {
+					continue
+				}
+				return d.chunk(StreamDelta{ToolCalls: []ToolCallDelta{{
+					Index:    index,
+					Function: FunctionCall{Arguments: streamEvent.Delta.PartialJSON},
+				}}}, nil), nil
+			}
+
+		case "message_delta":
+			if streamEvent.Usage != nil {
+				d.usage.OutputTokens = streamEvent.Usage.OutputTokens
+			}
+			if streamEvent.Delta == nil || streamEvent.Delta.StopReason == "" {
+				continue
+			}
+			return d.chunk(StreamDelta{}, stringPtr(convertAnthropicStopReason(streamEvent.Delta.StopReason))), nil
+
+		case "message_stop":
+			// Emit a trailing usage chunk, mirroring OpenAI's include_usage behaviour
+			d.done = true
+			chunk := d.chunk(StreamDelta{}, nil)
+			chunk.Choices = []StreamChoice{}
+			usage := d.usage.usage()
+			chunk.Usage = &usage
+			return chunk, nil
+
+		case "error":
+			if streamEvent.Error != nil {
+				return nil, newStreamError(d.provider, streamEvent.Error)
+			}
+			return nil, newStreamError(d.provider, &APIError{Message: event.Data})
+		}
+	}
+}
+
+// chunk builds a unified stream chunk for the current message
+func (d *anthropicStreamDecoder) chunk(delta StreamDelta, finishReason *string) *StreamResponse {
+	return &StreamResponse{
+		ID:      d.id,
+		Object:  "chat.completion.chunk",
+		Created: d.created,
+		Model:   d.model,
+		Choices: []StreamChoice{
+			{
+				Index:        0,
+				Delta:        delta,
+				FinishReason: finishReason,
+			},
 		},
 	}
 }
 
+// Close closes the underlying stream
+func (d *anthropicStreamDecoder) Close() error {
+	return d.body.Close()
+}
+
 // AnthropicRequest represents the request format for Anthropic API
 type AnthropicRequest struct {
-	Model         string             `json:"model"`
-	MaxTokens     int                `json:"max_tokens,omitempty"`
-	Temperature   float64            `json:"temperature,omitempty"`
-	TopP          float64            `json:"top_p,omitempty"`
-	Stream        bool               `json:"stream,omitempty"`
-	StopSequences []string           `json:"stop_sequences,omitempty"`
-	Messages      []AnthropicMessage `json:"messages"`
+	Model         string               `json:"model"`
+	MaxTokens     int                  `json:"max_tokens,omitempty"`
+	System        string               `json:"system,omitempty"`
+	Temperature   float64              `json:"temperature,omitempty"`
+	TopP          float64              `json:"top_p,omitempty"`
+	Stream        bool                 `json:"stream,omitempty"`
+	StopSequences []string             `json:"stop_sequences,omitempty"`
+	Messages      []AnthropicMessage   `json:"messages"`
+	Tools         []AnthropicTool      `json:"tools,omitempty"`
+	ToolChoice    *AnthropicToolChoice `json:"tool_choice,omitempty"`
 }
 
 // AnthropicMessage represents a message in Anthropic format
 type AnthropicMessage struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
+	Role    string             `json:"role"`
+	Content []AnthropicContent `json:"content"`
+}
+
+// AnthropicTool represents a tool definition in Anthropic format
+type AnthropicTool struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	InputSchema json.RawMessage `json:"input_schema"`
+}
+
+// AnthropicToolChoice represents a tool choice in Anthropic format
+type AnthropicToolChoice struct {
+	Type string `json:"type"`
+	Name string `json:"name,omitempty"`
 }
 
 // AnthropicResponse represents the response format from Anthropic API
@@ -275,14 +593,64 @@ type AnthropicResponse struct {
 	Usage        AnthropicUsage     `json:"usage"`
 }
 
-// AnthropicContent represents content in Anthropic response
+// AnthropicContent represents a content block in Anthropic format
 type AnthropicContent struct {
-	Type string `json:"type"`
-	Text string `json:"text"`
+	Type      string           `json:"type"`
+	Text      string           `json:"text,omitempty"`
+	ID        string           `json:"id,omitempty"`
+	Name      string           `json:"name,omitempty"`
+	Input     json.RawMessage  `json:"input,omitempty"`
+	ToolUseID string           `json:"tool_use_id,omitempty"`
+	Content   string           `json:"content,omitempty"`
+	Source    *AnthropicSource `json:"source,omitempty"`
+}
+
+// AnthropicSource represents the source of an image or document block in Anthropic format
+type AnthropicSource struct {
+	Type      string `json:"type"`
+	MediaType string `json:"media_type,omitempty"`
+	Data      string `json:"data,omitempty"`
+	URL       string `json:"url,omitempty"`
 }
 
-// AnthropicUsage represents usage information in Anthropic response
+// AnthropicUsage represents usage information in Anthropic response. Input tokens
+// exclude the tokens written to and read from the prompt cache.
 type AnthropicUsage struct {
-	InputTokens  int `json:"input_tokens"`
-	OutputTokens int `json:"output_tokens"`
+	InputTokens              int `json:"input_tokens"`
+	OutputTokens             int `json:"output_tokens"`
+	CacheCreationInputTokens int `json:"cache_creation_input_tokens,omitempty"`
+	CacheReadInputTokens     int `json:"cache_read_input_tokens,omitempty"`
+}
+
+// usage converts Anthropic usage to our unified format, where prompt tokens include cached tokens
+func (u AnthropicUsage) usage() Usage {
+	prompt := u.InputTokens + u.CacheCreationInputTokens + u.CacheReadInputTokens
+	usage := Usage{
+		PromptTokens:     prompt,
+		CompletionTokens: u.OutputTokens,
+		TotalTokens:      prompt + u.OutputTokens,
+	}
+	if u.CacheReadInputTokens > 0 {
+		usage.PromptTokensDetails = &PromptTokensDetails{CachedTokens: u.CacheReadInputTokens}
+	}
+	return usage
+}
+
+// AnthropicStreamEvent represents an event in an Anthropic message stream
+type AnthropicStreamEvent struct {
+	Type         string                `json:"type"`
+	Index        int                   `json:"index"`
+	Message      *AnthropicResponse    `json:"message,omitempty"`
+	ContentBlock *AnthropicContent     `json:"content_block,omitempty"`
+	Delta        *AnthropicStreamDelta `json:"delta,omitempty"`
+	Usage        *AnthropicUsage       `json:"usage,omitempty"`
+	Error        *APIError             `json:"error,omitempty"`
+}
+
+// AnthropicStreamDelta represents the delta payload of an Anthropic stream event
+type AnthropicStreamDelta struct {
+	Type        string `json:"type"`
+	Text        string `json:"text,omitempty"`
+	PartialJSON string `json:"partial_json,omitempty"`
+	StopReason  string `json:"stop_reason,omitempty"`
 }
@@ -0,0 +1,131 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// defaultAzureAPIVersion is the Azure OpenAI data-plane API version used when none is configured
+const defaultAzureAPIVersion = "2024-10-21"
+
+// AzureOpenAIProvider implements the Provider interface for Azure OpenAI. Azure serves the
+// OpenAI wire protocol per deployment, so requests, responses and streams reuse the OpenAI
+// conversion while URLs are built from the deployment a public model ID is mapped to.
+type AzureOpenAIProvider struct {
+	*OpenAIProvider
+	apiVersion  string
+	deployments map[string]string
+}
+
+// NewAzureOpenAIProvider creates a new Azure OpenAI provider instance. config.BaseURL is the
+// resource endpoint, e.g. https://my-resource.openai.azure.com. config.Options may carry
+// "api_version" and "deployments", a map of public model IDs to deployment names, and
+// "endpoint", which takes the place of the base URL for organizations that bring the key
+// of their own resource.
+func NewAzureOpenAIProvider(config Config) (*AzureOpenAIProvider, error) {
+	if config.Name == "" {
+		config.Name = "azure"
+	}
+	if endpoint, ok := config.Options["endpoint"].(string); ok && endpoint != "" {
+		if !azureEndpoint(endpoint) {
+			return nil, fmt.Errorf("azure provider %s: %s is not an Azure OpenAI resource endpoint", config.Name, endpoint)
+		}
+		config.BaseURL = endpoint
+	}
+	if config.BaseURL == "" {
+		return nil, fmt.Errorf("azure provider %s requires a resource endpoint", config.Name)
+	}
+	config.BaseURL = strings.TrimRight(config.BaseURL, "/")
+
+	p := &AzureOpenAIProvider{
+		OpenAIProvider: newOpenAIProvider(config.Name, config, true),
+		apiVersion:     defaultAzureAPIVersion,
+		deployments:    make(map[string]string),
+	}
+
+	if version, ok := config.Options["api_version"].(string); ok && version != "" {
+		p.apiVersion = version
+	}
+	if deployments, ok := config.Options["deployments"].(map[string]interface{}); ok {
+		for model, deployment := range deployments {
+			name, ok := deployment.(string)
+			if !ok || name == "" {
+				return nil, fmt.Errorf("azure provider %s: invalid deployment for model %s", config.Name, model)
+			}
+			p.deployments[model] = name
+		}
+	}
+
+	p.apiKeyHeader = "api-key"
+	p.endpoint = p.deploymentURL
+	p.completionModels = openAICompletionModel
+
+	return p, nil
+}
+
+// azureEndpoint reports whether endpoint is the HTTPS endpoint of an Azure OpenAI resource.
+// Endpoints come from organizations' own settings, so requests must not be sent anywhere else.
+func azureEndpoint(endpoint string) bool {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Scheme != "https" || u.User != nil {
+		return false
+	}
+	host := u.Hostname()
+	return strings.HasSuffix(host, ".openai.azure.com") || strings.HasSuffix(host, ".cognitiveservices.azure.com")
+}
+
+// deploymentURL returns the URL of an API path on the deployment serving model
+func (p *AzureOpenAIProvider) deploymentURL(model, path string) string {
+	return fmt.Sprintf("%s/openai/deployments/%s%s?api-version=%s",
+		p.config.BaseURL, url.PathEscape(p.deployment(model)), path, url.QueryEscape(p.apiVersion))
+}
+
+// deployment returns the deployment name for a public model ID. Unmapped models are
+// assumed to be deployed under their own name.
+func (p *AzureOpenAIProvider) deployment(model string) string {
+	if deployment, ok := p.deployments[model]; ok {
+		return deployment
+	}
+	return model
+}
+
+// GetModels returns the public model IDs mapped to deployments
+func (p *AzureOpenAIProvider) GetModels(ctx context.Context) ([]ModelInfo, error) {
+	ids := make([]string, 0, len(p.deployments))
+	for id := range p.deployments {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	models := make([]ModelInfo, 0, len(ids))
+	for _, id := range ids {
+		models = append(models, ModelInfo{
+			ID:      id,
+			Object:  "model",
+			OwnedBy: p.Name(),
+		})
+	}
+	return models, nil
+}
+
+// GetModelInfo returns detailed information about a specific model
+func (p *AzureOpenAIProvider) GetModelInfo(ctx context.Context, modelID string) (*ModelInfo, error) {
+	if _, ok := p.deployments[modelID]; !ok {
+		return nil, fmt.Errorf("model not found: %s", modelID)
+	}
+
+	return &ModelInfo{
+		ID:      modelID,
+		Object:  "model",
+		OwnedBy: p.Name(),
+	}, nil
+}
+
+// ValidateModel checks if a model is mapped to a deployment
+func (p *AzureOpenAIProvider) ValidateModel(ctx context.Context, modelID string) error {
+	_, err := p.GetModelInfo(ctx, modelID)
+	return err
+}
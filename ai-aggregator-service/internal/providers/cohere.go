@@ -0,0 +1,579 @@
+package providers
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// CohereProvider implements the Provider interface for Cohere
+type CohereProvider struct {
+	config Config
+	client *http.Client
+}
+
+// NewCohereProvider creates a new Cohere provider instance
+func NewCohereProvider(config Config) *CohereProvider {
+	if config.Name == "" {
+		config.Name = "cohere"
+	}
+	if config.BaseURL == "" {
+		config.BaseURL = "https://api.cohere.ai/v1"
+	}
+	if config.Timeout == 0 {
+		config.Timeout = 30
+	}
+
+	p := &CohereProvider{config: config}
+	p.client = newHTTPClient(config, p.authorize)
+	return p
+}
+
+// Name returns the provider name
+func (p *CohereProvider) Name() string {
+	return p.config.Name
+}
+
+// MaxRetries returns the retry limit configured for Cohere, and false when the router's
+// retry policy applies
+func (p *CohereProvider) MaxRetries() (int, bool) {
+	return p.config.RetryLimit()
+}
+
+// SendRequest sends a request to Cohere
+func (p *CohereProvider) SendRequest(ctx context.Context, req *Request) (*Response, error) {
+	if req.usesTools() {
+		return nil, newInvalidRequestError(p.Name(), "tools", "tool calling is not supported")
+	}
+	if !req.textOnly() {
+		return nil, newInvalidRequestError(p.Name(), "messages", "only text content is supported")
+	}
+
+	cohereReq := p.convertToCohereRequest(req)
+
+	jsonData, err := json.Marshal(cohereReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", p.config.BaseURL+"/chat", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	p.setHeaders(httpReq)
+
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		return nil, newTransportError(p.Name(), err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newHTTPError(p.Name(), resp)
+	}
+
+	var cohereResp CohereResponse
+	if err := json.NewDecoder(resp.Body).Decode(&cohereResp); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return p.convertFromCohereResponse(&cohereResp, req.Model), nil
+}
+
+// SendStreamRequest sends a streaming request to Cohere
+func (p *CohereProvider) SendStreamRequest(ctx context.Context, req *Request) (io.ReadCloser, error) {
+	if req.usesTools() {
+		return nil, newInvalidRequestError(p.Name(), "tools", "tool calling is not supported")
+	}
+	if !req.textOnly() {
+		return nil, newInvalidRequestError(p.Name(), "messages", "only text content is supported")
+	}
+
+	cohereReq := p.convertToCohereRequest(req)
+	cohereReq.Stream = true
+
+	jsonData, err := json.Marshal(cohereReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", p.config.BaseURL+"/chat", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	p.setHeaders(httpReq)
+
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		return nil, newTransportError(p.Name(), err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, newHTTPError(p.Name(), resp)
+	}
+
+	return resp.Body, nil
+}
+
+// NewStreamDecoder wraps a Cohere event stream in a decoder that emits unified chunks
+func (p *CohereProvider) NewStreamDecoder(body io.ReadCloser, req *Request) StreamDecoder {
+	// Cohere streams newline-delimited JSON events rather than Server-Sent Events
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), maxStreamLineSize)
+
+	return &cohereStreamDecoder{
+		provider: p.Name(),
+		body:     body,
+		scanner:  scanner,
+		model:    req.Model,
+		created:  time.Now().Unix(),
+	}
+}
+
+// MaxEmbeddingInputs returns how many texts Cohere accepts in one embed request
+func (p *CohereProvider) MaxEmbeddingInputs() int {
+	return 96
+}
+
+// CreateEmbeddings creates embeddings for the request's inputs
+func (p *CohereProvider) CreateEmbeddings(ctx context.Context, req *EmbeddingRequest) (*EmbeddingResponse, error) {
+	if req.Dimensions > 0 {
+		return nil, newInvalidRequestError(p.Name(), "dimensions", "dimensions is not supported")
+	}
+
+	// v3 embedding models require an input type; documents suit general-purpose use
+	inputType := req.InputType
+	if inputType == "" {
+		inputType = "search_document"
+	}
+
+	jsonData, err := json.Marshal(CohereEmbedRequest{
+		Model:     req.Model,
+		Texts:     req.Input,
+		InputType: inputType,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", p.config.BaseURL+"/embed", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	p.setHeaders(httpReq)
+
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		return nil, newTransportError(p.Name(), err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newHTTPError(p.Name(), resp)
+	}
+
+	var embedResp CohereEmbedResponse
+	if err := json.NewDecoder(resp.Body).Decode(&embedResp); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	data := make([]Embedding, 0, len(embedResp.Embeddings))
+	for i, embedding := range embedResp.Embeddings {
+		data = append(data, Embedding{
+			Object:    "embedding",
+			Embedding: embedding,
+			Index:     i,
+		})
+	}
+
+	inputTokens := embedResp.Meta.BilledUnits.InputTokens
+	return &EmbeddingResponse{
+		Object: "list",
+		Data:   data,
+		Model:  req.Model,
+		Usage: Usage{
+			PromptTokens: inputTokens,
+			TotalTokens:  inputTokens,
+		},
+	}, nil
+}
+
+// GetModels returns the list of available Cohere models
+func (p *CohereProvider) GetModels(ctx context.Context) ([]ModelInfo, error) {
+	var models []ModelInfo
+	pageToken := ""
+
+	for {
+		query := url.Values{"page_size": {"1000"}}
+		if pageToken != "" {
+			query.Set("page_token", pageToken)
+		}
+
+		httpReq, err := http.NewRequestWithContext(ctx, "GET", p.config.BaseURL+"/models?"+query.Encode(), nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+
+		p.setHeaders(httpReq)
+
+		resp, err := p.client.Do(httpReq)
+		if err != nil {
+			return nil, newTransportError(p.Name(), err)
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			err := newHTTPError(p.Name(), resp)
+			resp.Body.Close()
+			return nil, err
+		}
+
+		var modelsResp CohereModelsResponse
+		err = json.NewDecoder(resp.Body).Decode(&modelsResp)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode response: %w", err)
+		}
+
+		for _, model := range modelsResp.Models {
+			models = append(models, p.convertModel(model))
+		}
+
+		if modelsResp.NextPageToken == "" {
+			return models, nil
+		}
+		pageToken = modelsResp.NextPageToken
+	}
+}
+
+// GetModelInfo returns detailed information about a specific model
+func (p *CohereProvider) GetModelInfo(ctx context.Context, modelID string) (*ModelInfo, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, "GET", p.config.BaseURL+"/models/"+url.PathEscape(modelID), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	p.setHeaders(httpReq)
+
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		return nil, newTransportError(p.Name(), err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("model not found: %s", modelID)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newHTTPError(p.Name(), resp)
+	}
+
+	var model CohereModel
+	if err := json.NewDecoder(resp.Body).Decode(&model); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	info := p.convertModel(model)
+	return &info, nil
+}
+
+// ValidateModel checks if a model is valid for Cohere
+func (p *CohereProvider) ValidateModel(ctx context.Context, modelID string) error {
+	_, err := p.GetModelInfo(ctx, modelID)
+	return err
+}
+
+// setHeaders sets the required headers for Cohere API
+func (p *CohereProvider) setHeaders(req *http.Request) {
+	req.Header.Set("Content-Type", "application/json")
+	p.authorize(req, p.config.APIKey)
+
+	for key, value := range p.config.Headers {
+		req.Header.Set(key, value)
+	}
+}
+
+// authorize sets the API key on req
+func (p *CohereProvider) authorize(req *http.Request, key string) {
+	req.Header.Set("Authorization", "Bearer "+key)
+}
+
+// convertModel converts a Cohere model to our unified format
+func (p *CohereProvider) convertModel(model CohereModel) ModelInfo {
+	return ModelInfo{
+		ID:          model.Name,
+		Object:      "model",
+		OwnedBy:     "cohere",
+		ContextSize: model.ContextLength,
+	}
+}
+
+// convertToCohereRequest converts our unified request to Cohere format
+func (p *CohereProvider) convertToCohereRequest(req *Request) CohereRequest {
+	preamble, history, message := p.convertMessages(req.Messages)
+
+	return CohereRequest{
+		Model:         req.Model,
+		Message:       message,
+		ChatHistory:   history,
+		Preamble:      preamble,
+		MaxTokens:     req.MaxTokens,
+		Temperature:   req.Temperature,
+		P:             req.TopP,
+		StopSequences: req.Stop,
+		Stream:        req.Stream,
+	}
+}
+
+// convertMessages converts our message format to Cohere format. Cohere takes the latest user
+// message separately from the chat history, and system prompts as a preamble.
+func (p *CohereProvider) convertMessages(messages []Message) (string, []CohereMessage, string) {
+	var preamble []string
+	var history []CohereMessage
+	for _, msg := range messages {
+		switch msg.Role {
+		case "system":
+			preamble = append(preamble, msg.Content.Text())
+		case "assistant":
+			history = append(history, CohereMessage{Role: "CHATBOT", Message: msg.Content.Text()})
+		default:
+			history = append(history, CohereMessage{Role: "USER", Message: msg.Content.Text()})
+		}
+	}
+
+	var message string
+	if n := len(history); n > 0 && history[n-1].Role == "USER" {
+		message = history[n-1].Message
+		history = history[:n-1]
+	}
+
+	return strings.Join(preamble, "\n\n"), history, message
+}
+
+// convertFromCohereResponse converts Cohere response to our unified format
+func (p *CohereProvider) convertFromCohereResponse(resp *CohereResponse, model string) *Response {
+	id := resp.ResponseID
+	if id == "" {
+		id = resp.GenerationID
+	}
+
+	return &Response{
+		ID:      id,
+		Object:  "chat.completion",
+		Created: time.Now().Unix(),
+		Model:   model,
+		Choices: []Choice{
+			{
+				Index: 0,
+				Message: Message{
+					Role:    "assistant",
+					Content: TextContent(resp.Text),
+				},
+				FinishReason: convertCohereFinishReason(resp.FinishReason),
+			},
+		},
+		Usage: resp.Meta.usage(),
+	}
+}
+
+// convertCohereFinishReason maps Cohere finish reasons to OpenAI finish reasons
+func convertCohereFinishReason(reason string) string {
+	switch reason {
+	case "":
+		return ""
+	case "COMPLETE", "STOP_SEQUENCE":
+		return "stop"
+	case "MAX_TOKENS", "ERROR_LIMIT":
+		return "length"
+	case "ERROR_TOXIC":
+		return "content_filter"
+	default:
+		return strings.ToLower(reason)
+	}
+}
+
+// cohereStreamDecoder translates Cohere stream events into unified chunks
+type cohereStreamDecoder struct {
+	provider string
+	body     io.ReadCloser
+	scanner  *bufio.Scanner
+	id       string
+	model    string
+	created  int64
+	usage    *Usage
+	done     bool
+}
+
+// Recv returns the next chunk translated from the Cohere stream
+func (d *cohereStreamDecoder) Recv() (*StreamResponse, error) {
+	for {
+		if d.done {
+			if d.usage == nil {
+				return nil, io.EOF
+			}
+			// Emit a trailing usage chunk, mirroring OpenAI's include_usage behaviour
+			chunk := d.chunk(nil)
+			chunk.Choices = []StreamChoice{}
+			chunk.Usage = d.usage
+			d.usage = nil
+			return chunk, nil
+		}
+
+		if !d.scanner.Scan() {
+			if err := d.scanner.Err(); err != nil {
+				return nil, err
+			}
+			return nil, io.EOF
+		}
+
+		line := bytes.TrimSpace(d.scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var event CohereStreamEvent
+		if err := json.Unmarshal(line, &event); err != nil {
+			return nil, fmt.Errorf("failed to decode stream event: %w", err)
+		}
+
+		switch event.EventType {
+		case "stream-start":
+			d.id = event.GenerationID
+			return d.chunk(&StreamChoice{Delta: StreamDelta{Role: "assistant"}}), nil
+
+		case "text-generation":
+			return d.chunk(&StreamChoice{Delta: StreamDelta{Content: event.Text}}), nil
+
+		case "stream-end":
+			d.done = true
+			if event.FinishReason == "ERROR" {
+				return nil, newStreamError(d.provider, &APIError{Message: "stream ended with an error"})
+			}
+			if event.Response != nil {
+				usage := event.Response.Meta.usage()
+				d.usage = &usage
+			}
+			return d.chunk(&StreamChoice{FinishReason: stringPtr(convertCohereFinishReason(event.FinishReason))}), nil
+		}
+	}
+}
+
+// chunk builds a unified stream chunk for the current response
+func (d *cohereStreamDecoder) chunk(choice *StreamChoice) *StreamResponse {
+	chunk := &StreamResponse{
+		ID:      d.id,
+		Object:  "chat.completion.chunk",
+		Created: d.created,
+		Model:   d.model,
+	}
+	if choice != nil {
+		chunk.Choices = []StreamChoice{*choice}
+	}
+	return chunk
+}
+
+// Close closes the underlying stream
+func (d *cohereStreamDecoder) Close() error {
+	return d.body.Close()
+}
+
+// CohereRequest represents the chat request format for Cohere API
+type CohereRequest struct {
+	Model         string          `json:"model"`
+	Message       string          `json:"message"`
+	ChatHistory   []CohereMessage `json:"chat_history,omitempty"`
+	Preamble      string          `json:"preamble,omitempty"`
+	MaxTokens     int             `json:"max_tokens,omitempty"`
+	Temperature   float64         `json:"temperature,omitempty"`
+	P             float64         `json:"p,omitempty"`
+	StopSequences []string        `json:"stop_sequences,omitempty"`
+	Stream        bool            `json:"stream,omitempty"`
+}
+
+// CohereMessage represents a chat history entry in Cohere format
+type CohereMessage struct {
+	Role    string `json:"role"`
+	Message string `json:"message"`
+}
+
+// CohereResponse represents the chat response format from Cohere API
+type CohereResponse struct {
+	ResponseID   string     `json:"response_id"`
+	GenerationID string     `json:"generation_id"`
+	Text         string     `json:"text"`
+	FinishReason string     `json:"finish_reason"`
+	Meta         CohereMeta `json:"meta"`
+}
+
+// CohereMeta represents response metadata in Cohere format
+type CohereMeta struct {
+	BilledUnits CohereUnits `json:"billed_units"`
+	Tokens      CohereUnits `json:"tokens"`
+}
+
+// CohereUnits represents token counts in Cohere format
+type CohereUnits struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+// usage converts Cohere metadata to our unified format, preferring billed token counts
+func (m CohereMeta) usage() Usage {
+	units := m.BilledUnits
+	if units.InputTokens == 0 && units.OutputTokens == 0 {
+		units = m.Tokens
+	}
+
+	return Usage{
+		PromptTokens:     units.InputTokens,
+		CompletionTokens: units.OutputTokens,
+		TotalTokens:      units.InputTokens + units.OutputTokens,
+	}
+}
+
+// CohereStreamEvent represents an event in a Cohere chat stream
+type CohereStreamEvent struct {
+	EventType    string          `json:"event_type"`
+	GenerationID string          `json:"generation_id,omitempty"`
+	Text         string          `json:"text,omitempty"`
+	FinishReason string          `json:"finish_reason,omitempty"`
+	Response     *CohereResponse `json:"response,omitempty"`
+}
+
+// CohereEmbedRequest represents the embed request format for Cohere API
+type CohereEmbedRequest struct {
+	Model     string   `json:"model"`
+	Texts     []string `json:"texts"`
+	InputType string   `json:"input_type,omitempty"`
+}
+
+// CohereEmbedResponse represents the embed response format from Cohere API
+type CohereEmbedResponse struct {
+	ID         string      `json:"id"`
+	Embeddings [][]float64 `json:"embeddings"`
+	Meta       CohereMeta  `json:"meta"`
+}
+
+// CohereModelsResponse represents the models response from Cohere
+type CohereModelsResponse struct {
+	Models        []CohereModel `json:"models"`
+	NextPageToken string        `json:"next_page_token"`
+}
+
+// CohereModel represents a model in Cohere
+type CohereModel struct {
+	Name          string   `json:"name"`
+	Endpoints     []string `json:"endpoints"`
+	ContextLength int      `json:"context_length"`
+}
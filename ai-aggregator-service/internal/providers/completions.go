@@ -0,0 +1,296 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// CompletionRequest represents a unified legacy text completion request
+type CompletionRequest struct {
+	Model       string   `json:"model"`
+	Prompt      []string `json:"prompt"`
+	Suffix      string   `json:"suffix,omitempty"`
+	MaxTokens   int      `json:"max_tokens,omitempty"`
+	Temperature float64  `json:"temperature,omitempty"`
+	TopP        float64  `json:"top_p,omitempty"`
+	N           int      `json:"n,omitempty"`
+	Stream      bool     `json:"stream,omitempty"`
+	Logprobs    *int     `json:"logprobs,omitempty"`
+	Echo        bool     `json:"echo,omitempty"`
+	Stop        []string `json:"stop,omitempty"`
+}
+
+// choices returns the number of choices generated for each prompt
+func (r *CompletionRequest) choices() int {
+	if r.N < 1 {
+		return 1
+	}
+	return r.N
+}
+
+// withModel returns the request for a fallback model, or the request itself when unchanged
+func (r *CompletionRequest) withModel(model string) *CompletionRequest {
+	if model == r.Model {
+		return r
+	}
+	fallback := *r
+	fallback.Model = model
+	return &fallback
+}
+
+// CompletionResponse represents a legacy text completion response or stream chunk.
+// Usage is always set on responses, and only on the final chunk of a stream.
+type CompletionResponse struct {
+	ID      string             `json:"id"`
+	Object  string             `json:"object"`
+	Created int64              `json:"created"`
+	Model   string             `json:"model"`
+	Choices []CompletionChoice `json:"choices"`
+	Usage   *Usage             `json:"usage,omitempty"`
+}
+
+// CompletionChoice represents a text completion choice. Logprobs are only available
+// from providers that serve completions natively.
+type CompletionChoice struct {
+	Text         string          `json:"text"`
+	Index        int             `json:"index"`
+	Logprobs     json.RawMessage `json:"logprobs"`
+	FinishReason *string         `json:"finish_reason"`
+}
+
+// CompletionStream reads a text completion stream chunk by chunk
+type CompletionStream interface {
+	// Recv returns the next chunk, or io.EOF once the stream has finished
+	Recv() (*CompletionResponse, error)
+
+	// Close releases the underlying stream
+	Close() error
+}
+
+// Completer is implemented by providers that serve legacy text completions natively.
+// Models it does not support are served by emulating completions over chat.
+type Completer interface {
+	// SupportsCompletions reports whether the model is served by the native completions API
+	SupportsCompletions(model string) bool
+
+	// CreateCompletion sends a text completion request
+	CreateCompletion(ctx context.Context, req *CompletionRequest) (*CompletionResponse, error)
+
+	// CreateCompletionStream sends a streaming text completion request
+	CreateCompletionStream(ctx context.Context, req *CompletionRequest) (CompletionStream, error)
+}
+
+// nativeCompleter returns the provider as a Completer if it serves the model natively
+func nativeCompleter(p Provider, model string) (Completer, bool) {
+	completer, ok := p.(Completer)
+	if !ok || !completer.SupportsCompletions(model) {
+		return nil, false
+	}
+	return completer, true
+}
+
+// suffixInstruction asks a chat model to fill in the middle, which it has no native mode for
+const suffixInstruction = "Continue the text in the user message. It is followed by the text in <suffix> tags. " +
+	"Reply with only the text that belongs between the two, without the tags or any commentary."
+
+// chatRequest wraps a prompt into a single chat turn. A suffix turns the turn into an insertion task.
+func (r *CompletionRequest) chatRequest(prompt string) *Request {
+	messages := []Message{{Role: "user", Content: TextContent(prompt)}}
+	if r.Suffix != "" {
+		messages = []Message{
+			{Role: "system", Content: TextContent(suffixInstruction)},
+			{Role: "user", Content: TextContent(prompt + "\n<suffix>" + r.Suffix + "</suffix>")},
+		}
+	}
+
+	return &Request{
+		Model:       r.Model,
+		Messages:    messages,
+		MaxTokens:   r.MaxTokens,
+		Temperature: r.Temperature,
+		TopP:        r.TopP,
+		Stream:      r.Stream,
+		Stop:        r.Stop,
+	}
+}
+
+// checkEmulated rejects the options chat models cannot honour
+func (r *CompletionRequest) checkEmulated(provider string) error {
+	if r.Logprobs != nil {
+		return newInvalidRequestError(provider, "logprobs", "logprobs requires a model that supports native completions")
+	}
+	return nil
+}
+
+// emulateCompletion serves a completion request by sending one chat request per prompt and
+// choice. When a call fails, the response built so far is returned along with the error, so
+// that the usage of the calls already made is still accounted for.
+func emulateCompletion(ctx context.Context, p Provider, req *CompletionRequest) (*CompletionResponse, error) {
+	if err := req.checkEmulated(p.Name()); err != nil {
+		return nil, err
+	}
+
+	result := &CompletionResponse{
+		Object: "text_completion",
+		Model:  req.Model,
+		Usage:  &Usage{},
+	}
+	n := req.choices()
+	for i, prompt := range req.Prompt {
+		chatReq := req.chatRequest(prompt)
+		for j := 0; j < n; j++ {
+			resp, err := p.SendRequest(ctx, chatReq)
+			if err != nil {
+				if len(result.Choices) == 0 {
+					return nil, err
+				}
+				return result, err
+			}
+			if result.ID == "" {
+				result.ID = resp.ID
+				result.Created = resp.Created
+				result.Model = resp.Model
+			}
+
+			choice := CompletionChoice{Index: i*n + j}
+			if len(resp.Choices) > 0 {
+				choice.Text = resp.Choices[0].Message.Content.Text()
+				choice.FinishReason = stringPtr(resp.Choices[0].FinishReason)
+			}
+			if req.Echo {
+				choice.Text = prompt + choice.Text
+			}
+			result.Choices = append(result.Choices, choice)
+
+			result.Usage.add(resp.Usage)
+		}
+	}
+
+	return result, nil
+}
+
+// emulateCompletionStream serves a streaming completion request over a chat stream. Only
+// a single prompt and choice can be streamed this way.
+func emulateCompletionStream(ctx context.Context, p Provider, req *CompletionRequest) (CompletionStream, error) {
+	if err := req.checkEmulated(p.Name()); err != nil {
+		return nil, err
+	}
+	if len(req.Prompt) != 1 || req.choices() != 1 {
+		return nil, newInvalidRequestError(p.Name(), "n", "streaming requires a single prompt and n=1")
+	}
+
+	chatReq := req.chatRequest(req.Prompt[0])
+	body, err := p.SendStreamRequest(ctx, chatReq)
+	if err != nil {
+		return nil, err
+	}
+
+	stream := &chatCompletionStream{stream: p.NewStreamDecoder(body, chatReq)}
+	if req.Echo {
+		stream.echo = req.Prompt[0]
+	}
+	return stream, nil
+}
+
+// chatCompletionStream converts a chat stream into text completion chunks
+type chatCompletionStream struct {
+	stream StreamDecoder
+
+	// echo is the prompt, emitted ahead of the first chunk when echo was requested
+	echo string
+}
+
+// Recv returns the next text completion chunk
+func (s *chatCompletionStream) Recv() (*CompletionResponse, error) {
+	for {
+		chunk, err := s.stream.Recv()
+		if err != nil {
+			return nil, err
+		}
+
+		resp := &CompletionResponse{
+			ID:      chunk.ID,
+			Object:  "text_completion",
+			Created: chunk.Created,
+			Model:   chunk.Model,
+			Choices: make([]CompletionChoice, 0, len(chunk.Choices)),
+			Usage:   chunk.Usage,
+		}
+		var text strings.Builder
+		for _, choice := range chunk.Choices {
+			text.WriteString(choice.Delta.Content)
+			resp.Choices = append(resp.Choices, CompletionChoice{
+				Index:        choice.Index,
+				Text:         choice.Delta.Content,
+				FinishReason: choice.FinishReason,
+			})
+		}
+
+		// Skip chunks such as the opening role delta that carry nothing for the client
+		if text.Len() == 0 && resp.Usage == nil && !finished(resp.Choices) {
+			continue
+		}
+		if s.echo != "" && len(resp.Choices) > 0 {
+			resp.Choices[0].Text = s.echo + resp.Choices[0].Text
+			s.echo = ""
+		}
+		return resp, nil
+	}
+}
+
+// Close closes the underlying chat stream
+func (s *chatCompletionStream) Close() error {
+	return s.stream.Close()
+}
+
+// finished reports whether any choice carries a finish reason
+func finished(choices []CompletionChoice) bool {
+	for _, choice := range choices {
+		if choice.FinishReason != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// completionStreamDecoder decodes a native text completion stream in the OpenAI format
+type completionStreamDecoder struct {
+	provider string
+	body     io.ReadCloser
+	reader   *sseReader
+}
+
+// Recv returns the next chunk from the completion stream
+func (d *completionStreamDecoder) Recv() (*CompletionResponse, error) {
+	for {
+		event, err := d.reader.next()
+		if err != nil {
+			return nil, err
+		}
+
+		if event.Data == "[DONE]" {
+			return nil, io.EOF
+		}
+		if event.Data == "" {
+			continue
+		}
+
+		var chunk OpenAICompletionChunk
+		if err := json.Unmarshal([]byte(event.Data), &chunk); err != nil {
+			return nil, fmt.Errorf("failed to decode stream chunk: %w", err)
+		}
+		if chunk.Error != nil {
+			return nil, newStreamError(d.provider, chunk.Error)
+		}
+
+		return &chunk.CompletionResponse, nil
+	}
+}
+
+// Close closes the underlying stream
+func (d *completionStreamDecoder) Close() error {
+	return d.body.Close()
+}
@@ -0,0 +1,136 @@
+package providers
+
+import (
+	"encoding/json"
+	"mime"
+	"path"
+	"strings"
+)
+
+// Content part types, matching OpenAI's chat content parts
+const (
+	ContentPartText       = "text"
+	ContentPartImageURL   = "image_url"
+	ContentPartInputAudio = "input_audio"
+	ContentPartFile       = "file"
+)
+
+// Content is the content of a message. It is encoded as a plain string when it consists of
+// a single text part, and as an array of OpenAI-style content parts otherwise.
+type Content []ContentPart
+
+// TextContent returns content consisting of a single text part
+func TextContent(text string) Content {
+	return Content{{Type: ContentPartText, Text: text}}
+}
+
+// Text returns the concatenated text of all text parts
+func (c Content) Text() string {
+	var text strings.Builder
+	for _, part := range c {
+		if part.Type == ContentPartText {
+			text.WriteString(part.Text)
+		}
+	}
+	return text.String()
+}
+
+// IsText reports whether the content consists of text parts only
+func (c Content) IsText() bool {
+	for _, part := range c {
+		if part.Type != ContentPartText {
+			return false
+		}
+	}
+	return true
+}
+
+// MarshalJSON implements json.Marshaler
+func (c Content) MarshalJSON() ([]byte, error) {
+	switch {
+	case len(c) == 0:
+		return json.Marshal("")
+	case len(c) == 1 && c[0].Type == ContentPartText:
+		return json.Marshal(c[0].Text)
+	default:
+		return json.Marshal([]ContentPart(c))
+	}
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (c *Content) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		*c = nil
+		return nil
+	}
+
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		*c = TextContent(text)
+		return nil
+	}
+
+	return json.Unmarshal(data, (*[]ContentPart)(c))
+}
+
+// ContentPart represents a part of a message's content
+type ContentPart struct {
+	Type       string      `json:"type"`
+	Text       string      `json:"text,omitempty"`
+	ImageURL   *ImageURL   `json:"image_url,omitempty"`
+	InputAudio *InputAudio `json:"input_audio,omitempty"`
+	File       *File       `json:"file,omitempty"`
+}
+
+// ImageURL references an image by http(s) URL or base64 data URL
+type ImageURL struct {
+	URL    string `json:"url"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// InputAudio holds base64-encoded audio in the given format, e.g. wav or mp3
+type InputAudio struct {
+	Data   string `json:"data"`
+	Format string `json:"format"`
+}
+
+// File holds a document as a base64 data URL, or references a file uploaded to the provider
+type File struct {
+	FileData string `json:"file_data,omitempty"`
+	FileID   string `json:"file_id,omitempty"`
+	Filename string `json:"filename,omitempty"`
+}
+
+// parseDataURL splits a base64 data URL such as data:image/png;base64,... into
+// its media type and payload
+func parseDataURL(url string) (mediaType, data string, ok bool) {
+	rest, found := strings.CutPrefix(url, "data:")
+	if !found {
+		return "", "", false
+	}
+
+	meta, data, found := strings.Cut(rest, ",")
+	if !found {
+		return "", "", false
+	}
+
+	mediaType, found = strings.CutSuffix(meta, ";base64")
+	if !found {
+		return "", "", false
+	}
+	return mediaType, data, true
+}
+
+// audioMediaType returns the media type of an input_audio format
+func audioMediaType(format string) string {
+	return "audio/" + strings.ToLower(format)
+}
+
+// urlMediaType guesses the media type of a remote file from its extension
+func urlMediaType(url string) string {
+	if i := strings.IndexAny(url, "?#"); i >= 0 {
+		url = url[:i]
+	}
+	mediaType, _, _ := strings.Cut(mime.TypeByExtension(path.Ext(url)), ";")
+	return mediaType
+}
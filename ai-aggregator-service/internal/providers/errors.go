@@ -0,0 +1,331 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxErrorBodySize bounds how much of an upstream error body is kept
+const maxErrorBodySize = 64 * 1024
+
+// ErrorKind classifies why a request failed, independently of the provider that failed it
+type ErrorKind string
+
+const (
+	// ErrorKindInvalidRequest means the request was malformed or used unsupported parameters
+	ErrorKindInvalidRequest ErrorKind = "invalid_request"
+
+	// ErrorKindContextLengthExceeded means the prompt and completion do not fit the model's context window
+	ErrorKindContextLengthExceeded ErrorKind = "context_length_exceeded"
+
+	// ErrorKindContentFiltered means the input or output was blocked by a content policy
+	ErrorKindContentFiltered ErrorKind = "content_filtered"
+
+	// ErrorKindAuth means the credentials were missing, invalid or lacked permission
+	ErrorKindAuth ErrorKind = "auth"
+
+	// ErrorKindPermission means the caller may not use the requested model or provider
+	ErrorKindPermission ErrorKind = "permission_denied"
+
+	// ErrorKindRateLimited means a rate limit or quota was exceeded
+	ErrorKindRateLimited ErrorKind = "rate_limited"
+
+	// ErrorKindTimeout means the request did not complete in time
+	ErrorKindTimeout ErrorKind = "timeout"
+
+	// ErrorKindUpstreamUnavailable means the provider failed, was overloaded or could not be reached
+	ErrorKindUpstreamUnavailable ErrorKind = "upstream_unavailable"
+)
+
+// Error is a classified failure of a request. Provider and StatusCode are only set when
+// the error came from an upstream provider rather than from the gateway itself.
+type Error struct {
+	Kind       ErrorKind
+	Provider   string
+	StatusCode int
+	Message    string
+	Code       string // overrides Kind as the client-facing error code
+	Param      string
+	Body       string
+	RetryAfter time.Duration
+	Err        error
+}
+
+// Error implements the error interface
+func (e *Error) Error() string {
+	if e.Provider == "" {
+		return e.Message
+	}
+	if e.StatusCode != 0 {
+		return fmt.Sprintf("%s: API request failed with status %d: %s", e.Provider, e.StatusCode, e.Message)
+	}
+	return fmt.Sprintf("%s: %s", e.Provider, e.Message)
+}
+
+// Unwrap returns the underlying error, if any
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Retryable reports whether the same request may succeed if sent again
+func (e *Error) Retryable() bool {
+	switch e.Kind {
+	case ErrorKindRateLimited, ErrorKindTimeout, ErrorKindUpstreamUnavailable:
+		return true
+	default:
+		return false
+	}
+}
+
+// errorEnvelope covers the error bodies returned by the supported vendors:
+// OpenAI-style {"error":{"message","type","code","param"}}, Anthropic
+// {"type":"error","error":{"type","message"}}, Google {"error":{"code","message","status"}}
+// and flat {"message"} or {"error":"..."} bodies
+type errorEnvelope struct {
+	Error   json.RawMessage `json:"error"`
+	Message string          `json:"message"`
+	Type    string          `json:"type"`
+	Code    json.RawMessage `json:"code"`
+}
+
+// errorDetail is the nested error object of an errorEnvelope
+type errorDetail struct {
+	Message string          `json:"message"`
+	Type    string          `json:"type"`
+	Status  string          `json:"status"`
+	Code    json.RawMessage `json:"code"`
+	Param   string          `json:"param"`
+}
+
+// newInvalidRequestError builds an Error for a request the provider cannot translate
+func newInvalidRequestError(provider, param, format string, args ...interface{}) *Error {
+	return &Error{
+		Kind:     ErrorKindInvalidRequest,
+		Provider: provider,
+		Message:  fmt.Sprintf(format, args...),
+		Param:    param,
+	}
+}
+
+// newHTTPError builds a classified Error from a failed provider response
+func newHTTPError(provider string, resp *http.Response) *Error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+	detail := parseErrorBody(body)
+
+	message := detail.Message
+	if message == "" {
+		message = strings.TrimSpace(string(body))
+	}
+	if message == "" {
+		message = http.StatusText(resp.StatusCode)
+	}
+
+	errType := detail.Type
+	if errType == "" {
+		errType = detail.Status
+	}
+
+	return &Error{
+		Kind:       classifyError(resp.StatusCode, errType, rawString(detail.Code), message),
+		Provider:   provider,
+		StatusCode: resp.StatusCode,
+		Message:    message,
+		Param:      detail.Param,
+		Body:       string(body),
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+	}
+}
+
+// newStreamError builds a classified Error from an error event embedded in a provider stream
+func newStreamError(provider string, apiErr *APIError) *Error {
+	err := &Error{
+		Kind:     classifyError(0, apiErr.Type, apiErr.Code, apiErr.Message),
+		Provider: provider,
+		Message:  apiErr.Message,
+	}
+	if apiErr.Param != nil {
+		err.Param = *apiErr.Param
+	}
+	return err
+}
+
+// newTransportError classifies a failure to reach the provider at all
+func newTransportError(provider string, err error) error {
+	// Cancellation by the caller is not a provider failure
+	if errors.Is(err, context.Canceled) {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+
+	kind := ErrorKindUpstreamUnavailable
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		kind = ErrorKindTimeout
+	}
+
+	return &Error{
+		Kind:     kind,
+		Provider: provider,
+		Message:  "failed to send request: " + err.Error(),
+		Err:      err,
+	}
+}
+
+// parseErrorBody extracts the error details from any of the vendor error formats
+func parseErrorBody(body []byte) errorDetail {
+	var envelope errorEnvelope
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return errorDetail{}
+	}
+
+	var detail errorDetail
+	if len(envelope.Error) > 0 {
+		if err := json.Unmarshal(envelope.Error, &detail); err != nil {
+			// Some servers report the error as a plain string
+			var message string
+			if json.Unmarshal(envelope.Error, &message) == nil {
+				detail.Message = message
+			}
+		}
+	}
+
+	if detail.Message == "" {
+		detail.Message = envelope.Message
+		if detail.Type == "" && envelope.Type != "error" {
+			detail.Type = envelope.Type
+		}
+		if len(detail.Code) == 0 {
+			detail.Code = envelope.Code
+		}
+	}
+
+	return detail
+}
+
+// rawString returns a JSON string or number as a plain string
+func rawString(raw json.RawMessage) string {
+	if len(raw) == 0 || bytes.Equal(raw, []byte("null")) {
+		return ""
+	}
+
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s
+	}
+	return string(raw)
+}
+
+// classifyError maps an upstream status code and vendor error details to an ErrorKind.
+// A status of zero classifies errors reported outside of an HTTP status, such as stream
+// events, by their vendor error type instead.
+func classifyError(status int, errType, code, message string) ErrorKind {
+	switch {
+	case status == http.StatusUnauthorized, status == http.StatusForbidden:
+		return ErrorKindAuth
+	case status == http.StatusTooManyRequests:
+		return ErrorKindRateLimited
+	case status == http.StatusRequestTimeout, status == http.StatusGatewayTimeout:
+		return ErrorKindTimeout
+	case status >= http.StatusInternalServerError:
+		return ErrorKindUpstreamUnavailable
+	}
+
+	if status == 0 {
+		switch strings.ToLower(errType) {
+		case "authentication_error", "permission_error", "unauthenticated", "permission_denied":
+			return ErrorKindAuth
+		case "rate_limit_error", "resource_exhausted":
+			return ErrorKindRateLimited
+		case "timeout", "deadline_exceeded":
+			return ErrorKindTimeout
+		case "api_error", "overloaded_error", "server_error", "internal", "unavailable":
+			return ErrorKindUpstreamUnavailable
+		}
+	}
+
+	// Only what remains are client errors, which the text can refine. A rate limit
+	// whose message mentions "too many tokens" must stay retryable.
+	text := strings.ToLower(errType + " " + code + " " + message)
+	switch {
+	case strings.Contains(text, "context_length_exceeded"),
+		strings.Contains(text, "maximum context length"),
+		strings.Contains(text, "prompt is too long"),
+		strings.Contains(text, "too many tokens"):
+		return ErrorKindContextLengthExceeded
+	case strings.Contains(text, "content_filter"),
+		strings.Contains(text, "content_policy"):
+		return ErrorKindContentFiltered
+	}
+
+	if status >= http.StatusBadRequest {
+		return ErrorKindInvalidRequest
+	}
+	switch strings.ToLower(errType) {
+	case "invalid_request_error", "not_found_error", "invalid_argument", "not_found":
+		return ErrorKindInvalidRequest
+	}
+	return ErrorKindUpstreamUnavailable
+}
+
+// parseRetryAfter parses a Retry-After header given either in seconds or as an HTTP date
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+
+	if at, err := http.ParseTime(value); err == nil {
+		if wait := time.Until(at); wait > 0 {
+			return wait
+		}
+	}
+
+	return 0
+}
+
+// IsRetryable reports whether a request that failed with err may succeed if sent again
+func IsRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	// Cancellation by the caller is never retried
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	var providerErr *Error
+	if errors.As(err, &providerErr) {
+		return providerErr.Retryable()
+	}
+	return false
+}
+
+// retryAfter returns the delay requested by the provider, if any
+func retryAfter(err error) time.Duration {
+	var providerErr *Error
+	if errors.As(err, &providerErr) {
+		return providerErr.RetryAfter
+	}
+	return 0
+}
+
+// statusCode returns the upstream HTTP status code carried by err, if any
+func statusCode(err error) int {
+	var providerErr *Error
+	if errors.As(err, &providerErr) {
+		return providerErr.StatusCode
+	}
+	return 0
+}
@@ -0,0 +1,52 @@
+package providers
+
+import "testing"
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		errType string
+		code    string
+		message string
+		want    ErrorKind
+	}{
+		// The status decides first, whatever the body says
+		{"401", 401, "invalid_request_error", "", "Incorrect API key provided", ErrorKindAuth},
+		{"403", 403, "", "", "Permission denied", ErrorKindAuth},
+		{"408", 408, "", "", "Request timeout", ErrorKindTimeout},
+		{"429", 429, "rate_limit_error", "", "Rate limit reached", ErrorKindRateLimited},
+		{"429 mentioning too many tokens", 429, "", "", "Resource exhausted: too many tokens per minute", ErrorKindRateLimited},
+		{"500", 500, "", "", "Internal error", ErrorKindUpstreamUnavailable},
+		{"503 mentioning the context length", 503, "", "", "maximum context length exceeded while overloaded", ErrorKindUpstreamUnavailable},
+		{"504", 504, "", "", "Gateway timeout", ErrorKindTimeout},
+		{"529 overloaded", 529, "overloaded_error", "", "Overloaded", ErrorKindUpstreamUnavailable},
+
+		// Stream errors carry no status, so the vendor type decides
+		{"stream authentication_error", 0, "authentication_error", "", "invalid x-api-key", ErrorKindAuth},
+		{"stream PERMISSION_DENIED", 0, "PERMISSION_DENIED", "", "API key not valid", ErrorKindAuth},
+		{"stream rate_limit_error", 0, "rate_limit_error", "", "too many tokens", ErrorKindRateLimited},
+		{"stream RESOURCE_EXHAUSTED", 0, "RESOURCE_EXHAUSTED", "", "Quota exceeded", ErrorKindRateLimited},
+		{"stream DEADLINE_EXCEEDED", 0, "DEADLINE_EXCEEDED", "", "Deadline exceeded", ErrorKindTimeout},
+		{"stream overloaded_error", 0, "overloaded_error", "", "Overloaded", ErrorKindUpstreamUnavailable},
+		{"stream invalid_request_error", 0, "invalid_request_error", "", "messages: field required", ErrorKindInvalidRequest},
+		{"stream error of unknown type", 0, "", "", "something broke", ErrorKindUpstreamUnavailable},
+
+		// The text refines the remaining client errors
+		{"context_length_exceeded code", 400, "invalid_request_error", "context_length_exceeded", "This model's maximum context length is 8192 tokens", ErrorKindContextLengthExceeded},
+		{"prompt is too long", 400, "invalid_request_error", "", "prompt is too long: 210000 tokens > 200000 maximum", ErrorKindContextLengthExceeded},
+		{"stream prompt is too long", 0, "invalid_request_error", "", "prompt is too long", ErrorKindContextLengthExceeded},
+		{"content_filter code", 400, "invalid_request_error", "content_filter", "The response was filtered", ErrorKindContentFiltered},
+		{"content_policy_violation", 400, "invalid_request_error", "content_policy_violation", "Your request was rejected", ErrorKindContentFiltered},
+		{"other client error", 400, "invalid_request_error", "", "Unknown parameter: foo", ErrorKindInvalidRequest},
+		{"404", 404, "not_found_error", "", "model: claude-9", ErrorKindInvalidRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := classifyError(tt.status, tt.errType, tt.code, tt.message); got != tt.want {
+				t.Errorf("classifyError(%d, %q, %q, %q) = %s, want %s", tt.status, tt.errType, tt.code, tt.message, got, tt.want)
+			}
+		})
+	}
+}
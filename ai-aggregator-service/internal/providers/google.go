@@ -0,0 +1,961 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// GoogleAIProvider implements the Provider interface for Google AI (Gemini)
+type GoogleAIProvider struct {
+	config Config
+	client *http.Client
+}
+
+// NewGoogleAIProvider creates a new Google AI provider instance
+func NewGoogleAIProvider(config Config) *GoogleAIProvider {
+	if config.Name == "" {
+		config.Name = "google"
+	}
+	if config.BaseURL == "" {
+		config.BaseURL = "https://generativelanguage.googleapis.com/v1beta"
+	}
+	if config.Timeout == 0 {
+		config.Timeout = 30
+	}
+
+	p := &GoogleAIProvider{config: config}
+	p.client = newHTTPClient(config, p.authorize)
+	return p
+}
+
+// Name returns the provider name
+func (p *GoogleAIProvider) Name() string {
+	return p.config.Name
+}
+
+// MaxRetries returns the retry limit configured for Google AI, and false when the router's
+// retry policy applies
+func (p *GoogleAIProvider) MaxRetries() (int, bool) {
+	return p.config.RetryLimit()
+}
+
+// SendRequest sends a request to Google AI
+func (p *GoogleAIProvider) SendRequest(ctx context.Context, req *Request) (*Response, error) {
+	googleReq, err := p.convertToGoogleAIRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	jsonData, err := json.Marshal(googleReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", p.modelURL(req.Model, "generateContent"), bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	p.setHeaders(httpReq)
+
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		return nil, newTransportError(p.Name(), err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newHTTPError(p.Name(), resp)
+	}
+
+	var googleResp GoogleAIResponse
+	if err := json.NewDecoder(resp.Body).Decode(&googleResp); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	if err := p.checkPromptFeedback(&googleResp); err != nil {
+		return nil, err
+	}
+
+	return p.convertFromGoogleAIResponse(&googleResp, req.Model), nil
+}
+
+// SendStreamRequest sends a streaming request to Google AI
+func (p *GoogleAIProvider) SendStreamRequest(ctx context.Context, req *Request) (io.ReadCloser, error) {
+	googleReq, err := p.convertToGoogleAIRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	jsonData, err := json.Marshal(googleReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	// alt=sse switches the stream from a JSON array to Server-Sent Events
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", p.modelURL(req.Model, "streamGenerateContent")+"?alt=sse", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	p.setHeaders(httpReq)
+
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		return nil, newTransportError(p.Name(), err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, newHTTPError(p.Name(), resp)
+	}
+
+	return resp.Body, nil
+}
+
+// NewStreamDecoder wraps a Google AI event stream in a decoder that emits unified chunks
+func (p *GoogleAIProvider) NewStreamDecoder(body io.ReadCloser, req *Request) StreamDecoder {
+	return &googleAIStreamDecoder{
+		provider: p,
+		body:     body,
+		reader:   newSSEReader(body),
+		id:       fmt.Sprintf("gemini-%d", time.Now().UnixNano()),
+		model:    req.Model,
+		created:  time.Now().Unix(),
+		calls:    make(map[int]int),
+	}
+}
+
+// MaxEmbeddingInputs returns how many texts Google AI accepts in one batch embed request
+func (p *GoogleAIProvider) MaxEmbeddingInputs() int {
+	return 100
+}
+
+// CreateEmbeddings creates embeddings for the request's inputs. Gemini's embed methods
+// don't report token usage, so the inputs are counted with countTokens afterwards.
+func (p *GoogleAIProvider) CreateEmbeddings(ctx context.Context, req *EmbeddingRequest) (*EmbeddingResponse, error) {
+	model := "models/" + strings.TrimPrefix(req.Model, "models/")
+	embedReq := GoogleAIBatchEmbedRequest{
+		Requests: make([]GoogleAIEmbedRequest, 0, len(req.Input)),
+	}
+	for _, input := range req.Input {
+		embedReq.Requests = append(embedReq.Requests, GoogleAIEmbedRequest{
+			Model:                model,
+			Content:              GoogleAIContent{Parts: []GoogleAIPart{{Text: input}}},
+			OutputDimensionality: req.Dimensions,
+		})
+	}
+
+	jsonData, err := json.Marshal(embedReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", p.modelURL(req.Model, "batchEmbedContents"), bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	p.setHeaders(httpReq)
+
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		return nil, newTransportError(p.Name(), err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newHTTPError(p.Name(), resp)
+	}
+
+	var embedResp GoogleAIBatchEmbedResponse
+	if err := json.NewDecoder(resp.Body).Decode(&embedResp); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	data := make([]Embedding, 0, len(embedResp.Embeddings))
+	for i, embedding := range embedResp.Embeddings {
+		data = append(data, Embedding{
+			Object:    "embedding",
+			Embedding: embedding.Values,
+			Index:     i,
+		})
+	}
+
+	inputTokens, err := p.countTokens(ctx, req.Model, embedReq.Requests)
+	if err != nil {
+		slog.Warn("Failed to count embedding tokens", "provider", p.Name(), "model", req.Model, "error", err)
+	}
+
+	return &EmbeddingResponse{
+		Object: "list",
+		Data:   data,
+		Model:  req.Model,
+		Usage: Usage{
+			PromptTokens: inputTokens,
+			TotalTokens:  inputTokens,
+		},
+	}, nil
+}
+
+// countTokens returns the total number of tokens in the contents of embed requests
+func (p *GoogleAIProvider) countTokens(ctx context.Context, modelID string, requests []GoogleAIEmbedRequest) (int, error) {
+	countReq := GoogleAICountTokensRequest{
+		Contents: make([]GoogleAIContent, 0, len(requests)),
+	}
+	for _, r := range requests {
+		countReq.Contents = append(countReq.Contents, r.Content)
+	}
+
+	jsonData, err := json.Marshal(countReq)
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", p.modelURL(modelID, "countTokens"), bytes.NewBuffer(jsonData))
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	p.setHeaders(httpReq)
+
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		return 0, newTransportError(p.Name(), err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, newHTTPError(p.Name(), resp)
+	}
+
+	var countResp GoogleAICountTokensResponse
+	if err := json.NewDecoder(resp.Body).Decode(&countResp); err != nil {
+		return 0, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return countResp.TotalTokens, nil
+}
+
+// GetModels returns the list of available Google AI models
+func (p *GoogleAIProvider) GetModels(ctx context.Context) ([]ModelInfo, error) {
+	var models []ModelInfo
+	pageToken := ""
+
+	for {
+		query := url.Values{"pageSize": {"1000"}}
+		if pageToken != "" {
+			query.Set("pageToken", pageToken)
+		}
+
+		httpReq, err := http.NewRequestWithContext(ctx, "GET", p.config.BaseURL+"/models?"+query.Encode(), nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+
+		p.setHeaders(httpReq)
+
+		resp, err := p.client.Do(httpReq)
+		if err != nil {
+			return nil, newTransportError(p.Name(), err)
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			err := newHTTPError(p.Name(), resp)
+			resp.Body.Close()
+			return nil, err
+		}
+
+		var modelsResp GoogleAIModelsResponse
+		err = json.NewDecoder(resp.Body).Decode(&modelsResp)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode response: %w", err)
+		}
+
+		for _, model := range modelsResp.Models {
+			models = append(models, p.convertModel(model))
+		}
+
+		if modelsResp.NextPageToken == "" {
+			return models, nil
+		}
+		pageToken = modelsResp.NextPageToken
+	}
+}
+
+// GetModelInfo returns detailed information about a specific model
+func (p *GoogleAIProvider) GetModelInfo(ctx context.Context, modelID string) (*ModelInfo, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, "GET", p.modelURL(modelID, ""), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	p.setHeaders(httpReq)
+
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		return nil, newTransportError(p.Name(), err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("model not found: %s", modelID)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newHTTPError(p.Name(), resp)
+	}
+
+	var model GoogleAIModel
+	if err := json.NewDecoder(resp.Body).Decode(&model); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	info := p.convertModel(model)
+	return &info, nil
+}
+
+// ValidateModel checks if a model is valid for Google AI
+func (p *GoogleAIProvider) ValidateModel(ctx context.Context, modelID string) error {
+	_, err := p.GetModelInfo(ctx, modelID)
+	return err
+}
+
+// setHeaders sets the required headers for Google AI API
+func (p *GoogleAIProvider) setHeaders(req *http.Request) {
+	req.Header.Set("Content-Type", "application/json")
+	p.authorize(req, p.config.APIKey)
+
+	for key, value := range p.config.Headers {
+		req.Header.Set(key, value)
+	}
+}
+
+// authorize sets the API key on req
+func (p *GoogleAIProvider) authorize(req *http.Request, key string) {
+	req.Header.Set("x-goog-api-key", key)
+}
+
+// modelURL returns the URL of a model resource, or of one of its methods when method is set
+func (p *GoogleAIProvider) modelURL(modelID, method string) string {
+	endpoint := p.config.BaseURL + "/models/" + url.PathEscape(strings.TrimPrefix(modelID, "models/"))
+	if method != "" {
+		endpoint += ":" + method
+	}
+	return endpoint
+}
+
+// convertModel converts a Google AI model resource to our unified format
+func (p *GoogleAIProvider) convertModel(model GoogleAIModel) ModelInfo {
+	return ModelInfo{
+		ID:          strings.TrimPrefix(model.Name, "models/"),
+		Object:      "model",
+		OwnedBy:     "google",
+		MaxTokens:   model.OutputTokenLimit,
+		ContextSize: model.InputTokenLimit,
+	}
+}
+
+// convertToGoogleAIRequest converts our unified request to Google AI format
+func (p *GoogleAIProvider) convertToGoogleAIRequest(req *Request) (GoogleAIRequest, error) {
+	system, contents, err := p.convertMessages(req.Messages)
+	if err != nil {
+		return GoogleAIRequest{}, err
+	}
+
+	googleReq := GoogleAIRequest{
+		Contents:          contents,
+		SystemInstruction: system,
+	}
+
+	if req.MaxTokens != 0 || req.Temperature != 0 || req.TopP != 0 || len(req.Stop) > 0 {
+		googleReq.GenerationConfig = &GoogleAIGenerationConfig{
+			MaxOutputTokens: req.MaxTokens,
+			Temperature:     req.Temperature,
+			TopP:            req.TopP,
+			StopSequences:   req.Stop,
+		}
+	}
+
+	if len(req.Tools) > 0 {
+		declarations := make([]GoogleAIFunctionDeclaration, 0, len(req.Tools))
+		for _, tool := range req.Tools {
+			declarations = append(declarations, GoogleAIFunctionDeclaration{
+				Name:                 tool.Function.Name,
+				Description:          tool.Function.Description,
+				ParametersJSONSchema: tool.Function.Parameters,
+			})
+		}
+		googleReq.Tools = []GoogleAITool{{FunctionDeclarations: declarations}}
+
+		if req.ToolChoice != nil {
+			googleReq.ToolConfig = convertToGoogleAIToolConfig(req.ToolChoice)
+		}
+	}
+
+	return googleReq, nil
+}
+
+// convertToGoogleAIToolConfig maps an OpenAI tool choice to a Google AI function calling mode.
+// A specific function is expressed as mode ANY restricted to that function.
+func convertToGoogleAIToolConfig(choice *ToolChoice) *GoogleAIToolConfig {
+	config := GoogleAIFunctionCallingConfig{Mode: strings.ToUpper(choice.Mode)}
+	switch {
+	case choice.Function != "":
+		config.Mode = "ANY"
+		config.AllowedFunctionNames = []string{choice.Function}
+	case choice.Mode == "required":
+		config.Mode = "ANY"
+	}
+	return &GoogleAIToolConfig{FunctionCallingConfig: config}
+}
+
+// convertMessages converts our message format to Google AI contents. Gemini names the
+// assistant role "model" and takes system prompts as a separate system instruction.
+// Tool calls become functionCall parts and tool messages become functionResponse parts,
+// which Gemini identifies by function name rather than by call ID.
+func (p *GoogleAIProvider) convertMessages(messages []Message) (*GoogleAIContent, []GoogleAIContent, error) {
+	var system *GoogleAIContent
+	var contents []GoogleAIContent
+	functionNames := make(map[string]string)
+	for i, msg := range messages {
+		var content GoogleAIContent
+		switch msg.Role {
+		case "system":
+			if system == nil {
+				system = &GoogleAIContent{}
+			}
+			system.Parts = append(system.Parts, GoogleAIPart{Text: msg.Content.Text()})
+			continue
+		case "assistant":
+			content.Role = "model"
+			if text := msg.Content.Text(); text != "" || len(msg.ToolCalls) == 0 {
+				content.Parts = append(content.Parts, GoogleAIPart{Text: text})
+			}
+			for _, call := range msg.ToolCalls {
+				args := json.RawMessage(call.Function.Arguments)
+				if len(args) == 0 {
+					args = json.RawMessage("{}")
+				}
+				if !json.Valid(args) {
+					return nil, nil, newInvalidRequestError(p.Name(), fmt.Sprintf("messages[%d].tool_calls", i),
+						"arguments of tool call %s are not valid JSON", call.ID)
+				}
+				functionNames[call.ID] = call.Function.Name
+				content.Parts = append(content.Parts, GoogleAIPart{
+					FunctionCall: &GoogleAIFunctionCall{Name: call.Function.Name, Args: args},
+				})
+			}
+		case "tool":
+			name, ok := functionNames[msg.ToolCallID]
+			if !ok {
+				return nil, nil, newInvalidRequestError(p.Name(), fmt.Sprintf("messages[%d].tool_call_id", i),
+					"tool_call_id %s does not match a preceding tool call", msg.ToolCallID)
+			}
+			content.Role = "user"
+			content.Parts = []GoogleAIPart{{
+				FunctionResponse: &GoogleAIFunctionResponse{Name: name, Response: functionResponse(msg.Content.Text())},
+			}}
+		default:
+			content.Role = "user"
+			for j, part := range msg.Content {
+				googlePart, err := p.convertContentPart(part)
+				if err != nil {
+					err.Param = fmt.Sprintf("messages[%d].content[%d]", i, j)
+					return nil, nil, err
+				}
+				content.Parts = append(content.Parts, googlePart)
+			}
+			if len(content.Parts) == 0 {
+				content.Parts = []GoogleAIPart{{Text: ""}}
+			}
+		}
+
+		// Responses to parallel calls must be sent together in one turn
+		if n := len(contents); n > 0 && contents[n-1].Role == content.Role {
+			contents[n-1].Parts = append(contents[n-1].Parts, content.Parts...)
+			continue
+		}
+		contents = append(contents, content)
+	}
+	return system, contents, nil
+}
+
+// convertContentPart converts a content part to a Google AI part. Data URLs and audio are sent
+// inline; remote URLs are referenced as file data, with the media type taken from the extension.
+func (p *GoogleAIProvider) convertContentPart(part ContentPart) (GoogleAIPart, *Error) {
+	var url string
+	switch part.Type {
+	case ContentPartText:
+		return GoogleAIPart{Text: part.Text}, nil
+	case ContentPartImageURL:
+		if part.ImageURL != nil {
+			url = part.ImageURL.URL
+		}
+	case ContentPartInputAudio:
+		if part.InputAudio != nil {
+			return GoogleAIPart{InlineData: &GoogleAIBlob{
+				MimeType: audioMediaType(part.InputAudio.Format),
+				Data:     part.InputAudio.Data,
+			}}, nil
+		}
+	case ContentPartFile:
+		if part.File != nil {
+			url = part.File.FileData
+		}
+	}
+
+	if url == "" {
+		return GoogleAIPart{}, newInvalidRequestError(p.Name(), "", "content part type %s is not supported", part.Type)
+	}
+
+	if mediaType, data, ok := parseDataURL(url); ok {
+		return GoogleAIPart{InlineData: &GoogleAIBlob{MimeType: mediaType, Data: data}}, nil
+	}
+
+	mediaType := urlMediaType(url)
+	if mediaType == "" {
+		return GoogleAIPart{}, newInvalidRequestError(p.Name(), "", "cannot determine the media type of %s", url)
+	}
+	return GoogleAIPart{FileData: &GoogleAIFileData{MimeType: mediaType, FileURI: url}}, nil
+}
+
+// functionResponse wraps a tool result in the JSON object Gemini expects. Results that are
+// already JSON objects are passed through.
+func functionResponse(result string) json.RawMessage {
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(result), &object); err == nil && object != nil {
+		return json.RawMessage(result)
+	}
+
+	wrapped, _ := json.Marshal(map[string]string{"content": result})
+	return wrapped
+}
+
+// checkPromptFeedback returns an error when Google AI blocked the prompt itself, in which
+// case the response carries no candidates
+func (p *GoogleAIProvider) checkPromptFeedback(resp *GoogleAIResponse) error {
+	if resp.PromptFeedback == nil || resp.PromptFeedback.BlockReason == "" {
+		return nil
+	}
+
+	return &Error{
+		Kind:     ErrorKindContentFiltered,
+		Provider: p.Name(),
+		Message:  "prompt was blocked by Google AI: " + resp.PromptFeedback.BlockReason,
+	}
+}
+
+// convertFromGoogleAIResponse converts Google AI response to our unified format
+func (p *GoogleAIProvider) convertFromGoogleAIResponse(resp *GoogleAIResponse, model string) *Response {
+	id := resp.ResponseID
+	if id == "" {
+		id = fmt.Sprintf("gemini-%d", time.Now().UnixNano())
+	}
+
+	var choices []Choice
+	for _, candidate := range resp.Candidates {
+		var toolCalls []ToolCall
+		for _, call := range candidate.Content.toolCalls(id, candidate.Index, 0) {
+			toolCalls = append(toolCalls, ToolCall{ID: call.ID, Type: call.Type, Function: call.Function})
+		}
+
+		choices = append(choices, Choice{
+			Index: candidate.Index,
+			Message: Message{
+				Role:      "assistant",
+				Content:   TextContent(candidate.Content.text()),
+				ToolCalls: toolCalls,
+			},
+			FinishReason: convertGoogleAIFinishReason(candidate.FinishReason, len(toolCalls) > 0),
+		})
+	}
+
+	if resp.ModelVersion != "" {
+		model = resp.ModelVersion
+	}
+
+	return &Response{
+		ID:      id,
+		Object:  "chat.completion",
+		Created: time.Now().Unix(),
+		Model:   model,
+		Choices: choices,
+		Usage:   resp.UsageMetadata.usage(),
+	}
+}
+
+// convertGoogleAIFinishReason maps Google AI finish reasons to OpenAI finish reasons.
+// Responses stopped by safety ratings, recitation or blocklists are reported as content_filter.
+// Gemini reports STOP after calling functions, which OpenAI reports as tool_calls.
+func convertGoogleAIFinishReason(reason string, calledTools bool) string {
+	switch reason {
+	case "":
+		return ""
+	case "STOP":
+		if calledTools {
+			return "tool_calls"
+		}
+		return "stop"
+	case "MAX_TOKENS":
+		return "length"
+	case "SAFETY", "RECITATION", "BLOCKLIST", "PROHIBITED_CONTENT", "SPII", "IMAGE_SAFETY":
+		return "content_filter"
+	default:
+		return strings.ToLower(reason)
+	}
+}
+
+// googleAIStreamDecoder translates Google AI stream responses into unified chunks
+type googleAIStreamDecoder struct {
+	provider *GoogleAIProvider
+	body     io.ReadCloser
+	reader   *sseReader
+	id       string
+	model    string
+	created  int64
+	usage    *Usage
+	roleSent bool
+	done     bool
+
+	// calls counts the tool calls emitted so far for each candidate
+	calls map[int]int
+}
+
+// Recv returns the next chunk translated from the Google AI stream
+func (d *googleAIStreamDecoder) Recv() (*StreamResponse, error) {
+	for {
+		if d.done {
+			return nil, io.EOF
+		}
+
+		event, err := d.reader.next()
+		if err == io.EOF {
+			// Emit a trailing usage chunk, mirroring OpenAI's include_usage behaviour
+			d.done = true
+			if d.usage == nil {
+				return nil, io.EOF
+			}
+			chunk := d.chunk(nil)
+			chunk.Usage = d.usage
+			return chunk, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if event.Data == "" {
+			continue
+		}
+
+		var streamChunk GoogleAIStreamChunk
+		if err := json.Unmarshal([]byte(event.Data), &streamChunk); err != nil {
+			return nil, fmt.Errorf("failed to decode stream chunk: %w", err)
+		}
+		if streamChunk.Error != nil {
+			return nil, newStreamError(d.provider.Name(), &APIError{
+				Message: streamChunk.Error.Message,
+				Type:    streamChunk.Error.Status,
+			})
+		}
+		if err := d.provider.checkPromptFeedback(&streamChunk.GoogleAIResponse); err != nil {
+			return nil, err
+		}
+
+		if streamChunk.ModelVersion != "" {
+			d.model = streamChunk.ModelVersion
+		}
+		if streamChunk.ResponseID != "" {
+			d.id = streamChunk.ResponseID
+		}
+		if streamChunk.UsageMetadata.TotalTokenCount > 0 {
+			usage := streamChunk.UsageMetadata.usage()
+			d.usage = &usage
+		}
+
+		if len(streamChunk.Candidates) == 0 {
+			continue
+		}
+
+		choices := make([]StreamChoice, 0, len(streamChunk.Candidates))
+		for _, candidate := range streamChunk.Candidates {
+			// Gemini streams each function call whole, so it is emitted as a single delta
+			toolCalls := candidate.Content.toolCalls(d.id, candidate.Index, d.calls[candidate.Index])
+			d.calls[candidate.Index] += len(toolCalls)
+
+			choice := StreamChoice{
+				Index: candidate.Index,
+				Delta: StreamDelta{Content: candidate.Content.text(), ToolCalls: toolCalls},
+			}
+			if !d.roleSent {
+				choice.Delta.Role = "assistant"
+			}
+			if candidate.FinishReason != "" {
+				choice.FinishReason = stringPtr(convertGoogleAIFinishReason(candidate.FinishReason, d.calls[candidate.Index] > 0))
+			}
+			choices = append(choices, choice)
+		}
+		d.roleSent = true
+
+		return d.chunk(choices), nil
+	}
+}
+
+// chunk builds a unified stream chunk for the current response
+func (d *googleAIStreamDecoder) chunk(choices []StreamChoice) *StreamResponse {
+	if choices == nil {
+		choices = []StreamChoice{}
+	}
+
+	return &StreamResponse{
+		ID:      d.id,
+		Object:  "chat.completion.chunk",
+		Created: d.created,
+		Model:   d.model,
+		Choices: choices,
+	}
+}
+
+// Close closes the underlying stream
+func (d *googleAIStreamDecoder) Close() error {
+	return d.body.Close()
+}
+
+// GoogleAIRequest represents the request format for Google AI API
+type GoogleAIRequest struct {
+	Contents          []GoogleAIContent         `json:"contents"`
+	SystemInstruction *GoogleAIContent          `json:"systemInstruction,omitempty"`
+	GenerationConfig  *GoogleAIGenerationConfig `json:"generationConfig,omitempty"`
+	Tools             []GoogleAITool            `json:"tools,omitempty"`
+	ToolConfig        *GoogleAIToolConfig       `json:"toolConfig,omitempty"`
+}
+
+// GoogleAITool represents a set of function declarations in Google AI format
+type GoogleAITool struct {
+	FunctionDeclarations []GoogleAIFunctionDeclaration `json:"functionDeclarations"`
+}
+
+// GoogleAIFunctionDeclaration represents a function the model may call. The parameters are
+// given as JSON Schema rather than Gemini's OpenAPI subset, so tool schemas pass through unchanged.
+type GoogleAIFunctionDeclaration struct {
+	Name                 string          `json:"name"`
+	Description          string          `json:"description,omitempty"`
+	ParametersJSONSchema json.RawMessage `json:"parametersJsonSchema,omitempty"`
+}
+
+// GoogleAIToolConfig represents the tool configuration in Google AI format
+type GoogleAIToolConfig struct {
+	FunctionCallingConfig GoogleAIFunctionCallingConfig `json:"functionCallingConfig"`
+}
+
+// GoogleAIFunctionCallingConfig represents the function calling mode in Google AI format
+type GoogleAIFunctionCallingConfig struct {
+	Mode                 string   `json:"mode"`
+	AllowedFunctionNames []string `json:"allowedFunctionNames,omitempty"`
+}
+
+// GoogleAIContent represents a message in Google AI format
+type GoogleAIContent struct {
+	Role  string         `json:"role,omitempty"`
+	Parts []GoogleAIPart `json:"parts"`
+}
+
+// text returns the concatenated text of all parts
+func (c GoogleAIContent) text() string {
+	var text strings.Builder
+	for _, part := range c.Parts {
+		text.WriteString(part.Text)
+	}
+	return text.String()
+}
+
+// toolCalls returns the function calls of all parts as tool call deltas, numbered from offset.
+// Calls without an ID are given one derived from the response ID.
+func (c GoogleAIContent) toolCalls(responseID string, candidate, offset int) []ToolCallDelta {
+	var calls []ToolCallDelta
+	for _, part := range c.Parts {
+		if part.FunctionCall == nil {
+			continue
+		}
+
+		index := offset + len(calls)
+		id := part.FunctionCall.ID
+		if id == "" {
+			id = fmt.Sprintf("call_%s_%d_%d", responseID, candidate, index)
+		}
+
+		args := string(part.FunctionCall.Args)
+		if args == "" {
+			args = "{}"
+		}
+
+		calls = append(calls, ToolCallDelta{
+			Index:    index,
+			ID:       id,
+			Type:     "function",
+			Function: FunctionCall{Name: part.FunctionCall.Name, Arguments: args},
+		})
+	}
+	return calls
+}
+
+// GoogleAIPart represents a part of a message in Google AI format
+type GoogleAIPart struct {
+	Text             string                    `json:"text,omitempty"`
+	InlineData       *GoogleAIBlob             `json:"inlineData,omitempty"`
+	FileData         *GoogleAIFileData         `json:"fileData,omitempty"`
+	FunctionCall     *GoogleAIFunctionCall     `json:"functionCall,omitempty"`
+	FunctionResponse *GoogleAIFunctionResponse `json:"functionResponse,omitempty"`
+}
+
+// GoogleAIBlob represents base64-encoded media sent inline in Google AI format
+type GoogleAIBlob struct {
+	MimeType string `json:"mimeType"`
+	Data     string `json:"data"`
+}
+
+// GoogleAIFileData represents media referenced by URI in Google AI format
+type GoogleAIFileData struct {
+	MimeType string `json:"mimeType"`
+	FileURI  string `json:"fileUri"`
+}
+
+// GoogleAIFunctionCall represents a function call in Google AI format
+type GoogleAIFunctionCall struct {
+	ID   string          `json:"id,omitempty"`
+	Name string          `json:"name"`
+	Args json.RawMessage `json:"args,omitempty"`
+}
+
+// GoogleAIFunctionResponse represents the result of a function call in Google AI format
+type GoogleAIFunctionResponse struct {
+	Name     string          `json:"name"`
+	Response json.RawMessage `json:"response"`
+}
+
+// GoogleAIGenerationConfig represents generation parameters in Google AI format
+type GoogleAIGenerationConfig struct {
+	MaxOutputTokens int      `json:"maxOutputTokens,omitempty"`
+	Temperature     float64  `json:"temperature,omitempty"`
+	TopP            float64  `json:"topP,omitempty"`
+	StopSequences   []string `json:"stopSequences,omitempty"`
+}
+
+// GoogleAIResponse represents the response format from Google AI API
+type GoogleAIResponse struct {
+	Candidates     []GoogleAICandidate     `json:"candidates"`
+	PromptFeedback *GoogleAIPromptFeedback `json:"promptFeedback,omitempty"`
+	UsageMetadata  GoogleAIUsageMetadata   `json:"usageMetadata"`
+	ModelVersion   string                  `json:"modelVersion"`
+	ResponseID     string                  `json:"responseId"`
+}
+
+// GoogleAIStreamChunk represents a streaming chunk from Google AI API
+type GoogleAIStreamChunk struct {
+	GoogleAIResponse
+	Error *GoogleAIError `json:"error,omitempty"`
+}
+
+// GoogleAIError represents an error in Google AI format
+type GoogleAIError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Status  string `json:"status"`
+}
+
+// GoogleAICandidate represents a candidate in Google AI response
+type GoogleAICandidate struct {
+	Index         int                    `json:"index"`
+	Content       GoogleAIContent        `json:"content"`
+	FinishReason  string                 `json:"finishReason"`
+	SafetyRatings []GoogleAISafetyRating `json:"safetyRatings,omitempty"`
+}
+
+// GoogleAISafetyRating represents a safety rating in Google AI response
+type GoogleAISafetyRating struct {
+	Category    string `json:"category"`
+	Probability string `json:"probability"`
+	Blocked     bool   `json:"blocked,omitempty"`
+}
+
+// GoogleAIPromptFeedback represents the prompt feedback in Google AI response
+type GoogleAIPromptFeedback struct {
+	BlockReason   string                 `json:"blockReason,omitempty"`
+	SafetyRatings []GoogleAISafetyRating `json:"safetyRatings,omitempty"`
+}
+
+// GoogleAIUsageMetadata represents usage information in Google AI response
+type GoogleAIUsageMetadata struct {
+	PromptTokenCount        int `json:"promptTokenCount"`
+	CandidatesTokenCount    int `json:"candidatesTokenCount"`
+	TotalTokenCount         int `json:"totalTokenCount"`
+	CachedContentTokenCount int `json:"cachedContentTokenCount,omitempty"`
+}
+
+// usage converts Google AI usage metadata to our unified format
+func (u GoogleAIUsageMetadata) usage() Usage {
+	usage := Usage{
+		PromptTokens:     u.PromptTokenCount,
+		CompletionTokens: u.CandidatesTokenCount,
+		TotalTokens:      u.TotalTokenCount,
+	}
+	if u.CachedContentTokenCount > 0 {
+		usage.PromptTokensDetails = &PromptTokensDetails{CachedTokens: u.CachedContentTokenCount}
+	}
+	return usage
+}
+
+// GoogleAIBatchEmbedRequest represents the batch embed request format for Google AI API
+type GoogleAIBatchEmbedRequest struct {
+	Requests []GoogleAIEmbedRequest `json:"requests"`
+}
+
+// GoogleAIEmbedRequest represents a single embed request in Google AI format
+type GoogleAIEmbedRequest struct {
+	Model                string          `json:"model"`
+	Content              GoogleAIContent `json:"content"`
+	OutputDimensionality int             `json:"outputDimensionality,omitempty"`
+}
+
+// GoogleAIBatchEmbedResponse represents the batch embed response from Google AI
+type GoogleAIBatchEmbedResponse struct {
+	Embeddings []GoogleAIEmbedding `json:"embeddings"`
+}
+
+// GoogleAIEmbedding represents a single embedding in Google AI response
+type GoogleAIEmbedding struct {
+	Values []float64 `json:"values"`
+}
+
+// GoogleAICountTokensRequest represents the count tokens request format for Google AI API
+type GoogleAICountTokensRequest struct {
+	Contents []GoogleAIContent `json:"contents"`
+}
+
+// GoogleAICountTokensResponse represents the count tokens response from Google AI
+type GoogleAICountTokensResponse struct {
+	TotalTokens int `json:"totalTokens"`
+}
+
+// GoogleAIModelsResponse represents the models response from Google AI
+type GoogleAIModelsResponse struct {
+	Models        []GoogleAIModel `json:"models"`
+	NextPageToken string          `json:"nextPageToken"`
+}
+
+// GoogleAIModel represents a model in Google AI
+type GoogleAIModel struct {
+	Name                       string   `json:"name"`
+	DisplayName                string   `json:"displayName"`
+	InputTokenLimit            int      `json:"inputTokenLimit"`
+	OutputTokenLimit           int      `json:"outputTokenLimit"`
+	SupportedGenerationMethods []string `json:"supportedGenerationMethods"`
+}
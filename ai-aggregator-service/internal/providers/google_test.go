@@ -0,0 +1,349 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+// newTestGoogleAIProvider returns a provider talking to a test server run by handler
+func newTestGoogleAIProvider(t *testing.T, handler http.HandlerFunc) *GoogleAIProvider {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return NewGoogleAIProvider(Config{APIKey: "test-key", BaseURL: server.URL})
+}
+
+func TestGoogleAISendRequest(t *testing.T) {
+	var got GoogleAIRequest
+	p := newTestGoogleAIProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/models/gemini-2.0-flash:generateContent" {
+			t.Errorf("path = %s, want the generateContent method of the model", r.URL.Path)
+		}
+		if key := r.Header.Get("x-goog-api-key"); key != "test-key" {
+			t.Errorf("x-goog-api-key = %q, want test-key", key)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Fatalf("failed to decode request: %v", err)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{
+			"responseId": "resp-1",
+			"modelVersion": "gemini-2.0-flash-001",
+			"candidates": [{
+				"index": 0,
+				"content": {"role": "model", "parts": [
+					{"text": "Checking the weather."},
+					{"functionCall": {"name": "get_weather", "args": {"city":"Pune"}}}
+				]},
+				"finishReason": "STOP"
+			}],
+			"usageMetadata": {"promptTokenCount": 12, "candidatesTokenCount": 8, "totalTokenCount": 20, "cachedContentTokenCount": 4}
+		}`)
+	})
+
+	resp, err := p.SendRequest(context.Background(), &Request{
+		Model: "gemini-2.0-flash",
+		Messages: []Message{
+			{Role: "system", Content: TextContent("Be brief.")},
+			{Role: "user", Content: TextContent("Weather in Mumbai?")},
+			{Role: "assistant", ToolCalls: []ToolCall{{
+				ID:       "call_1",
+				Type:     "function",
+				Function: FunctionCall{Name: "get_weather", Arguments: `{"city":"Mumbai"}`},
+			}}},
+			{Role: "tool", ToolCallID: "call_1", Content: TextContent("31C and humid")},
+			{Role: "user", Content: TextContent("And in Pune?")},
+		},
+		MaxTokens:   256,
+		Temperature: 0.5,
+		Stop:        []string{"END"},
+		Tools: []Tool{{
+			Type: "function",
+			Function: FunctionDefinition{
+				Name:       "get_weather",
+				Parameters: json.RawMessage(`{"type":"object","properties":{"city":{"type":"string"}}}`),
+			},
+		}},
+		ToolChoice: &ToolChoice{Function: "get_weather"},
+	})
+	if err != nil {
+		t.Fatalf("SendRequest() error = %v", err)
+	}
+
+	if got.SystemInstruction == nil || got.SystemInstruction.text() != "Be brief." {
+		t.Errorf("systemInstruction = %+v, want the system prompt", got.SystemInstruction)
+	}
+	// The tool result and the following user message are sent together in one user turn
+	roles := make([]string, 0, len(got.Contents))
+	for _, content := range got.Contents {
+		roles = append(roles, content.Role)
+	}
+	if want := []string{"user", "model", "user"}; !slices.Equal(roles, want) {
+		t.Fatalf("content roles = %v, want %v", roles, want)
+	}
+	if call := got.Contents[1].Parts[0].FunctionCall; call == nil || call.Name != "get_weather" || string(call.Args) != `{"city":"Mumbai"}` {
+		t.Errorf("model turn = %+v, want the get_weather call", got.Contents[1].Parts)
+	}
+	turn := got.Contents[2].Parts
+	if len(turn) != 2 || turn[0].FunctionResponse == nil || turn[0].FunctionResponse.Name != "get_weather" {
+		t.Fatalf("last user turn = %+v, want the function response and the question", turn)
+	}
+	if response := string(turn[0].FunctionResponse.Response); response != `{"content":"31C and humid"}` {
+		t.Errorf("function response = %s, want the tool result wrapped in an object", response)
+	}
+	if turn[1].Text != "And in Pune?" {
+		t.Errorf("last part = %+v, want the user question", turn[1])
+	}
+
+	config := got.GenerationConfig
+	if config == nil || config.MaxOutputTokens != 256 || config.Temperature != 0.5 || !slices.Equal(config.StopSequences, []string{"END"}) {
+		t.Errorf("generationConfig = %+v, want the request's sampling parameters", config)
+	}
+	if len(got.Tools) != 1 || len(got.Tools[0].FunctionDeclarations) != 1 || got.Tools[0].FunctionDeclarations[0].Name != "get_weather" {
+		t.Errorf("tools = %+v, want the get_weather declaration", got.Tools)
+	}
+	if got.ToolConfig == nil || got.ToolConfig.FunctionCallingConfig.Mode != "ANY" ||
+		!slices.Equal(got.ToolConfig.FunctionCallingConfig.AllowedFunctionNames, []string{"get_weather"}) {
+		t.Errorf("toolConfig = %+v, want mode ANY restricted to get_weather", got.ToolConfig)
+	}
+
+	if resp.ID != "resp-1" || resp.Model != "gemini-2.0-flash-001" {
+		t.Errorf("id, model = %s, %s, want resp-1, gemini-2.0-flash-001", resp.ID, resp.Model)
+	}
+	if len(resp.Choices) != 1 {
+		t.Fatalf("got %d choices, want 1", len(resp.Choices))
+	}
+	choice := resp.Choices[0]
+	if text := choice.Message.Content.Text(); text != "Checking the weather." {
+		t.Errorf("content = %q, want the candidate text", text)
+	}
+	if len(choice.Message.ToolCalls) != 1 {
+		t.Fatalf("got %d tool calls, want 1", len(choice.Message.ToolCalls))
+	}
+	call := choice.Message.ToolCalls[0]
+	if call.ID != "call_resp-1_0_0" || call.Function.Name != "get_weather" || call.Function.Arguments != `{"city":"Pune"}` {
+		t.Errorf("tool call = %+v, want get_weather with an ID derived from the response", call)
+	}
+	if choice.FinishReason != "tool_calls" {
+		t.Errorf("finish reason = %q, want tool_calls after a function call", choice.FinishReason)
+	}
+	if resp.Usage.PromptTokens != 12 || resp.Usage.CompletionTokens != 8 || resp.Usage.TotalTokens != 20 {
+		t.Errorf("usage = %+v, want 12 prompt and 8 completion tokens", resp.Usage)
+	}
+	if resp.Usage.PromptTokensDetails == nil || resp.Usage.PromptTokensDetails.CachedTokens != 4 {
+		t.Errorf("prompt tokens details = %+v, want 4 cached tokens", resp.Usage.PromptTokensDetails)
+	}
+}
+
+func TestGoogleAISendRequestErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		body       string
+		wantKind   ErrorKind
+		wantStatus int
+	}{
+		{
+			name:     "blocked prompt",
+			status:   http.StatusOK,
+			body:     `{"promptFeedback": {"blockReason": "SAFETY"}}`,
+			wantKind: ErrorKindContentFiltered,
+		},
+		{
+			name:       "invalid argument",
+			status:     http.StatusBadRequest,
+			body:       `{"error": {"code": 400, "message": "Invalid JSON payload", "status": "INVALID_ARGUMENT"}}`,
+			wantKind:   ErrorKindInvalidRequest,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "quota exhausted",
+			status:     http.StatusTooManyRequests,
+			body:       `{"error": {"code": 429, "message": "Resource has been exhausted (e.g. check quota): too many tokens", "status": "RESOURCE_EXHAUSTED"}}`,
+			wantKind:   ErrorKindRateLimited,
+			wantStatus: http.StatusTooManyRequests,
+		},
+		{
+			name:       "rejected key",
+			status:     http.StatusForbidden,
+			body:       `{"error": {"code": 403, "message": "API key not valid", "status": "PERMISSION_DENIED"}}`,
+			wantKind:   ErrorKindAuth,
+			wantStatus: http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestGoogleAIProvider(t, func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(tt.status)
+				io.WriteString(w, tt.body)
+			})
+
+			_, err := p.SendRequest(context.Background(), &Request{
+				Model:    "gemini-2.0-flash",
+				Messages: []Message{{Role: "user", Content: TextContent("Hello")}},
+			})
+
+			var providerErr *Error
+			if !errors.As(err, &providerErr) {
+				t.Fatalf("SendRequest() error = %v, want a provider error", err)
+			}
+			if providerErr.Kind != tt.wantKind || providerErr.StatusCode != tt.wantStatus {
+				t.Errorf("error kind, status = %s, %d, want %s, %d", providerErr.Kind, providerErr.StatusCode, tt.wantKind, tt.wantStatus)
+			}
+			if providerErr.Provider != "google" {
+				t.Errorf("error provider = %q, want google", providerErr.Provider)
+			}
+		})
+	}
+}
+
+func TestConvertGoogleAIFinishReason(t *testing.T) {
+	tests := []struct {
+		reason      string
+		calledTools bool
+		want        string
+	}{
+		{"", false, ""},
+		{"STOP", false, "stop"},
+		{"STOP", true, "tool_calls"},
+		{"MAX_TOKENS", false, "length"},
+		{"SAFETY", false, "content_filter"},
+		{"RECITATION", false, "content_filter"},
+		{"BLOCKLIST", false, "content_filter"},
+		{"PROHIBITED_CONTENT", false, "content_filter"},
+		{"SPII", false, "content_filter"},
+		{"IMAGE_SAFETY", false, "content_filter"},
+		{"MALFORMED_FUNCTION_CALL", false, "malformed_function_call"},
+	}
+
+	for _, tt := range tests {
+		if got := convertGoogleAIFinishReason(tt.reason, tt.calledTools); got != tt.want {
+			t.Errorf("convertGoogleAIFinishReason(%q, %v) = %q, want %q", tt.reason, tt.calledTools, got, tt.want)
+		}
+	}
+}
+
+func TestGoogleAIStream(t *testing.T) {
+	p := newTestGoogleAIProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/models/gemini-2.0-flash:streamGenerateContent" || r.URL.Query().Get("alt") != "sse" {
+			t.Errorf("url = %s, want the streamGenerateContent method with alt=sse", r.URL)
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, `data: {"responseId": "resp-2", "modelVersion": "gemini-2.0-flash-001", "candidates": [{"index": 0, "content": {"role": "model", "parts": [{"text": "Once upon"}]}}]}`+"\n\n")
+		io.WriteString(w, `data: {"candidates": [{"index": 0, "content": {"role": "model", "parts": [{"text": " a time"}]}}]}`+"\n\n")
+		io.WriteString(w, `data: {"candidates": [{"index": 0, "content": {"role": "model", "parts": []}, "finishReason": "SAFETY", "safetyRatings": [{"category": "HARM_CATEGORY_DANGEROUS_CONTENT", "probability": "HIGH", "blocked": true}]}], "usageMetadata": {"promptTokenCount": 5, "candidatesTokenCount": 3, "totalTokenCount": 8}}`+"\n\n")
+	})
+
+	req := &Request{
+		Model:    "gemini-2.0-flash",
+		Messages: []Message{{Role: "user", Content: TextContent("Tell me a story")}},
+		Stream:   true,
+	}
+	body, err := p.SendStreamRequest(context.Background(), req)
+	if err != nil {
+		t.Fatalf("SendStreamRequest() error = %v", err)
+	}
+	decoder := p.NewStreamDecoder(body, req)
+	defer decoder.Close()
+
+	var chunks []*StreamResponse
+	for {
+		chunk, err := decoder.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Recv() error = %v", err)
+		}
+		chunks = append(chunks, chunk)
+	}
+
+	if len(chunks) != 4 {
+		t.Fatalf("got %d chunks, want 3 content chunks and a usage chunk", len(chunks))
+	}
+	for _, chunk := range chunks {
+		if chunk.ID != "resp-2" || chunk.Model != "gemini-2.0-flash-001" {
+			t.Errorf("chunk id, model = %s, %s, want resp-2, gemini-2.0-flash-001", chunk.ID, chunk.Model)
+		}
+	}
+
+	if delta := chunks[0].Choices[0].Delta; delta.Role != "assistant" || delta.Content != "Once upon" {
+		t.Errorf("first delta = %+v, want the assistant role and the first text", delta)
+	}
+	if delta := chunks[1].Choices[0].Delta; delta.Role != "" || delta.Content != " a time" {
+		t.Errorf("second delta = %+v, want only the next text", delta)
+	}
+	if reason := chunks[2].Choices[0].FinishReason; reason == nil || *reason != "content_filter" {
+		t.Errorf("finish reason = %v, want content_filter for a safety stop", reason)
+	}
+	if chunks[0].Choices[0].FinishReason != nil {
+		t.Errorf("first chunk finish reason = %q, want none", *chunks[0].Choices[0].FinishReason)
+	}
+
+	last := chunks[3]
+	if len(last.Choices) != 0 || last.Usage == nil {
+		t.Fatalf("last chunk = %+v, want a usage chunk without choices", last)
+	}
+	if last.Usage.PromptTokens != 5 || last.Usage.CompletionTokens != 3 || last.Usage.TotalTokens != 8 {
+		t.Errorf("usage = %+v, want 5 prompt and 3 completion tokens", last.Usage)
+	}
+}
+
+func TestGoogleAIStreamErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		event    string
+		wantKind ErrorKind
+	}{
+		{
+			name:     "error event",
+			event:    `{"error": {"code": 429, "message": "Resource has been exhausted", "status": "RESOURCE_EXHAUSTED"}}`,
+			wantKind: ErrorKindRateLimited,
+		},
+		{
+			name:     "blocked prompt",
+			event:    `{"promptFeedback": {"blockReason": "PROHIBITED_CONTENT"}}`,
+			wantKind: ErrorKindContentFiltered,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestGoogleAIProvider(t, func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/event-stream")
+				io.WriteString(w, "data: "+tt.event+"\n\n")
+			})
+
+			req := &Request{
+				Model:    "gemini-2.0-flash",
+				Messages: []Message{{Role: "user", Content: TextContent("Hello")}},
+				Stream:   true,
+			}
+			body, err := p.SendStreamRequest(context.Background(), req)
+			if err != nil {
+				t.Fatalf("SendStreamRequest() error = %v", err)
+			}
+			decoder := p.NewStreamDecoder(body, req)
+			defer decoder.Close()
+
+			_, err = decoder.Recv()
+			var providerErr *Error
+			if !errors.As(err, &providerErr) {
+				t.Fatalf("Recv() error = %v, want a provider error", err)
+			}
+			if providerErr.Kind != tt.wantKind {
+				t.Errorf("error kind = %s, want %s", providerErr.Kind, tt.wantKind)
+			}
+		})
+	}
+}
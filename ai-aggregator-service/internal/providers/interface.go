@@ -2,6 +2,8 @@ package providers
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"io"
 )
 
@@ -14,13 +16,122 @@ type Request struct {
 	TopP        float64                `json:"top_p,omitempty"`
 	Stream      bool                   `json:"stream,omitempty"`
 	Stop        []string               `json:"stop,omitempty"`
+	Tools       []Tool                 `json:"tools,omitempty"`
+	ToolChoice  *ToolChoice            `json:"tool_choice,omitempty"`
 	Metadata    map[string]interface{} `json:"metadata,omitempty"`
 }
 
-// Message represents a chat message
+// usesTools reports whether the request declares tools or carries a tool-calling exchange
+func (r *Request) usesTools() bool {
+	if len(r.Tools) > 0 {
+		return true
+	}
+	for _, msg := range r.Messages {
+		if msg.Role == "tool" || len(msg.ToolCalls) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// withModel returns the request for a fallback model, or the request itself when unchanged
+func (r *Request) withModel(model string) *Request {
+	if model == r.Model {
+		return r
+	}
+	fallback := *r
+	fallback.Model = model
+	return &fallback
+}
+
+// textOnly reports whether every message consists of text parts only
+func (r *Request) textOnly() bool {
+	for _, msg := range r.Messages {
+		if !msg.Content.IsText() {
+			return false
+		}
+	}
+	return true
+}
+
+// Message represents a chat message. Assistant messages may carry tool calls, and
+// messages with the "tool" role carry the result of the call named by ToolCallID.
 type Message struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
+	Role       string     `json:"role"`
+	Content    Content    `json:"content"`
+	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string     `json:"tool_call_id,omitempty"`
+}
+
+// Tool represents a tool the model may call. Only function tools are supported.
+type Tool struct {
+	Type     string             `json:"type"`
+	Function FunctionDefinition `json:"function"`
+}
+
+// FunctionDefinition describes a function tool. Parameters is a JSON Schema object.
+type FunctionDefinition struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Parameters  json.RawMessage `json:"parameters,omitempty"`
+}
+
+// ToolChoice controls whether and which tools the model calls. It is encoded as
+// "none", "auto" or "required", or as an object naming a single function.
+type ToolChoice struct {
+	Mode     string // none, auto or required; empty when Function is set
+	Function string
+}
+
+// toolChoiceFunction is the object form of a ToolChoice
+type toolChoiceFunction struct {
+	Type     string `json:"type"`
+	Function struct {
+		Name string `json:"name"`
+	} `json:"function"`
+}
+
+// MarshalJSON implements json.Marshaler
+func (c ToolChoice) MarshalJSON() ([]byte, error) {
+	if c.Function == "" {
+		return json.Marshal(c.Mode)
+	}
+
+	choice := toolChoiceFunction{Type: "function"}
+	choice.Function.Name = c.Function
+	return json.Marshal(choice)
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (c *ToolChoice) UnmarshalJSON(data []byte) error {
+	var mode string
+	if err := json.Unmarshal(data, &mode); err == nil {
+		*c = ToolChoice{Mode: mode}
+		return nil
+	}
+
+	var choice toolChoiceFunction
+	if err := json.Unmarshal(data, &choice); err != nil {
+		return err
+	}
+	if choice.Type != "function" || choice.Function.Name == "" {
+		return fmt.Errorf("tool_choice must be none, auto, required or a function")
+	}
+	*c = ToolChoice{Function: choice.Function.Name}
+	return nil
+}
+
+// ToolCall represents a call the model made to a function tool
+type ToolCall struct {
+	ID       string       `json:"id"`
+	Type     string       `json:"type"`
+	Function FunctionCall `json:"function"`
+}
+
+// FunctionCall holds the function name and its JSON-encoded arguments
+type FunctionCall struct {
+	Name      string `json:"name,omitempty"`
+	Arguments string `json:"arguments"`
 }
 
 // Response represents a unified response structure from all AI providers
@@ -43,16 +154,45 @@ type Choice struct {
 
 // Usage represents token usage information
 type Usage struct {
-	PromptTokens     int `json:"prompt_tokens"`
-	CompletionTokens int `json:"completion_tokens"`
-	TotalTokens      int `json:"total_tokens"`
+	PromptTokens        int                  `json:"prompt_tokens"`
+	CompletionTokens    int                  `json:"completion_tokens"`
+	TotalTokens         int                  `json:"total_tokens"`
+	PromptTokensDetails *PromptTokensDetails `json:"prompt_tokens_details,omitempty"`
+}
+
+// PromptTokensDetails breaks down the prompt tokens. Cached tokens are included in the
+// prompt tokens and are billed at the provider's cached input rate.
+type PromptTokensDetails struct {
+	CachedTokens int `json:"cached_tokens"`
+}
+
+// CachedTokens returns the number of prompt tokens served from the provider's prompt cache
+func (u *Usage) CachedTokens() int {
+	if u == nil || u.PromptTokensDetails == nil {
+		return 0
+	}
+	return u.PromptTokensDetails.CachedTokens
+}
+
+// add accumulates the usage of another request into u
+func (u *Usage) add(other Usage) {
+	u.PromptTokens += other.PromptTokens
+	u.CompletionTokens += other.CompletionTokens
+	u.TotalTokens += other.TotalTokens
+	if cached := other.CachedTokens(); cached > 0 {
+		if u.PromptTokensDetails == nil {
+			u.PromptTokensDetails = &PromptTokensDetails{}
+		}
+		u.PromptTokensDetails.CachedTokens += cached
+	}
 }
 
-// APIError represents an error response from the provider
+// APIError represents an OpenAI-compatible error object
 type APIError struct {
-	Message string `json:"message"`
-	Type    string `json:"type"`
-	Code    string `json:"code,omitempty"`
+	Message string  `json:"message"`
+	Type    string  `json:"type"`
+	Code    string  `json:"code,omitempty"`
+	Param   *string `json:"param"`
 }
 
 // StreamResponse represents a streaming response chunk
@@ -62,6 +202,7 @@ type StreamResponse struct {
 	Created int64          `json:"created"`
 	Model   string         `json:"model"`
 	Choices []StreamChoice `json:"choices"`
+	Usage   *Usage         `json:"usage,omitempty"`
 }
 
 // StreamChoice represents a streaming choice
@@ -73,7 +214,65 @@ type StreamChoice struct {
 
 // StreamDelta represents a streaming delta
 type StreamDelta struct {
-	Content string `json:"content"`
+	Role      string          `json:"role,omitempty"`
+	Content   string          `json:"content,omitempty"`
+	ToolCalls []ToolCallDelta `json:"tool_calls,omitempty"`
+}
+
+// ToolCallDelta represents a fragment of a tool call in a stream. The first fragment of
+// a call carries its ID and function name; later ones append to its arguments.
+type ToolCallDelta struct {
+	Index    int          `json:"index"`
+	ID       string       `json:"id,omitempty"`
+	Type     string       `json:"type,omitempty"`
+	Function FunctionCall `json:"function"`
+}
+
+// EmbeddingRequest represents a unified embeddings request
+type EmbeddingRequest struct {
+	Model      string   `json:"model"`
+	Input      []string `json:"input"`
+	Dimensions int      `json:"dimensions,omitempty"`
+	InputType  string   `json:"input_type,omitempty"` // what the inputs are for, for providers that embed queries and documents differently
+}
+
+// withModel returns the request for a resolved alias, or the request itself when unchanged
+func (r *EmbeddingRequest) withModel(model string) *EmbeddingRequest {
+	if model == r.Model {
+		return r
+	}
+	resolved := *r
+	resolved.Model = model
+	return &resolved
+}
+
+// EmbeddingResponse represents a unified embeddings response
+type EmbeddingResponse struct {
+	Object string      `json:"object"`
+	Data   []Embedding `json:"data"`
+	Model  string      `json:"model"`
+	Usage  Usage       `json:"usage"`
+}
+
+// Embedding represents a single embedding vector
+type Embedding struct {
+	Object    string    `json:"object"`
+	Embedding []float64 `json:"embedding"`
+	Index     int       `json:"index"`
+}
+
+// embeddingBatcher is implemented by embedders that cap the number of inputs per request
+type embeddingBatcher interface {
+	MaxEmbeddingInputs() int
+}
+
+// StreamDecoder reads a provider's native stream as OpenAI-compatible chunks
+type StreamDecoder interface {
+	// Recv returns the next chunk, or io.EOF once the stream has finished
+	Recv() (*StreamResponse, error)
+
+	// Close releases the underlying stream
+	Close() error
 }
 
 // Provider defines the interface that all AI providers must implement
@@ -87,6 +286,9 @@ type Provider interface {
 	// SendStreamRequest sends a streaming request to the provider
 	SendStreamRequest(ctx context.Context, req *Request) (io.ReadCloser, error)
 
+	// NewStreamDecoder wraps a stream returned by SendStreamRequest in a decoder
+	NewStreamDecoder(body io.ReadCloser, req *Request) StreamDecoder
+
 	// GetModels returns the list of available models for this provider
 	GetModels(ctx context.Context) ([]ModelInfo, error)
 
@@ -95,9 +297,12 @@ type Provider interface {
 
 	// ValidateModel checks if a model is valid for this provider
 	ValidateModel(ctx context.Context, modelID string) error
+}
 
-	// GetPricing returns the pricing information for a model
-	GetPricing(ctx context.Context, modelID string) (*Pricing, error)
+// Embedder is implemented by providers that can create embeddings
+type Embedder interface {
+	// CreateEmbeddings returns one embedding per input, in input order
+	CreateEmbeddings(ctx context.Context, req *EmbeddingRequest) (*EmbeddingResponse, error)
 }
 
 // ModelInfo contains information about a model
@@ -110,24 +315,35 @@ type ModelInfo struct {
 	ContextSize int      `json:"context_size"`
 }
 
-// Pricing contains pricing information for a model
-type Pricing struct {
-	InputCost  float64 `json:"input_cost"`  // Cost per 1K input tokens
-	OutputCost float64 `json:"output_cost"` // Cost per 1K output tokens
-	Currency   string  `json:"currency"`
-}
-
 // Config contains provider-specific configuration
 type Config struct {
-	APIKey     string            `json:"api_key"`
-	BaseURL    string            `json:"base_url"`
-	Headers    map[string]string `json:"headers"`
-	Timeout    int               `json:"timeout"`
-	MaxRetries int               `json:"max_retries"`
-	RateLimit  RateLimitConfig   `json:"rate_limit"`
+	Name       string                 `json:"name"` // instance name, defaulting to the provider type's name
+	Type       string                 `json:"type"` // provider type, selecting the constructor used by ProviderFactory
+	APIKey     string                 `json:"api_key"`
+	APIKeys    []APIKeyConfig         `json:"api_keys"` // key pool used instead of APIKey when set
+	BaseURL    string                 `json:"base_url"`
+	Headers    map[string]string      `json:"headers"`
+	Timeout    int                    `json:"timeout"`
+	MaxRetries *int                   `json:"max_retries"` // overrides the router's retry policy when set
+	RateLimit  RateLimitConfig        `json:"rate_limit"`
+	Models     []string               `json:"models"`  // static model list for servers without model discovery
+	Options    map[string]interface{} `json:"options"` // provider-specific settings, e.g. from providers.config
+}
+
+// RetryLimit returns the configured retry limit, and false when none is set
+func (c Config) RetryLimit() (int, bool) {
+	if c.MaxRetries == nil {
+		return 0, false
+	}
+	return *c.MaxRetries, true
 }
 
-// RateLimitConfig contains rate limiting configuration
+// HasAPIKey reports whether the config carries any API key
+func (c Config) HasAPIKey() bool {
+	return c.APIKey != "" || len(c.APIKeys) > 0
+}
+
+// RateLimitConfig contains rate limiting configuration. For a key pool it caps each key.
 type RateLimitConfig struct {
 	RequestsPerMinute int `json:"requests_per_minute"`
 	TokensPerMinute   int `json:"tokens_per_minute"`
@@ -137,3 +353,35 @@ type RateLimitConfig struct {
 type ProviderFactory interface {
 	Create(config Config) (Provider, error)
 }
+
+// factory creates the built-in provider types
+type factory struct{}
+
+// NewProviderFactory returns a ProviderFactory that creates built-in providers by config.Type
+func NewProviderFactory() ProviderFactory {
+	return factory{}
+}
+
+// Create creates a provider instance of config.Type
+func (factory) Create(config Config) (Provider, error) {
+	switch config.Type {
+	case "openai":
+		return NewOpenAIProvider(config), nil
+	case "anthropic":
+		return NewAnthropicProvider(config), nil
+	case "google":
+		return NewGoogleAIProvider(config), nil
+	case "cohere":
+		return NewCohereProvider(config), nil
+	case "mistral":
+		return NewMistralProvider(config), nil
+	case "azure":
+		return NewAzureOpenAIProvider(config)
+	case "openai_compatible":
+		return NewOpenAICompatibleProvider(config)
+	case "ollama":
+		return NewOllamaProvider(config), nil
+	default:
+		return nil, fmt.Errorf("unknown provider type %q", config.Type)
+	}
+}
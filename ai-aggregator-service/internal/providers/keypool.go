@@ -0,0 +1,508 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// rateLimitCooldown is how long a key that hit a rate limit is rested when the
+	// provider does not say how long to wait
+	rateLimitCooldown = 30 * time.Second
+
+	// authCooldown is how long a key the provider rejected is rested, giving a rotated
+	// or suspended key time to be fixed without failing every request meanwhile
+	authCooldown = 5 * time.Minute
+
+	// keyWindow is the sliding window per-key request and token limits apply to
+	keyWindow = time.Minute
+)
+
+// Key selection strategies, set through the "key_selection" provider option
+const (
+	// KeySelectionRoundRobin spreads requests across keys in proportion to their weights
+	KeySelectionRoundRobin = "round_robin"
+
+	// KeySelectionLeastLoaded sends each request to the key with the most headroom
+	// relative to its weight
+	KeySelectionLeastLoaded = "least_loaded"
+)
+
+// APIKeyConfig is one upstream API key in a provider's key pool
+type APIKeyConfig struct {
+	Key string `json:"key"`
+
+	// Weight is the key's share of requests relative to the other keys; defaults to 1
+	Weight int `json:"weight"`
+
+	// RateLimit caps the key's requests and tokens per minute; zero fields fall back
+	// to the provider's RateLimit, and zero there means unlimited
+	RateLimit RateLimitConfig `json:"rate_limit"`
+}
+
+// authorizer sets the credentials of one API key on an outgoing request
+type authorizer func(req *http.Request, key string)
+
+// newHTTPClient returns the client a provider sends its requests with. When the config
+// has a key pool, requests are spread across the pool's keys, each set with authorize.
+func newHTTPClient(config Config, authorize authorizer) *http.Client {
+	client := &http.Client{
+		Timeout: time.Duration(config.Timeout) * time.Second,
+	}
+	if len(config.APIKeys) > 0 {
+		client.Transport = newKeyPool(config, authorize, http.DefaultTransport)
+	}
+	return client
+}
+
+// pooledKey is a key of a pool and what it has been used for recently
+type pooledKey struct {
+	key    string
+	weight int
+	limit  RateLimitConfig
+
+	// current is the key's smooth weighted round-robin counter
+	current int
+
+	inFlight int
+	requests []time.Time
+	tokens   []tokenUse
+
+	cooldownUntil time.Time
+}
+
+// tokenUse is a number of tokens charged to a key at a point in time
+type tokenUse struct {
+	at     time.Time
+	tokens int
+}
+
+// keyPool is an http.RoundTripper that sends each request with one of several API keys.
+// Keys are skipped while they are at their per-minute limits or cooling down after the
+// provider rate limited or rejected them; such a rejected request is sent again with
+// another key when one is available.
+type keyPool struct {
+	provider  string
+	strategy  string
+	authorize authorizer
+	transport http.RoundTripper
+
+	mu   sync.Mutex
+	keys []*pooledKey
+}
+
+// newKeyPool creates a key pool from the config's APIKeys
+func newKeyPool(config Config, authorize authorizer, transport http.RoundTripper) *keyPool {
+	p := &keyPool{
+		provider:  config.Name,
+		strategy:  KeySelectionRoundRobin,
+		authorize: authorize,
+		transport: transport,
+	}
+	if strategy, ok := config.Options["key_selection"].(string); ok && strategy != "" {
+		p.strategy = strategy
+	}
+
+	for _, keyConfig := range config.APIKeys {
+		key := &pooledKey{
+			key:    keyConfig.Key,
+			weight: keyConfig.Weight,
+			limit:  keyConfig.RateLimit,
+		}
+		if key.weight <= 0 {
+			key.weight = 1
+		}
+		if key.limit.RequestsPerMinute == 0 {
+			key.limit.RequestsPerMinute = config.RateLimit.RequestsPerMinute
+		}
+		if key.limit.TokensPerMinute == 0 {
+			key.limit.TokensPerMinute = config.RateLimit.TokensPerMinute
+		}
+		p.keys = append(p.keys, key)
+	}
+	return p
+}
+
+// RoundTrip sends req with an available key. When none is available, a 429 response
+// is returned without calling the provider, with a Retry-After of when the first key
+// frees up, so that the router backs off or falls back as it would for the provider's own.
+func (p *keyPool) RoundTrip(req *http.Request) (*http.Response, error) {
+	rejected := make(map[*pooledKey]bool)
+	key, wait := p.acquire(rejected)
+
+	for attempt := 0; ; attempt++ {
+		if key == nil {
+			return p.exhausted(req, wait), nil
+		}
+
+		out := req.Clone(req.Context())
+		if attempt > 0 && req.Body != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				p.release(key)
+				return nil, err
+			}
+			out.Body = body
+		}
+		p.authorize(out, key.key)
+		if lease := leaseFrom(req.Context()); lease != nil {
+			lease.set(p, key)
+		}
+
+		resp, err := p.transport.RoundTrip(out)
+		if err != nil {
+			p.release(key)
+			return nil, err
+		}
+		if !p.reject(key, resp) {
+			resp.Body = &keyBody{ReadCloser: resp.Body, release: func() { p.release(key) }}
+			return resp, nil
+		}
+		rejected[key] = true
+
+		// Only a request whose body can be sent again is retried with another key
+		var next *pooledKey
+		if req.Body == nil || req.GetBody != nil {
+			next, _ = p.acquire(rejected)
+		}
+		if next == nil {
+			resp.Body = &keyBody{ReadCloser: resp.Body, release: func() { p.release(key) }}
+			return resp, nil
+		}
+
+		io.Copy(io.Discard, io.LimitReader(resp.Body, maxErrorBodySize))
+		resp.Body.Close()
+		p.release(key)
+		key = next
+	}
+}
+
+// acquire picks the key to send the next request with and counts the request against
+// it. When no key outside skip is available it returns nil and how long until one may be.
+func (p *keyPool) acquire(skip map[*pooledKey]bool) (*pooledKey, time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	var available []*pooledKey
+	wait := time.Duration(math.MaxInt64)
+	for _, key := range p.keys {
+		if skip[key] {
+			continue
+		}
+		key.prune(now)
+		if free := key.freeAt(now); free > 0 {
+			wait = min(wait, free)
+			continue
+		}
+		available = append(available, key)
+	}
+	if len(available) == 0 {
+		return nil, wait
+	}
+
+	var chosen *pooledKey
+	if p.strategy == KeySelectionLeastLoaded {
+		chosen = leastLoaded(available)
+	} else {
+		chosen = nextWeighted(available)
+	}
+
+	chosen.inFlight++
+	chosen.requests = append(chosen.requests, now)
+	return chosen, 0
+}
+
+// nextWeighted picks a key by smooth weighted round-robin, which interleaves the keys
+// instead of sending a heavy key's whole share in a burst
+func nextWeighted(keys []*pooledKey) *pooledKey {
+	var chosen *pooledKey
+	total := 0
+	for _, key := range keys {
+		key.current += key.weight
+		total += key.weight
+		if chosen == nil || key.current > chosen.current {
+			chosen = key
+		}
+	}
+	chosen.current -= total
+	return chosen
+}
+
+// leastLoaded picks the key with the lowest load per unit of weight
+func leastLoaded(keys []*pooledKey) *pooledKey {
+	var chosen *pooledKey
+	var lowest float64
+	for _, key := range keys {
+		load := key.load() / float64(key.weight)
+		if chosen == nil || load < lowest {
+			chosen, lowest = key, load
+		}
+	}
+	return chosen
+}
+
+// load is the key's in-flight requests plus the share of its per-minute limits used
+func (k *pooledKey) load() float64 {
+	load := float64(k.inFlight)
+	if k.limit.RequestsPerMinute > 0 {
+		load += float64(len(k.requests)) / float64(k.limit.RequestsPerMinute)
+	}
+	if k.limit.TokensPerMinute > 0 {
+		load += float64(k.usedTokens()) / float64(k.limit.TokensPerMinute)
+	}
+	return load
+}
+
+// prune drops the requests and tokens that fell out of the window
+func (k *pooledKey) prune(now time.Time) {
+	cutoff := now.Add(-keyWindow)
+
+	i := 0
+	for i < len(k.requests) && !k.requests[i].After(cutoff) {
+		i++
+	}
+	k.requests = k.requests[i:]
+
+	i = 0
+	for i < len(k.tokens) && !k.tokens[i].at.After(cutoff) {
+		i++
+	}
+	k.tokens = k.tokens[i:]
+}
+
+// usedTokens returns the tokens charged to the key within the window
+func (k *pooledKey) usedTokens() int {
+	used := 0
+	for _, use := range k.tokens {
+		used += use.tokens
+	}
+	return used
+}
+
+// freeAt returns how long until the key may be used, or 0 if it may be used now
+func (k *pooledKey) freeAt(now time.Time) time.Duration {
+	var wait time.Duration
+	if now.Before(k.cooldownUntil) {
+		wait = k.cooldownUntil.Sub(now)
+	}
+	if limit := k.limit.RequestsPerMinute; limit > 0 && len(k.requests) >= limit {
+		wait = max(wait, k.requests[len(k.requests)-limit].Add(keyWindow).Sub(now))
+	}
+	if limit := k.limit.TokensPerMinute; limit > 0 {
+		// Tokens free up as the oldest uses leave the window
+		excess := k.usedTokens() - limit
+		for _, use := range k.tokens {
+			if excess < 0 {
+				break
+			}
+			excess -= use.tokens
+			wait = max(wait, use.at.Add(keyWindow).Sub(now))
+		}
+	}
+	return wait
+}
+
+// reject puts the key in cooldown if the provider rate limited or refused it, and
+// reports whether it did
+func (p *keyPool) reject(key *pooledKey, resp *http.Response) bool {
+	var cooldown time.Duration
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests:
+		cooldown = parseRetryAfter(resp.Header.Get("Retry-After"))
+		if cooldown <= 0 {
+			cooldown = rateLimitCooldown
+		}
+	case http.StatusUnauthorized, http.StatusForbidden:
+		cooldown = authCooldown
+		slog.Warn("Provider rejected API key, cooling it down",
+			"provider", p.provider,
+			"key", maskKey(key.key),
+			"status", resp.StatusCode,
+			"cooldown", cooldown,
+		)
+	default:
+		return false
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if until := time.Now().Add(cooldown); until.After(key.cooldownUntil) {
+		key.cooldownUntil = until
+	}
+	return true
+}
+
+// release marks a request made with the key as finished
+func (p *keyPool) release(key *pooledKey) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	key.inFlight--
+}
+
+// charge counts tokens used by a request against the key it was sent with
+func (p *keyPool) charge(key *pooledKey, tokens int) {
+	if tokens <= 0 {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	key.tokens = append(key.tokens, tokenUse{at: time.Now(), tokens: tokens})
+}
+
+// exhausted builds the response returned when every key is rate limited or cooling down
+func (p *keyPool) exhausted(req *http.Request, wait time.Duration) *http.Response {
+	seconds := int(math.Ceil(wait.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	message := fmt.Sprintf("all API keys of provider %s are rate limited or cooling down", p.provider)
+	body := fmt.Sprintf(`{"error":{"message":%q,"type":"rate_limit_error"}}`, message)
+
+	header := make(http.Header)
+	header.Set("Content-Type", "application/json")
+	header.Set("Retry-After", strconv.Itoa(seconds))
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", http.StatusTooManyRequests, http.StatusText(http.StatusTooManyRequests)),
+		StatusCode:    http.StatusTooManyRequests,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(strings.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
+
+// keyBody releases the key a response was received with once its body is closed,
+// so that streams count as in flight until they end
+type keyBody struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+// Close closes the body and releases the key
+func (b *keyBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
+
+// maskKey returns enough of a key to tell keys apart in logs
+func maskKey(key string) string {
+	if len(key) <= 8 {
+		return "****"
+	}
+	return key[:4] + "..." + key[len(key)-4:]
+}
+
+type keyLeaseKey struct{}
+
+// keyLease records which pooled key served an attempt, so that the tokens the attempt
+// used can be charged to it once they are known
+type keyLease struct {
+	mu   sync.Mutex
+	pool *keyPool
+	key  *pooledKey
+}
+
+// withKeyLease returns a context whose pooled requests are recorded in a new lease
+func withKeyLease(ctx context.Context) (context.Context, *keyLease) {
+	lease := &keyLease{}
+	return context.WithValue(ctx, keyLeaseKey{}, lease), lease
+}
+
+// leaseFrom returns the lease attached to an attempt's context, if any
+func leaseFrom(ctx context.Context) *keyLease {
+	lease, _ := ctx.Value(keyLeaseKey{}).(*keyLease)
+	return lease
+}
+
+// set records the key a request was sent with
+func (l *keyLease) set(pool *keyPool, key *pooledKey) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.pool, l.key = pool, key
+}
+
+// charge counts the attempt's usage against the key that served it, if any. Streams are
+// charged by leasedStream once they end.
+func (l *keyLease) charge(usage *Usage) {
+	if l == nil {
+		return
+	}
+
+	l.mu.Lock()
+	pool, key := l.pool, l.key
+	l.mu.Unlock()
+
+	if pool == nil || usage == nil {
+		return
+	}
+	pool.charge(key, usage.TotalTokens)
+}
+
+// leasedStream charges the usage reported by a chat stream to the pooled key that served
+// it when the stream is closed
+type leasedStream struct {
+	StreamDecoder
+	lease *keyLease
+	usage *Usage
+	once  sync.Once
+}
+
+// Recv returns the next chunk, keeping the usage it reports
+func (s *leasedStream) Recv() (*StreamResponse, error) {
+	chunk, err := s.StreamDecoder.Recv()
+	if err == nil && chunk.Usage != nil {
+		s.usage = chunk.Usage
+	}
+	return chunk, err
+}
+
+// Close closes the stream and charges its usage
+func (s *leasedStream) Close() error {
+	err := s.StreamDecoder.Close()
+	s.once.Do(func() { s.lease.charge(s.usage) })
+	return err
+}
+
+// leasedCompletionStream is a leasedStream for text completion streams
+type leasedCompletionStream struct {
+	CompletionStream
+	lease *keyLease
+	usage *Usage
+	once  sync.Once
+}
+
+// Recv returns the next chunk, keeping the usage it reports
+func (s *leasedCompletionStream) Recv() (*CompletionResponse, error) {
+	chunk, err := s.CompletionStream.Recv()
+	if err == nil && chunk.Usage != nil {
+		s.usage = chunk.Usage
+	}
+	return chunk, err
+}
+
+// Close closes the stream and charges its usage
+func (s *leasedCompletionStream) Close() error {
+	err := s.CompletionStream.Close()
+	s.once.Do(func() { s.lease.charge(s.usage) })
+	return err
+}
@@ -0,0 +1,317 @@
+package providers
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTestKeyPool returns a pool of the keys, sending requests with a bearer token
+func newTestKeyPool(strategy string, keys ...APIKeyConfig) *keyPool {
+	config := Config{Name: "test", APIKeys: keys}
+	if strategy != "" {
+		config.Options = map[string]interface{}{"key_selection": strategy}
+	}
+	return newKeyPool(config, func(req *http.Request, key string) {
+		req.Header.Set("Authorization", "Bearer "+key)
+	}, http.DefaultTransport)
+}
+
+// keyServer is a test server answering each key with the status set for it, and 200 otherwise
+type keyServer struct {
+	mu       sync.Mutex
+	statuses map[string]int
+	header   http.Header // sent along with non-200 statuses
+	keys     []string    // keys in the order requests were received with
+	bodies   []string
+}
+
+// newKeyServer starts a key server and returns it with a client sending through pool
+func newKeyServer(t *testing.T, pool *keyPool) (*keyServer, *httptest.Server, *http.Client) {
+	t.Helper()
+	ks := &keyServer{statuses: make(map[string]int), header: make(http.Header)}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		key := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+
+		ks.mu.Lock()
+		ks.keys = append(ks.keys, key)
+		ks.bodies = append(ks.bodies, string(body))
+		status, ok := ks.statuses[key]
+		ks.mu.Unlock()
+
+		if ok && status != http.StatusOK {
+			for name, values := range ks.header {
+				w.Header()[name] = values
+			}
+			w.WriteHeader(status)
+			io.WriteString(w, `{"error":{"message":"rejected"}}`)
+			return
+		}
+		io.WriteString(w, `{}`)
+	}))
+	t.Cleanup(server.Close)
+	return ks, server, &http.Client{Transport: pool}
+}
+
+// post sends body to the server and returns the response status and Retry-After header
+func post(t *testing.T, client *http.Client, url, body string) (int, string) {
+	t.Helper()
+	resp, err := client.Post(url, "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("Post() error = %v", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	return resp.StatusCode, resp.Header.Get("Retry-After")
+}
+
+func TestKeyPoolWeightedRoundRobin(t *testing.T) {
+	pool := newTestKeyPool("", APIKeyConfig{Key: "a", Weight: 3}, APIKeyConfig{Key: "b"})
+
+	var picks []string
+	for i := 0; i < 8; i++ {
+		key, _ := pool.acquire(nil)
+		picks = append(picks, key.key)
+		pool.release(key)
+	}
+
+	// Smooth weighted round-robin interleaves the lighter key instead of sending a's share in a burst
+	if got, want := strings.Join(picks, ""), "aabaaaba"; got != want {
+		t.Errorf("picked keys %s, want %s", got, want)
+	}
+}
+
+func TestKeyPoolLeastLoaded(t *testing.T) {
+	pool := newTestKeyPool(KeySelectionLeastLoaded, APIKeyConfig{Key: "a"}, APIKeyConfig{Key: "b", Weight: 2})
+
+	// b may carry two requests for every one of a's
+	var picks []string
+	for i := 0; i < 3; i++ {
+		key, _ := pool.acquire(nil)
+		picks = append(picks, key.key)
+	}
+	if got, want := strings.Join(picks, ""), "abb"; got != want {
+		t.Errorf("picked keys %s while holding them, want %s", got, want)
+	}
+
+	// Releasing a's request makes it the least loaded key again
+	pool.release(pool.keys[0])
+	if key, _ := pool.acquire(nil); key.key != "a" {
+		t.Errorf("picked key %s after a was released, want a", key.key)
+	}
+}
+
+func TestKeyPoolRequestLimit(t *testing.T) {
+	pool := newTestKeyPool("",
+		APIKeyConfig{Key: "a", RateLimit: RateLimitConfig{RequestsPerMinute: 1}},
+		APIKeyConfig{Key: "b", RateLimit: RateLimitConfig{RequestsPerMinute: 1}},
+	)
+
+	first, _ := pool.acquire(nil)
+	second, _ := pool.acquire(nil)
+	if first == nil || second == nil || first == second {
+		t.Fatalf("acquire() = %v, %v, want each key once", first, second)
+	}
+
+	key, wait := pool.acquire(nil)
+	if key != nil {
+		t.Fatalf("acquire() = %s with both keys at their limits, want none", key.key)
+	}
+	if wait <= 0 || wait > keyWindow {
+		t.Errorf("acquire() wait = %v, want until the first request leaves the window", wait)
+	}
+}
+
+func TestKeyPoolTokenLimit(t *testing.T) {
+	pool := newTestKeyPool("", APIKeyConfig{Key: "a", RateLimit: RateLimitConfig{TokensPerMinute: 100}})
+
+	key, _ := pool.acquire(nil)
+	pool.release(key)
+	pool.charge(key, 60)
+	if key, _ := pool.acquire(nil); key == nil {
+		t.Fatal("acquire() = nil with tokens left, want the key")
+	}
+	pool.release(key)
+	pool.charge(key, 60)
+
+	if key, wait := pool.acquire(nil); key != nil || wait <= 0 {
+		t.Errorf("acquire() = %v, %v over the token limit, want no key and a wait", key, wait)
+	}
+}
+
+func TestKeyPoolCooldown(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		retryAfter string
+		want       time.Duration
+	}{
+		{"rate limited", http.StatusTooManyRequests, "", rateLimitCooldown},
+		{"rate limited with Retry-After", http.StatusTooManyRequests, "120", 120 * time.Second},
+		{"unauthorized", http.StatusUnauthorized, "", authCooldown},
+		{"forbidden", http.StatusForbidden, "", authCooldown},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pool := newTestKeyPool("", APIKeyConfig{Key: "a"}, APIKeyConfig{Key: "b"})
+			ks, server, client := newKeyServer(t, pool)
+			ks.statuses["a"] = tt.status
+			if tt.retryAfter != "" {
+				ks.header.Set("Retry-After", tt.retryAfter)
+			}
+
+			// The rejected request is sent again with the other key, body and all
+			if status, _ := post(t, client, server.URL, `{"model":"gpt-4o"}`); status != http.StatusOK {
+				t.Fatalf("status = %d, want 200 from the other key", status)
+			}
+			if got := strings.Join(ks.keys, ","); got != "a,b" {
+				t.Errorf("requests sent with keys %s, want a,b", got)
+			}
+			if ks.bodies[1] != `{"model":"gpt-4o"}` {
+				t.Errorf("body sent with the other key = %q, want the original body", ks.bodies[1])
+			}
+
+			rest := time.Until(pool.keys[0].cooldownUntil)
+			if rest <= tt.want-time.Minute/2 || rest > tt.want {
+				t.Errorf("key a rests for %v, want %v", rest, tt.want)
+			}
+
+			// The resting key is skipped
+			post(t, client, server.URL, `{}`)
+			if got := ks.keys[len(ks.keys)-1]; got != "b" {
+				t.Errorf("request sent with key %s while a rests, want b", got)
+			}
+			for _, key := range pool.keys {
+				if key.inFlight != 0 {
+					t.Errorf("key %s has %d requests in flight after all finished, want 0", key.key, key.inFlight)
+				}
+			}
+		})
+	}
+}
+
+func TestKeyPoolExhausted(t *testing.T) {
+	pool := newTestKeyPool("", APIKeyConfig{Key: "a"}, APIKeyConfig{Key: "b"})
+	ks, server, client := newKeyServer(t, pool)
+	ks.statuses["a"] = http.StatusTooManyRequests
+	ks.statuses["b"] = http.StatusTooManyRequests
+	ks.header.Set("Retry-After", "20")
+
+	// With no key left to try, the provider's own 429 is returned
+	if status, _ := post(t, client, server.URL, `{}`); status != http.StatusTooManyRequests {
+		t.Fatalf("status = %d, want the provider's 429", status)
+	}
+	if len(ks.keys) != 2 {
+		t.Fatalf("provider received %d requests, want one per key", len(ks.keys))
+	}
+
+	// Now every key rests, so the pool answers without calling the provider
+	status, retryAfter := post(t, client, server.URL, `{}`)
+	if status != http.StatusTooManyRequests {
+		t.Fatalf("status = %d, want 429", status)
+	}
+	if retryAfter != "20" {
+		t.Errorf("Retry-After = %q, want the time until the first key frees up", retryAfter)
+	}
+	if len(ks.keys) != 2 {
+		t.Errorf("provider received %d requests, want none while every key rests", len(ks.keys)-2)
+	}
+}
+
+func TestKeyPoolUnreplayableBody(t *testing.T) {
+	pool := newTestKeyPool("", APIKeyConfig{Key: "a"}, APIKeyConfig{Key: "b"})
+	ks, server, client := newKeyServer(t, pool)
+	ks.statuses["a"] = http.StatusUnauthorized
+
+	// A body without GetBody cannot be sent twice, so the rejection is returned as is
+	req, err := http.NewRequest(http.MethodPost, server.URL, io.NopCloser(strings.NewReader(`{}`)))
+	if err != nil {
+		t.Fatalf("NewRequest() error = %v", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusUnauthorized || len(ks.keys) != 1 {
+		t.Errorf("status = %d after %d requests, want the 401 of the only attempt", resp.StatusCode, len(ks.keys))
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  time.Duration
+	}{
+		{"empty", "", 0},
+		{"seconds", "30", 30 * time.Second},
+		{"zero seconds", "0", 0},
+		{"negative seconds", "-5", 0},
+		{"past date", "Mon, 02 Jan 2006 15:04:05 GMT", 0},
+		{"garbage", "soon", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseRetryAfter(tt.value); got != tt.want {
+				t.Errorf("parseRetryAfter(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+
+	future := time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)
+	if got := parseRetryAfter(future); got <= 59*time.Minute || got > time.Hour {
+		t.Errorf("parseRetryAfter(%q) = %v, want about an hour", future, got)
+	}
+}
+
+// usageStream is a chat stream ending with a usage chunk
+type usageStream struct {
+	chunks []*StreamResponse
+}
+
+func (s *usageStream) Recv() (*StreamResponse, error) {
+	if len(s.chunks) == 0 {
+		return nil, io.EOF
+	}
+	chunk := s.chunks[0]
+	s.chunks = s.chunks[1:]
+	return chunk, nil
+}
+
+func (s *usageStream) Close() error { return nil }
+
+func TestLeasedStreamChargesUsage(t *testing.T) {
+	pool := newTestKeyPool("", APIKeyConfig{Key: "a"})
+	key, _ := pool.acquire(nil)
+	ctx, lease := withKeyLease(context.Background())
+	lease.set(pool, key)
+
+	stream := &leasedStream{
+		StreamDecoder: &usageStream{chunks: []*StreamResponse{{}, {Usage: &Usage{TotalTokens: 42}}}},
+		lease:         leaseFrom(ctx),
+	}
+	for {
+		if _, err := stream.Recv(); err != nil {
+			break
+		}
+	}
+	if used := key.usedTokens(); used != 0 {
+		t.Fatalf("key charged %d tokens before the stream was closed, want 0", used)
+	}
+
+	stream.Close()
+	stream.Close()
+	if used := key.usedTokens(); used != 42 {
+		t.Errorf("key charged %d tokens, want the 42 of the usage chunk once", used)
+	}
+}
@@ -0,0 +1,26 @@
+package providers
+
+// MistralProvider implements the Provider interface for Mistral AI. Mistral's API follows
+// the OpenAI wire protocol, so requests, responses and streams reuse the OpenAI conversion.
+type MistralProvider struct {
+	*OpenAIProvider
+}
+
+// NewMistralProvider creates a new Mistral provider instance
+func NewMistralProvider(config Config) *MistralProvider {
+	if config.Name == "" {
+		config.Name = "mistral"
+	}
+	if config.BaseURL == "" {
+		config.BaseURL = "https://api.mistral.ai/v1"
+	}
+
+	// Mistral rejects stream_options but always reports usage on the final chunk
+	p := &MistralProvider{
+		OpenAIProvider: newOpenAIProvider(config.Name, config, false),
+	}
+
+	// Mistral limits embeddings requests by total tokens, so keep batches small
+	p.maxEmbeddingInputs = 128
+	return p
+}
@@ -0,0 +1,515 @@
+package providers
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// OllamaProvider implements the Provider interface for Ollama's native API.
+// Several instances can be registered under different names.
+type OllamaProvider struct {
+	config Config
+	client *http.Client
+}
+
+// NewOllamaProvider creates a new Ollama provider instance
+func NewOllamaProvider(config Config) *OllamaProvider {
+	if config.Name == "" {
+		config.Name = "ollama"
+	}
+	if config.BaseURL == "" {
+		config.BaseURL = "http://localhost:11434"
+	}
+	if config.Timeout == 0 {
+		// Local models can take a while to load on the first request
+		config.Timeout = 300
+	}
+
+	p := &OllamaProvider{config: config}
+	p.client = newHTTPClient(config, p.authorize)
+	return p
+}
+
+// Name returns the provider name
+func (p *OllamaProvider) Name() string {
+	return p.config.Name
+}
+
+// MaxRetries returns the retry limit configured for Ollama, and false when the router's
+// retry policy applies
+func (p *OllamaProvider) MaxRetries() (int, bool) {
+	return p.config.RetryLimit()
+}
+
+// SendRequest sends a request to Ollama
+func (p *OllamaProvider) SendRequest(ctx context.Context, req *Request) (*Response, error) {
+	if req.usesTools() {
+		return nil, newInvalidRequestError(p.Name(), "tools", "tool calling is not supported")
+	}
+
+	ollamaReq, err := p.convertToOllamaRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	ollamaReq.Stream = false
+
+	jsonData, err := json.Marshal(ollamaReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", p.config.BaseURL+"/api/chat", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	p.setHeaders(httpReq)
+
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		return nil, newTransportError(p.Name(), err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newHTTPError(p.Name(), resp)
+	}
+
+	var ollamaResp OllamaChatResponse
+	if err := json.NewDecoder(resp.Body).Decode(&ollamaResp); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return p.convertFromOllamaResponse(&ollamaResp, req.Model), nil
+}
+
+// SendStreamRequest sends a streaming request to Ollama
+func (p *OllamaProvider) SendStreamRequest(ctx context.Context, req *Request) (io.ReadCloser, error) {
+	if req.usesTools() {
+		return nil, newInvalidRequestError(p.Name(), "tools", "tool calling is not supported")
+	}
+
+	ollamaReq, err := p.convertToOllamaRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	ollamaReq.Stream = true
+
+	jsonData, err := json.Marshal(ollamaReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", p.config.BaseURL+"/api/chat", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	p.setHeaders(httpReq)
+
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		return nil, newTransportError(p.Name(), err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, newHTTPError(p.Name(), resp)
+	}
+
+	return resp.Body, nil
+}
+
+// NewStreamDecoder wraps an Ollama stream in a decoder that emits unified chunks
+func (p *OllamaProvider) NewStreamDecoder(body io.ReadCloser, req *Request) StreamDecoder {
+	// Ollama streams newline-delimited JSON objects rather than Server-Sent Events
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), maxStreamLineSize)
+
+	created := time.Now()
+	return &ollamaStreamDecoder{
+		provider: p.Name(),
+		body:     body,
+		scanner:  scanner,
+		id:       fmt.Sprintf("ollama-%d", created.UnixNano()),
+		model:    req.Model,
+		created:  created.Unix(),
+	}
+}
+
+// CreateEmbeddings creates embeddings for the request's inputs
+func (p *OllamaProvider) CreateEmbeddings(ctx context.Context, req *EmbeddingRequest) (*EmbeddingResponse, error) {
+	jsonData, err := json.Marshal(OllamaEmbedRequest{
+		Model:      req.Model,
+		Input:      req.Input,
+		Dimensions: req.Dimensions,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", p.config.BaseURL+"/api/embed", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	p.setHeaders(httpReq)
+
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		return nil, newTransportError(p.Name(), err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newHTTPError(p.Name(), resp)
+	}
+
+	var embedResp OllamaEmbedResponse
+	if err := json.NewDecoder(resp.Body).Decode(&embedResp); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	data := make([]Embedding, 0, len(embedResp.Embeddings))
+	for i, embedding := range embedResp.Embeddings {
+		data = append(data, Embedding{
+			Object:    "embedding",
+			Embedding: embedding,
+			Index:     i,
+		})
+	}
+
+	return &EmbeddingResponse{
+		Object: "list",
+		Data:   data,
+		Model:  req.Model,
+		Usage: Usage{
+			PromptTokens: embedResp.PromptEvalCount,
+			TotalTokens:  embedResp.PromptEvalCount,
+		},
+	}, nil
+}
+
+// GetModels returns the models pulled on the Ollama server, or the configured models if set
+func (p *OllamaProvider) GetModels(ctx context.Context) ([]ModelInfo, error) {
+	if len(p.config.Models) > 0 {
+		models := make([]ModelInfo, 0, len(p.config.Models))
+		for _, id := range p.config.Models {
+			models = append(models, ModelInfo{ID: id, Object: "model", OwnedBy: p.Name()})
+		}
+		return models, nil
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "GET", p.config.BaseURL+"/api/tags", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	p.setHeaders(httpReq)
+
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		return nil, newTransportError(p.Name(), err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newHTTPError(p.Name(), resp)
+	}
+
+	var tagsResp OllamaTagsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&tagsResp); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	var models []ModelInfo
+	for _, model := range tagsResp.Models {
+		models = append(models, ModelInfo{
+			ID:      model.Name,
+			Object:  "model",
+			OwnedBy: p.Name(),
+		})
+	}
+
+	return models, nil
+}
+
+// GetModelInfo returns detailed information about a specific model
+func (p *OllamaProvider) GetModelInfo(ctx context.Context, modelID string) (*ModelInfo, error) {
+	models, err := p.GetModels(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, model := range models {
+		if model.ID == modelID {
+			return &model, nil
+		}
+	}
+
+	return nil, fmt.Errorf("model not found: %s", modelID)
+}
+
+// ValidateModel checks if a model is available on the Ollama server
+func (p *OllamaProvider) ValidateModel(ctx context.Context, modelID string) error {
+	_, err := p.GetModelInfo(ctx, modelID)
+	return err
+}
+
+// setHeaders sets the required headers for Ollama API
+func (p *OllamaProvider) setHeaders(req *http.Request) {
+	req.Header.Set("Content-Type", "application/json")
+	p.authorize(req, p.config.APIKey)
+
+	for key, value := range p.config.Headers {
+		req.Header.Set(key, value)
+	}
+}
+
+// authorize sets the API key on req. Ollama has no authentication of its own, but is
+// often deployed behind a proxy that does.
+func (p *OllamaProvider) authorize(req *http.Request, key string) {
+	if key != "" {
+		req.Header.Set("Authorization", "Bearer "+key)
+	}
+}
+
+// convertToOllamaRequest converts our unified request to Ollama format. Ollama takes the
+// images of a message as a separate list of base64 payloads, so only data URLs are supported.
+func (p *OllamaProvider) convertToOllamaRequest(req *Request) (OllamaChatRequest, error) {
+	messages := make([]OllamaMessage, 0, len(req.Messages))
+	for i, msg := range req.Messages {
+		ollamaMsg := OllamaMessage{
+			Role:    msg.Role,
+			Content: msg.Content.Text(),
+		}
+		for j, part := range msg.Content {
+			switch part.Type {
+			case ContentPartText:
+				continue
+			case ContentPartImageURL:
+				if part.ImageURL != nil {
+					if _, data, ok := parseDataURL(part.ImageURL.URL); ok {
+						ollamaMsg.Images = append(ollamaMsg.Images, data)
+						continue
+					}
+				}
+			}
+			return OllamaChatRequest{}, newInvalidRequestError(p.Name(), fmt.Sprintf("messages[%d].content[%d]", i, j),
+				"only text and images given as data URLs are supported")
+		}
+		messages = append(messages, ollamaMsg)
+	}
+
+	return OllamaChatRequest{
+		Model:    req.Model,
+		Messages: messages,
+		Options: OllamaOptions{
+			NumPredict:  req.MaxTokens,
+			Temperature: req.Temperature,
+			TopP:        req.TopP,
+			Stop:        req.Stop,
+		},
+	}, nil
+}
+
+// convertFromOllamaResponse converts Ollama response to our unified format
+func (p *OllamaProvider) convertFromOllamaResponse(resp *OllamaChatResponse, model string) *Response {
+	if resp.Model != "" {
+		model = resp.Model
+	}
+
+	return &Response{
+		ID:      fmt.Sprintf("ollama-%d", time.Now().UnixNano()),
+		Object:  "chat.completion",
+		Created: time.Now().Unix(),
+		Model:   model,
+		Choices: []Choice{
+			{
+				Index: 0,
+				Message: Message{
+					Role:    "assistant",
+					Content: TextContent(resp.Message.Content),
+				},
+				FinishReason: convertOllamaDoneReason(resp.DoneReason),
+			},
+		},
+		Usage: resp.usage(),
+	}
+}
+
+// convertOllamaDoneReason maps Ollama done reasons to OpenAI finish reasons
+func convertOllamaDoneReason(reason string) string {
+	switch reason {
+	case "", "stop", "unload":
+		return "stop"
+	default:
+		return reason
+	}
+}
+
+// ollamaStreamDecoder translates Ollama stream objects into unified chunks
+type ollamaStreamDecoder struct {
+	provider string
+	body     io.ReadCloser
+	scanner  *bufio.Scanner
+	id       string
+	model    string
+	created  int64
+	usage    *Usage
+	roleSent bool
+	done     bool
+}
+
+// Recv returns the next chunk translated from the Ollama stream
+func (d *ollamaStreamDecoder) Recv() (*StreamResponse, error) {
+	for {
+		if d.done {
+			if d.usage == nil {
+				return nil, io.EOF
+			}
+			// Emit a trailing usage chunk, mirroring OpenAI's include_usage behaviour
+			chunk := d.chunk(StreamDelta{}, nil)
+			chunk.Choices = []StreamChoice{}
+			chunk.Usage = d.usage
+			d.usage = nil
+			return chunk, nil
+		}
+
+		if !d.scanner.Scan() {
+			if err := d.scanner.Err(); err != nil {
+				return nil, err
+			}
+			return nil, io.EOF
+		}
+
+		line := bytes.TrimSpace(d.scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var streamChunk OllamaChatResponse
+		if err := json.Unmarshal(line, &streamChunk); err != nil {
+			return nil, fmt.Errorf("failed to decode stream chunk: %w", err)
+		}
+		if streamChunk.Error != "" {
+			return nil, newStreamError(d.provider, &APIError{Message: streamChunk.Error})
+		}
+
+		if streamChunk.Model != "" {
+			d.model = streamChunk.Model
+		}
+
+		delta := StreamDelta{Content: streamChunk.Message.Content}
+		if !d.roleSent {
+			delta.Role = "assistant"
+			d.roleSent = true
+		}
+
+		if streamChunk.Done {
+			d.done = true
+			usage := streamChunk.usage()
+			d.usage = &usage
+			return d.chunk(delta, stringPtr(convertOllamaDoneReason(streamChunk.DoneReason))), nil
+		}
+
+		return d.chunk(delta, nil), nil
+	}
+}
+
+// chunk builds a unified stream chunk for the current response
+func (d *ollamaStreamDecoder) chunk(delta StreamDelta, finishReason *string) *StreamResponse {
+	return &StreamResponse{
+		ID:      d.id,
+		Object:  "chat.completion.chunk",
+		Created: d.created,
+		Model:   d.model,
+		Choices: []StreamChoice{
+			{
+				Index:        0,
+				Delta:        delta,
+				FinishReason: finishReason,
+			},
+		},
+	}
+}
+
+// Close closes the underlying stream
+func (d *ollamaStreamDecoder) Close() error {
+	return d.body.Close()
+}
+
+// OllamaChatRequest represents the chat request format for Ollama API
+type OllamaChatRequest struct {
+	Model    string          `json:"model"`
+	Messages []OllamaMessage `json:"messages"`
+	Stream   bool            `json:"stream"`
+	Options  OllamaOptions   `json:"options,omitempty"`
+}
+
+// OllamaMessage represents a message in Ollama format
+type OllamaMessage struct {
+	Role    string   `json:"role"`
+	Content string   `json:"content"`
+	Images  []string `json:"images,omitempty"`
+}
+
+// OllamaOptions represents model parameters in Ollama format
+type OllamaOptions struct {
+	NumPredict  int      `json:"num_predict,omitempty"`
+	Temperature float64  `json:"temperature,omitempty"`
+	TopP        float64  `json:"top_p,omitempty"`
+	Stop        []string `json:"stop,omitempty"`
+}
+
+// OllamaChatResponse represents a chat response, or a stream chunk, from Ollama API
+type OllamaChatResponse struct {
+	Model           string        `json:"model"`
+	Message         OllamaMessage `json:"message"`
+	Done            bool          `json:"done"`
+	DoneReason      string        `json:"done_reason,omitempty"`
+	PromptEvalCount int           `json:"prompt_eval_count,omitempty"`
+	EvalCount       int           `json:"eval_count,omitempty"`
+	Error           string        `json:"error,omitempty"`
+}
+
+// usage converts Ollama evaluation counts to our unified format
+func (r *OllamaChatResponse) usage() Usage {
+	return Usage{
+		PromptTokens:     r.PromptEvalCount,
+		CompletionTokens: r.EvalCount,
+		TotalTokens:      r.PromptEvalCount + r.EvalCount,
+	}
+}
+
+// OllamaEmbedRequest represents the embed request format for Ollama API
+type OllamaEmbedRequest struct {
+	Model      string   `json:"model"`
+	Input      []string `json:"input"`
+	Dimensions int      `json:"dimensions,omitempty"`
+}
+
+// OllamaEmbedResponse represents the embed response format from Ollama API
+type OllamaEmbedResponse struct {
+	Model           string      `json:"model"`
+	Embeddings      [][]float64 `json:"embeddings"`
+	PromptEvalCount int         `json:"prompt_eval_count"`
+}
+
+// OllamaTagsResponse represents the list of local models from Ollama
+type OllamaTagsResponse struct {
+	Models []OllamaModel `json:"models"`
+}
+
+// OllamaModel represents a local model in Ollama
+type OllamaModel struct {
+	Name  string `json:"name"`
+	Model string `json:"model"`
+	Size  int64  `json:"size"`
+}
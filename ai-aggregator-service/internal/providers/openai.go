@@ -7,13 +7,30 @@ import (
 	"fmt"
 	"io"
 	"net/http"
-	"time"
+	"strings"
 )
 
-// OpenAIProvider implements the Provider interface for OpenAI
+// OpenAIProvider implements the Provider interface for OpenAI and APIs that speak its wire protocol
 type OpenAIProvider struct {
+	name   string
 	config Config
 	client *http.Client
+
+	// streamUsage requests a trailing usage chunk through stream_options,
+	// which not every OpenAI-compatible API accepts
+	streamUsage bool
+
+	// endpoint returns the URL of an API path for a model
+	endpoint func(model, path string) string
+
+	// apiKeyHeader, when set, carries the API key instead of a bearer token
+	apiKeyHeader string
+
+	// maxEmbeddingInputs caps the number of inputs sent in one embeddings request
+	maxEmbeddingInputs int
+
+	// completionModels reports which models the API serves on /completions; nil means none
+	completionModels func(model string) bool
 }
 
 // NewOpenAIProvider creates a new OpenAI provider instance
@@ -21,24 +38,47 @@ func NewOpenAIProvider(config Config) *OpenAIProvider {
 	if config.BaseURL == "" {
 		config.BaseURL = "https://api.openai.com/v1"
 	}
+	if config.Name == "" {
+		config.Name = "openai"
+	}
+	p := newOpenAIProvider(config.Name, config, true)
+	p.completionModels = openAICompletionModel
+	return p
+}
+
+// openAICompletionModel reports whether an OpenAI model is served by the legacy completions API
+func openAICompletionModel(model string) bool {
+	return strings.HasPrefix(model, "gpt-3.5-turbo-instruct") || model == "davinci-002" || model == "babbage-002"
+}
+
+// newOpenAIProvider creates a provider for an API that speaks the OpenAI wire protocol
+func newOpenAIProvider(name string, config Config, streamUsage bool) *OpenAIProvider {
 	if config.Timeout == 0 {
 		config.Timeout = 30
 	}
-	if config.MaxRetries == 0 {
-		config.MaxRetries = 3
-	}
 
-	return &OpenAIProvider{
-		config: config,
-		client: &http.Client{
-			Timeout: time.Duration(config.Timeout) * time.Second,
-		},
+	p := &OpenAIProvider{
+		name:               name,
+		config:             config,
+		streamUsage:        streamUsage,
+		maxEmbeddingInputs: 2048,
 	}
+	p.client = newHTTPClient(config, p.authorize)
+	p.endpoint = func(model, path string) string {
+		return p.config.BaseURL + path
+	}
+	return p
 }
 
 // Name returns the provider name
 func (p *OpenAIProvider) Name() string {
-	return "openai"
+	return p.name
+}
+
+// MaxRetries returns the retry limit configured for the provider, and false when the router's
+// retry policy applies
+func (p *OpenAIProvider) MaxRetries() (int, bool) {
+	return p.config.RetryLimit()
 }
 
 // SendRequest sends a request to OpenAI
@@ -50,7 +90,7 @@ func (p *OpenAIProvider) SendRequest(ctx context.Context, req *Request) (*Respon
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	httpReq, err := http.NewRequestWithContext(ctx, "POST", p.config.BaseURL+"/chat/completions", bytes.NewBuffer(jsonData))
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", p.endpoint(req.Model, "/chat/completions"), bytes.NewBuffer(jsonData))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
@@ -59,13 +99,12 @@ func (p *OpenAIProvider) SendRequest(ctx context.Context, req *Request) (*Respon
 
 	resp, err := p.client.Do(httpReq)
 	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
+		return nil, newTransportError(p.Name(), err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body))
+		return nil, newHTTPError(p.Name(), resp)
 	}
 
 	var openaiResp OpenAIResponse
@@ -80,13 +119,16 @@ func (p *OpenAIProvider) SendRequest(ctx context.Context, req *Request) (*Respon
 func (p *OpenAIProvider) SendStreamRequest(ctx context.Context, req *Request) (io.ReadCloser, error) {
 	openaiReq := p.convertToOpenAIRequest(req)
 	openaiReq.Stream = true
+	if p.streamUsage {
+		openaiReq.StreamOptions = &OpenAIStreamOptions{IncludeUsage: true}
+	}
 
 	jsonData, err := json.Marshal(openaiReq)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	httpReq, err := http.NewRequestWithContext(ctx, "POST", p.config.BaseURL+"/chat/completions", bytes.NewBuffer(jsonData))
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", p.endpoint(req.Model, "/chat/completions"), bytes.NewBuffer(jsonData))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
@@ -95,18 +137,135 @@ func (p *OpenAIProvider) SendStreamRequest(ctx context.Context, req *Request) (i
 
 	resp, err := p.client.Do(httpReq)
 	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
+		return nil, newTransportError(p.Name(), err)
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		resp.Body.Close()
-		return nil, fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body))
+		defer resp.Body.Close()
+		return nil, newHTTPError(p.Name(), resp)
 	}
 
 	return resp.Body, nil
 }
 
+// NewStreamDecoder wraps an OpenAI stream, which is already in the unified chunk format
+func (p *OpenAIProvider) NewStreamDecoder(body io.ReadCloser, req *Request) StreamDecoder {
+	return &openAIStreamDecoder{
+		provider: p.Name(),
+		body:     body,
+		reader:   newSSEReader(body),
+	}
+}
+
+// SupportsCompletions reports whether the model is served by the legacy completions API
+func (p *OpenAIProvider) SupportsCompletions(model string) bool {
+	return p.completionModels != nil && p.completionModels(model)
+}
+
+// CreateCompletion sends a legacy text completion request
+func (p *OpenAIProvider) CreateCompletion(ctx context.Context, req *CompletionRequest) (*CompletionResponse, error) {
+	resp, err := p.sendCompletionRequest(ctx, OpenAICompletionRequest{CompletionRequest: *req, Stream: false})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var completionResp CompletionResponse
+	if err := json.NewDecoder(resp.Body).Decode(&completionResp); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return &completionResp, nil
+}
+
+// CreateCompletionStream sends a streaming legacy text completion request
+func (p *OpenAIProvider) CreateCompletionStream(ctx context.Context, req *CompletionRequest) (CompletionStream, error) {
+	completionReq := OpenAICompletionRequest{CompletionRequest: *req, Stream: true}
+	if p.streamUsage {
+		completionReq.StreamOptions = &OpenAIStreamOptions{IncludeUsage: true}
+	}
+
+	resp, err := p.sendCompletionRequest(ctx, completionReq)
+	if err != nil {
+		return nil, err
+	}
+
+	return &completionStreamDecoder{
+		provider: p.Name(),
+		body:     resp.Body,
+		reader:   newSSEReader(resp.Body),
+	}, nil
+}
+
+// sendCompletionRequest posts a request to /completions and returns the successful response
+func (p *OpenAIProvider) sendCompletionRequest(ctx context.Context, req OpenAICompletionRequest) (*http.Response, error) {
+	jsonData, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", p.endpoint(req.Model, "/completions"), bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	p.setHeaders(httpReq)
+
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		return nil, newTransportError(p.Name(), err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, newHTTPError(p.Name(), resp)
+	}
+
+	return resp, nil
+}
+
+// MaxEmbeddingInputs returns how many inputs the provider accepts in one embeddings request
+func (p *OpenAIProvider) MaxEmbeddingInputs() int {
+	return p.maxEmbeddingInputs
+}
+
+// CreateEmbeddings creates embeddings for the request's inputs
+func (p *OpenAIProvider) CreateEmbeddings(ctx context.Context, req *EmbeddingRequest) (*EmbeddingResponse, error) {
+	jsonData, err := json.Marshal(OpenAIEmbeddingRequest{
+		Model:          req.Model,
+		Input:          req.Input,
+		Dimensions:     req.Dimensions,
+		EncodingFormat: "float",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", p.endpoint(req.Model, "/embeddings"), bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	p.setHeaders(httpReq)
+
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		return nil, newTransportError(p.Name(), err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newHTTPError(p.Name(), resp)
+	}
+
+	var embeddingResp EmbeddingResponse
+	if err := json.NewDecoder(resp.Body).Decode(&embeddingResp); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return &embeddingResp, nil
+}
+
 // GetModels returns the list of available OpenAI models
 func (p *OpenAIProvider) GetModels(ctx context.Context) ([]ModelInfo, error) {
 	httpReq, err := http.NewRequestWithContext(ctx, "GET", p.config.BaseURL+"/models", nil)
@@ -118,13 +277,12 @@ func (p *OpenAIProvider) GetModels(ctx context.Context) ([]ModelInfo, error) {
 
 	resp, err := p.client.Do(httpReq)
 	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
+		return nil, newTransportError(p.Name(), err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body))
+		return nil, newHTTPError(p.Name(), resp)
 	}
 
 	var modelsResp OpenAIModelsResponse
@@ -155,7 +313,7 @@ func (p *OpenAIProvider) GetModelInfo(ctx context.Context, modelID string) (*Mod
 
 	resp, err := p.client.Do(httpReq)
 	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
+		return nil, newTransportError(p.Name(), err)
 	}
 	defer resp.Body.Close()
 
@@ -164,8 +322,7 @@ func (p *OpenAIProvider) GetModelInfo(ctx context.Context, modelID string) (*Mod
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body))
+		return nil, newHTTPError(p.Name(), resp)
 	}
 
 	var model OpenAIModel
@@ -186,44 +343,28 @@ func (p *OpenAIProvider) ValidateModel(ctx context.Context, modelID string) erro
 	return err
 }
 
-// GetPricing returns the pricing information for an OpenAI model
-func (p *OpenAIProvider) GetPricing(ctx context.Context, modelID string) (*Pricing, error) {
-	// OpenAI pricing data (as of 2024)
-	pricingMap := map[string]Pricing{
-		"gpt-4":             {InputCost: 0.03, OutputCost: 0.06, Currency: "usd"},
-		"gpt-4-turbo":       {InputCost: 0.01, OutputCost: 0.03, Currency: "usd"},
-		"gpt-3.5-turbo":     {InputCost: 0.0005, OutputCost: 0.0015, Currency: "usd"},
-		"gpt-3.5-turbo-16k": {InputCost: 0.003, OutputCost: 0.004, Currency: "usd"},
-		"text-davinci-003":  {InputCost: 0.02, OutputCost: 0.02, Currency: "usd"},
-		"text-curie-001":    {InputCost: 0.002, OutputCost: 0.002, Currency: "usd"},
-		"text-babbage-001":  {InputCost: 0.0005, OutputCost: 0.0005, Currency: "usd"},
-		"text-ada-001":      {InputCost: 0.0004, OutputCost: 0.0004, Currency: "usd"},
-		"dall-e-3":          {InputCost: 0.04, OutputCost: 0.08, Currency: "usd"},
-		"whisper-1":         {InputCost: 0.006, OutputCost: 0.006, Currency: "usd"},
-	}
-
-	if pricing, exists := pricingMap[modelID]; exists {
-		return &pricing, nil
-	}
-
-	// Default pricing for unknown models
-	return &Pricing{
-		InputCost:  0.01,
-		OutputCost: 0.03,
-		Currency:   "usd",
-	}, nil
-}
-
 // setHeaders sets the required headers for OpenAI API
 func (p *OpenAIProvider) setHeaders(req *http.Request) {
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+p.config.APIKey)
+	p.authorize(req, p.config.APIKey)
 
 	for key, value := range p.config.Headers {
 		req.Header.Set(key, value)
 	}
 }
 
+// authorize sets the API key on req. Self-hosted OpenAI-compatible servers are often
+// run without authentication, so no header is set without a key.
+func (p *OpenAIProvider) authorize(req *http.Request, key string) {
+	switch {
+	case key == "":
+	case p.apiKeyHeader != "":
+		req.Header.Set(p.apiKeyHeader, key)
+	default:
+		req.Header.Set("Authorization", "Bearer "+key)
+	}
+}
+
 // convertToOpenAIRequest converts our unified request to OpenAI format
 func (p *OpenAIProvider) convertToOpenAIRequest(req *Request) OpenAIRequest {
 	return OpenAIRequest{
@@ -234,6 +375,8 @@ func (p *OpenAIProvider) convertToOpenAIRequest(req *Request) OpenAIRequest {
 		TopP:        req.TopP,
 		Stream:      req.Stream,
 		Stop:        req.Stop,
+		Tools:       req.Tools,
+		ToolChoice:  req.ToolChoice,
 	}
 }
 
@@ -242,8 +385,10 @@ func (p *OpenAIProvider) convertMessages(messages []Message) []OpenAIMessage {
 	var openaiMessages []OpenAIMessage
 	for _, msg := range messages {
 		openaiMessages = append(openaiMessages, OpenAIMessage{
-			Role:    msg.Role,
-			Content: msg.Content,
+			Role:       msg.Role,
+			Content:    msg.Content,
+			ToolCalls:  msg.ToolCalls,
+			ToolCallID: msg.ToolCallID,
 		})
 	}
 	return openaiMessages
@@ -253,10 +398,19 @@ func (p *OpenAIProvider) convertMessages(messages []Message) []OpenAIMessage {
 func (p *OpenAIProvider) convertFromOpenAIResponse(resp *OpenAIResponse) *Response {
 	var choices []Choice
 	for _, choice := range resp.Choices {
+		finishReason := choice.FinishReason
+		if contentFiltered(choice.ContentFilterResults) {
+			finishReason = "content_filter"
+		}
+
 		choices = append(choices, Choice{
-			Index:        choice.Index,
-			Message:      Message{Role: choice.Message.Role, Content: choice.Message.Content},
-			FinishReason: choice.FinishReason,
+			Index: choice.Index,
+			Message: Message{
+				Role:      choice.Message.Role,
+				Content:   choice.Message.Content,
+				ToolCalls: choice.Message.ToolCalls,
+			},
+			FinishReason: finishReason,
 		})
 	}
 
@@ -267,28 +421,100 @@ func (p *OpenAIProvider) convertFromOpenAIResponse(resp *OpenAIResponse) *Respon
 		Model:   resp.Model,
 		Choices: choices,
 		Usage: Usage{
-			PromptTokens:     resp.Usage.PromptTokens,
-			CompletionTokens: resp.Usage.CompletionTokens,
-			TotalTokens:      resp.Usage.TotalTokens,
+			PromptTokens:        resp.Usage.PromptTokens,
+			CompletionTokens:    resp.Usage.CompletionTokens,
+			TotalTokens:         resp.Usage.TotalTokens,
+			PromptTokensDetails: resp.Usage.PromptTokensDetails,
 		},
 	}
 }
 
+// openAIStreamDecoder decodes OpenAI Server-Sent Events chunks
+type openAIStreamDecoder struct {
+	provider string
+	body     io.ReadCloser
+	reader   *sseReader
+}
+
+// Recv returns the next chunk from the OpenAI stream
+func (d *openAIStreamDecoder) Recv() (*StreamResponse, error) {
+	for {
+		event, err := d.reader.next()
+		if err != nil {
+			return nil, err
+		}
+
+		if event.Data == "[DONE]" {
+			return nil, io.EOF
+		}
+		if event.Data == "" {
+			continue
+		}
+
+		var chunk OpenAIStreamChunk
+		if err := json.Unmarshal([]byte(event.Data), &chunk); err != nil {
+			return nil, fmt.Errorf("failed to decode stream chunk: %w", err)
+		}
+		if chunk.Error != nil {
+			return nil, newStreamError(d.provider, chunk.Error)
+		}
+
+		resp := chunk.StreamResponse
+		resp.Choices = make([]StreamChoice, 0, len(chunk.Choices))
+		for _, choice := range chunk.Choices {
+			if contentFiltered(choice.ContentFilterResults) {
+				choice.FinishReason = stringPtr("content_filter")
+			}
+			resp.Choices = append(resp.Choices, choice.StreamChoice)
+		}
+
+		return &resp, nil
+	}
+}
+
+// Close closes the underlying stream
+func (d *openAIStreamDecoder) Close() error {
+	return d.body.Close()
+}
+
 // OpenAIRequest represents the request format for OpenAI API
 type OpenAIRequest struct {
-	Model       string          `json:"model"`
-	Messages    []OpenAIMessage `json:"messages"`
-	MaxTokens   int             `json:"max_tokens,omitempty"`
-	Temperature float64         `json:"temperature,omitempty"`
-	TopP        float64         `json:"top_p,omitempty"`
-	Stream      bool            `json:"stream,omitempty"`
-	Stop        []string        `json:"stop,omitempty"`
+	Model         string               `json:"model"`
+	Messages      []OpenAIMessage      `json:"messages"`
+	MaxTokens     int                  `json:"max_tokens,omitempty"`
+	Temperature   float64              `json:"temperature,omitempty"`
+	TopP          float64              `json:"top_p,omitempty"`
+	Stream        bool                 `json:"stream,omitempty"`
+	StreamOptions *OpenAIStreamOptions `json:"stream_options,omitempty"`
+	Stop          []string             `json:"stop,omitempty"`
+	Tools         []Tool               `json:"tools,omitempty"`
+	ToolChoice    *ToolChoice          `json:"tool_choice,omitempty"`
+}
+
+// OpenAIStreamOptions represents streaming options in OpenAI format
+type OpenAIStreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+// OpenAIStreamChunk represents a streaming chunk from OpenAI API
+type OpenAIStreamChunk struct {
+	StreamResponse
+	Choices []OpenAIStreamChoice `json:"choices"`
+	Error   *APIError            `json:"error,omitempty"`
+}
+
+// OpenAIStreamChoice represents a streaming choice in OpenAI format
+type OpenAIStreamChoice struct {
+	StreamChoice
+	ContentFilterResults map[string]OpenAIContentFilterResult `json:"content_filter_results,omitempty"`
 }
 
 // OpenAIMessage represents a message in OpenAI format
 type OpenAIMessage struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
+	Role       string     `json:"role"`
+	Content    Content    `json:"content"`
+	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string     `json:"tool_call_id,omitempty"`
 }
 
 // OpenAIResponse represents the response format from OpenAI API
@@ -303,16 +529,56 @@ type OpenAIResponse struct {
 
 // OpenAIChoice represents a choice in OpenAI response
 type OpenAIChoice struct {
-	Index        int           `json:"index"`
-	Message      OpenAIMessage `json:"message"`
-	FinishReason string        `json:"finish_reason"`
+	Index                int                                  `json:"index"`
+	Message              OpenAIMessage                        `json:"message"`
+	FinishReason         string                               `json:"finish_reason"`
+	ContentFilterResults map[string]OpenAIContentFilterResult `json:"content_filter_results,omitempty"`
+}
+
+// OpenAIContentFilterResult represents the result of one content filter category,
+// as reported by Azure OpenAI
+type OpenAIContentFilterResult struct {
+	Filtered bool   `json:"filtered"`
+	Severity string `json:"severity,omitempty"`
+}
+
+// contentFiltered reports whether any content filter category blocked the output
+func contentFiltered(results map[string]OpenAIContentFilterResult) bool {
+	for _, result := range results {
+		if result.Filtered {
+			return true
+		}
+	}
+	return false
 }
 
 // OpenAIUsage represents usage information in OpenAI response
 type OpenAIUsage struct {
-	PromptTokens     int `json:"prompt_tokens"`
-	CompletionTokens int `json:"completion_tokens"`
-	TotalTokens      int `json:"total_tokens"`
+	PromptTokens        int                  `json:"prompt_tokens"`
+	CompletionTokens    int                  `json:"completion_tokens"`
+	TotalTokens         int                  `json:"total_tokens"`
+	PromptTokensDetails *PromptTokensDetails `json:"prompt_tokens_details,omitempty"`
+}
+
+// OpenAICompletionRequest represents the legacy completions request format for OpenAI API
+type OpenAICompletionRequest struct {
+	CompletionRequest
+	Stream        bool                 `json:"stream,omitempty"`
+	StreamOptions *OpenAIStreamOptions `json:"stream_options,omitempty"`
+}
+
+// OpenAICompletionChunk represents a legacy completions streaming chunk from OpenAI API
+type OpenAICompletionChunk struct {
+	CompletionResponse
+	Error *APIError `json:"error,omitempty"`
+}
+
+// OpenAIEmbeddingRequest represents the embeddings request format for OpenAI API
+type OpenAIEmbeddingRequest struct {
+	Model          string   `json:"model"`
+	Input          []string `json:"input"`
+	Dimensions     int      `json:"dimensions,omitempty"`
+	EncodingFormat string   `json:"encoding_format,omitempty"`
 }
 
 // OpenAIModelsResponse represents the models response from OpenAI
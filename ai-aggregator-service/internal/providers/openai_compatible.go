@@ -0,0 +1,74 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+)
+
+// OpenAICompatibleProvider implements the Provider interface for self-hosted inference servers
+// that speak the OpenAI wire protocol, such as vLLM, the llama.cpp server and Ollama's /v1 API.
+// Several instances can be registered under different names.
+type OpenAICompatibleProvider struct {
+	*OpenAIProvider
+}
+
+// NewOpenAICompatibleProvider creates a new OpenAI-compatible provider instance named config.Name
+func NewOpenAICompatibleProvider(config Config) (*OpenAICompatibleProvider, error) {
+	if config.Name == "" {
+		return nil, fmt.Errorf("openai_compatible provider requires a name")
+	}
+	if config.BaseURL == "" {
+		return nil, fmt.Errorf("openai_compatible provider %s requires a base URL", config.Name)
+	}
+
+	p := &OpenAICompatibleProvider{
+		OpenAIProvider: newOpenAIProvider(config.Name, config, true),
+	}
+
+	// Self-hosted servers serve every model on /completions as well as /chat/completions
+	p.completionModels = func(string) bool { return true }
+	return p, nil
+}
+
+// GetModels returns the configured models, or the models discovered from the server's /models endpoint
+func (p *OpenAICompatibleProvider) GetModels(ctx context.Context) ([]ModelInfo, error) {
+	if len(p.config.Models) == 0 {
+		return p.OpenAIProvider.GetModels(ctx)
+	}
+
+	models := make([]ModelInfo, 0, len(p.config.Models))
+	for _, id := range p.config.Models {
+		models = append(models, ModelInfo{
+			ID:      id,
+			Object:  "model",
+			OwnedBy: p.Name(),
+		})
+	}
+	return models, nil
+}
+
+// GetModelInfo returns detailed information about a specific model
+func (p *OpenAICompatibleProvider) GetModelInfo(ctx context.Context, modelID string) (*ModelInfo, error) {
+	if len(p.config.Models) == 0 {
+		return p.OpenAIProvider.GetModelInfo(ctx, modelID)
+	}
+
+	models, err := p.GetModels(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, model := range models {
+		if model.ID == modelID {
+			return &model, nil
+		}
+	}
+
+	return nil, fmt.Errorf("model not found: %s", modelID)
+}
+
+// ValidateModel checks if a model is served by the provider
+func (p *OpenAICompatibleProvider) ValidateModel(ctx context.Context, modelID string) error {
+	_, err := p.GetModelInfo(ctx, modelID)
+	return err
+}
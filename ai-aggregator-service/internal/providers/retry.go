@@ -0,0 +1,97 @@
+package providers
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+// RetryPolicy controls how the router retries failed provider calls
+type RetryPolicy struct {
+	MaxRetries     int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+// DefaultRetryPolicy returns the retry policy used when none is configured
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxRetries:     3,
+		InitialBackoff: 500 * time.Millisecond,
+		MaxBackoff:     10 * time.Second,
+	}
+}
+
+// backoff returns how long to wait before retry number retry (starting at 1).
+// A Retry-After from the provider takes precedence over the exponential schedule.
+// The second return value is false when the provider asked for a longer wait than
+// MaxBackoff, in which case the caller should move on instead of waiting.
+func (p RetryPolicy) backoff(retry int, err error) (time.Duration, bool) {
+	if wait := retryAfter(err); wait > 0 {
+		return wait, wait <= p.MaxBackoff
+	}
+
+	ceiling := p.InitialBackoff << (retry - 1)
+	if ceiling <= 0 || ceiling > p.MaxBackoff {
+		ceiling = p.MaxBackoff
+	}
+
+	// Full jitter spreads out retries from concurrent requests
+	return time.Duration(rand.Int63n(int64(ceiling) + 1)), true
+}
+
+// retryLimiter is implemented by providers that can configure their own retry limit.
+// MaxRetries reports false when the provider leaves it to the router's policy.
+type retryLimiter interface {
+	MaxRetries() (int, bool)
+}
+
+// Attempt describes a single call made to a provider while serving a request
+type Attempt struct {
+	Provider   string
+	Model      string
+	BYOK       bool // served with the organization's own provider credentials
+	RetryCount int
+	StatusCode int
+	Latency    time.Duration
+	Usage      *Usage
+	Err        error
+}
+
+// AttemptObserver is called after every provider call made by the router
+type AttemptObserver func(Attempt)
+
+type attemptObserverKey struct{}
+
+// WithAttemptObserver returns a context that reports router attempts to observer, after
+// any observer already attached to ctx
+func WithAttemptObserver(ctx context.Context, observer AttemptObserver) context.Context {
+	if previous, ok := ctx.Value(attemptObserverKey{}).(AttemptObserver); ok {
+		next := observer
+		observer = func(attempt Attempt) {
+			previous(attempt)
+			next(attempt)
+		}
+	}
+	return context.WithValue(ctx, attemptObserverKey{}, observer)
+}
+
+// observeAttempt reports an attempt to the observer attached to ctx, if any
+func observeAttempt(ctx context.Context, attempt Attempt) {
+	if observer, ok := ctx.Value(attemptObserverKey{}).(AttemptObserver); ok {
+		observer(attempt)
+	}
+}
+
+// sleep waits for d or until ctx is done
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,45 @@
+package providers
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	policy := RetryPolicy{MaxRetries: 3, InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+
+	tests := []struct {
+		name   string
+		retry  int
+		err    error
+		want   time.Duration // upper bound of the jittered wait, or the exact Retry-After
+		jitter bool
+		wantOK bool
+	}{
+		{"first retry", 1, errors.New("failed"), 100 * time.Millisecond, true, true},
+		{"doubles per retry", 3, errors.New("failed"), 400 * time.Millisecond, true, true},
+		{"capped at MaxBackoff", 5, errors.New("failed"), time.Second, true, true},
+		{"capped after the shift overflows", 80, errors.New("failed"), time.Second, true, true},
+		{"Retry-After takes precedence", 1, &Error{Kind: ErrorKindRateLimited, RetryAfter: 700 * time.Millisecond}, 700 * time.Millisecond, false, true},
+		{"Retry-After of MaxBackoff", 1, &Error{Kind: ErrorKindRateLimited, RetryAfter: time.Second}, time.Second, false, true},
+		{"Retry-After beyond MaxBackoff", 1, &Error{Kind: ErrorKindRateLimited, RetryAfter: time.Minute}, time.Minute, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i := 0; i < 100; i++ {
+				wait, ok := policy.backoff(tt.retry, tt.err)
+				if ok != tt.wantOK {
+					t.Fatalf("backoff(%d) ok = %v, want %v", tt.retry, ok, tt.wantOK)
+				}
+				if tt.jitter && (wait < 0 || wait > tt.want) {
+					t.Fatalf("backoff(%d) = %v, want between 0 and %v", tt.retry, wait, tt.want)
+				}
+				if !tt.jitter && wait != tt.want {
+					t.Fatalf("backoff(%d) = %v, want the Retry-After of %v", tt.retry, wait, tt.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,670 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrModelNotFound is returned when no registered provider serves a model
+	ErrModelNotFound = errors.New("model not found")
+
+	// ErrProviderNotFound is returned when a provider is not registered with the router
+	ErrProviderNotFound = errors.New("provider not found")
+)
+
+const (
+	// modelIndexTTL controls how long the model-to-provider index is trusted
+	modelIndexTTL = 10 * time.Minute
+
+	// modelIndexRetryInterval limits how often a lookup miss triggers a refresh
+	modelIndexRetryInterval = 30 * time.Second
+)
+
+// Router resolves model IDs to the provider that serves them and dispatches requests
+type Router struct {
+	mu        sync.RWMutex
+	providers map[string]Provider
+	order     []string
+	models    map[string]string   // model ID -> provider name
+	servers   map[string][]string // model ID -> names of all providers serving it, in registration order
+	indexedAt time.Time
+	fallbacks map[string][]string // model ID -> ordered fallback model IDs
+	retry     RetryPolicy
+	aliases   AliasResolver
+	tenants   map[uuid.UUID]map[string]Provider // organization -> provider name -> the organization's own instance
+
+	routeStats RouteStats
+	routing    Routing // applied to requests without routing hints
+
+	refreshMu sync.Mutex
+}
+
+// candidate is a provider and model the router may try for a request
+type candidate struct {
+	provider Provider
+	model    string
+	byok     bool // served with the organization's own credentials
+}
+
+// NewRouter creates an empty router
+func NewRouter() *Router {
+	return &Router{
+		providers: make(map[string]Provider),
+		models:    make(map[string]string),
+		servers:   make(map[string][]string),
+		fallbacks: make(map[string][]string),
+		tenants:   make(map[uuid.UUID]map[string]Provider),
+		retry:     DefaultRetryPolicy(),
+	}
+}
+
+// SetFallbacks configures the fallback chains tried, in order, when a model's provider fails
+func (r *Router) SetFallbacks(fallbacks map[string][]string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.fallbacks = fallbacks
+}
+
+// AliasResolver maps model aliases to the concrete models they stand for
+type AliasResolver interface {
+	// ResolveAlias returns the model an alias stands for and the provider it is pinned to,
+	// if any; ok is false when model is not an alias
+	ResolveAlias(ctx context.Context, model string) (target, provider string, ok bool)
+}
+
+// SetAliases configures the resolver used to map model aliases to concrete models
+func (r *Router) SetAliases(aliases AliasResolver) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.aliases = aliases
+}
+
+// ResolveModel returns the concrete model a requested model stands for, which is the
+// model itself unless it is an alias
+func (r *Router) ResolveModel(ctx context.Context, model string) string {
+	model, _ = r.resolveAlias(ctx, model, "")
+	return model
+}
+
+// resolveAlias returns the concrete model and the provider to use for a requested model.
+// A provider forced by the caller takes precedence over the one an alias is pinned to.
+func (r *Router) resolveAlias(ctx context.Context, model, providerName string) (string, string) {
+	r.mu.RLock()
+	aliases := r.aliases
+	r.mu.RUnlock()
+	if aliases == nil {
+		return model, providerName
+	}
+
+	target, pinned, ok := aliases.ResolveAlias(ctx, model)
+	if !ok {
+		return model, providerName
+	}
+	if providerName == "" {
+		providerName = pinned
+	}
+	return target, providerName
+}
+
+// SetRetryPolicy configures how failed provider calls are retried
+func (r *Router) SetRetryPolicy(policy RetryPolicy) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.retry = policy
+}
+
+// Register adds a provider to the router, replacing any provider with the same name
+func (r *Router) Register(p Provider) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	name := p.Name()
+	if _, exists := r.providers[name]; !exists {
+		r.order = append(r.order, name)
+	}
+	r.providers[name] = p
+
+	// Force the next lookup to rebuild the index with the new provider's models
+	r.indexedAt = time.Time{}
+}
+
+// Unregister removes the provider with the given name and forgets the models it served
+func (r *Router) Unregister(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.providers[name]; !exists {
+		return
+	}
+	delete(r.providers, name)
+	r.order = slices.DeleteFunc(r.order, func(n string) bool { return n == name })
+
+	// The index may be read outside the lock by a refresh, so it is replaced rather than modified
+	models := make(map[string]string, len(r.models))
+	for model, provider := range r.models {
+		if provider != name {
+			models[model] = provider
+		}
+	}
+	r.models = models
+
+	servers := make(map[string][]string, len(r.servers))
+	for model, names := range r.servers {
+		if names = slices.DeleteFunc(slices.Clone(names), func(n string) bool { return n == name }); len(names) > 0 {
+			servers[model] = names
+		}
+	}
+	r.servers = servers
+
+	// Let the next lookup rebuild the index in case other providers serve the same models
+	r.indexedAt = time.Time{}
+}
+
+// Provider returns the registered provider with the given name
+func (r *Router) Provider(name string) (Provider, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	p, exists := r.providers[name]
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", ErrProviderNotFound, name)
+	}
+	return p, nil
+}
+
+// Providers returns all registered providers in registration order
+func (r *Router) Providers() []Provider {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	providers := make([]Provider, 0, len(r.order))
+	for _, name := range r.order {
+		providers = append(providers, r.providers[name])
+	}
+	return providers
+}
+
+// Resolve returns the provider that should serve the model. A non-empty
+// providerName forces that backend regardless of the model index. The organization
+// attached to ctx is served by its own instance of the backend when it has one.
+func (r *Router) Resolve(ctx context.Context, model, providerName string) (Provider, error) {
+	c, err := r.resolve(ctx, model, providerName)
+	if err != nil {
+		return nil, err
+	}
+	return c.provider, nil
+}
+
+// resolve returns the candidate that should serve the model
+func (r *Router) resolve(ctx context.Context, model, providerName string) (candidate, error) {
+	forced := providerName != ""
+	if !forced {
+		name, ok := r.lookup(ctx, model)
+		if !ok {
+			return candidate{}, fmt.Errorf("%w: %s", ErrModelNotFound, model)
+		}
+		providerName = name
+	}
+
+	p, byok, err := r.providerFor(ctx, providerName)
+	if err != nil {
+		// The model may only be served with other organizations' own credentials
+		if !forced && errors.Is(err, ErrProviderNotFound) {
+			return candidate{}, fmt.Errorf("%w: %s", ErrModelNotFound, model)
+		}
+		return candidate{}, err
+	}
+	return candidate{provider: p, model: model, byok: byok}, nil
+}
+
+// SendRequest resolves the provider for the request's model and sends the request to it,
+// retrying and falling back along the model's fallback chain on retryable failures.
+// Routing hints in the request metadata override the default routing.
+func (r *Router) SendRequest(ctx context.Context, req *Request, providerName string) (*Response, error) {
+	routing, err := RoutingFromMetadata(req.Metadata, r.DefaultRouting())
+	if err != nil {
+		return nil, err
+	}
+	chain, err := r.candidates(ctx, req.Model, providerName, routing)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp *Response
+	err = r.execute(ctx, chain, func(ctx context.Context, c candidate) (*Usage, error) {
+		var err error
+		resp, err = c.provider.SendRequest(ctx, req.withModel(c.model))
+		if err != nil {
+			return nil, err
+		}
+		if resp.Model == "" {
+			resp.Model = c.model
+		}
+		return &resp.Usage, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// SendStreamRequest resolves the provider for the request's model and opens a decoded stream.
+// Retries and fallbacks only apply while establishing the stream.
+func (r *Router) SendStreamRequest(ctx context.Context, req *Request, providerName string) (StreamDecoder, error) {
+	routing, err := RoutingFromMetadata(req.Metadata, r.DefaultRouting())
+	if err != nil {
+		return nil, err
+	}
+	chain, err := r.candidates(ctx, req.Model, providerName, routing)
+	if err != nil {
+		return nil, err
+	}
+
+	var stream StreamDecoder
+	err = r.execute(ctx, chain, func(ctx context.Context, c candidate) (*Usage, error) {
+		attemptReq := req.withModel(c.model)
+		body, err := c.provider.SendStreamRequest(ctx, attemptReq)
+		if err != nil {
+			return nil, err
+		}
+		stream = &leasedStream{StreamDecoder: c.provider.NewStreamDecoder(body, attemptReq), lease: leaseFrom(ctx)}
+		return nil, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return stream, nil
+}
+
+// CreateCompletion resolves the provider for the request's model and sends a legacy text
+// completion request, natively where the provider supports it and over chat otherwise
+func (r *Router) CreateCompletion(ctx context.Context, req *CompletionRequest, providerName string) (*CompletionResponse, error) {
+	chain, err := r.candidates(ctx, req.Model, providerName, r.DefaultRouting())
+	if err != nil {
+		return nil, err
+	}
+
+	var resp *CompletionResponse
+	err = r.execute(ctx, chain, func(ctx context.Context, c candidate) (*Usage, error) {
+		attemptReq := req.withModel(c.model)
+
+		var err error
+		if completer, ok := nativeCompleter(c.provider, c.model); ok {
+			resp, err = completer.CreateCompletion(ctx, attemptReq)
+		} else {
+			resp, err = emulateCompletion(ctx, c.provider, attemptReq)
+		}
+		if err != nil {
+			// An emulated completion that failed part way has still paid for its earlier calls
+			if resp != nil {
+				return resp.Usage, err
+			}
+			return nil, err
+		}
+		if resp.Model == "" {
+			resp.Model = c.model
+		}
+		return resp.Usage, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// CreateCompletionStream resolves the provider for the request's model and opens a text
+// completion stream. Retries and fallbacks only apply while establishing the stream.
+func (r *Router) CreateCompletionStream(ctx context.Context, req *CompletionRequest, providerName string) (CompletionStream, error) {
+	chain, err := r.candidates(ctx, req.Model, providerName, r.DefaultRouting())
+	if err != nil {
+		return nil, err
+	}
+
+	var stream CompletionStream
+	err = r.execute(ctx, chain, func(ctx context.Context, c candidate) (*Usage, error) {
+		attemptReq := req.withModel(c.model)
+
+		var err error
+		if completer, ok := nativeCompleter(c.provider, c.model); ok {
+			stream, err = completer.CreateCompletionStream(ctx, attemptReq)
+		} else {
+			stream, err = emulateCompletionStream(ctx, c.provider, attemptReq)
+		}
+		if err != nil {
+			return nil, err
+		}
+		stream = &leasedCompletionStream{CompletionStream: stream, lease: leaseFrom(ctx)}
+		return nil, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return stream, nil
+}
+
+// CreateEmbeddings resolves the provider for the request's model and embeds the input.
+// Inputs beyond the provider's per-request limit are sent in consecutive batches whose
+// results and usage are merged. Fallback models are never tried, since vectors from a
+// different model live in a different space.
+func (r *Router) CreateEmbeddings(ctx context.Context, req *EmbeddingRequest, providerName string) (*EmbeddingResponse, error) {
+	model, providerName := r.resolveAlias(ctx, req.Model, providerName)
+	req = req.withModel(model)
+
+	c, err := r.resolve(ctx, req.Model, providerName)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := permitted(ctx, req.Model, []candidate{c}); err != nil {
+		return nil, err
+	}
+	p := c.provider
+
+	embedder, ok := p.(Embedder)
+	if !ok {
+		return nil, newInvalidRequestError(p.Name(), "model", "embeddings are not supported")
+	}
+
+	batchSize := len(req.Input)
+	if batcher, ok := p.(embeddingBatcher); ok && batcher.MaxEmbeddingInputs() > 0 {
+		batchSize = batcher.MaxEmbeddingInputs()
+	}
+
+	result := &EmbeddingResponse{
+		Object: "list",
+		Data:   make([]Embedding, 0, len(req.Input)),
+		Model:  req.Model,
+	}
+	chain := []candidate{c}
+	for offset := 0; offset < len(req.Input); offset += batchSize {
+		batch := *req
+		batch.Input = req.Input[offset:min(offset+batchSize, len(req.Input))]
+
+		var resp *EmbeddingResponse
+		err := r.execute(ctx, chain, func(ctx context.Context, _ candidate) (*Usage, error) {
+			var err error
+			resp, err = embedder.CreateEmbeddings(ctx, &batch)
+			if err != nil {
+				return nil, err
+			}
+			return &resp.Usage, nil
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, embedding := range resp.Data {
+			embedding.Index += offset
+			result.Data = append(result.Data, embedding)
+		}
+		if resp.Model != "" {
+			result.Model = resp.Model
+		}
+		result.Usage.add(resp.Usage)
+	}
+
+	return result, nil
+}
+
+// execute calls send for each candidate in turn. Retryable failures are retried with
+// backoff up to the retry policy's limit, or the provider's own limit when one is
+// configured, before moving to the next candidate; any other failure is returned
+// immediately. Each attempt gets its own context, through which the tokens it used are
+// charged to the pooled API key that served it.
+func (r *Router) execute(ctx context.Context, chain []candidate, send func(context.Context, candidate) (*Usage, error)) error {
+	r.mu.RLock()
+	policy := r.retry
+	r.mu.RUnlock()
+
+	var lastErr error
+	for i, c := range chain {
+		maxRetries := policy.MaxRetries
+		if limiter, ok := c.provider.(retryLimiter); ok {
+			if limit, set := limiter.MaxRetries(); set {
+				maxRetries = limit
+			}
+		}
+
+		for retry := 0; ; retry++ {
+			start := time.Now()
+			attemptCtx, lease := withKeyLease(ctx)
+			usage, err := send(attemptCtx, c)
+			lease.charge(usage)
+
+			attempt := Attempt{
+				Provider:   c.provider.Name(),
+				Model:      c.model,
+				BYOK:       c.byok,
+				RetryCount: retry,
+				StatusCode: http.StatusOK,
+				Latency:    time.Since(start),
+				Usage:      usage,
+				Err:        err,
+			}
+			if err != nil {
+				attempt.StatusCode = statusCode(err)
+			}
+			observeAttempt(ctx, attempt)
+
+			if err == nil {
+				return nil
+			}
+			lastErr = err
+
+			if !IsRetryable(err) || ctx.Err() != nil {
+				return err
+			}
+			if retry >= maxRetries {
+				break
+			}
+
+			wait, ok := policy.backoff(retry+1, err)
+			if !ok {
+				break
+			}
+			if err := sleep(ctx, wait); err != nil {
+				return lastErr
+			}
+		}
+
+		if i < len(chain)-1 {
+			slog.Warn("Provider failed, falling back",
+				"provider", c.provider.Name(),
+				"model", c.model,
+				"fallback_provider", chain[i+1].provider.Name(),
+				"fallback_model", chain[i+1].model,
+				"error", lastErr,
+			)
+		}
+	}
+
+	return lastErr
+}
+
+// candidates returns the ordered provider/model pairs to try for a model, after resolving
+// aliases. A forced or pinned provider disables fallbacks so that the choice of backend is
+// honoured. With active routing, every eligible provider of the model is tried in the
+// order of the routing policy before the fallback models, which are routed the same way.
+func (r *Router) candidates(ctx context.Context, model, providerName string, routing Routing) ([]candidate, error) {
+	model, providerName = r.resolveAlias(ctx, model, providerName)
+
+	var chain []candidate
+	if providerName != "" || !routing.active() {
+		c, err := r.resolve(ctx, model, providerName)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, c)
+		if providerName != "" {
+			return permitted(ctx, model, chain)
+		}
+	} else {
+		routed, err := r.routed(ctx, model, routing)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, routed...)
+	}
+
+	r.mu.RLock()
+	fallbacks := r.fallbacks[model]
+	r.mu.RUnlock()
+
+	for _, fallback := range fallbacks {
+		if routing.active() {
+			routed, err := r.routed(ctx, fallback, routing)
+			if err != nil {
+				slog.Debug("Skipping fallback model", "model", model, "fallback", fallback, "error", err)
+				continue
+			}
+			chain = append(chain, routed...)
+			continue
+		}
+
+		fc, err := r.resolve(ctx, fallback, "")
+		if err != nil {
+			slog.Warn("Skipping unresolvable fallback model", "model", model, "fallback", fallback, "error", err)
+			continue
+		}
+		chain = append(chain, fc)
+	}
+
+	return permitted(ctx, model, chain)
+}
+
+// routed returns a candidate for each provider serving the model that meets the routing's
+// constraints, in the order of its policy
+func (r *Router) routed(ctx context.Context, model string, routing Routing) ([]candidate, error) {
+	names := r.lookupAll(ctx, model)
+	if len(names) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrModelNotFound, model)
+	}
+
+	ranked := r.route(ctx, model, names, routing)
+	if len(ranked) == 0 {
+		return nil, newInvalidRequestError("", "metadata", "no provider serves model %s with %s", model, routing.constraints())
+	}
+
+	chain := make([]candidate, 0, len(ranked))
+	for _, name := range ranked {
+		p, byok, err := r.providerFor(ctx, name)
+		if err != nil {
+			// Served only with other organizations' own credentials
+			continue
+		}
+		chain = append(chain, candidate{provider: p, model: model, byok: byok})
+	}
+	if len(chain) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrModelNotFound, model)
+	}
+	return chain, nil
+}
+
+// RefreshModels rebuilds the model-to-provider index from each provider's model list.
+// Models of providers that fail to list keep their previous mapping.
+func (r *Router) RefreshModels(ctx context.Context) {
+	r.refreshMu.Lock()
+	defer r.refreshMu.Unlock()
+
+	r.refreshModels(ctx)
+}
+
+// refreshModels rebuilds the index; callers must hold refreshMu
+func (r *Router) refreshModels(ctx context.Context) {
+	r.mu.RLock()
+	previous, previousServers := r.models, r.servers
+	r.mu.RUnlock()
+
+	index := make(map[string]string)
+	servers := make(map[string][]string)
+	failed := make(map[string]bool)
+	for _, p := range append(r.Providers(), r.tenantOnly()...) {
+		models, err := p.GetModels(ctx)
+		if err != nil {
+			slog.Warn("Failed to list provider models", "provider", p.Name(), "error", err)
+			failed[p.Name()] = true
+			continue
+		}
+		for _, model := range models {
+			// The first registered provider wins when several serve the same model
+			if _, exists := index[model.ID]; !exists {
+				index[model.ID] = p.Name()
+			}
+			if !slices.Contains(servers[model.ID], p.Name()) {
+				servers[model.ID] = append(servers[model.ID], p.Name())
+			}
+		}
+	}
+
+	for model, name := range previous {
+		if _, exists := index[model]; !exists && failed[name] {
+			index[model] = name
+		}
+	}
+	for model, names := range previousServers {
+		for _, name := range names {
+			if failed[name] && !slices.Contains(servers[model], name) {
+				servers[model] = append(servers[model], name)
+			}
+		}
+	}
+
+	r.mu.Lock()
+	r.models = index
+	r.servers = servers
+	r.indexedAt = time.Now()
+	r.mu.Unlock()
+}
+
+// lookup returns the name of the provider serving the model, refreshing the index when stale
+func (r *Router) lookup(ctx context.Context, model string) (string, bool) {
+	r.mu.RLock()
+	name, ok := r.models[model]
+	indexedAt := r.indexedAt
+	r.mu.RUnlock()
+
+	age := time.Since(indexedAt)
+	if ok && age < modelIndexTTL {
+		return name, true
+	}
+	if !ok && age < modelIndexRetryInterval {
+		return "", false
+	}
+
+	r.refreshMu.Lock()
+	r.mu.RLock()
+	current := r.indexedAt
+	r.mu.RUnlock()
+	// Another request may have rebuilt the index while we waited
+	if current.Equal(indexedAt) {
+		r.refreshModels(ctx)
+	}
+	r.refreshMu.Unlock()
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	name, ok = r.models[model]
+	return name, ok
+}
+
+// lookupAll returns the names of all providers serving the model, refreshing the index when stale
+func (r *Router) lookupAll(ctx context.Context, model string) []string {
+	if _, ok := r.lookup(ctx, model); !ok {
+		return nil
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.servers[model]
+}
@@ -0,0 +1,216 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path"
+	"slices"
+	"testing"
+	"time"
+)
+
+// stubProvider serves its models with responses from send, recording the models it was called with
+type stubProvider struct {
+	name   string
+	models []string
+	send   func(req *Request) (*Response, error)
+	calls  []string
+}
+
+func (p *stubProvider) Name() string { return p.name }
+
+func (p *stubProvider) SendRequest(ctx context.Context, req *Request) (*Response, error) {
+	p.calls = append(p.calls, req.Model)
+	if p.send == nil {
+		return &Response{Model: req.Model}, nil
+	}
+	return p.send(req)
+}
+
+func (p *stubProvider) SendStreamRequest(ctx context.Context, req *Request) (io.ReadCloser, error) {
+	return nil, errors.New("streaming is not supported")
+}
+
+func (p *stubProvider) NewStreamDecoder(body io.ReadCloser, req *Request) StreamDecoder {
+	return nil
+}
+
+func (p *stubProvider) GetModels(ctx context.Context) ([]ModelInfo, error) {
+	models := make([]ModelInfo, 0, len(p.models))
+	for _, model := range p.models {
+		models = append(models, ModelInfo{ID: model})
+	}
+	return models, nil
+}
+
+func (p *stubProvider) GetModelInfo(ctx context.Context, modelID string) (*ModelInfo, error) {
+	return &ModelInfo{ID: modelID}, nil
+}
+
+func (p *stubProvider) ValidateModel(ctx context.Context, modelID string) error {
+	return nil
+}
+
+// stubAliases resolves aliases from a map of alias to target model
+type stubAliases map[string]string
+
+func (a stubAliases) ResolveAlias(ctx context.Context, model string) (string, string, bool) {
+	target, ok := a[model]
+	return target, "", ok
+}
+
+func TestRouteFilterAppliesToResolvedAlias(t *testing.T) {
+	router := NewRouter()
+	router.Register(&stubProvider{name: "openai", models: []string{"gpt-4o"}})
+	router.SetAliases(stubAliases{"bharat-smart": "gpt-4o"})
+
+	tests := []struct {
+		name    string
+		pattern string
+		allowed bool
+	}{
+		{"pattern matching the target", "gpt-4o*", true},
+		{"pattern matching only the alias", "bharat-*", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := WithRouteFilter(context.Background(), func(provider, model string) bool {
+				ok, _ := path.Match(tt.pattern, model)
+				return ok
+			})
+
+			resp, err := router.SendRequest(ctx, &Request{Model: "bharat-smart"}, "")
+			if !tt.allowed {
+				var providerErr *Error
+				if !errors.As(err, &providerErr) || providerErr.Kind != ErrorKindPermission {
+					t.Fatalf("SendRequest() error = %v, want a permission error", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("SendRequest() error = %v", err)
+			}
+			if resp.Model != "gpt-4o" {
+				t.Errorf("model = %s, want the alias target gpt-4o", resp.Model)
+			}
+		})
+	}
+}
+
+// limitedProvider is a stubProvider with its own retry limit
+type limitedProvider struct {
+	stubProvider
+	limit int
+}
+
+func (p *limitedProvider) MaxRetries() (int, bool) {
+	return p.limit, true
+}
+
+// failWith returns a send function failing every request with err
+func failWith(err error) func(*Request) (*Response, error) {
+	return func(*Request) (*Response, error) { return nil, err }
+}
+
+func TestRouterFallbackChain(t *testing.T) {
+	unavailable := &Error{Kind: ErrorKindUpstreamUnavailable, Provider: "stub", StatusCode: 503, Message: "overloaded"}
+	invalid := &Error{Kind: ErrorKindInvalidRequest, Provider: "stub", StatusCode: 400, Message: "bad request"}
+	longWait := &Error{Kind: ErrorKindRateLimited, Provider: "stub", StatusCode: 429, Message: "slow down", RetryAfter: time.Hour}
+
+	tests := []struct {
+		name       string
+		primary    Provider
+		secondErr  error
+		thirdFails bool
+		forced     string
+		want       []string // provider of each attempt
+		wantErr    bool
+	}{
+		{
+			name:    "served by the primary",
+			primary: &stubProvider{name: "primary", models: []string{"m1"}},
+			want:    []string{"primary"},
+		},
+		{
+			name:      "retries then falls back in order",
+			primary:   &stubProvider{name: "primary", models: []string{"m1"}, send: failWith(unavailable)},
+			secondErr: unavailable,
+			want:      []string{"primary", "primary", "primary", "second", "second", "second", "third"},
+		},
+		{
+			name:    "provider's own retry limit",
+			primary: &limitedProvider{stubProvider: stubProvider{name: "primary", models: []string{"m1"}, send: failWith(unavailable)}, limit: 0},
+			want:    []string{"primary", "second"},
+		},
+		{
+			name:      "Retry-After beyond MaxBackoff moves on",
+			primary:   &stubProvider{name: "primary", models: []string{"m1"}, send: failWith(longWait)},
+			secondErr: longWait,
+			want:      []string{"primary", "second", "third"},
+		},
+		{
+			name:      "non-retryable failure of a fallback stops",
+			primary:   &stubProvider{name: "primary", models: []string{"m1"}, send: failWith(unavailable)},
+			secondErr: invalid,
+			want:      []string{"primary", "primary", "primary", "second"},
+			wantErr:   true,
+		},
+		{
+			name:    "non-retryable failure of the primary stops",
+			primary: &stubProvider{name: "primary", models: []string{"m1"}, send: failWith(invalid)},
+			want:    []string{"primary"},
+			wantErr: true,
+		},
+		{
+			name:       "chain exhausted",
+			primary:    &limitedProvider{stubProvider: stubProvider{name: "primary", models: []string{"m1"}, send: failWith(unavailable)}, limit: 0},
+			secondErr:  longWait,
+			thirdFails: true,
+			want:       []string{"primary", "second", "third", "third", "third"},
+			wantErr:    true,
+		},
+		{
+			name:    "forced provider disables fallbacks",
+			primary: &stubProvider{name: "primary", models: []string{"m1"}, send: failWith(unavailable)},
+			forced:  "primary",
+			want:    []string{"primary", "primary", "primary"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			second := &stubProvider{name: "second", models: []string{"m2"}}
+			if tt.secondErr != nil {
+				second.send = failWith(tt.secondErr)
+			}
+			third := &stubProvider{name: "third", models: []string{"m3"}}
+			if tt.thirdFails {
+				third.send = failWith(unavailable)
+			}
+
+			router := NewRouter()
+			router.Register(tt.primary)
+			router.Register(second)
+			router.Register(third)
+			router.SetRetryPolicy(RetryPolicy{MaxRetries: 2, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond})
+			// The unresolvable fallback is skipped
+			router.SetFallbacks(map[string][]string{"m1": {"missing", "m2", "m3"}})
+
+			var attempts []string
+			ctx := WithAttemptObserver(context.Background(), func(attempt Attempt) {
+				attempts = append(attempts, attempt.Provider)
+			})
+
+			_, err := router.SendRequest(ctx, &Request{Model: "m1"}, tt.forced)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SendRequest() error = %v, want error: %v", err, tt.wantErr)
+			}
+			if !slices.Equal(attempts, tt.want) {
+				t.Errorf("attempts = %v, want %v", attempts, tt.want)
+			}
+		})
+	}
+}
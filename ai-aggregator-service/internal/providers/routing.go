@@ -0,0 +1,269 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// RoutingPolicy decides which of the providers serving a model is tried first
+type RoutingPolicy string
+
+const (
+	// PolicyDefault tries the first registered provider serving the model
+	PolicyDefault RoutingPolicy = ""
+
+	// PolicyCheapest prefers the lowest combined input and output price
+	PolicyCheapest RoutingPolicy = "cheapest"
+
+	// PolicyFastest prefers the lowest median latency, then the lowest p95 latency
+	PolicyFastest RoutingPolicy = "fastest"
+
+	// PolicyReliable prefers the highest recent success rate
+	PolicyReliable RoutingPolicy = "reliable"
+)
+
+// Metadata keys clients pass routing hints under
+const (
+	MetadataRoutingPolicy        = "routing_policy"
+	MetadataMinContextWindow     = "min_context_window"
+	MetadataRequiredCapabilities = "required_capabilities"
+)
+
+// minRouteSamples is how many recent calls a route needs before its latency and success
+// rate are trusted; routes with fewer are ranked after the measured ones
+const minRouteSamples = 20
+
+// Routing holds how the router chooses among the providers serving a model. Hard
+// constraints drop the providers whose catalog entry does not meet them.
+type Routing struct {
+	Policy               RoutingPolicy
+	MinContextWindow     int
+	RequiredCapabilities []string
+}
+
+// constrained reports whether routing has hard constraints
+func (r Routing) constrained() bool {
+	return r.MinContextWindow > 0 || len(r.RequiredCapabilities) > 0
+}
+
+// constraints describes the hard constraints for error messages
+func (r Routing) constraints() string {
+	var parts []string
+	if r.MinContextWindow > 0 {
+		parts = append(parts, fmt.Sprintf("a context window of at least %d tokens", r.MinContextWindow))
+	}
+	if len(r.RequiredCapabilities) > 0 {
+		parts = append(parts, "capabilities "+strings.Join(r.RequiredCapabilities, ", "))
+	}
+	return strings.Join(parts, " and ")
+}
+
+// active reports whether routing chooses among providers at all
+func (r Routing) active() bool {
+	return r.Policy != PolicyDefault || r.constrained()
+}
+
+// ParseRoutingPolicy validates a routing policy name
+func ParseRoutingPolicy(value string) (RoutingPolicy, error) {
+	policy := RoutingPolicy(strings.ToLower(strings.TrimSpace(value)))
+	switch policy {
+	case PolicyDefault, PolicyCheapest, PolicyFastest, PolicyReliable:
+		return policy, nil
+	}
+	return "", fmt.Errorf("routing policy must be one of %s, %s, %s", PolicyCheapest, PolicyFastest, PolicyReliable)
+}
+
+// RoutingFromMetadata reads the routing hints a client passed in request metadata,
+// e.g. {"routing_policy": "cheapest", "min_context_window": 128000,
+// "required_capabilities": ["vision"]}. Hints that are not set keep the defaults.
+func RoutingFromMetadata(metadata map[string]interface{}, defaults Routing) (Routing, error) {
+	routing := defaults
+
+	if value, ok := metadata[MetadataRoutingPolicy]; ok {
+		name, ok := value.(string)
+		if !ok {
+			return routing, newInvalidRequestError("", "metadata."+MetadataRoutingPolicy, "%s must be a string", MetadataRoutingPolicy)
+		}
+		policy, err := ParseRoutingPolicy(name)
+		if err != nil {
+			return routing, newInvalidRequestError("", "metadata."+MetadataRoutingPolicy, "%s", err)
+		}
+		routing.Policy = policy
+	}
+
+	if value, ok := metadata[MetadataMinContextWindow]; ok {
+		window, ok := value.(float64)
+		if !ok || window < 0 || window != math.Trunc(window) {
+			return routing, newInvalidRequestError("", "metadata."+MetadataMinContextWindow, "%s must be a non-negative integer", MetadataMinContextWindow)
+		}
+		routing.MinContextWindow = int(window)
+	}
+
+	if value, ok := metadata[MetadataRequiredCapabilities]; ok {
+		list, ok := value.([]interface{})
+		if !ok {
+			return routing, newInvalidRequestError("", "metadata."+MetadataRequiredCapabilities, "%s must be an array of strings", MetadataRequiredCapabilities)
+		}
+		routing.RequiredCapabilities = nil
+		for _, item := range list {
+			capability, ok := item.(string)
+			if !ok || capability == "" {
+				return routing, newInvalidRequestError("", "metadata."+MetadataRequiredCapabilities, "%s must be an array of strings", MetadataRequiredCapabilities)
+			}
+			routing.RequiredCapabilities = append(routing.RequiredCapabilities, capability)
+		}
+	}
+
+	return routing, nil
+}
+
+// Route describes serving a model with one provider
+type Route struct {
+	// Cataloged is false when the provider's model has no active catalog entry, in
+	// which case nothing below except the statistics is known
+	Cataloged       bool
+	ContextWindow   int
+	Capabilities    []string
+	InputCostPer1K  *float64
+	OutputCostPer1K *float64
+
+	// Recent calls made to the route
+	Samples     int
+	LatencyP50  time.Duration
+	LatencyP95  time.Duration
+	SuccessRate float64
+}
+
+// RouteStats supplies what routing policies and constraints choose by
+type RouteStats interface {
+	// Routes returns what is known about serving model with each of the named providers;
+	// providers nothing is known about may be missing
+	Routes(ctx context.Context, model string, providers []string) map[string]Route
+}
+
+// SetRouting configures where route information comes from and the routing applied to
+// requests that carry no hints of their own
+func (r *Router) SetRouting(stats RouteStats, defaults Routing) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.routeStats = stats
+	r.routing = defaults
+}
+
+// DefaultRouting returns the routing applied to requests that carry no hints of their own
+func (r *Router) DefaultRouting() Routing {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.routing
+}
+
+// route orders the providers serving a model for the routing, dropping those that fail
+// its hard constraints. Without route information the order is left as it is, and
+// constraints cannot be checked, so every provider is dropped.
+func (r *Router) route(ctx context.Context, model string, names []string, routing Routing) []string {
+	r.mu.RLock()
+	stats := r.routeStats
+	r.mu.RUnlock()
+
+	var routes map[string]Route
+	if stats != nil {
+		routes = stats.Routes(ctx, model, names)
+	}
+
+	eligible := make([]string, 0, len(names))
+	for _, name := range names {
+		if routing.constrained() && !routes[name].satisfies(routing) {
+			continue
+		}
+		eligible = append(eligible, name)
+	}
+
+	var less func(a, b Route) bool
+	switch routing.Policy {
+	case PolicyCheapest:
+		less = cheaper
+	case PolicyFastest:
+		less = faster
+	case PolicyReliable:
+		less = moreReliable
+	default:
+		return eligible
+	}
+
+	// Ties keep the registration order
+	sort.SliceStable(eligible, func(i, j int) bool {
+		return less(routes[eligible[i]], routes[eligible[j]])
+	})
+	return eligible
+}
+
+// satisfies reports whether the route meets the routing's hard constraints
+func (route Route) satisfies(routing Routing) bool {
+	if !route.Cataloged {
+		return false
+	}
+	if routing.MinContextWindow > 0 && route.ContextWindow < routing.MinContextWindow {
+		return false
+	}
+	for _, capability := range routing.RequiredCapabilities {
+		if !slices.Contains(route.Capabilities, capability) {
+			return false
+		}
+	}
+	return true
+}
+
+// price returns the combined input and output price, and false for unpriced routes
+func (route Route) price() (float64, bool) {
+	if route.InputCostPer1K == nil || route.OutputCostPer1K == nil {
+		return 0, false
+	}
+	return *route.InputCostPer1K + *route.OutputCostPer1K, true
+}
+
+// measured reports whether the route has enough recent calls to judge it by
+func (route Route) measured() bool {
+	return route.Samples >= minRouteSamples
+}
+
+// cheaper orders priced routes by price, ahead of unpriced ones
+func cheaper(a, b Route) bool {
+	aPrice, aPriced := a.price()
+	bPrice, bPriced := b.price()
+	if aPriced != bPriced {
+		return aPriced
+	}
+	return aPrice < bPrice
+}
+
+// faster orders measured routes by median then p95 latency, ahead of unmeasured ones.
+// Routes whose recent calls all failed have no latency and are ordered last.
+func faster(a, b Route) bool {
+	aTimed, bTimed := a.measured() && a.SuccessRate > 0, b.measured() && b.SuccessRate > 0
+	if aTimed != bTimed {
+		return aTimed
+	}
+	if a.LatencyP50 != b.LatencyP50 {
+		return a.LatencyP50 < b.LatencyP50
+	}
+	return a.LatencyP95 < b.LatencyP95
+}
+
+// moreReliable orders measured routes by success rate, then as faster does, ahead of
+// unmeasured ones
+func moreReliable(a, b Route) bool {
+	if a.measured() != b.measured() {
+		return a.measured()
+	}
+	if a.SuccessRate != b.SuccessRate {
+		return a.SuccessRate > b.SuccessRate
+	}
+	return faster(a, b)
+}
@@ -0,0 +1,260 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+// stubRouteStats serves routes from a map of provider to route, whatever the model
+type stubRouteStats map[string]Route
+
+func (s stubRouteStats) Routes(ctx context.Context, model string, providers []string) map[string]Route {
+	return s
+}
+
+// priced returns a cataloged route with the combined price split over input and output
+func priced(price float64) Route {
+	input, output := price/2, price/2
+	return Route{Cataloged: true, InputCostPer1K: &input, OutputCostPer1K: &output}
+}
+
+// measuredRoute returns a route with enough recent calls to be judged by
+func measuredRoute(p50, p95 time.Duration, successRate float64) Route {
+	return Route{Cataloged: true, Samples: minRouteSamples, LatencyP50: p50, LatencyP95: p95, SuccessRate: successRate}
+}
+
+func TestRoute(t *testing.T) {
+	inputOnly := 0.1
+	names := []string{"a", "b", "c"}
+
+	tests := []struct {
+		name    string
+		stats   RouteStats
+		routing Routing
+		want    []string
+	}{
+		{
+			name:  "default policy keeps the registration order",
+			stats: stubRouteStats{"a": priced(3), "b": priced(1), "c": priced(2)},
+			want:  []string{"a", "b", "c"},
+		},
+		{
+			name:    "cheapest",
+			stats:   stubRouteStats{"a": priced(3), "b": priced(1), "c": priced(2)},
+			routing: Routing{Policy: PolicyCheapest},
+			want:    []string{"b", "c", "a"},
+		},
+		{
+			name: "cheapest orders unpriced routes last",
+			stats: stubRouteStats{
+				"a": {Cataloged: true, InputCostPer1K: &inputOnly},
+				"b": priced(3),
+			},
+			routing: Routing{Policy: PolicyCheapest},
+			want:    []string{"b", "a", "c"},
+		},
+		{
+			name: "fastest by median then p95 latency",
+			stats: stubRouteStats{
+				"a": measuredRoute(200*time.Millisecond, 300*time.Millisecond, 1),
+				"b": measuredRoute(100*time.Millisecond, 900*time.Millisecond, 1),
+				"c": measuredRoute(100*time.Millisecond, 400*time.Millisecond, 1),
+			},
+			routing: Routing{Policy: PolicyFastest},
+			want:    []string{"c", "b", "a"},
+		},
+		{
+			name: "fastest orders unmeasured routes last",
+			stats: stubRouteStats{
+				"a": {Cataloged: true, Samples: minRouteSamples - 1, LatencyP50: time.Millisecond, SuccessRate: 1},
+				"b": measuredRoute(time.Second, time.Second, 0.5),
+				"c": {Cataloged: true, Samples: 1, LatencyP50: 2 * time.Millisecond, SuccessRate: 1},
+			},
+			routing: Routing{Policy: PolicyFastest},
+			want:    []string{"b", "a", "c"},
+		},
+		{
+			name: "fastest orders always failing routes last",
+			stats: stubRouteStats{
+				"a": measuredRoute(0, 0, 0),
+				"b": measuredRoute(time.Second, time.Second, 0.5),
+				"c": measuredRoute(2*time.Second, 2*time.Second, 1),
+			},
+			routing: Routing{Policy: PolicyFastest},
+			want:    []string{"b", "c", "a"},
+		},
+		{
+			name: "reliable by success rate then latency",
+			stats: stubRouteStats{
+				"a": measuredRoute(100*time.Millisecond, 100*time.Millisecond, 0.9),
+				"b": measuredRoute(300*time.Millisecond, 300*time.Millisecond, 0.99),
+				"c": measuredRoute(200*time.Millisecond, 200*time.Millisecond, 0.99),
+			},
+			routing: Routing{Policy: PolicyReliable},
+			want:    []string{"c", "b", "a"},
+		},
+		{
+			name: "reliable orders unmeasured routes last",
+			stats: stubRouteStats{
+				"a": {Cataloged: true, Samples: 1, SuccessRate: 1},
+				"b": measuredRoute(time.Second, time.Second, 0.5),
+			},
+			routing: Routing{Policy: PolicyReliable},
+			want:    []string{"b", "a", "c"},
+		},
+		{
+			name: "minimum context window",
+			stats: stubRouteStats{
+				"a": {Cataloged: true, ContextWindow: 8000},
+				"b": {Cataloged: true, ContextWindow: 128000},
+				"c": {Cataloged: true, ContextWindow: 200000},
+			},
+			routing: Routing{MinContextWindow: 128000},
+			want:    []string{"b", "c"},
+		},
+		{
+			name: "required capabilities",
+			stats: stubRouteStats{
+				"a": {Cataloged: true, Capabilities: []string{"vision", "tools"}},
+				"b": {Cataloged: true, Capabilities: []string{"tools"}},
+				"c": {Cataloged: true, Capabilities: []string{"tools", "vision", "json"}},
+			},
+			routing: Routing{RequiredCapabilities: []string{"vision", "tools"}},
+			want:    []string{"a", "c"},
+		},
+		{
+			name: "constraints drop uncataloged routes",
+			stats: stubRouteStats{
+				"a": {ContextWindow: 128000},
+				"b": {Cataloged: true, ContextWindow: 128000},
+			},
+			routing: Routing{MinContextWindow: 1},
+			want:    []string{"b"},
+		},
+		{
+			name: "constraints and policy together",
+			stats: stubRouteStats{
+				"a": {Cataloged: true, ContextWindow: 8000, InputCostPer1K: priced(1).InputCostPer1K, OutputCostPer1K: priced(1).OutputCostPer1K},
+				"b": {Cataloged: true, ContextWindow: 128000, InputCostPer1K: priced(3).InputCostPer1K, OutputCostPer1K: priced(3).OutputCostPer1K},
+				"c": {Cataloged: true, ContextWindow: 128000, InputCostPer1K: priced(2).InputCostPer1K, OutputCostPer1K: priced(2).OutputCostPer1K},
+			},
+			routing: Routing{Policy: PolicyCheapest, MinContextWindow: 100000},
+			want:    []string{"c", "b"},
+		},
+		{
+			name:    "without route information the order is kept",
+			routing: Routing{Policy: PolicyCheapest},
+			want:    []string{"a", "b", "c"},
+		},
+		{
+			name:    "without route information constraints drop every provider",
+			routing: Routing{MinContextWindow: 1},
+			want:    []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := NewRouter()
+			router.SetRouting(tt.stats, Routing{})
+
+			if got := router.route(context.Background(), "m", slices.Clone(names), tt.routing); !slices.Equal(got, tt.want) {
+				t.Errorf("route() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRoutingFromMetadata(t *testing.T) {
+	defaults := Routing{Policy: PolicyReliable, MinContextWindow: 4000, RequiredCapabilities: []string{"tools"}}
+
+	tests := []struct {
+		name      string
+		metadata  map[string]interface{}
+		want      Routing
+		wantParam string // of the invalid request error, if any
+	}{
+		{
+			name:     "no hints keep the defaults",
+			metadata: map[string]interface{}{"user": "42"},
+			want:     defaults,
+		},
+		{
+			name: "every hint",
+			metadata: map[string]interface{}{
+				MetadataRoutingPolicy:        " Cheapest ",
+				MetadataMinContextWindow:     float64(128000),
+				MetadataRequiredCapabilities: []interface{}{"vision", "json"},
+			},
+			want: Routing{Policy: PolicyCheapest, MinContextWindow: 128000, RequiredCapabilities: []string{"vision", "json"}},
+		},
+		{
+			name:     "empty policy and capabilities clear the defaults",
+			metadata: map[string]interface{}{MetadataRoutingPolicy: "", MetadataRequiredCapabilities: []interface{}{}},
+			want:     Routing{MinContextWindow: 4000},
+		},
+		{
+			name:      "unknown policy",
+			metadata:  map[string]interface{}{MetadataRoutingPolicy: "random"},
+			wantParam: "metadata." + MetadataRoutingPolicy,
+		},
+		{
+			name:      "policy that is not a string",
+			metadata:  map[string]interface{}{MetadataRoutingPolicy: float64(1)},
+			wantParam: "metadata." + MetadataRoutingPolicy,
+		},
+		{
+			name:      "negative context window",
+			metadata:  map[string]interface{}{MetadataMinContextWindow: float64(-1)},
+			wantParam: "metadata." + MetadataMinContextWindow,
+		},
+		{
+			name:      "fractional context window",
+			metadata:  map[string]interface{}{MetadataMinContextWindow: 1.5},
+			wantParam: "metadata." + MetadataMinContextWindow,
+		},
+		{
+			name:      "context window that is not a number",
+			metadata:  map[string]interface{}{MetadataMinContextWindow: "128000"},
+			wantParam: "metadata." + MetadataMinContextWindow,
+		},
+		{
+			name:      "capabilities that are not an array",
+			metadata:  map[string]interface{}{MetadataRequiredCapabilities: "vision"},
+			wantParam: "metadata." + MetadataRequiredCapabilities,
+		},
+		{
+			name:      "capability that is not a string",
+			metadata:  map[string]interface{}{MetadataRequiredCapabilities: []interface{}{"vision", true}},
+			wantParam: "metadata." + MetadataRequiredCapabilities,
+		},
+		{
+			name:      "empty capability",
+			metadata:  map[string]interface{}{MetadataRequiredCapabilities: []interface{}{""}},
+			wantParam: "metadata." + MetadataRequiredCapabilities,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := RoutingFromMetadata(tt.metadata, defaults)
+			if tt.wantParam != "" {
+				var providerErr *Error
+				if !errors.As(err, &providerErr) || providerErr.Kind != ErrorKindInvalidRequest || providerErr.Param != tt.wantParam {
+					t.Fatalf("RoutingFromMetadata() error = %v, want an invalid request error for %s", err, tt.wantParam)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("RoutingFromMetadata() error = %v", err)
+			}
+			if got.Policy != tt.want.Policy || got.MinContextWindow != tt.want.MinContextWindow ||
+				!slices.Equal(got.RequiredCapabilities, tt.want.RequiredCapabilities) {
+				t.Errorf("RoutingFromMetadata() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package providers
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// maxStreamLineSize bounds a single line of a provider stream
+const maxStreamLineSize = 1024 * 1024
+
+// sseEvent represents a single Server-Sent Event
+type sseEvent struct {
+	Event string
+	Data  string
+}
+
+// sseReader parses a text/event-stream body into events
+type sseReader struct {
+	scanner *bufio.Scanner
+}
+
+// newSSEReader creates a new Server-Sent Events reader
+func newSSEReader(r io.Reader) *sseReader {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxStreamLineSize)
+	return &sseReader{scanner: scanner}
+}
+
+// next returns the next event, or io.EOF when the stream ends
+func (r *sseReader) next() (*sseEvent, error) {
+	var event sseEvent
+	var data []string
+
+	for r.scanner.Scan() {
+		line := r.scanner.Text()
+
+		// A blank line dispatches the buffered event
+		if line == "" {
+			if event.Event == "" && len(data) == 0 {
+				continue
+			}
+			event.Data = strings.Join(data, "\n")
+			return &event, nil
+		}
+
+		// Lines starting with a colon are comments (often keep-alives)
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "event":
+			event.Event = value
+		case "data":
+			data = append(data, value)
+		}
+	}
+
+	if err := r.scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	// Flush an event left unterminated at the end of the stream
+	if event.Event != "" || len(data) > 0 {
+		event.Data = strings.Join(data, "\n")
+		return &event, nil
+	}
+
+	return nil, io.EOF
+}
+
+// stringPtr returns a pointer to the given string
+func stringPtr(s string) *string {
+	return &s
+}
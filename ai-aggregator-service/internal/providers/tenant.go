@@ -0,0 +1,95 @@
+package providers
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type organizationKey struct{}
+
+// WithOrganization returns a context whose requests are routed for the organization:
+// its own model aliases and provider credentials take precedence over the shared ones
+func WithOrganization(ctx context.Context, organizationID uuid.UUID) context.Context {
+	return context.WithValue(ctx, organizationKey{}, organizationID)
+}
+
+// OrganizationFrom returns the organization attached to ctx, or uuid.Nil if there is none
+func OrganizationFrom(ctx context.Context) uuid.UUID {
+	organizationID, _ := ctx.Value(organizationKey{}).(uuid.UUID)
+	return organizationID
+}
+
+// RegisterForOrganization adds a provider instance that serves only the organization's
+// requests, in place of the shared provider with the same name, e.g. one built with the
+// organization's own API key
+func (r *Router) RegisterForOrganization(organizationID uuid.UUID, p Provider) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.tenants[organizationID] == nil {
+		r.tenants[organizationID] = make(map[string]Provider)
+	}
+	_, exists := r.tenants[organizationID][p.Name()]
+	r.tenants[organizationID][p.Name()] = p
+
+	// A provider only organizations bring keys for must have its models indexed
+	if _, shared := r.providers[p.Name()]; !shared && !exists {
+		r.indexedAt = time.Time{}
+	}
+}
+
+// UnregisterForOrganization removes the organization's instance of the named provider,
+// so that its requests are served by the shared provider again
+func (r *Router) UnregisterForOrganization(organizationID uuid.UUID, name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.tenants[organizationID], name)
+	if len(r.tenants[organizationID]) == 0 {
+		delete(r.tenants, organizationID)
+	}
+}
+
+// OrganizationProvider returns the organization's own instance of the named provider
+func (r *Router) OrganizationProvider(organizationID uuid.UUID, name string) (Provider, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	p, ok := r.tenants[organizationID][name]
+	return p, ok
+}
+
+// providerFor returns the provider serving the named backend for the organization
+// attached to ctx. byok is true when it is the organization's own instance.
+func (r *Router) providerFor(ctx context.Context, name string) (p Provider, byok bool, err error) {
+	if organizationID := OrganizationFrom(ctx); organizationID != uuid.Nil {
+		if p, ok := r.OrganizationProvider(organizationID, name); ok {
+			return p, true, nil
+		}
+	}
+
+	p, err = r.Provider(name)
+	return p, false, err
+}
+
+// tenantOnly returns one organization instance of each provider that has no shared
+// instance, so that the models such providers serve can be indexed
+func (r *Router) tenantOnly() []Provider {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	seen := make(map[string]bool)
+	var providers []Provider
+	for _, instances := range r.tenants {
+		for name, p := range instances {
+			if _, shared := r.providers[name]; shared || seen[name] {
+				continue
+			}
+			seen[name] = true
+			providers = append(providers, p)
+		}
+	}
+	return providers
+}
@@ -0,0 +1,377 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"ai-aggregator-service/internal/config"
+	"ai-aggregator-service/internal/credentials"
+	"ai-aggregator-service/internal/models"
+	"ai-aggregator-service/internal/providers"
+
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+// Reasons a provider instance is not served
+const (
+	reasonNoAPIKey  = "no API key configured"
+	reasonDisabled  = "disabled in the providers table"
+	reasonRemoved   = "removed from the providers table"
+	reasonNoBackend = "provider is not configured"
+)
+
+// Registry builds provider instances from the providers table, merged with the secrets in
+// configuration, and keeps the router's providers in sync as rows change. Organizations'
+// own credentials get instances of their own, which serve only their requests.
+type Registry struct {
+	db          *bun.DB
+	router      *providers.Router
+	factory     providers.ProviderFactory
+	configured  map[string]configured
+	credentials *credentials.Store
+
+	mu      sync.Mutex
+	loaded  bool
+	states  map[string]string    // provider name -> fingerprint of the state last applied
+	tenants map[tenantKey]string // organization instance -> fingerprint of the state last applied
+}
+
+// tenantKey identifies an organization's instance of a provider
+type tenantKey struct {
+	organizationID uuid.UUID
+	provider       string
+}
+
+// configured holds the settings configuration supplies for a provider instance
+type configured struct {
+	config      providers.Config
+	keyRequired bool
+}
+
+// desired is the state a provider instance should be in after a sync
+type desired struct {
+	config providers.Config
+
+	// reason explains why the provider is not served; empty when it should be registered
+	reason string
+}
+
+// NewRegistry creates a registry that registers providers with router
+func NewRegistry(db *bun.DB, router *providers.Router, cfg config.ProvidersConfig) *Registry {
+	r := &Registry{
+		db:         db,
+		router:     router,
+		factory:    providers.NewProviderFactory(),
+		configured: make(map[string]configured),
+		states:     make(map[string]string),
+		tenants:    make(map[tenantKey]string),
+	}
+
+	builtIns := []struct {
+		typ string
+		cfg config.ProviderConfig
+	}{
+		{"openai", cfg.OpenAI},
+		{"anthropic", cfg.Anthropic},
+		{"google", cfg.GoogleAI},
+		{"cohere", cfg.Cohere},
+		{"mistral", cfg.Mistral},
+		{"azure", cfg.Azure},
+	}
+	for _, builtIn := range builtIns {
+		name := builtIn.cfg.Name
+		if name == "" {
+			name = builtIn.typ
+		}
+		r.configured[name] = configured{
+			config: providers.Config{
+				Name:    name,
+				Type:    builtIn.typ,
+				APIKey:  builtIn.cfg.APIKey,
+				APIKeys: apiKeys(builtIn.cfg.APIKeys),
+				BaseURL: builtIn.cfg.BaseURL,
+				Headers: builtIn.cfg.Headers,
+				Models:  builtIn.cfg.Models,
+			},
+			keyRequired: true,
+		}
+	}
+
+	for _, custom := range cfg.Custom {
+		r.configured[custom.Name] = configured{
+			config: providers.Config{
+				Name:    custom.Name,
+				Type:    custom.Type,
+				APIKey:  custom.APIKey,
+				APIKeys: apiKeys(custom.APIKeys),
+				BaseURL: custom.BaseURL,
+				Headers: custom.Headers,
+				Timeout: custom.Timeout,
+				Models:  custom.Models,
+			},
+			keyRequired: custom.APIKeyRequired,
+		}
+	}
+
+	return r
+}
+
+// SetCredentials configures the store organizations' own provider credentials are read from
+func (r *Registry) SetCredentials(store *credentials.Store) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.credentials = store
+}
+
+// Sync reconciles the router with the providers table: new and changed rows are (re)built,
+// and removed or inactive ones unregistered. When the table cannot be read the router is
+// left as it is, or, before the first successful read, built from configuration alone.
+// Organization instances are then reconciled with the provider credentials.
+func (r *Registry) Sync(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var rows []models.Provider
+	err := r.db.NewSelect().
+		Model(&rows).
+		Column("name", "type", "base_url", "api_key_required", "rate_limit_rpm", "rate_limit_tpm", "is_active", "config").
+		Scan(ctx)
+	if err != nil {
+		err = fmt.Errorf("failed to load providers: %w", err)
+		if r.loaded {
+			return err
+		}
+		r.apply(r.desired(nil))
+		return err
+	}
+
+	r.loaded = true
+	states := r.desired(rows)
+	r.apply(states)
+
+	if r.credentials == nil {
+		return nil
+	}
+	creds, err := r.credentials.Active(ctx)
+	if err != nil {
+		// Organization instances keep serving with the credentials last read
+		return err
+	}
+	r.applyTenants(r.desiredTenants(states, creds))
+	return nil
+}
+
+// Watch syncs the registry every interval until ctx is done
+func (r *Registry) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := r.Sync(ctx); err != nil {
+				slog.Warn("Failed to refresh provider registry", "error", err)
+			}
+		}
+	}
+}
+
+// desired merges the providers table rows with configuration. A row's base_url and config
+// take effect unless configuration overrides the base URL; secrets only come from configuration.
+// Configured providers without a row are served as configured.
+func (r *Registry) desired(rows []models.Provider) map[string]desired {
+	states := make(map[string]desired)
+
+	for name, conf := range r.configured {
+		state := desired{config: conf.config}
+		if conf.keyRequired && !conf.config.HasAPIKey() {
+			state.reason = reasonNoAPIKey
+		}
+		states[name] = state
+	}
+
+	for _, row := range rows {
+		providerConfig := r.configured[row.Name].config
+		providerConfig.Name = row.Name
+		if row.Type != "" {
+			providerConfig.Type = row.Type
+		}
+		if providerConfig.Type == "" {
+			providerConfig.Type = row.Name
+		}
+		if providerConfig.BaseURL == "" {
+			providerConfig.BaseURL = row.BaseURL
+		}
+		providerConfig.Options = row.Config
+		// The row's rate limits cap each key of a key pool that sets none of its own
+		providerConfig.RateLimit = providers.RateLimitConfig{
+			RequestsPerMinute: row.RateLimitRPM,
+			TokensPerMinute:   row.RateLimitTPM,
+		}
+
+		state := desired{config: providerConfig}
+		switch {
+		case !row.IsActive:
+			state.reason = reasonDisabled
+		case row.APIKeyRequired && !providerConfig.HasAPIKey():
+			state.reason = reasonNoAPIKey
+		}
+		states[row.Name] = state
+	}
+
+	return states
+}
+
+// apply registers, rebuilds and unregisters providers whose desired state changed since
+// the last sync; callers must hold mu
+func (r *Registry) apply(states map[string]desired) {
+	for name, state := range states {
+		fingerprint := state.fingerprint()
+		previous, known := r.states[name]
+		if known && previous == fingerprint {
+			continue
+		}
+		r.states[name] = fingerprint
+
+		if state.reason != "" {
+			if _, err := r.router.Provider(name); err == nil {
+				r.router.Unregister(name)
+				slog.Info("Unregistered provider", "provider", name, "reason", state.reason)
+			} else {
+				slog.Debug("Skipping provider", "provider", name, "reason", state.reason)
+			}
+			continue
+		}
+
+		p, err := r.factory.Create(state.config)
+		if err != nil {
+			// A running instance keeps serving until its row is fixed
+			slog.Error("Failed to build provider", "provider", name, "error", err)
+			continue
+		}
+		if _, err := r.router.Provider(name); err == nil {
+			slog.Info("Rebuilt provider", "provider", name)
+		} else {
+			slog.Info("Registered provider", "provider", name)
+		}
+		r.router.Register(p)
+	}
+
+	for name := range r.states {
+		if _, ok := states[name]; !ok {
+			delete(r.states, name)
+			r.router.Unregister(name)
+			slog.Info("Unregistered provider", "provider", name, "reason", reasonRemoved)
+		}
+	}
+}
+
+// desiredTenants builds the state of each organization instance from the shared
+// provider's state, with the organization's key in place of ours and its options merged
+// over the shared ones. An organization instance is served even when we have no key of
+// our own for the provider, but not when the provider is disabled.
+func (r *Registry) desiredTenants(states map[string]desired, creds []credentials.Credential) map[tenantKey]desired {
+	tenants := make(map[tenantKey]desired, len(creds))
+	for _, cred := range creds {
+		shared, ok := states[cred.Provider]
+		if !ok {
+			tenants[tenantKey{cred.OrganizationID, cred.Provider}] = desired{reason: reasonNoBackend}
+			continue
+		}
+
+		providerConfig := shared.config
+		providerConfig.APIKey = cred.APIKey
+		providerConfig.APIKeys = nil
+		if len(cred.Options) > 0 {
+			options := make(map[string]interface{}, len(shared.config.Options)+len(cred.Options))
+			for name, value := range shared.config.Options {
+				options[name] = value
+			}
+			for name, value := range cred.Options {
+				options[name] = value
+			}
+			providerConfig.Options = options
+		}
+
+		state := desired{config: providerConfig}
+		if shared.reason == reasonDisabled {
+			state.reason = reasonDisabled
+		}
+		tenants[tenantKey{cred.OrganizationID, cred.Provider}] = state
+	}
+	return tenants
+}
+
+// applyTenants registers, rebuilds and unregisters organization instances whose desired
+// state changed since the last sync; callers must hold mu
+func (r *Registry) applyTenants(states map[tenantKey]desired) {
+	for key, state := range states {
+		fingerprint := state.fingerprint()
+		if previous, known := r.tenants[key]; known && previous == fingerprint {
+			continue
+		}
+		r.tenants[key] = fingerprint
+
+		if state.reason != "" {
+			if _, ok := r.router.OrganizationProvider(key.organizationID, key.provider); ok {
+				r.router.UnregisterForOrganization(key.organizationID, key.provider)
+				slog.Info("Unregistered organization provider", "organization_id", key.organizationID, "provider", key.provider, "reason", state.reason)
+			} else {
+				slog.Debug("Skipping organization provider", "organization_id", key.organizationID, "provider", key.provider, "reason", state.reason)
+			}
+			continue
+		}
+
+		p, err := r.factory.Create(state.config)
+		if err != nil {
+			slog.Error("Failed to build organization provider", "organization_id", key.organizationID, "provider", key.provider, "error", err)
+			continue
+		}
+		r.router.RegisterForOrganization(key.organizationID, p)
+		slog.Info("Registered organization provider", "organization_id", key.organizationID, "provider", key.provider)
+	}
+
+	for key := range r.tenants {
+		if _, ok := states[key]; !ok {
+			delete(r.tenants, key)
+			r.router.UnregisterForOrganization(key.organizationID, key.provider)
+			slog.Info("Unregistered organization provider", "organization_id", key.organizationID, "provider", key.provider, "reason", "credential removed")
+		}
+	}
+}
+
+// apiKeys converts a configured key pool
+func apiKeys(keys config.APIKeys) []providers.APIKeyConfig {
+	var pool []providers.APIKeyConfig
+	for _, key := range keys {
+		if key.Key == "" {
+			continue
+		}
+		pool = append(pool, providers.APIKeyConfig{
+			Key:    key.Key,
+			Weight: key.Weight,
+			RateLimit: providers.RateLimitConfig{
+				RequestsPerMinute: key.RequestsPerMinute,
+				TokensPerMinute:   key.TokensPerMinute,
+			},
+		})
+	}
+	return pool
+}
+
+// fingerprint identifies the state, so that unchanged providers are not rebuilt
+func (d desired) fingerprint() string {
+	data, err := json.Marshal(d.config)
+	if err != nil {
+		return ""
+	}
+	return d.reason + "|" + string(data)
+}
@@ -0,0 +1,164 @@
+package routing
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"ai-aggregator-service/internal/models"
+	"ai-aggregator-service/internal/providers"
+
+	"github.com/uptrace/bun"
+)
+
+// refreshInterval controls how long loaded routes are trusted before they are read again
+const refreshInterval = time.Minute
+
+// routeKey identifies serving a model with a provider
+type routeKey struct {
+	model    string
+	provider string
+}
+
+// routeStats holds the recent calls made to a route, aggregated from api_responses
+type routeStats struct {
+	Provider    string   `bun:"provider"`
+	Model       string   `bun:"model"`
+	Samples     int      `bun:"samples"`
+	LatencyP50  *float64 `bun:"latency_p50"`
+	LatencyP95  *float64 `bun:"latency_p95"`
+	SuccessRate float64  `bun:"success_rate"`
+}
+
+// Stats supplies routing with the catalog entry of every model and provider and the
+// latency and success rate of the calls made to it within a rolling window. Routes are
+// kept in memory and reloaded periodically, so that routing does not add queries per
+// request.
+type Stats struct {
+	db     *bun.DB
+	window time.Duration
+
+	mu       sync.RWMutex
+	routes   map[routeKey]providers.Route
+	loadedAt time.Time
+
+	refreshMu sync.Mutex
+}
+
+// NewStats creates route statistics over the calls made within window
+func NewStats(db *bun.DB, window time.Duration) *Stats {
+	return &Stats{
+		db:     db,
+		window: window,
+		routes: make(map[routeKey]providers.Route),
+	}
+}
+
+// Routes returns what is known about serving model with each of the named providers. It
+// implements providers.RouteStats.
+func (s *Stats) Routes(ctx context.Context, model string, names []string) map[string]providers.Route {
+	s.refresh(ctx)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	routes := make(map[string]providers.Route, len(names))
+	for _, name := range names {
+		if route, ok := s.routes[routeKey{model: model, provider: name}]; ok {
+			routes[name] = route
+		}
+	}
+	return routes
+}
+
+// refresh reloads the routes when they are stale. When they cannot be read the
+// previously loaded routes keep being served until the next attempt.
+func (s *Stats) refresh(ctx context.Context) {
+	s.mu.RLock()
+	fresh := time.Since(s.loadedAt) < refreshInterval
+	s.mu.RUnlock()
+	if fresh {
+		return
+	}
+
+	s.refreshMu.Lock()
+	defer s.refreshMu.Unlock()
+
+	s.mu.RLock()
+	fresh = time.Since(s.loadedAt) < refreshInterval
+	s.mu.RUnlock()
+	if fresh {
+		return
+	}
+
+	routes, err := s.load(ctx)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.loadedAt = time.Now()
+	if err != nil {
+		slog.Warn("Failed to load routing statistics", "error", err)
+		return
+	}
+	s.routes = routes
+}
+
+// load reads the active catalog entries and the statistics of the calls made within the
+// window
+func (s *Stats) load(ctx context.Context) (map[routeKey]providers.Route, error) {
+	var rows []models.Model
+	err := s.db.NewSelect().
+		Model(&rows).
+		Relation("Provider").
+		Where("model.is_active").
+		Where("provider.is_active").
+		Scan(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load models: %w", err)
+	}
+
+	routes := make(map[routeKey]providers.Route, len(rows))
+	for _, row := range rows {
+		routes[routeKey{model: row.Name, provider: row.Provider.Name}] = providers.Route{
+			Cataloged:       true,
+			ContextWindow:   row.ContextWindow,
+			Capabilities:    row.Capabilities,
+			InputCostPer1K:  row.InputCostPer1K,
+			OutputCostPer1K: row.OutputCostPer1K,
+		}
+	}
+
+	var stats []routeStats
+	err = s.db.NewSelect().
+		TableExpr("api_responses AS response").
+		Join("JOIN providers AS provider ON provider.id = response.provider_id").
+		Join("JOIN models AS model ON model.id = response.model_id").
+		ColumnExpr("provider.name AS provider, model.name AS model").
+		ColumnExpr("count(*) AS samples").
+		ColumnExpr("percentile_cont(0.5) WITHIN GROUP (ORDER BY response.latency_ms) FILTER (WHERE response.status_code BETWEEN 200 AND 399) AS latency_p50").
+		ColumnExpr("percentile_cont(0.95) WITHIN GROUP (ORDER BY response.latency_ms) FILTER (WHERE response.status_code BETWEEN 200 AND 399) AS latency_p95").
+		ColumnExpr("avg(CASE WHEN response.status_code BETWEEN 200 AND 399 THEN 1 ELSE 0 END) AS success_rate").
+		Where("response.created_at >= ?", time.Now().Add(-s.window)).
+		GroupExpr("provider.name, model.name").
+		Scan(ctx, &stats)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load response statistics: %w", err)
+	}
+
+	for _, stat := range stats {
+		key := routeKey{model: stat.Model, provider: stat.Provider}
+		route := routes[key]
+		route.Samples = stat.Samples
+		route.SuccessRate = stat.SuccessRate
+		if stat.LatencyP50 != nil {
+			route.LatencyP50 = time.Duration(*stat.LatencyP50 * float64(time.Millisecond))
+		}
+		if stat.LatencyP95 != nil {
+			route.LatencyP95 = time.Duration(*stat.LatencyP95 * float64(time.Millisecond))
+		}
+		routes[key] = route
+	}
+	return routes, nil
+}
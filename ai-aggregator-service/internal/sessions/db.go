@@ -0,0 +1,146 @@
+package sessions
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"ai-aggregator-service/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+// DBStore keeps sessions in the refresh_tokens and revoked_tokens tables, and the time
+// a user last signed out of all sessions in users.sessions_revoked_at
+type DBStore struct {
+	db *bun.DB
+}
+
+// NewDBStore creates a new database-backed session store
+func NewDBStore(db *bun.DB) *DBStore {
+	return &DBStore{db: db}
+}
+
+// CreateRefreshToken implements Store
+func (s *DBStore) CreateRefreshToken(ctx context.Context, token *models.RefreshToken) error {
+	if _, err := s.db.NewInsert().Model(token).Returning("id").Exec(ctx); err != nil {
+		return fmt.Errorf("failed to insert refresh token: %w", err)
+	}
+	return nil
+}
+
+// RefreshToken implements Store
+func (s *DBStore) RefreshToken(ctx context.Context, hash string) (*models.RefreshToken, error) {
+	row := new(models.RefreshToken)
+	err := s.db.NewSelect().
+		Model(row).
+		Where("token_hash = ?", hash).
+		Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrTokenNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load refresh token: %w", err)
+	}
+	return row, nil
+}
+
+// UseRefreshToken implements Store. Concurrent refreshes with the same token race on
+// the update, and only one of them wins.
+func (s *DBStore) UseRefreshToken(ctx context.Context, id uuid.UUID, at time.Time) (bool, error) {
+	result, err := s.db.NewUpdate().
+		Model((*models.RefreshToken)(nil)).
+		Set("used_at = ?", at).
+		Where("id = ?", id).
+		Where("used_at IS NULL").
+		Where("revoked_at IS NULL").
+		Exec(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to use refresh token: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to use refresh token: %w", err)
+	}
+	return rows == 1, nil
+}
+
+// RevokeFamily implements Store
+func (s *DBStore) RevokeFamily(ctx context.Context, familyID uuid.UUID, at time.Time) error {
+	_, err := s.db.NewUpdate().
+		Model((*models.RefreshToken)(nil)).
+		Set("revoked_at = ?", at).
+		Where("family_id = ?", familyID).
+		Where("revoked_at IS NULL").
+		Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to revoke refresh tokens: %w", err)
+	}
+	return nil
+}
+
+// RevokeUser implements Store
+func (s *DBStore) RevokeUser(ctx context.Context, userID uuid.UUID, at time.Time) error {
+	return s.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		_, err := tx.NewUpdate().
+			Model((*models.RefreshToken)(nil)).
+			Set("revoked_at = ?", at).
+			Where("user_id = ?", userID).
+			Where("revoked_at IS NULL").
+			Exec(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to revoke refresh tokens: %w", err)
+		}
+
+		_, err = tx.NewUpdate().
+			Model((*models.User)(nil)).
+			Set("sessions_revoked_at = ?", at).
+			Where("id = ?", userID).
+			Exec(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to revoke access tokens: %w", err)
+		}
+		return nil
+	})
+}
+
+// RevokeAccessToken implements Store. Entries past their expiry are pruned on the way.
+func (s *DBStore) RevokeAccessToken(ctx context.Context, jti string, userID uuid.UUID, expiresAt time.Time) error {
+	row := &models.RevokedToken{
+		JTI:       jti,
+		RevokedAt: time.Now(),
+		ExpiresAt: expiresAt,
+	}
+	if userID != uuid.Nil {
+		row.UserID = models.UUIDPtr(userID)
+	}
+	if _, err := s.db.NewInsert().Model(row).On("CONFLICT (jti) DO NOTHING").Exec(ctx); err != nil {
+		return fmt.Errorf("failed to revoke access token: %w", err)
+	}
+
+	_, err := s.db.NewDelete().
+		Model((*models.RevokedToken)(nil)).
+		Where("expires_at < ?", time.Now()).
+		Exec(ctx)
+	if err != nil {
+		slog.Warn("Failed to prune revoked tokens", "error", err)
+	}
+	return nil
+}
+
+// AccessTokenRevoked implements Store
+func (s *DBStore) AccessTokenRevoked(ctx context.Context, jti string, userID uuid.UUID, issuedAt time.Time) (bool, error) {
+	var revoked bool
+	err := s.db.NewRaw(
+		"SELECT EXISTS (SELECT 1 FROM revoked_tokens WHERE jti = ?) OR EXISTS (SELECT 1 FROM users WHERE id = ? AND sessions_revoked_at >= ?)",
+		jti, userID, issuedAt,
+	).Scan(ctx, &revoked)
+	if err != nil {
+		return false, fmt.Errorf("failed to check token revocation: %w", err)
+	}
+	return revoked, nil
+}
@@ -0,0 +1,164 @@
+package sessions
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"ai-aggregator-service/internal/auth"
+	"ai-aggregator-service/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// refreshTokenBytes is the number of random bytes in a refresh token
+const refreshTokenBytes = 32
+
+var (
+	// ErrInvalidRefreshToken is returned for refresh tokens that are unknown, expired or
+	// revoked
+	ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+
+	// ErrRefreshTokenReused is returned when a refresh token is presented again after it
+	// was exchanged; its whole family is revoked, signing the session out
+	ErrRefreshTokenReused = errors.New("refresh token was already used")
+)
+
+// Manager issues and rotates the refresh tokens of signed-in users and keeps the
+// revocation list of access tokens. Each sign-in starts a family of refresh tokens;
+// every refresh exchanges the presented token for the next one of its family.
+type Manager struct {
+	store Store
+	ttl   time.Duration
+}
+
+// NewManager creates a session manager whose refresh tokens stay valid for ttl
+func NewManager(store Store, ttl time.Duration) *Manager {
+	return &Manager{store: store, ttl: ttl}
+}
+
+// Start begins a session for the user and returns its first refresh token
+func (m *Manager) Start(ctx context.Context, userID uuid.UUID) (auth.Token, error) {
+	return m.issue(ctx, userID, uuid.New())
+}
+
+// Refresh exchanges a refresh token for the next one of its family and returns the user
+// it was issued to. Presenting a token that was already exchanged revokes the family,
+// since either the user or whoever stole the token is replaying it.
+func (m *Manager) Refresh(ctx context.Context, token string) (uuid.UUID, auth.Token, error) {
+	row, err := m.lookup(ctx, token)
+	if err != nil {
+		return uuid.Nil, auth.Token{}, err
+	}
+
+	now := time.Now()
+	used, err := m.store.UseRefreshToken(ctx, row.ID, now)
+	if err != nil {
+		return uuid.Nil, auth.Token{}, err
+	}
+	if !used {
+		if err := m.store.RevokeFamily(ctx, row.FamilyID, now); err != nil {
+			return uuid.Nil, auth.Token{}, err
+		}
+		slog.Warn("Refresh token reused, revoked its session", "user_id", row.UserID, "family_id", row.FamilyID)
+		return uuid.Nil, auth.Token{}, ErrRefreshTokenReused
+	}
+
+	next, err := m.issue(ctx, row.UserID, row.FamilyID)
+	if err != nil {
+		return uuid.Nil, auth.Token{}, err
+	}
+	return row.UserID, next, nil
+}
+
+// End signs out the session of a refresh token by revoking its family, and returns the
+// user it was issued to
+func (m *Manager) End(ctx context.Context, token string) (uuid.UUID, error) {
+	row, err := m.lookup(ctx, token)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if err := m.store.RevokeFamily(ctx, row.FamilyID, time.Now()); err != nil {
+		return uuid.Nil, err
+	}
+	return row.UserID, nil
+}
+
+// EndAll signs the user out of every session: all refresh tokens are revoked, and so
+// are the access tokens issued up to now
+func (m *Manager) EndAll(ctx context.Context, userID uuid.UUID) error {
+	return m.store.RevokeUser(ctx, userID, time.Now())
+}
+
+// RevokeAccess adds the access token the claims were verified from to the revocation
+// list. Tokens without a jti cannot be revoked one by one.
+func (m *Manager) RevokeAccess(ctx context.Context, claims *auth.Claims) error {
+	if claims.TokenID == "" || claims.ExpiresAt.Before(time.Now()) {
+		return nil
+	}
+	return m.store.RevokeAccessToken(ctx, claims.TokenID, claims.UserID, claims.ExpiresAt)
+}
+
+// Revoked reports whether the access token the claims were verified from was revoked.
+// The verifier rejects tokens without an iat; claims without one cannot be told apart
+// from tokens issued before the user signed out everywhere, so they count as revoked.
+func (m *Manager) Revoked(ctx context.Context, claims *auth.Claims) (bool, error) {
+	if claims.IssuedAt.IsZero() {
+		return true, nil
+	}
+	return m.store.AccessTokenRevoked(ctx, claims.TokenID, claims.UserID, claims.IssuedAt)
+}
+
+// lookup returns the stored, unexpired and unrevoked refresh token
+func (m *Manager) lookup(ctx context.Context, token string) (*models.RefreshToken, error) {
+	if token == "" {
+		return nil, ErrInvalidRefreshToken
+	}
+	row, err := m.store.RefreshToken(ctx, hashToken(token))
+	if errors.Is(err, ErrTokenNotFound) {
+		return nil, ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	if row.RevokedAt != nil || time.Now().After(row.ExpiresAt) {
+		return nil, ErrInvalidRefreshToken
+	}
+	return row, nil
+}
+
+// issue creates a refresh token of the family and stores its hash
+func (m *Manager) issue(ctx context.Context, userID, familyID uuid.UUID) (auth.Token, error) {
+	raw := make([]byte, refreshTokenBytes)
+	if _, err := rand.Read(raw); err != nil {
+		return auth.Token{}, fmt.Errorf("failed to generate refresh token: %w", err)
+	}
+	token := auth.Token{
+		Value:     base64.RawURLEncoding.EncodeToString(raw),
+		ExpiresAt: time.Now().Add(m.ttl),
+	}
+
+	row := &models.RefreshToken{
+		UserID:    userID,
+		FamilyID:  familyID,
+		TokenHash: hashToken(token.Value),
+		ExpiresAt: token.ExpiresAt,
+	}
+	if err := m.store.CreateRefreshToken(ctx, row); err != nil {
+		return auth.Token{}, err
+	}
+	return token, nil
+}
+
+// hashToken returns the hash refresh tokens are stored and looked up by. Tokens are
+// random, so an unsalted hash suffices.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
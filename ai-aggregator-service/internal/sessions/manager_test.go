@@ -0,0 +1,226 @@
+package sessions
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"ai-aggregator-service/internal/auth"
+
+	"github.com/google/uuid"
+)
+
+func newTestManager() *Manager {
+	return NewManager(NewMemoryStore(), time.Hour)
+}
+
+func TestRefreshRotatesTokens(t *testing.T) {
+	ctx := context.Background()
+	m := newTestManager()
+	userID := uuid.New()
+
+	first, err := m.Start(ctx, userID)
+	if err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+
+	gotUser, second, err := m.Refresh(ctx, first.Value)
+	if err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+	if gotUser != userID {
+		t.Errorf("Refresh() user = %s, want %s", gotUser, userID)
+	}
+	if second.Value == "" || second.Value == first.Value {
+		t.Fatalf("Refresh() token = %q, want a new token", second.Value)
+	}
+
+	if _, third, err := m.Refresh(ctx, second.Value); err != nil || third.Value == "" {
+		t.Errorf("Refresh() with the rotated token: error = %v, want a new token", err)
+	}
+}
+
+func TestRefreshRejectsUnknownTokens(t *testing.T) {
+	m := newTestManager()
+
+	for _, token := range []string{"", "not-a-token"} {
+		if _, _, err := m.Refresh(context.Background(), token); !errors.Is(err, ErrInvalidRefreshToken) {
+			t.Errorf("Refresh(%q) error = %v, want ErrInvalidRefreshToken", token, err)
+		}
+	}
+}
+
+func TestRefreshRejectsExpiredTokens(t *testing.T) {
+	ctx := context.Background()
+	m := NewManager(NewMemoryStore(), -time.Minute)
+
+	token, err := m.Start(ctx, uuid.New())
+	if err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	if _, _, err := m.Refresh(ctx, token.Value); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("Refresh() error = %v, want ErrInvalidRefreshToken", err)
+	}
+}
+
+func TestReplayRevokesFamily(t *testing.T) {
+	ctx := context.Background()
+	m := newTestManager()
+	userID := uuid.New()
+
+	first, err := m.Start(ctx, userID)
+	if err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	other, err := m.Start(ctx, userID)
+	if err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	_, second, err := m.Refresh(ctx, first.Value)
+	if err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+
+	// Presenting the exchanged token again signs its session out
+	if _, _, err := m.Refresh(ctx, first.Value); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("Refresh() with a used token: error = %v, want ErrRefreshTokenReused", err)
+	}
+	if _, _, err := m.Refresh(ctx, second.Value); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("Refresh() with the family's latest token: error = %v, want ErrInvalidRefreshToken", err)
+	}
+
+	// Other sessions of the user are not affected
+	if _, _, err := m.Refresh(ctx, other.Value); err != nil {
+		t.Errorf("Refresh() in another session: error = %v, want none", err)
+	}
+}
+
+func TestEnd(t *testing.T) {
+	ctx := context.Background()
+	m := newTestManager()
+	userID := uuid.New()
+
+	first, err := m.Start(ctx, userID)
+	if err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	_, second, err := m.Refresh(ctx, first.Value)
+	if err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+	other, err := m.Start(ctx, userID)
+	if err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+
+	gotUser, err := m.End(ctx, second.Value)
+	if err != nil {
+		t.Fatalf("End() error = %v", err)
+	}
+	if gotUser != userID {
+		t.Errorf("End() user = %s, want %s", gotUser, userID)
+	}
+	if _, _, err := m.Refresh(ctx, second.Value); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("Refresh() after End(): error = %v, want ErrInvalidRefreshToken", err)
+	}
+	if _, err := m.End(ctx, second.Value); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("End() twice: error = %v, want ErrInvalidRefreshToken", err)
+	}
+	if _, _, err := m.Refresh(ctx, other.Value); err != nil {
+		t.Errorf("Refresh() in another session: error = %v, want none", err)
+	}
+}
+
+func TestEndAll(t *testing.T) {
+	ctx := context.Background()
+	m := newTestManager()
+	userID := uuid.New()
+	otherUserID := uuid.New()
+
+	first, err := m.Start(ctx, userID)
+	if err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	second, err := m.Start(ctx, userID)
+	if err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	otherUser, err := m.Start(ctx, otherUserID)
+	if err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+
+	// An access token issued in the same second as, and before, the sign-out
+	issued := &auth.Claims{UserID: userID, TokenID: uuid.NewString(), IssuedAt: time.Now().Truncate(time.Second)}
+
+	if err := m.EndAll(ctx, userID); err != nil {
+		t.Fatalf("EndAll() error = %v", err)
+	}
+
+	for _, token := range []string{first.Value, second.Value} {
+		if _, _, err := m.Refresh(ctx, token); !errors.Is(err, ErrInvalidRefreshToken) {
+			t.Errorf("Refresh() after EndAll(): error = %v, want ErrInvalidRefreshToken", err)
+		}
+	}
+	if revoked, err := m.Revoked(ctx, issued); err != nil || !revoked {
+		t.Errorf("Revoked() for a token issued before EndAll() = %v, %v, want true", revoked, err)
+	}
+
+	later := &auth.Claims{UserID: userID, TokenID: uuid.NewString(), IssuedAt: time.Now().Truncate(time.Second).Add(time.Second)}
+	if revoked, err := m.Revoked(ctx, later); err != nil || revoked {
+		t.Errorf("Revoked() for a token issued after EndAll() = %v, %v, want false", revoked, err)
+	}
+	if _, err := m.Start(ctx, userID); err != nil {
+		t.Errorf("Start() after EndAll(): error = %v, want a new session", err)
+	}
+
+	if _, _, err := m.Refresh(ctx, otherUser.Value); err != nil {
+		t.Errorf("Refresh() for another user: error = %v, want none", err)
+	}
+	otherClaims := &auth.Claims{UserID: otherUserID, TokenID: uuid.NewString(), IssuedAt: issued.IssuedAt}
+	if revoked, err := m.Revoked(ctx, otherClaims); err != nil || revoked {
+		t.Errorf("Revoked() for another user = %v, %v, want false", revoked, err)
+	}
+}
+
+func TestRevokeAccess(t *testing.T) {
+	ctx := context.Background()
+	m := newTestManager()
+	userID := uuid.New()
+	now := time.Now().Truncate(time.Second)
+
+	claims := &auth.Claims{UserID: userID, TokenID: uuid.NewString(), IssuedAt: now, ExpiresAt: now.Add(time.Hour)}
+	other := &auth.Claims{UserID: userID, TokenID: uuid.NewString(), IssuedAt: now, ExpiresAt: now.Add(time.Hour)}
+
+	if revoked, err := m.Revoked(ctx, claims); err != nil || revoked {
+		t.Fatalf("Revoked() before RevokeAccess() = %v, %v, want false", revoked, err)
+	}
+	if err := m.RevokeAccess(ctx, claims); err != nil {
+		t.Fatalf("RevokeAccess() error = %v", err)
+	}
+	if revoked, err := m.Revoked(ctx, claims); err != nil || !revoked {
+		t.Errorf("Revoked() after RevokeAccess() = %v, %v, want true", revoked, err)
+	}
+	if revoked, err := m.Revoked(ctx, other); err != nil || revoked {
+		t.Errorf("Revoked() for another token of the user = %v, %v, want false", revoked, err)
+	}
+
+	// Tokens without a jti cannot be revoked one by one
+	anonymous := &auth.Claims{UserID: userID, IssuedAt: now, ExpiresAt: now.Add(time.Hour)}
+	if err := m.RevokeAccess(ctx, anonymous); err != nil {
+		t.Fatalf("RevokeAccess() without a jti: error = %v", err)
+	}
+	if revoked, err := m.Revoked(ctx, anonymous); err != nil || revoked {
+		t.Errorf("Revoked() for a token without a jti = %v, %v, want false", revoked, err)
+	}
+}
+
+func TestRevokedWithoutIssuedAt(t *testing.T) {
+	m := newTestManager()
+
+	claims := &auth.Claims{UserID: uuid.New(), TokenID: uuid.NewString()}
+	if revoked, err := m.Revoked(context.Background(), claims); err != nil || !revoked {
+		t.Errorf("Revoked() for claims without iat = %v, %v, want true", revoked, err)
+	}
+}
@@ -0,0 +1,127 @@
+package sessions
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"ai-aggregator-service/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// MemoryStore keeps sessions in memory. It backs the session manager in tests and in
+// development setups without a database; its sessions do not survive a restart and
+// are not shared between instances.
+type MemoryStore struct {
+	mu              sync.Mutex
+	refreshTokens   map[string]*models.RefreshToken // hash -> token
+	revokedTokens   map[string]time.Time            // jti -> expiry
+	sessionsEndedAt map[uuid.UUID]time.Time         // user ID -> when all sessions were revoked
+}
+
+// NewMemoryStore creates an empty in-memory session store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		refreshTokens:   make(map[string]*models.RefreshToken),
+		revokedTokens:   make(map[string]time.Time),
+		sessionsEndedAt: make(map[uuid.UUID]time.Time),
+	}
+}
+
+// CreateRefreshToken implements Store
+func (s *MemoryStore) CreateRefreshToken(ctx context.Context, token *models.RefreshToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token.ID = uuid.New()
+	token.CreatedAt = time.Now()
+	stored := *token
+	s.refreshTokens[token.TokenHash] = &stored
+	return nil
+}
+
+// RefreshToken implements Store
+func (s *MemoryStore) RefreshToken(ctx context.Context, hash string) (*models.RefreshToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token, ok := s.refreshTokens[hash]
+	if !ok {
+		return nil, ErrTokenNotFound
+	}
+	found := *token
+	return &found, nil
+}
+
+// UseRefreshToken implements Store
+func (s *MemoryStore) UseRefreshToken(ctx context.Context, id uuid.UUID, at time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, token := range s.refreshTokens {
+		if token.ID != id {
+			continue
+		}
+		if token.UsedAt != nil || token.RevokedAt != nil {
+			return false, nil
+		}
+		token.UsedAt = &at
+		return true, nil
+	}
+	return false, nil
+}
+
+// RevokeFamily implements Store
+func (s *MemoryStore) RevokeFamily(ctx context.Context, familyID uuid.UUID, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, token := range s.refreshTokens {
+		if token.FamilyID == familyID && token.RevokedAt == nil {
+			token.RevokedAt = &at
+		}
+	}
+	return nil
+}
+
+// RevokeUser implements Store
+func (s *MemoryStore) RevokeUser(ctx context.Context, userID uuid.UUID, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, token := range s.refreshTokens {
+		if token.UserID == userID && token.RevokedAt == nil {
+			token.RevokedAt = &at
+		}
+	}
+	s.sessionsEndedAt[userID] = at
+	return nil
+}
+
+// RevokeAccessToken implements Store. Entries past their expiry are pruned on the way.
+func (s *MemoryStore) RevokeAccessToken(ctx context.Context, jti string, userID uuid.UUID, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for revoked, expiry := range s.revokedTokens {
+		if expiry.Before(now) {
+			delete(s.revokedTokens, revoked)
+		}
+	}
+	s.revokedTokens[jti] = expiresAt
+	return nil
+}
+
+// AccessTokenRevoked implements Store
+func (s *MemoryStore) AccessTokenRevoked(ctx context.Context, jti string, userID uuid.UUID, issuedAt time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.revokedTokens[jti]; ok && jti != "" {
+		return true, nil
+	}
+	endedAt, ok := s.sessionsEndedAt[userID]
+	return ok && !endedAt.Before(issuedAt), nil
+}
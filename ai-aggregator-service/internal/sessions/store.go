@@ -0,0 +1,45 @@
+package sessions
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"ai-aggregator-service/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// ErrTokenNotFound is returned by a Store for a refresh token it does not hold
+var ErrTokenNotFound = errors.New("refresh token not found")
+
+// Store persists refresh tokens, by hash, and the revocation list of access tokens.
+// DBStore keeps them in the database; MemoryStore keeps them in memory, for tests and
+// single-instance development setups.
+type Store interface {
+	// CreateRefreshToken stores a new refresh token
+	CreateRefreshToken(ctx context.Context, token *models.RefreshToken) error
+
+	// RefreshToken returns the refresh token with the hash, or ErrTokenNotFound
+	RefreshToken(ctx context.Context, hash string) (*models.RefreshToken, error)
+
+	// UseRefreshToken marks an unused, unrevoked refresh token used, and reports false
+	// when it was already used or revoked
+	UseRefreshToken(ctx context.Context, id uuid.UUID, at time.Time) (bool, error)
+
+	// RevokeFamily revokes every refresh token of the family
+	RevokeFamily(ctx context.Context, familyID uuid.UUID, at time.Time) error
+
+	// RevokeUser revokes every refresh token of the user, and every access token issued
+	// to the user up to at
+	RevokeUser(ctx context.Context, userID uuid.UUID, at time.Time) error
+
+	// RevokeAccessToken adds an access token to the revocation list until it expires
+	RevokeAccessToken(ctx context.Context, jti string, userID uuid.UUID, expiresAt time.Time) error
+
+	// AccessTokenRevoked reports whether an access token was revoked, either by its jti
+	// or by revoking all of its user's tokens after it was issued. issuedAt comes from
+	// the iat claim, which is in whole seconds, so tokens issued in the second the
+	// user's tokens were revoked count as revoked, even those issued just after.
+	AccessTokenRevoked(ctx context.Context, jti string, userID uuid.UUID, issuedAt time.Time) (bool, error)
+}
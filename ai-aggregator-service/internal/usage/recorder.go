@@ -0,0 +1,329 @@
+package usage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"time"
+
+	"ai-aggregator-service/internal/models"
+	"ai-aggregator-service/internal/pricing"
+	"ai-aggregator-service/internal/providers"
+
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+// Request statuses stored in api_requests.status
+const (
+	StatusPending   = "pending"
+	StatusCompleted = "completed"
+	StatusFailed    = "failed"
+)
+
+// TransactionTypePlatformFee is the billing_transactions type of the fee charged for
+// requests served with an organization's own provider key
+const TransactionTypePlatformFee = "platform_fee"
+
+// RequestInfo describes an incoming API request
+type RequestInfo struct {
+	RequestID      string
+	APIKeyID       uuid.UUID
+	UserID         uuid.UUID
+	OrganizationID uuid.UUID
+	Method         string
+	Endpoint       string
+	Model          string
+	Stream         bool
+	Images         int
+	IPAddress      string
+	UserAgent      string
+}
+
+// Recorder persists API requests and the provider attempts made to serve them, and
+// prices completed requests. Requests served with an organization's own provider key
+// are not priced; the organization is charged platformFee for them instead.
+type Recorder struct {
+	db          *bun.DB
+	pricer      *pricing.Pricer
+	platformFee float64
+}
+
+// NewRecorder creates a new usage recorder
+func NewRecorder(db *bun.DB, pricer *pricing.Pricer, platformFee float64) *Recorder {
+	return &Recorder{db: db, pricer: pricer, platformFee: platformFee}
+}
+
+// Start records a new pending API request
+func (r *Recorder) Start(ctx context.Context, info RequestInfo) (*models.APIRequest, error) {
+	if info.RequestID == "" {
+		info.RequestID = uuid.NewString()
+	}
+
+	request := &models.APIRequest{
+		RequestID: info.RequestID,
+		Status:    StatusPending,
+		Method:    info.Method,
+		Endpoint:  info.Endpoint,
+		Headers:   models.JSONB{},
+		RequestBody: models.JSONB{
+			"model":  info.Model,
+			"stream": info.Stream,
+		},
+		UserAgent:   info.UserAgent,
+		InputImages: info.Images,
+	}
+	if info.APIKeyID != uuid.Nil {
+		request.APIKeyID = models.UUIDPtr(info.APIKeyID)
+	}
+	if info.UserID != uuid.Nil {
+		request.UserID = models.UUIDPtr(info.UserID)
+	}
+	if info.OrganizationID != uuid.Nil {
+		request.OrganizationID = models.UUIDPtr(info.OrganizationID)
+	}
+	if ip := net.ParseIP(info.IPAddress); ip != nil {
+		request.IPAddress = models.StringPtr(ip.String())
+	}
+
+	if _, err := r.db.NewInsert().Model(request).Exec(ctx); err != nil {
+		return nil, fmt.Errorf("failed to insert api request: %w", err)
+	}
+
+	return request, nil
+}
+
+// RecordAttempt records a single provider call made while serving request. The model
+// that served a successful attempt is the one the request is priced for.
+func (r *Recorder) RecordAttempt(ctx context.Context, request *models.APIRequest, attempt providers.Attempt) error {
+	if attempt.Err == nil {
+		request.BYOK = attempt.BYOK
+	}
+
+	model, err := r.lookupModel(ctx, attempt.Provider, attempt.Model)
+	switch {
+	case err != nil:
+		slog.Warn("Failed to look up served model, request will not be priced", "provider", attempt.Provider, "model", attempt.Model, "error", err)
+	case attempt.Err == nil:
+		if model == nil {
+			slog.Warn("Served model is not in the catalog, request will not be priced", "provider", attempt.Provider, "model", attempt.Model)
+		} else {
+			request.ProviderID = models.UUIDPtr(model.ProviderID)
+			request.ModelID = models.UUIDPtr(model.ID)
+		}
+	}
+
+	response := &models.APIResponse{
+		RequestID:  request.ID,
+		StatusCode: attempt.StatusCode,
+		LatencyMS:  int(attempt.Latency / time.Millisecond),
+		RetryCount: attempt.RetryCount,
+		ResponseData: models.JSONB{
+			"provider": attempt.Provider,
+			"model":    attempt.Model,
+			"byok":     attempt.BYOK,
+		},
+	}
+
+	if model != nil {
+		response.ProviderID = models.UUIDPtr(model.ProviderID)
+		response.ModelID = models.UUIDPtr(model.ID)
+	}
+	if attempt.Usage != nil {
+		response.UsageData = toJSONB(attempt.Usage)
+	}
+	if attempt.Err != nil {
+		response.ErrorData = errorData(attempt.Err)
+	}
+
+	if _, err := r.db.NewInsert().Model(response).Exec(ctx); err != nil {
+		return fmt.Errorf("failed to insert api response: %w", err)
+	}
+
+	return nil
+}
+
+// Complete marks request as finished with the final usage or error
+func (r *Recorder) Complete(ctx context.Context, request *models.APIRequest, usage *providers.Usage, statusCode int, reqErr error) error {
+	now := time.Now()
+	latency := int(now.Sub(request.CreatedAt) / time.Millisecond)
+
+	request.Status = StatusCompleted
+	request.StatusCode = &statusCode
+	request.LatencyMS = &latency
+	request.CompletedAt = &now
+
+	if usage != nil {
+		request.InputTokens = usage.PromptTokens
+		request.OutputTokens = usage.CompletionTokens
+		request.TotalTokens = usage.TotalTokens
+		request.CachedTokens = usage.CachedTokens()
+	}
+	if reqErr != nil {
+		request.Status = StatusFailed
+		request.ErrorMessage = models.StringPtr(reqErr.Error())
+	}
+	if usage != nil && request.ModelID != nil && !request.BYOK {
+		cost, err := r.cost(ctx, request)
+		if err != nil {
+			// Left unpriced; the request can be re-priced once the price is recorded
+			slog.Warn("Request could not be priced", "request_id", request.RequestID, "error", err)
+		}
+		request.Cost = cost
+	}
+
+	_, err := r.db.NewUpdate().
+		Model(request).
+		Column("status", "status_code", "latency_ms", "completed_at", "provider_id", "model_id", "input_tokens", "cached_input_tokens", "output_tokens", "total_tokens", "input_images", "cost", "byok", "error_message", "updated_at").
+		WherePK().
+		Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to update api request: %w", err)
+	}
+
+	if request.BYOK && request.Status == StatusCompleted {
+		if err := r.chargePlatformFee(ctx, request); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// chargePlatformFee debits the platform fee of a request served with the organization's
+// own provider key from the organization's billing account
+func (r *Recorder) chargePlatformFee(ctx context.Context, request *models.APIRequest) error {
+	if r.platformFee <= 0 || request.OrganizationID == nil {
+		return nil
+	}
+
+	return r.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		account := new(models.BillingAccount)
+		err := tx.NewSelect().
+			Model(account).
+			Column("id", "balance").
+			Where("organization_id = ?", *request.OrganizationID).
+			Where("is_active").
+			OrderExpr("created_at").
+			Limit(1).
+			For("UPDATE").
+			Scan(ctx)
+		if errors.Is(err, sql.ErrNoRows) {
+			slog.Warn("Organization has no billing account, platform fee not charged",
+				"organization_id", *request.OrganizationID,
+				"request_id", request.RequestID,
+			)
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to load billing account: %w", err)
+		}
+
+		now := time.Now()
+		balance := account.Balance - r.platformFee
+		_, err = tx.NewUpdate().
+			Model((*models.BillingAccount)(nil)).
+			Set("balance = ?", balance).
+			Set("updated_at = ?", now).
+			Where("id = ?", account.ID).
+			Exec(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to update billing account balance: %w", err)
+		}
+
+		transaction := &models.BillingTransaction{
+			BillingAccountID: account.ID,
+			APIRequestID:     models.UUIDPtr(request.ID),
+			TransactionType:  TransactionTypePlatformFee,
+			// Debits are negative, so that balance_after is the balance plus the amount
+			Amount:       -r.platformFee,
+			BalanceAfter: balance,
+			Currency:     "USD",
+			Description:  "Platform fee for a request served with the organization's own provider key",
+			Metadata: models.JSONB{
+				"request_id":   request.RequestID,
+				"total_tokens": request.TotalTokens,
+			},
+			Status:      StatusCompleted,
+			ProcessedAt: &now,
+		}
+		if _, err := tx.NewInsert().Model(transaction).Exec(ctx); err != nil {
+			return fmt.Errorf("failed to insert billing transaction: %w", err)
+		}
+		return nil
+	})
+}
+
+// cost prices the request at the price in effect when it was made
+func (r *Recorder) cost(ctx context.Context, request *models.APIRequest) (float64, error) {
+	if r.pricer == nil {
+		return 0, pricing.ErrPriceNotFound
+	}
+
+	price, err := r.pricer.PriceAt(ctx, *request.ModelID, request.CreatedAt)
+	if err != nil {
+		return 0, err
+	}
+	return price.Cost(pricing.Quantity{
+		InputTokens:       request.InputTokens,
+		CachedInputTokens: request.CachedTokens,
+		OutputTokens:      request.OutputTokens,
+		Images:            request.InputImages,
+	}), nil
+}
+
+// lookupModel returns the catalog entry of the model a provider served, or nil if it has none
+func (r *Recorder) lookupModel(ctx context.Context, provider, model string) (*models.Model, error) {
+	row := new(models.Model)
+	err := r.db.NewSelect().
+		Model(row).
+		Column("model.id", "model.provider_id").
+		Join("JOIN providers AS provider ON provider.id = model.provider_id").
+		Where("provider.name = ?", provider).
+		Where("model.name = ?", model).
+		Limit(1).
+		Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up served model: %w", err)
+	}
+	return row, nil
+}
+
+// errorData converts a provider error into the api_responses.error_data payload
+func errorData(err error) models.JSONB {
+	data := models.JSONB{"message": err.Error()}
+
+	var providerErr *providers.Error
+	if errors.As(err, &providerErr) {
+		data["kind"] = string(providerErr.Kind)
+		if providerErr.StatusCode != 0 {
+			data["status_code"] = providerErr.StatusCode
+		}
+		if providerErr.RetryAfter > 0 {
+			data["retry_after_ms"] = providerErr.RetryAfter.Milliseconds()
+		}
+	}
+
+	return data
+}
+
+// toJSONB converts a struct into a JSONB map using its JSON representation
+func toJSONB(v interface{}) models.JSONB {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+
+	var m models.JSONB
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil
+	}
+	return m
+}
@@ -2,7 +2,7 @@
 CREATE TABLE IF NOT EXISTS billing_transactions (
     id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
     created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
-    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
     billing_account_id UUID REFERENCES billing_accounts(id) ON DELETE CASCADE,
     api_request_id UUID REFERENCES api_requests(id) ON DELETE SET NULL,
     transaction_type VARCHAR(50) NOT NULL,
@@ -10,7 +10,7 @@ CREATE TABLE IF NOT EXISTS billing_transactions (
     currency VARCHAR(3) DEFAULT 'USD',
     description TEXT,
     metadata JSONB DEFAULT '{}'::jsonb,
-    balance_after DECIMAL(10,2) NOT NULL,
+    balance_after DECIMAL(10,2) NOT NULL
 );
 
 -- Create indexes for billing_transactions
@@ -0,0 +1,6 @@
+-- Insert the Azure OpenAI provider. base_url is the resource endpoint, e.g.
+-- https://my-resource.openai.azure.com, and config.deployments maps public
+-- model IDs to the deployment names created in that resource.
+INSERT INTO providers (name, display_name, base_url, api_key_required, rate_limit_rpm, rate_limit_tpm, config, supported_features) VALUES
+    ('azure', 'Azure OpenAI', '', true, 1000, 100000, '{"api_version": "2024-10-21", "deployments": {}}'::jsonb, '["chat", "completions", "embeddings"]'::jsonb)
+ON CONFLICT (name) DO NOTHING;
@@ -0,0 +1,10 @@
+-- Record which seeded models accept documents (PDF files) and audio input.
+-- Content parts are checked against these capabilities: image_url requires
+-- "vision", input_audio requires "audio" and file requires "documents".
+UPDATE models SET capabilities = capabilities || '["documents"]'::jsonb
+WHERE name IN ('gpt-4o', 'gpt-4o-mini', 'claude-3-5-sonnet-20241022', 'gemini-1.5-pro', 'gemini-1.5-flash')
+  AND NOT capabilities ? 'documents';
+
+UPDATE models SET capabilities = capabilities || '["audio"]'::jsonb
+WHERE name IN ('gemini-1.5-pro', 'gemini-1.5-flash')
+  AND NOT capabilities ? 'audio';
@@ -0,0 +1,15 @@
+-- Record which adapter serves each provider row, so that several rows can share
+-- a type, e.g. two openai_compatible servers. Rows without a type use their name.
+ALTER TABLE providers ADD COLUMN IF NOT EXISTS type VARCHAR(50);
+
+UPDATE providers SET type = name
+WHERE type IS NULL AND name IN ('openai', 'anthropic', 'google', 'cohere', 'mistral', 'azure');
+
+-- Providers are now built from base_url, which must be the API root the adapters
+-- append their paths to. Only the seeded values are corrected.
+UPDATE providers SET base_url = 'https://api.anthropic.com/v1' WHERE name = 'anthropic' AND base_url = 'https://api.anthropic.com';
+UPDATE providers SET base_url = 'https://generativelanguage.googleapis.com/v1beta' WHERE name = 'google' AND base_url = 'https://generativelanguage.googleapis.com';
+UPDATE providers SET base_url = 'https://api.cohere.ai/v1' WHERE name = 'cohere' AND base_url = 'https://api.cohere.ai';
+UPDATE providers SET base_url = 'https://api.mistral.ai/v1' WHERE name = 'mistral' AND base_url = 'https://api.mistral.ai';
+
+CREATE INDEX IF NOT EXISTS idx_providers_type ON providers(type);
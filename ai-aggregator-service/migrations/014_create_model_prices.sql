@@ -0,0 +1,36 @@
+-- Price requests from the database instead of per-provider price maps. The models
+-- table keeps the current price, and the pricing JSONB the rates beyond flat per-token
+-- costs: cached input, per-image and tiered prices, e.g.
+-- {"cached_input_cost_per_1k_tokens": 0.0025, "image_cost": 0.001,
+--  "tiers": [{"above_input_tokens": 128000, "input_cost_per_1k_tokens": 0.0025, "output_cost_per_1k_tokens": 0.01}]}
+ALTER TABLE models ADD COLUMN IF NOT EXISTS pricing JSONB DEFAULT '{}'::jsonb;
+
+-- A model without a price is unpriced rather than free, so new rows default to NULL
+ALTER TABLE models ALTER COLUMN input_cost_per_1k_tokens DROP DEFAULT;
+ALTER TABLE models ALTER COLUMN output_cost_per_1k_tokens DROP DEFAULT;
+
+-- Every price a model has had, so that requests can be re-priced at the price in
+-- effect when they were made
+CREATE TABLE IF NOT EXISTS model_prices (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    model_id UUID NOT NULL REFERENCES models(id) ON DELETE CASCADE,
+    effective_from TIMESTAMP WITH TIME ZONE NOT NULL,
+    input_cost_per_1k_tokens DECIMAL(10,6) NOT NULL,
+    output_cost_per_1k_tokens DECIMAL(10,6) NOT NULL,
+    pricing JSONB DEFAULT '{}'::jsonb,
+    UNIQUE(model_id, effective_from)
+);
+
+CREATE INDEX IF NOT EXISTS idx_model_prices_model_id_effective_from ON model_prices(model_id, effective_from DESC);
+
+-- The seeded prices apply to every request made before the first price change
+INSERT INTO model_prices (model_id, effective_from, input_cost_per_1k_tokens, output_cost_per_1k_tokens, pricing)
+SELECT id, '1970-01-01T00:00:00Z', input_cost_per_1k_tokens, output_cost_per_1k_tokens, COALESCE(pricing, '{}'::jsonb)
+FROM models
+WHERE input_cost_per_1k_tokens IS NOT NULL AND output_cost_per_1k_tokens IS NOT NULL
+ON CONFLICT (model_id, effective_from) DO NOTHING;
+
+-- Record what a request was billed for, so that it can be re-priced
+ALTER TABLE api_requests ADD COLUMN IF NOT EXISTS cached_input_tokens INTEGER DEFAULT 0;
+ALTER TABLE api_requests ADD COLUMN IF NOT EXISTS input_images INTEGER DEFAULT 0;
@@ -0,0 +1,28 @@
+-- Create model_aliases table. An alias names a concrete model, optionally pinned to a
+-- provider, so that clients need not change when vendors ship new snapshots. Aliases
+-- without an organization apply to everyone; an organization's own alias takes precedence.
+CREATE TABLE IF NOT EXISTS model_aliases (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    organization_id UUID REFERENCES organizations(id) ON DELETE CASCADE,
+    alias VARCHAR(255) NOT NULL,
+    model VARCHAR(255) NOT NULL,
+    provider VARCHAR(100),
+    is_active BOOLEAN DEFAULT TRUE
+);
+
+-- Create indexes for model_aliases
+CREATE UNIQUE INDEX IF NOT EXISTS idx_model_aliases_global_alias ON model_aliases(alias) WHERE organization_id IS NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_model_aliases_organization_alias ON model_aliases(organization_id, alias) WHERE organization_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_model_aliases_is_active ON model_aliases(is_active);
+
+-- Insert default aliases
+INSERT INTO model_aliases (alias, model) VALUES
+    ('bharat-smart', 'claude-3-5-sonnet-20241022'),
+    ('bharat-fast', 'gpt-4o-mini'),
+    ('claude-sonnet-latest', 'claude-3-5-sonnet-20241022'),
+    ('claude-haiku-latest', 'claude-3-5-haiku-20241022'),
+    ('gemini-pro-latest', 'gemini-1.5-pro'),
+    ('gemini-flash-latest', 'gemini-1.5-flash')
+ON CONFLICT (alias) WHERE organization_id IS NULL DO NOTHING;
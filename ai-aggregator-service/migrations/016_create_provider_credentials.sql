@@ -0,0 +1,34 @@
+-- Create provider_credentials table. Organizations bring their own provider API keys,
+-- which serve their requests in place of ours. Each key is encrypted with its own data
+-- key, and the data key with the master key from configuration, identified by master_key_id.
+CREATE TABLE IF NOT EXISTS provider_credentials (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    provider VARCHAR(100) NOT NULL,
+    encrypted_key BYTEA NOT NULL,
+    encrypted_data_key BYTEA NOT NULL,
+    master_key_id VARCHAR(16) NOT NULL,
+    key_hint VARCHAR(20),
+    is_active BOOLEAN DEFAULT TRUE,
+    UNIQUE(organization_id, provider)
+);
+
+-- Create indexes for provider_credentials
+CREATE INDEX IF NOT EXISTS idx_provider_credentials_organization_id ON provider_credentials(organization_id);
+CREATE INDEX IF NOT EXISTS idx_provider_credentials_is_active ON provider_credentials(is_active);
+
+-- Requests served with an organization's own key are not billed for tokens
+ALTER TABLE api_requests ADD COLUMN IF NOT EXISTS byok BOOLEAN DEFAULT FALSE;
+
+-- Bring billing_transactions in line with its model, and keep balances precise enough
+-- for per-request fees, which are fractions of a cent
+ALTER TABLE billing_transactions ADD COLUMN IF NOT EXISTS status VARCHAR(50) DEFAULT 'completed';
+ALTER TABLE billing_transactions ADD COLUMN IF NOT EXISTS reference_id VARCHAR(255);
+ALTER TABLE billing_transactions ADD COLUMN IF NOT EXISTS processed_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE billing_transactions ALTER COLUMN balance_after TYPE DECIMAL(12,6);
+ALTER TABLE billing_accounts ALTER COLUMN balance TYPE DECIMAL(12,6);
+
+-- A request is charged its platform fee at most once
+CREATE UNIQUE INDEX IF NOT EXISTS idx_billing_transactions_platform_fee ON billing_transactions(api_request_id) WHERE transaction_type = 'platform_fee';
@@ -0,0 +1,16 @@
+-- Gateway API keys are looked up by their public prefix and verified against key_hash,
+-- a salted hash of the whole key
+ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS key_prefix VARCHAR(32);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_api_keys_key_prefix ON api_keys(key_prefix);
+
+-- Bring api_keys in line with its model, which records last use in last_used_at
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM information_schema.columns WHERE table_name = 'api_keys' AND column_name = 'last_used') THEN
+        ALTER TABLE api_keys RENAME COLUMN last_used TO last_used_at;
+    END IF;
+END $$;
+
+-- Keys created by a user acting for an organization belong to both
+ALTER TABLE api_keys DROP CONSTRAINT IF EXISTS check_owner;
+ALTER TABLE api_keys ADD CONSTRAINT check_owner CHECK (user_id IS NOT NULL OR organization_id IS NOT NULL);
@@ -0,0 +1,17 @@
+-- Bring users in line with its model, which records the last sign-in in last_login_at
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM information_schema.columns WHERE table_name = 'users' AND column_name = 'last_login') THEN
+        ALTER TABLE users RENAME COLUMN last_login TO last_login_at;
+    END IF;
+END $$;
+
+-- Accounts are locked for a while after repeated failed sign-ins
+ALTER TABLE users ADD COLUMN IF NOT EXISTS failed_login_attempts INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS locked_until TIMESTAMP WITH TIME ZONE;
+
+-- Emails are unique regardless of case
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email_lower ON users(LOWER(email));
+
+-- Profile details without a column of their own, such as the username
+ALTER TABLE users ADD COLUMN IF NOT EXISTS metadata JSONB DEFAULT '{}';
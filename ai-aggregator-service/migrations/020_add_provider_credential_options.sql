@@ -0,0 +1,4 @@
+-- Organizations' own settings for the provider instance built with their key, merged
+-- over the provider row's config. Their Azure OpenAI keys, for one, belong to their own
+-- resource, with its own endpoint and deployments.
+ALTER TABLE provider_credentials ADD COLUMN IF NOT EXISTS options JSONB DEFAULT '{}';